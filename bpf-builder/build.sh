@@ -6,13 +6,19 @@ KUBESHARK_HOME=$(realpath ../../)
 
 docker build -t kubeshark-ebpf-builder . || exit 1
 
-BPF_TARGET=amd64
-BPF_CFLAGS="-O2 -g -D__TARGET_ARCH_x86"
-ARCH=$(uname -m)
-if [[ $ARCH == "aarch64" || $ARCH == "arm64" ]]; then
-    BPF_TARGET=arm64
-    BPF_CFLAGS="-O2 -g -D__TARGET_ARCH_arm64"
-fi
+# The objects of all the architectures are built, the eBPF bytecode doesn't depend on the host.
+# s390x and ppc64le need bpf/include/vmlinux_<suffix>.h, see make vmlinux
+GENERATE=""
+# The big endian object of s390x is the generic bpfeb one, see the Makefile
+for BPF_TARGET in amd64 arm64 bpfeb ppc64le; do
+	case $BPF_TARGET in
+		arm64) BPF_ARCH_SUFFIX=arm64 ;;
+		bpfeb) BPF_ARCH_SUFFIX=s390 ;;
+		ppc64le) BPF_ARCH_SUFFIX=powerpc ;;
+		*) BPF_ARCH_SUFFIX=x86 ;;
+	esac
+	GENERATE="$GENERATE BPF_TARGET=\"$BPF_TARGET\" BPF_CFLAGS=\"-O2 -g -D__TARGET_ARCH_$BPF_ARCH_SUFFIX\" go generate tracer/pkg/tracer/tracer.go || exit 1;"
+done
 
 docker run --rm \
 	--name kubeshark-ebpf-builder \
@@ -20,8 +26,8 @@ docker run --rm \
 	-v $(go env GOPATH):/root/go \
 	kubeshark-ebpf-builder \
 	sh -c "
-		BPF_TARGET=\"$BPF_TARGET\" BPF_CFLAGS=\"$BPF_CFLAGS\" go generate tracer/tracer.go
-        chown $(id -u):$(id -g) tracer/tracer*_bpf*
+		$GENERATE
+        chown $(id -u):$(id -g) tracer/pkg/tracer/tracer*_bpf*
 	" || exit 1
 
 popd
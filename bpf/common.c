@@ -22,7 +22,7 @@ static __always_inline int add_address_to_chunk(struct pt_regs *ctx, struct tls_
         return 0;
     }
 
-    chunk->flags |= (*flags & FLAGS_IS_CLIENT_BIT);
+    chunk->flags |= (*flags & (FLAGS_IS_CLIENT_BIT | FLAGS_IS_UDP_BIT));
 
     bpf_probe_read(&chunk->address_info, sizeof(chunk->address_info), &info->address_info);
 
@@ -55,7 +55,25 @@ static __always_inline void send_chunk_part(struct pt_regs *ctx, __u8* buffer, _
         return;
     }
 
-    bpf_perf_event_output(ctx, &chunks_buffer, BPF_F_CURRENT_CPU, chunk, sizeof(struct tls_chunk));
+    output_chunk(ctx, chunk);
+}
+
+static __always_inline void output_chunk(void *ctx, struct tls_chunk* chunk) {
+    int zero = 0;
+    struct settings *settings = bpf_map_lookup_elem(&settings_map, &zero);
+
+    if (settings == NULL || !settings->ringbuf) {
+        bpf_perf_event_output(ctx, &chunks_buffer, BPF_F_CURRENT_CPU, chunk, sizeof(struct tls_chunk));
+        return;
+    }
+
+    if (bpf_ringbuf_output(&chunks_ringbuf, chunk, sizeof(struct tls_chunk), 0) != 0) {
+        __u64 *drops = bpf_map_lookup_elem(&chunks_ringbuf_drops, &zero);
+
+        if (drops != NULL) {
+            *drops += 1;
+        }
+    }
 }
 
 static __always_inline void send_chunk(struct pt_regs *ctx, __u8* buffer, __u64 id, struct tls_chunk* chunk) {
@@ -75,7 +93,96 @@ static __always_inline void send_chunk(struct pt_regs *ctx, __u8* buffer, __u64
     }
 }
 
-static __always_inline void output_ssl_chunk(struct pt_regs *ctx, struct ssl_info* info, int count_bytes, __u64 id, __u32 flags) {
+static __always_inline int is_metadata_mode() {
+    int zero = 0;
+    struct settings *settings = bpf_map_lookup_elem(&settings_map, &zero);
+
+    return settings != NULL && settings->metadata_mode;
+}
+
+static __always_inline int is_paused() {
+    int zero = 0;
+    struct settings *settings = bpf_map_lookup_elem(&settings_map, &zero);
+
+    return settings != NULL && settings->paused;
+}
+
+static __always_inline int is_syscalls_only() {
+    int zero = 0;
+    struct settings *settings = bpf_map_lookup_elem(&settings_map, &zero);
+
+    return settings != NULL && settings->syscalls_only;
+}
+
+// get_fd_sock returns the socket of the fd of the current process, or NULL if the file
+// isn't a socket. The file of a socket points back to it from the socket.
+static __always_inline struct sock* get_fd_sock(__u32 fd) {
+    struct task_struct *task = (struct task_struct *) bpf_get_current_task();
+    struct file **fds = BPF_CORE_READ(task, files, fdt, fd);
+
+    struct file *file;
+    if (bpf_probe_read_kernel(&file, sizeof(file), &fds[fd]) != 0 || file == NULL) {
+        return NULL;
+    }
+
+    struct socket *socket = (struct socket *) BPF_CORE_READ(file, private_data);
+    if (socket == NULL || BPF_CORE_READ(socket, file) != file) {
+        return NULL;
+    }
+
+    return BPF_CORE_READ(socket, sk);
+}
+
+static __always_inline void aggregate_ssl_chunk(struct pt_regs *ctx, struct ssl_info* info, int count_bytes, __u64 id, __u32 flags) {
+    __u32 pid = id >> 32;
+    __u64 key = (__u64) pid << 32 | info->fd;
+
+    conn_flags *connFlags = bpf_map_lookup_elem(&connection_context, &key);
+
+    // Same as add_address_to_chunk, there is no address to account the bytes to
+    if (connFlags == NULL) {
+        return;
+    }
+
+    struct flow_key flow = {};
+    flow.pid = pid;
+    flow.flags = flags | (*connFlags & (FLAGS_IS_CLIENT_BIT | FLAGS_IS_UDP_BIT));
+    bpf_probe_read(&flow.address_info, sizeof(flow.address_info), &info->address_info);
+
+    // The values are per CPU, no need for atomic operations
+    struct flow_stats *stats = bpf_map_lookup_elem(&flow_stats_map, &flow);
+
+    if (stats == NULL) {
+        struct flow_stats new_stats = { .bytes = count_bytes, .messages = 1 };
+        long err = bpf_map_update_elem(&flow_stats_map, &flow, &new_stats, BPF_NOEXIST);
+
+        if (err == 0) {
+            return;
+        }
+
+        // Created by another CPU in the meantime
+        stats = bpf_map_lookup_elem(&flow_stats_map, &flow);
+
+        if (stats == NULL) {
+            log_error(ctx, LOG_ERROR_PUTTING_FLOW_STATS, id, err, 0l);
+            return;
+        }
+    }
+
+    stats->bytes += count_bytes;
+    stats->messages++;
+}
+
+static __always_inline void output_ssl_chunk(struct pt_regs *ctx, struct ssl_info* info, int count_bytes, __u64 id, __u32 flags, __u32 origin) {
+    if (is_paused()) {
+        return;
+    }
+
+    if (is_metadata_mode()) {
+        aggregate_ssl_chunk(ctx, info, count_bytes, id, flags);
+        return;
+    }
+
     if (count_bytes > (CHUNK_SIZE * MAX_CHUNKS_PER_OPERATION)) {
         log_error(ctx, LOG_ERROR_BUFFER_TOO_BIG, id, count_bytes, 0l);
         return;
@@ -95,6 +202,7 @@ static __always_inline void output_ssl_chunk(struct pt_regs *ctx, struct ssl_inf
     }
 
     chunk->flags = flags;
+    chunk->origin = origin;
     chunk->pid = id >> 32;
     chunk->tgid = id;
     chunk->len = count_bytes;
@@ -10,6 +10,7 @@ Copyright (C) Kubeshark
 #include "include/logger_messages.h"
 #include "include/pids.h"
 #include "include/common.h"
+#include "include/memory_bio.h"
 
 struct sys_enter_read_write_ctx {
 	__u64 __unused_syscall_header;
@@ -58,6 +59,52 @@ static __always_inline void fd_tracepoints_handle_go(struct sys_enter_read_write
 	}
 }
 
+// In the syscalls-only mode the payloads are read from the buffers of read and write, the
+//	address is read from the socket of the fd once the syscall returns
+//
+static __always_inline void syscall_payload_enter(struct sys_enter_read_write_ctx *ctx, __u64 id, __u64 origin_code) {
+	if (!is_syscalls_only()) {
+		return;
+	}
+
+	struct ssl_info info = new_ssl_info();
+	info.buffer = ctx->buf;
+	info.fd = ctx->fd;
+
+	long err = bpf_map_update_elem(&syscall_payload_context, &id, &info, BPF_ANY);
+
+	if (err != 0) {
+		log_error(ctx, LOG_ERROR_PUTTING_FILE_DESCRIPTOR, id, err, origin_code);
+	}
+}
+
+static __always_inline void syscall_payload_exit(struct sys_exit_read_write_ctx *ctx, __u64 id, __u32 flags, __u32 origin) {
+	struct ssl_info *infoPtr = bpf_map_lookup_elem(&syscall_payload_context, &id);
+
+	if (infoPtr == NULL) {
+		return;
+	}
+
+	struct ssl_info info;
+	long err = bpf_probe_read(&info, sizeof(struct ssl_info), infoPtr);
+	bpf_map_delete_elem(&syscall_payload_context, &id);
+
+	long count_bytes = ctx->ret;
+
+	if (err != 0 || count_bytes <= 0) {
+		return;
+	}
+
+	// Files and pipes have no socket
+	struct sock *sk = get_fd_sock(info.fd);
+
+	if (sk == NULL || tcp_kprobes_get_address_pair(ctx, sk, id, &info.address_info) != 0) {
+		return;
+	}
+
+	output_ssl_chunk((struct pt_regs *) ctx, &info, count_bytes, id, flags, origin);
+}
+
 SEC("tracepoint/syscalls/sys_enter_read")
 void sys_enter_read(struct sys_enter_read_write_ctx *ctx) {
 	__u64 id = bpf_get_current_pid_tgid();
@@ -72,7 +119,11 @@ void sys_enter_read(struct sys_enter_read_write_ctx *ctx) {
 		fd_tracepoints_handle_openssl(ctx, id, infoPtr, &openssl_read_context, ORIGIN_SYS_ENTER_READ_CODE);
 	}
 
+	mark_ssl_call_syscall(id);
+	record_thread_socket(ctx, id, ctx->fd, &thread_read_socket);
+
 	fd_tracepoints_handle_go(ctx, id, &go_kernel_read_context, ORIGIN_SYS_ENTER_READ_CODE);
+	syscall_payload_enter(ctx, id, ORIGIN_SYS_ENTER_READ_CODE);
 }
 	
 SEC("tracepoint/syscalls/sys_enter_write")
@@ -89,7 +140,48 @@ void sys_enter_write(struct sys_enter_read_write_ctx *ctx) {
 		fd_tracepoints_handle_openssl(ctx, id, infoPtr, &openssl_write_context, ORIGIN_SYS_ENTER_WRITE_CODE);
 	}
 
+	mark_ssl_call_syscall(id);
+	record_thread_socket(ctx, id, ctx->fd, &thread_write_socket);
+
 	fd_tracepoints_handle_go(ctx, id, &go_kernel_write_context, ORIGIN_SYS_ENTER_WRITE_CODE);
+	syscall_payload_enter(ctx, id, ORIGIN_SYS_ENTER_WRITE_CODE);
+}
+
+// BIO_dgram of DTLS reads with recvfrom, and writes with sendto unless the socket is connected.
+//	Their first arguments are the same as the ones of read and write.
+//
+SEC("tracepoint/syscalls/sys_enter_recvfrom")
+void sys_enter_recvfrom(struct sys_enter_read_write_ctx *ctx) {
+	__u64 id = bpf_get_current_pid_tgid();
+
+	if (!should_target(id >> 32)) {
+		return;
+	}
+
+	struct ssl_info *infoPtr = bpf_map_lookup_elem(&openssl_read_context, &id);
+
+	if (infoPtr != NULL) {
+		fd_tracepoints_handle_openssl(ctx, id, infoPtr, &openssl_read_context, ORIGIN_SYS_ENTER_RECVFROM_CODE);
+	}
+
+	mark_ssl_call_syscall(id);
+}
+
+SEC("tracepoint/syscalls/sys_enter_sendto")
+void sys_enter_sendto(struct sys_enter_read_write_ctx *ctx) {
+	__u64 id = bpf_get_current_pid_tgid();
+
+	if (!should_target(id >> 32)) {
+		return;
+	}
+
+	struct ssl_info *infoPtr = bpf_map_lookup_elem(&openssl_write_context, &id);
+
+	if (infoPtr != NULL) {
+		fd_tracepoints_handle_openssl(ctx, id, infoPtr, &openssl_write_context, ORIGIN_SYS_ENTER_SENDTO_CODE);
+	}
+
+	mark_ssl_call_syscall(id);
 }
 
 SEC("tracepoint/syscalls/sys_exit_read")
@@ -98,6 +190,8 @@ void sys_exit_read(struct sys_exit_read_write_ctx *ctx) {
 	// Delete from go map. The value is not used after exiting this syscall.
 	// Keep value in openssl map.
 	bpf_map_delete_elem(&go_kernel_read_context, &id);
+
+	syscall_payload_exit(ctx, id, FLAGS_IS_READ_BIT, PROBE_ORIGIN_SYSCALL_READ);
 }
 
 SEC("tracepoint/syscalls/sys_exit_write")
@@ -106,4 +200,13 @@ void sys_exit_write(struct sys_exit_read_write_ctx *ctx) {
 	// Delete from go map. The value is not used after exiting this syscall.
 	// Keep value in openssl map.
 	bpf_map_delete_elem(&go_kernel_write_context, &id);
+
+	syscall_payload_exit(ctx, id, 0, PROBE_ORIGIN_SYSCALL_WRITE);
+
+	if (!should_target(id >> 32)) {
+		return;
+	}
+
+	// The records of an SSL_write to a memory BIO are written by now
+	flush_memory_bio_write(ctx, id);
 }
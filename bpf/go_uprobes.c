@@ -59,11 +59,6 @@ Capstone Engine: https://www.capstone-engine.org/
 #include "include/go_types.h"
 
 
-// TODO: cilium/ebpf does not support .kconfig Therefore; for now, we build object files per kernel version.
-// Error: reference to .kconfig: not supported
-// See: https://github.com/cilium/ebpf/issues/698
-// extern int LINUX_KERNEL_VERSION __kconfig;
-
 enum ABI {
     ABI0=0,
     ABIInternal=1,
@@ -85,18 +80,17 @@ static __always_inline __u32 get_goid_from_thread_local_storage(__u64 *goroutine
         return 0;
     }
 
-    // Read task->thread
-    struct thread_struct *thr;
-    bpf_probe_read(&thr, sizeof(thr), &task->thread);
+    // task->thread is embedded in the task, its offset is relocated as well
+    struct thread_struct *thr = &task->thread;
 
-    // Read task->thread.fsbase
+    // Read task->thread.fsbase, the field is called fs before Linux 4.6. The relocation
+    // picks the one in the BTF of the kernel, so one object works on both.
     u64 fsbase;
-#ifdef KERNEL_BEFORE_4_6
-    // TODO: if (LINUX_KERNEL_VERSION <= KERNEL_VERSION(4, 6, 0)) {
-    fsbase = BPF_CORE_READ((struct thread_struct___v46 *)thr, fs);
-#else
-    fsbase = BPF_CORE_READ(thr, fsbase);
-#endif
+    if (bpf_core_field_exists(thr->fsbase)) {
+        fsbase = BPF_CORE_READ(thr, fsbase);
+    } else {
+        fsbase = BPF_CORE_READ((struct thread_struct___v46 *)thr, fs);
+    }
 
     // Get the Goroutine ID (goid) which is stored in thread-local storage.
     size_t g_addr;
@@ -107,25 +101,22 @@ static __always_inline __u32 get_goid_from_thread_local_storage(__u64 *goroutine
 }
 #endif
 
+#if defined(bpf_target_x86) || defined(bpf_target_arm64)
+
 static __always_inline __u32 go_crypto_tls_get_fd_from_tcp_conn(struct pt_regs *ctx, enum ABI abi) {
     struct go_interface conn;
     long err;
     __u64 addr;
-#if defined(bpf_target_arm64)
-    err = bpf_probe_read(&addr, sizeof(addr), (void*)GO_ABI_INTERNAL_PT_REGS_SP(ctx)+0x8);
-    if (err != 0) {
-        return invalid_fd;
-    }
-#elif defined(bpf_target_x86)
+    // The receiver is the first argument on both architectures, 0(SP) holds the return
+    // address on amd64 and the saved link register on arm64 in case of ABI0
     if (abi == ABI0) {
-        err = bpf_probe_read(&addr, sizeof(addr), (void*)GO_ABI_INTERNAL_PT_REGS_SP(ctx)+0x8);
+        err = bpf_probe_read(&addr, sizeof(addr), (void*)GO_ABI_0_PT_REGS_SP(ctx)+0x8);
         if (err != 0) {
             return invalid_fd;
         }
     } else {
         addr = GO_ABI_INTERNAL_PT_REGS_R1(ctx);
     }
-#endif
 
     err = bpf_probe_read(&conn, sizeof(conn), (void*)addr);
     if (err != 0) {
@@ -157,13 +148,6 @@ static __always_inline void go_crypto_tls_uprobe(struct pt_regs *ctx, struct bpf
     struct ssl_info info = new_ssl_info();
     long err;
 
-#if defined(bpf_target_arm64)
-    err = bpf_probe_read(&info.buffer_len, sizeof(__u32), (void*)GO_ABI_INTERNAL_PT_REGS_SP(ctx)+0x18);
-    if (err != 0) {
-        log_error(ctx, LOG_ERROR_READING_BYTES_COUNT, pid_tgid, err, ORIGIN_SSL_UPROBE_CODE);
-        return;
-    }
-#elif defined(bpf_target_x86)
     if (abi == ABI0) {
         err = bpf_probe_read(&info.buffer_len, sizeof(__u32), (void*)GO_ABI_0_PT_REGS_SP(ctx)+0x18);
         if (err != 0) {
@@ -171,11 +155,25 @@ static __always_inline void go_crypto_tls_uprobe(struct pt_regs *ctx, struct bpf
             return;
         }
     } else {
+#if defined(bpf_target_arm64)
+        // The slice is passed in x1, x2 and x3 on arm64, after the receiver in x0
+        info.buffer_len = GO_ABI_INTERNAL_PT_REGS_R3(ctx);
+#else
         info.buffer_len = GO_ABI_INTERNAL_PT_REGS_R2(ctx);
-    }
 #endif
+    }
 
-#if defined(bpf_target_x86)
+#if defined(bpf_target_arm64)
+    if (abi == ABI0) {
+        err = bpf_probe_read(&info.buffer, sizeof(info.buffer), (void*)GO_ABI_0_PT_REGS_SP(ctx)+0x10);
+        if (err != 0) {
+            log_error(ctx, LOG_ERROR_READING_FROM_SSL_BUFFER, pid_tgid, err, ORIGIN_SSL_UPROBE_CODE);
+            return;
+        }
+    } else {
+        info.buffer = (void*)GO_ABI_INTERNAL_PT_REGS_R2(ctx);
+    }
+#elif defined(bpf_target_x86)
     if (abi == ABI0) {
         err = bpf_probe_read(&info.buffer, sizeof(__u32), (void*)GO_ABI_0_PT_REGS_SP(ctx)+0x11);
         if (err != 0) {
@@ -185,9 +183,7 @@ static __always_inline void go_crypto_tls_uprobe(struct pt_regs *ctx, struct bpf
         // We basically add 00 suffix to the hex address.
         info.buffer = (void*)((long)info.buffer << 8);
     } else {
-#endif
         info.buffer = (void*)GO_ABI_INTERNAL_PT_REGS_R4(ctx);
-#if defined(bpf_target_x86)
     }
 #endif
     info.fd = go_crypto_tls_get_fd_from_tcp_conn(ctx, abi);
@@ -195,7 +191,7 @@ static __always_inline void go_crypto_tls_uprobe(struct pt_regs *ctx, struct bpf
     __u64 goroutine_id;
     if (abi == ABI0) {
 #if defined(bpf_target_arm64)
-        // In case of ABI0 and arm64, it's stored in the Goroutine register
+        // In case of ABI0 and arm64, it's stored in the Goroutine register, x28 as in ABIInternal
         goroutine_id = GO_ABI_0_PT_REGS_GP(ctx);
 #elif defined(bpf_target_x86)
         // In case of ABI0 and amd64, it's stored in the thread-local storage
@@ -228,7 +224,7 @@ static __always_inline void go_crypto_tls_ex_uprobe(struct pt_regs *ctx, struct
     __u64 goroutine_id;
     if (abi == ABI0) {
 #if defined(bpf_target_arm64)
-        // In case of ABI0 and arm64, it's stored in the Goroutine register
+        // In case of ABI0 and arm64, it's stored in the Goroutine register, x28 as in ABIInternal
         goroutine_id = GO_ABI_0_PT_REGS_GP(ctx);
 #elif defined(bpf_target_x86)
         // In case of ABI0 and amd64, it's stored in the thread-local storage
@@ -259,13 +255,6 @@ static __always_inline void go_crypto_tls_ex_uprobe(struct pt_regs *ctx, struct
 
     // In case of read, the length is determined on return
     if (flags == FLAGS_IS_READ_BIT) {
-#if defined(bpf_target_arm64)
-        // On ARM64 we look at a general-purpose register as an indicator of error return
-        if (GO_ABI_INTERNAL_PT_REGS_R6(ctx) == 0x10) {
-            return;
-        }
-        info.buffer_len = GO_ABI_INTERNAL_PT_REGS_R7(ctx); // n in return n, nil
-#elif defined(bpf_target_x86)
         if (abi == ABI0) {
             // n in return n, nil
             err = bpf_probe_read(&info.buffer_len, sizeof(__u32), (void*)GO_ABI_0_PT_REGS_SP(ctx)+0x28);
@@ -276,7 +265,6 @@ static __always_inline void go_crypto_tls_ex_uprobe(struct pt_regs *ctx, struct
         } else {
             info.buffer_len = GO_ABI_INTERNAL_PT_REGS_R1(ctx); // n in return n, nil
         }
-#endif
         // This check achieves ignoring 0 length reads (the reads result with an error)
         if (info.buffer_len <= 0) {
             return;
@@ -298,11 +286,30 @@ static __always_inline void go_crypto_tls_ex_uprobe(struct pt_regs *ctx, struct
     info.address_info.saddr = address_info->saddr;
     info.address_info.sport = address_info->sport;
 
-    output_ssl_chunk(ctx, &info, info.buffer_len, pid_tgid, flags);
+    __u32 origin;
+    if (abi == ABI0) {
+        origin = flags == FLAGS_IS_READ_BIT ? PROBE_ORIGIN_GO_ABI0_READ : PROBE_ORIGIN_GO_ABI0_WRITE;
+    } else {
+        origin = flags == FLAGS_IS_READ_BIT ? PROBE_ORIGIN_GO_ABI_INTERNAL_READ : PROBE_ORIGIN_GO_ABI_INTERNAL_WRITE;
+    }
+
+    output_ssl_chunk(ctx, &info, info.buffer_len, pid_tgid, flags, origin);
 
     return;
 }
 
+#else
+
+// The arguments of crypto/tls are located on amd64 and arm64 only, the programs of the other
+// architectures are built so that the objects have the same programs, but do nothing
+static __always_inline void go_crypto_tls_uprobe(struct pt_regs *ctx, struct bpf_map_def* go_context, enum ABI abi) {
+}
+
+static __always_inline void go_crypto_tls_ex_uprobe(struct pt_regs *ctx, struct bpf_map_def* go_context, struct bpf_map_def* go_user_kernel_context, __u32 flags, enum ABI abi) {
+}
+
+#endif
+
 SEC("uprobe/go_crypto_tls_abi0_write")
 int BPF_KPROBE(go_crypto_tls_abi0_write) {
     go_crypto_tls_uprobe(ctx, &go_write_context, ABI0);
@@ -39,7 +39,7 @@ if it's applicable the Goroutine pointer
 struct pt_regs;
 #define PT_REGS_ARM64 const volatile struct user_pt_regs
 #define GO_ABI_0_PT_REGS_SP(x) (((PT_REGS_ARM64 *)(x))->sp)
-#define GO_ABI_0_PT_REGS_GP(x) (((PT_REGS_ARM64 *)(x))->regs[18])
+#define GO_ABI_0_PT_REGS_GP(x) (((PT_REGS_ARM64 *)(x))->regs[28])
 
 #elif defined(bpf_target_powerpc)
 
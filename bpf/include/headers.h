@@ -12,6 +12,13 @@ Copyright (C) Kubeshark
 #include "vmlinux_x86.h"
 #include "vmlinux_arm64.h"
 
+// Generated with make vmlinux on a host of the architecture, see the Makefile
+#if defined(bpf_target_s390)
+#include "vmlinux_s390.h"
+#elif defined(bpf_target_powerpc)
+#include "vmlinux_powerpc.h"
+#endif
+
 #include "legacy_kernel.h"
 
 #include <bpf/bpf_endian.h>
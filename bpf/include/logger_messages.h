@@ -31,6 +31,9 @@ Copyright (C) Kubeshark
 #define LOG_ERROR_READING_SOCKET_SPORT (20)
 #define LOG_ERROR_PUTTING_GO_USER_KERNEL_CONTEXT (21)
 #define LOG_ERROR_GETTING_GO_USER_KERNEL_CONTEXT (22)
+#define LOG_ERROR_PUTTING_FLOW_STATS (23)
+#define LOG_ERROR_PUTTING_THREAD_SOCKET (24)
+#define LOG_ERROR_PUTTING_PENDING_SSL_WRITE (25)
 
 // Sometimes we have the same error, happening from different locations.
 // 	in order to be able to distinct between them in the log, we add an 
@@ -43,5 +46,8 @@ Copyright (C) Kubeshark
 #define ORIGIN_SYS_ENTER_WRITE_CODE (3l)
 #define ORIGIN_SYS_EXIT_ACCEPT4_CODE (4l)
 #define ORIGIN_SYS_EXIT_CONNECT_CODE (5l)
+#define ORIGIN_SYS_ENTER_RECVFROM_CODE (6l)
+#define ORIGIN_SYS_ENTER_SENDTO_CODE (7l)
+#define ORIGIN_UDP_KPROBE_CODE (8l)
 
 #endif /* __LOG_MESSAGES__ */
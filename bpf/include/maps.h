@@ -8,6 +8,36 @@ Copyright (C) Kubeshark
 
 #define FLAGS_IS_CLIENT_BIT (1 << 0)
 #define FLAGS_IS_READ_BIT (1 << 1)
+// Sent without data when a targeted process exits
+#define FLAGS_IS_EXIT_BIT (1 << 2)
+// The data is the TLS records of a BIO, not the plaintext
+#define FLAGS_IS_CIPHERTEXT_BIT (1 << 3)
+// The data is a secret logged by OpenSSL, see openssl_log_secret
+#define FLAGS_IS_KEYLOG_BIT (1 << 4)
+// The connection is UDP, e.g. DTLS, kept in connection_context as well
+#define FLAGS_IS_UDP_BIT (1 << 5)
+
+// The probe that produced a chunk, the same values can be found in probe_origin.go
+//
+#define PROBE_ORIGIN_SSL_WRITE (1)
+#define PROBE_ORIGIN_SSL_READ (2)
+#define PROBE_ORIGIN_SSL_WRITE_EX (3)
+#define PROBE_ORIGIN_SSL_READ_EX (4)
+#define PROBE_ORIGIN_GO_ABI0_WRITE (5)
+#define PROBE_ORIGIN_GO_ABI0_READ (6)
+#define PROBE_ORIGIN_GO_ABI_INTERNAL_WRITE (7)
+#define PROBE_ORIGIN_GO_ABI_INTERNAL_READ (8)
+#define PROBE_ORIGIN_MBEDTLS_WRITE (9)
+#define PROBE_ORIGIN_MBEDTLS_READ (10)
+#define PROBE_ORIGIN_WOLFSSL_WRITE (11)
+#define PROBE_ORIGIN_WOLFSSL_READ (12)
+#define PROBE_ORIGIN_S2N_SEND (13)
+#define PROBE_ORIGIN_S2N_RECV (14)
+#define PROBE_ORIGIN_OPENSSL_BIO_WRITE (15)
+#define PROBE_ORIGIN_OPENSSL_BIO_READ (16)
+#define PROBE_ORIGIN_OPENSSL_LOG_SECRET (17)
+#define PROBE_ORIGIN_SYSCALL_WRITE (18)
+#define PROBE_ORIGIN_SYSCALL_READ (19)
 
 #define CHUNK_SIZE (1 << 12)
 #define MAX_CHUNKS_PER_OPERATION (8)
@@ -15,9 +45,14 @@ Copyright (C) Kubeshark
 // One minute in nano seconds. Chosen by gut feeling.
 #define SSL_INFO_MAX_TTL_NANO (1000000000l * 60l)
 
+// A memory BIO call is attributed to the socket read before it or the socket write after it
+// within this time
+#define MEMORY_BIO_MAX_DELAY_NANO (1000000000l)
+
 #define MAX_ENTRIES_HASH        (1 << 12)  // 4096
 #define MAX_ENTRIES_PERF_OUTPUT	(1 << 10)  // 1024
 #define MAX_ENTRIES_LRU_HASH	(1 << 14)  // 16384
+#define MAX_ENTRIES_RINGBUF	(1 << 24)  // 16 MiB, set by user mode
 
 // The same struct can be found in chunk.go
 //  
@@ -39,6 +74,7 @@ struct tls_chunk {
     __u32 recorded;
     __u32 fd;
     __u32 flags;
+    __u32 origin;
     struct address_info address_info;
     __u8 data[CHUNK_SIZE]; // Must be N^2
 };
@@ -56,14 +92,57 @@ struct ssl_info {
     size_t *count_ptr;
 };
 
+// Aggregated per connection and direction in metadata mode, instead of sending chunks
+struct flow_key {
+    __u32 pid;
+    __u32 flags;
+    struct address_info address_info;
+};
+
+struct flow_stats {
+    __u64 bytes;
+    __u64 messages;
+};
+
+// Set by user mode, index 0 of settings_map
+struct settings {
+    __u32 metadata_mode;
+    // No chunks are sent or aggregated while paused, the contexts are still maintained
+    __u32 paused;
+    // The chunks are sent through chunks_ringbuf instead of chunks_buffer
+    __u32 ringbuf;
+    // The payloads of read and write are sent by the syscall tracepoints, for the kernels
+    // without kprobes and uprobes
+    __u32 syscalls_only;
+};
+
 typedef __u8 conn_flags;
 
+// The last read or write of a thread to a known connection, the memory BIOs of OpenSSL have
+// no fd so their records are attributed to the socket syscalls of the same thread
+struct thread_socket {
+    __u32 fd;
+    __u64 created_at_nano;
+    struct address_info address_info;
+};
+
+// An SSL_write to a memory BIO, sent once the thread writes the records to a socket
+struct pending_ssl_write {
+    struct ssl_info info;
+    __u64 started_at_nano;
+    __s32 count_bytes;
+    __u32 origin;
+};
+
 struct goid_offsets {
     __u64 g_addr_offset;
     __u64 goid_offset;
 };
 
 const struct goid_offsets *unused __attribute__((unused));
+const struct flow_key *unused_flow_key __attribute__((unused));
+const struct flow_stats *unused_flow_stats __attribute__((unused));
+const struct settings *unused_settings __attribute__((unused));
 
 // Heap-like area for eBPF programs - stack size limited to 512 bytes, we must use maps for bigger (chunk) objects.
 //
@@ -74,6 +153,22 @@ struct {
 	__type(value, struct tls_chunk);
 } heap SEC(".maps");
 
+// Shared by the CPUs, in order, on the kernels since 5.8. Replaced by an unused array on the
+// older kernels, where the calls to bpf_ringbuf_output are removed before the programs are loaded.
+//
+struct {
+	__uint(type, BPF_MAP_TYPE_RINGBUF);
+	__uint(max_entries, MAX_ENTRIES_RINGBUF);
+} chunks_ringbuf SEC(".maps");
+
+// The number of the chunks that didn't fit in chunks_ringbuf
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, int);
+	__type(value, __u64);
+} chunks_ringbuf_drops SEC(".maps");
+
 
 #define BPF_MAP(_name, _type, _key_type, _value_type, _max_entries)     \
     struct bpf_map_def SEC("maps") _name = {                            \
@@ -92,15 +187,43 @@ struct {
 #define BPF_LRU_HASH(_name, _key_type, _value_type) \
     BPF_MAP(_name, BPF_MAP_TYPE_LRU_HASH, _key_type, _value_type, MAX_ENTRIES_LRU_HASH)
 
+#define BPF_LRU_PERCPU_HASH(_name, _key_type, _value_type) \
+    BPF_MAP(_name, BPF_MAP_TYPE_LRU_PERCPU_HASH, _key_type, _value_type, MAX_ENTRIES_LRU_HASH)
+
+#define BPF_ARRAY(_name, _value_type, _max_entries) \
+    BPF_MAP(_name, BPF_MAP_TYPE_ARRAY, int, _value_type, _max_entries)
+
 // Generic
 BPF_HASH(pids_map, __u32, __u32);
 BPF_LRU_HASH(connection_context, __u64, conn_flags);
+// The peer of the unconnected UDP sockets by pid and fd, the destination of their last sendto
+BPF_LRU_HASH(udp_peers, __u64, struct address_info);
 BPF_PERF_OUTPUT(chunks_buffer);
 BPF_PERF_OUTPUT(log_buffer);
+BPF_ARRAY(settings_map, struct settings, 1);
+
+// Metadata mode
+BPF_LRU_PERCPU_HASH(flow_stats_map, struct flow_key, struct flow_stats);
 
 // OpenSSL specific
 BPF_LRU_HASH(openssl_write_context, __u64, struct ssl_info);
 BPF_LRU_HASH(openssl_read_context, __u64, struct ssl_info);
+BPF_LRU_HASH(openssl_call_started, __u64, __u64);
+BPF_LRU_HASH(openssl_pending_write, __u64, struct pending_ssl_write);
+BPF_LRU_HASH(thread_read_socket, __u64, struct thread_socket);
+BPF_LRU_HASH(thread_write_socket, __u64, struct thread_socket);
+// The offset of the method in struct bio_st per process, and the threads in BIO_write or
+// BIO_read of an SSL filter BIO, for the processes that offload the crypto
+BPF_LRU_HASH(openssl_bio_method_offsets, __u32, __u32);
+BPF_LRU_HASH(openssl_bio_calls, __u64, __u32);
+// The processes whose records are captured at BIO level instead of their plaintext
+BPF_LRU_HASH(bio_capture_pids, __u32, __u32);
+
+// Syscalls-only mode, the buffer and the fd of the read or write of a thread
+BPF_LRU_HASH(syscall_payload_context, __u64, struct ssl_info);
+
+// mbedTLS specific, the offset of p_bio in mbedtls_ssl_context per process
+BPF_LRU_HASH(mbedtls_bio_offsets, __u32, __u32);
 
 // Go specific
 BPF_HASH(goid_offsets_map, __u32, struct goid_offsets);
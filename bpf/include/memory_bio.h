@@ -0,0 +1,18 @@
+/*
+SPDX-License-Identifier: GPL-3.0
+Copyright (C) Kubeshark
+*/
+
+#ifndef __MEMORY_BIO__
+#define __MEMORY_BIO__
+
+static void record_thread_socket(void *ctx, __u64 id, __u32 fd, struct bpf_map_def *map_fd);
+static void forward_thread_socket_address(__u64 id, struct bpf_map_def *map_fd, struct address_info address_info);
+static void start_ssl_call(__u64 id);
+static void mark_ssl_call_syscall(__u64 id);
+static __u64 end_ssl_call(__u64 id);
+static int resolve_memory_bio_read(__u64 id, __u64 started, struct ssl_info *info);
+static void defer_memory_bio_write(struct pt_regs *ctx, __u64 id, __u64 started, struct ssl_info *info, int count_bytes, __u32 origin);
+static void flush_memory_bio_write(void *ctx, __u64 id);
+
+#endif /* __MEMORY_BIO__ */
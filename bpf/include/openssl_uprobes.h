@@ -0,0 +1,14 @@
+/*
+SPDX-License-Identifier: GPL-3.0
+Copyright (C) Kubeshark
+*/
+
+#ifndef __OPENSSL_UPROBES__
+#define __OPENSSL_UPROBES__
+
+// Shared with the probes of the other TLS libraries whose read and write functions take the
+// context, the buffer and its length, and return the number of bytes
+static void ssl_uprobe(struct pt_regs *ctx, void* ssl, void* buffer, int num, struct bpf_map_def* map_fd, size_t *count_ptr);
+static void ssl_uretprobe(struct pt_regs *ctx, struct bpf_map_def* map_fd, __u32 flags, __u32 origin);
+
+#endif /* __OPENSSL_UPROBES__ */
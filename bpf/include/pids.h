@@ -19,4 +19,10 @@ int should_target(__u32 pid) {
 	return shouldTargetGlobally != NULL && *shouldTargetGlobally == 1;
 }
 
+int should_capture_bio(__u32 pid) {
+	__u32* captureBio = bpf_map_lookup_elem(&bio_capture_pids, &pid);
+
+	return captureBio != NULL && *captureBio == 1;
+}
+
 #endif /* __PIDS__ */
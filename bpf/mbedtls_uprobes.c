@@ -0,0 +1,84 @@
+/*
+SPDX-License-Identifier: GPL-3.0
+Copyright (C) Kubeshark
+*/
+
+#include "include/headers.h"
+#include "include/util.h"
+#include "include/maps.h"
+#include "include/log.h"
+#include "include/logger_messages.h"
+#include "include/pids.h"
+#include "include/common.h"
+#include "include/openssl_uprobes.h"
+
+// mbedtls_ssl_write and mbedtls_ssl_read have the signature of SSL_write and SSL_read, the
+// fd is found by the syscall tracepoints the same way. A context that uses mbedtls_net_send
+// and mbedtls_net_recv has its fd in the mbedtls_net_context that p_bio points to, which
+// covers the reads that are served from the buffered records without a syscall.
+
+// The offset of p_bio differs between 2.x and 3.x and with the build options, the tracer
+// finds it in mbedtls_ssl_set_bio. A p_bio that isn't a known socket is ignored, e.g. the
+// context of a custom BIO.
+static __always_inline __u32 mbedtls_get_fd(void *ssl, __u64 id) {
+	__u32 pid = id >> 32;
+	__u32 *offset = bpf_map_lookup_elem(&mbedtls_bio_offsets, &pid);
+
+	if (offset == NULL) {
+		return invalid_fd;
+	}
+
+	void *bio;
+	if (bpf_probe_read_user(&bio, sizeof(bio), ssl + *offset) != 0 || bio == NULL) {
+		return invalid_fd;
+	}
+
+	__s32 fd;
+	if (bpf_probe_read_user(&fd, sizeof(fd), bio) != 0 || fd < 0) {
+		return invalid_fd;
+	}
+
+	__u64 key = (__u64) pid << 32 | fd;
+	if (bpf_map_lookup_elem(&connection_context, &key) == NULL) {
+		return invalid_fd;
+	}
+
+	return fd;
+}
+
+static __always_inline void mbedtls_uprobe(struct pt_regs *ctx, void* ssl, void* buffer, int num, struct bpf_map_def* map_fd) {
+	ssl_uprobe(ctx, ssl, buffer, num, map_fd, 0);
+
+	__u64 id = bpf_get_current_pid_tgid();
+	struct ssl_info *infoPtr = bpf_map_lookup_elem(map_fd, &id);
+
+	if (infoPtr == NULL) {
+		return;
+	}
+
+	__u32 fd = mbedtls_get_fd(ssl, id);
+
+	if (fd != invalid_fd) {
+		infoPtr->fd = fd;
+	}
+}
+
+SEC("uprobe/mbedtls_write")
+void BPF_KPROBE(mbedtls_write, void* ssl, void* buffer, size_t len) {
+	mbedtls_uprobe(ctx, ssl, buffer, len, &openssl_write_context);
+}
+
+SEC("uretprobe/mbedtls_write")
+void BPF_KPROBE(mbedtls_ret_write) {
+	ssl_uretprobe(ctx, &openssl_write_context, 0, PROBE_ORIGIN_MBEDTLS_WRITE);
+}
+
+SEC("uprobe/mbedtls_read")
+void BPF_KPROBE(mbedtls_read, void* ssl, void* buffer, size_t len) {
+	mbedtls_uprobe(ctx, ssl, buffer, len, &openssl_read_context);
+}
+
+SEC("uretprobe/mbedtls_read")
+void BPF_KPROBE(mbedtls_ret_read) {
+	ssl_uretprobe(ctx, &openssl_read_context, FLAGS_IS_READ_BIT, PROBE_ORIGIN_MBEDTLS_READ);
+}
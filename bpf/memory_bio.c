@@ -0,0 +1,134 @@
+/*
+SPDX-License-Identifier: GPL-3.0
+Copyright (C) Kubeshark
+*/
+
+#include "include/headers.h"
+#include "include/util.h"
+#include "include/maps.h"
+#include "include/log.h"
+#include "include/logger_messages.h"
+#include "include/common.h"
+#include "include/memory_bio.h"
+
+// With a memory BIO, e.g. in the event loops, OpenSSL doesn't call read or write during
+// SSL_read and SSL_write, the application moves the records between the BIO and the socket
+// itself. The records of SSL_read are read from the socket right before it, and the records
+// of SSL_write are written to the socket right after it, by the same thread.
+
+// Called on the entry of read and write, the fd is only recorded for the known connections.
+//	The socket of an earlier syscall is forgotten, so the tcp kprobe doesn't give it the
+//	address of another socket.
+static __always_inline void record_thread_socket(void *ctx, __u64 id, __u32 fd, struct bpf_map_def *map_fd) {
+	__u32 pid = id >> 32;
+	__u64 key = (__u64) pid << 32 | fd;
+
+	if (bpf_map_lookup_elem(&connection_context, &key) == NULL) {
+		bpf_map_delete_elem(map_fd, &id);
+		return;
+	}
+
+	struct thread_socket socket = {};
+	socket.fd = fd;
+	socket.created_at_nano = bpf_ktime_get_ns();
+
+	long err = bpf_map_update_elem(map_fd, &id, &socket, BPF_ANY);
+
+	if (err != 0) {
+		log_error(ctx, LOG_ERROR_PUTTING_THREAD_SOCKET, id, err, 0l);
+	}
+}
+
+// Called by the tcp kprobes, between the entry and the exit of read and write
+static __always_inline void forward_thread_socket_address(__u64 id, struct bpf_map_def *map_fd, struct address_info address_info) {
+	struct thread_socket *socket = bpf_map_lookup_elem(map_fd, &id);
+
+	if (socket == NULL) {
+		return;
+	}
+
+	socket->address_info = address_info;
+}
+
+static __always_inline void start_ssl_call(__u64 id) {
+	__u64 now = bpf_ktime_get_ns();
+	bpf_map_update_elem(&openssl_call_started, &id, &now, BPF_ANY);
+}
+
+// Called on the entry of read and write, the current SSL call of the thread uses a socket BIO
+static __always_inline void mark_ssl_call_syscall(__u64 id) {
+	bpf_map_delete_elem(&openssl_call_started, &id);
+}
+
+// Returns the start of the SSL_read or SSL_write that returned if the thread didn't read or
+// write during it, 0 otherwise
+static __always_inline __u64 end_ssl_call(__u64 id) {
+	__u64 *startedPtr = bpf_map_lookup_elem(&openssl_call_started, &id);
+
+	if (startedPtr == NULL) {
+		return 0;
+	}
+
+	__u64 started = *startedPtr;
+	bpf_map_delete_elem(&openssl_call_started, &id);
+
+	return started;
+}
+
+// Takes the fd and the address of the socket read before the SSL_read
+static __always_inline int resolve_memory_bio_read(__u64 id, __u64 started, struct ssl_info *info) {
+	struct thread_socket *socket = bpf_map_lookup_elem(&thread_read_socket, &id);
+
+	if (socket == NULL || started - socket->created_at_nano > MEMORY_BIO_MAX_DELAY_NANO) {
+		return 0;
+	}
+
+	info->fd = socket->fd;
+	info->address_info = socket->address_info;
+
+	return 1;
+}
+
+// Keeps the SSL_write until the thread writes its records, a later SSL_write of the thread
+// replaces it
+static __always_inline void defer_memory_bio_write(struct pt_regs *ctx, __u64 id, __u64 started, struct ssl_info *info, int count_bytes, __u32 origin) {
+	struct pending_ssl_write pending = {};
+	pending.info = *info;
+	pending.started_at_nano = started;
+	pending.count_bytes = count_bytes;
+	pending.origin = origin;
+
+	long err = bpf_map_update_elem(&openssl_pending_write, &id, &pending, BPF_ANY);
+
+	if (err != 0) {
+		log_error(ctx, LOG_ERROR_PUTTING_PENDING_SSL_WRITE, id, err, 0l);
+	}
+}
+
+// Called on the exit of write, once the tcp kprobe has the address of the socket
+static __always_inline void flush_memory_bio_write(void *ctx, __u64 id) {
+	struct pending_ssl_write *pendingPtr = bpf_map_lookup_elem(&openssl_pending_write, &id);
+
+	if (pendingPtr == NULL) {
+		return;
+	}
+
+	struct thread_socket *socket = bpf_map_lookup_elem(&thread_write_socket, &id);
+
+	if (socket == NULL || socket->created_at_nano < pendingPtr->started_at_nano) {
+		return;
+	}
+
+	if (socket->created_at_nano - pendingPtr->started_at_nano > MEMORY_BIO_MAX_DELAY_NANO) {
+		bpf_map_delete_elem(&openssl_pending_write, &id);
+		return;
+	}
+
+	struct pending_ssl_write pending = *pendingPtr;
+	bpf_map_delete_elem(&openssl_pending_write, &id);
+
+	pending.info.fd = socket->fd;
+	pending.info.address_info = socket->address_info;
+
+	output_ssl_chunk((struct pt_regs *) ctx, &pending.info, pending.count_bytes, id, 0, pending.origin);
+}
@@ -0,0 +1,173 @@
+/*
+SPDX-License-Identifier: GPL-3.0
+Copyright (C) Kubeshark
+*/
+
+#include "include/headers.h"
+#include "include/util.h"
+#include "include/maps.h"
+#include "include/log.h"
+#include "include/logger_messages.h"
+#include "include/pids.h"
+#include "include/common.h"
+#include "include/openssl_uprobes.h"
+
+// Attached to BIO_write and BIO_read of the processes that offload the crypto of OpenSSL to
+// an engine or a provider, where the plaintext may bypass the hooked SSL functions. Only the
+// SSL filter BIOs carry plaintext, the other BIOs carry the records. A call that reaches an
+// SSL probe is captured there, see ssl_uretprobe.
+//
+// The processes that are captured at BIO level send the records of their descriptor BIOs
+// instead, socket and datagram BIOs, with the secrets that openssl_log_secret sends for the
+// decryption.
+
+#define BIO_TYPE_SSL (7 | 0x0200)
+#define BIO_TYPE_DESCRIPTOR (0x0100 | 0x0400)
+
+// The values of openssl_bio_calls
+#define BIO_CALL_PLAINTEXT (1)
+#define BIO_CALL_CIPHERTEXT (2)
+
+// The label and the secret of a keylog chunk are at fixed offsets of its data
+#define KEYLOG_LABEL_SIZE (64)
+#define KEYLOG_SECRET_MAX_SIZE (64)
+
+static __always_inline int get_bio_type(void* bio, __u64 id, __s32 *type) {
+	__u32 pid = id >> 32;
+	__u32 *offset = bpf_map_lookup_elem(&openssl_bio_method_offsets, &pid);
+
+	if (offset == NULL) {
+		return 0;
+	}
+
+	void *method;
+	if (bpf_probe_read_user(&method, sizeof(method), bio + *offset) != 0 || method == NULL) {
+		return 0;
+	}
+
+	// type is the first field of BIO_METHOD
+	return bpf_probe_read_user(type, sizeof(*type), method) == 0;
+}
+
+static __always_inline void bio_uprobe(struct pt_regs *ctx, void* bio, void* buffer, int num, struct bpf_map_def* map_fd) {
+	__u64 id = bpf_get_current_pid_tgid();
+
+	if (!should_target(id >> 32)) {
+		return;
+	}
+
+	__s32 type;
+	if (!get_bio_type(bio, id, &type)) {
+		return;
+	}
+
+	__u32 call;
+	if (should_capture_bio(id >> 32)) {
+		if ((type & BIO_TYPE_DESCRIPTOR) != BIO_TYPE_DESCRIPTOR) {
+			return;
+		}
+
+		call = BIO_CALL_CIPHERTEXT;
+	} else {
+		if (type != BIO_TYPE_SSL) {
+			return;
+		}
+
+		call = BIO_CALL_PLAINTEXT;
+	}
+
+	long err = bpf_map_update_elem(&openssl_bio_calls, &id, &call, BPF_ANY);
+
+	if (err != 0) {
+		log_error(ctx, LOG_ERROR_PUTTING_SSL_CONTEXT, id, err, 0l);
+		return;
+	}
+
+	ssl_uprobe(ctx, bio, buffer, num, map_fd, 0);
+}
+
+static __always_inline void bio_uretprobe(struct pt_regs *ctx, struct bpf_map_def* map_fd, __u32 flags, __u32 origin) {
+	__u64 id = bpf_get_current_pid_tgid();
+
+	__u32 *call = bpf_map_lookup_elem(&openssl_bio_calls, &id);
+
+	if (call == NULL) {
+		return;
+	}
+
+	if (*call == BIO_CALL_CIPHERTEXT) {
+		flags |= FLAGS_IS_CIPHERTEXT_BIT;
+	}
+
+	bpf_map_delete_elem(&openssl_bio_calls, &id);
+
+	ssl_uretprobe(ctx, map_fd, flags, origin);
+}
+
+SEC("uprobe/openssl_bio_write")
+void BPF_KPROBE(openssl_bio_write, void* bio, void* buffer, int num) {
+	bio_uprobe(ctx, bio, buffer, num, &openssl_write_context);
+}
+
+SEC("uretprobe/openssl_bio_write")
+void BPF_KPROBE(openssl_bio_ret_write) {
+	bio_uretprobe(ctx, &openssl_write_context, 0, PROBE_ORIGIN_OPENSSL_BIO_WRITE);
+}
+
+SEC("uprobe/openssl_bio_read")
+void BPF_KPROBE(openssl_bio_read, void* bio, void* buffer, int num) {
+	bio_uprobe(ctx, bio, buffer, num, &openssl_read_context);
+}
+
+SEC("uretprobe/openssl_bio_read")
+void BPF_KPROBE(openssl_bio_ret_read) {
+	bio_uretprobe(ctx, &openssl_read_context, FLAGS_IS_READ_BIT, PROBE_ORIGIN_OPENSSL_BIO_READ);
+}
+
+// Attached to ssl_log_secret of the processes that are captured at BIO level, it's called with
+// each secret that OpenSSL would write to SSLKEYLOGFILE. The chunk carries the label and the
+// secret, the client random is taken from the ClientHello in user space.
+SEC("uprobe/openssl_log_secret")
+void BPF_KPROBE(openssl_log_secret, void* ssl, const char* label, const __u8* secret, size_t secret_len) {
+	__u64 id = bpf_get_current_pid_tgid();
+	__u32 pid = id >> 32;
+
+	if (!should_target(pid) || !should_capture_bio(pid) || is_paused()) {
+		return;
+	}
+
+	if (secret_len == 0 || secret_len > KEYLOG_SECRET_MAX_SIZE) {
+		return;
+	}
+
+	int zero = 0;
+	struct tls_chunk *chunk = bpf_map_lookup_elem(&heap, &zero);
+
+	if (chunk == NULL) {
+		log_error(ctx, LOG_ERROR_ALLOCATING_CHUNK, id, 0l, 0l);
+		return;
+	}
+
+	chunk->pid = pid;
+	chunk->tgid = id;
+	chunk->len = secret_len;
+	chunk->start = 0;
+	chunk->recorded = KEYLOG_LABEL_SIZE + secret_len;
+	chunk->fd = 0;
+	chunk->flags = FLAGS_IS_KEYLOG_BIT;
+	chunk->origin = PROBE_ORIGIN_OPENSSL_LOG_SECRET;
+	__builtin_memset(&chunk->address_info, 0, sizeof(chunk->address_info));
+
+	if (bpf_probe_read_user_str(chunk->data, KEYLOG_LABEL_SIZE, label) < 0) {
+		return;
+	}
+
+	long err = bpf_probe_read_user(chunk->data + KEYLOG_LABEL_SIZE, secret_len & (KEYLOG_SECRET_MAX_SIZE * 2 - 1), secret);
+
+	if (err != 0) {
+		log_error(ctx, LOG_ERROR_READING_FROM_SSL_BUFFER, id, err, 0l);
+		return;
+	}
+
+	output_chunk(ctx, chunk);
+}
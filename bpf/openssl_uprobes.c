@@ -10,6 +10,8 @@ Copyright (C) Kubeshark
 #include "include/logger_messages.h"
 #include "include/pids.h"
 #include "include/common.h"
+#include "include/memory_bio.h"
+#include "include/openssl_uprobes.h"
 
 
 static __always_inline int get_count_bytes(struct pt_regs *ctx, struct ssl_info* info, __u64 id) {
@@ -59,15 +61,20 @@ static __always_inline void ssl_uprobe(struct pt_regs *ctx, void* ssl, void* buf
 	if (err != 0) {
 		log_error(ctx, LOG_ERROR_PUTTING_SSL_CONTEXT, id, err, 0l);
 	}
+
+	start_ssl_call(id);
 }
 
-static __always_inline void ssl_uretprobe(struct pt_regs *ctx, struct bpf_map_def* map_fd, __u32 flags) {
+static __always_inline void ssl_uretprobe(struct pt_regs *ctx, struct bpf_map_def* map_fd, __u32 flags, __u32 origin) {
 	__u64 id = bpf_get_current_pid_tgid();
 	
 	if (!should_target(id >> 32)) {
 		return;
 	}
 
+	// The BIO_write or BIO_read of an SSL filter BIO that called this isn't captured again
+	bpf_map_delete_elem(&openssl_bio_calls, &id);
+
 	struct ssl_info *infoPtr = bpf_map_lookup_elem(map_fd, &id);
 	
 	if (infoPtr == NULL) {
@@ -90,22 +97,50 @@ static __always_inline void ssl_uretprobe(struct pt_regs *ctx, struct bpf_map_de
 	//
 	// bpf_map_delete_elem(map_fd, &id);
 	
+	__u64 memory_bio_started = end_ssl_call(id);
+
 	if (err != 0) {
 		log_error(ctx, LOG_ERROR_READING_SSL_CONTEXT, id, err, ORIGIN_SSL_URETPROBE_CODE);
 		return;
 	}
+
+	int count_bytes = get_count_bytes(ctx, &info, id);
+	if (count_bytes <= 0) {
+		return;
+	}
+
+	// The thread didn't read or write during the call, the fd of the context belongs to
+	//	an earlier call if any
+	//
+	if (memory_bio_started != 0) {
+		if (!(flags & FLAGS_IS_READ_BIT)) {
+			defer_memory_bio_write(ctx, id, memory_bio_started, &info, count_bytes, origin);
+			return;
+		}
+
+		resolve_memory_bio_read(id, memory_bio_started, &info);
+	}
 	
 	if (info.fd == invalid_fd) {
 		log_error(ctx, LOG_ERROR_MISSING_FILE_DESCRIPTOR, id, 0l, 0l);
 		return;
 	}
 
-	int count_bytes = get_count_bytes(ctx, &info, id);
-	if (count_bytes <= 0) {
+	output_ssl_chunk(ctx, &info, count_bytes, id, flags, origin);
+}
+
+// The plaintext of the processes that are captured at BIO level isn't sent, their records
+//	are sent by the BIO probes
+//
+static __always_inline void openssl_uretprobe(struct pt_regs *ctx, struct bpf_map_def* map_fd, __u32 flags, __u32 origin) {
+	__u64 id = bpf_get_current_pid_tgid();
+
+	if (should_capture_bio(id >> 32)) {
+		end_ssl_call(id);
 		return;
 	}
 
-	output_ssl_chunk(ctx, &info, count_bytes, id, flags);
+	ssl_uretprobe(ctx, map_fd, flags, origin);
 }
 
 SEC("uprobe/ssl_write")
@@ -115,7 +150,7 @@ void BPF_KPROBE(ssl_write, void* ssl, void* buffer, int num) {
 
 SEC("uretprobe/ssl_write")
 void BPF_KPROBE(ssl_ret_write) {
-	ssl_uretprobe(ctx, &openssl_write_context, 0);
+	openssl_uretprobe(ctx, &openssl_write_context, 0, PROBE_ORIGIN_SSL_WRITE);
 }
 
 SEC("uprobe/ssl_read")
@@ -125,7 +160,7 @@ void BPF_KPROBE(ssl_read, void* ssl, void* buffer, int num) {
 
 SEC("uretprobe/ssl_read")
 void BPF_KPROBE(ssl_ret_read) {
-	ssl_uretprobe(ctx, &openssl_read_context, FLAGS_IS_READ_BIT);
+	openssl_uretprobe(ctx, &openssl_read_context, FLAGS_IS_READ_BIT, PROBE_ORIGIN_SSL_READ);
 }
 
 SEC("uprobe/ssl_write_ex")
@@ -135,7 +170,7 @@ void BPF_KPROBE(ssl_write_ex, void* ssl, void* buffer, size_t num, size_t *writt
 
 SEC("uretprobe/ssl_write_ex")
 void BPF_KPROBE(ssl_ret_write_ex) {
-	ssl_uretprobe(ctx, &openssl_write_context, 0);
+	openssl_uretprobe(ctx, &openssl_write_context, 0, PROBE_ORIGIN_SSL_WRITE_EX);
 }
 
 SEC("uprobe/ssl_read_ex")
@@ -145,5 +180,5 @@ void BPF_KPROBE(ssl_read_ex, void* ssl, void* buffer, size_t num, size_t *readby
 
 SEC("uretprobe/ssl_read_ex")
 void BPF_KPROBE(ssl_ret_read_ex) {
-	ssl_uretprobe(ctx, &openssl_read_context, FLAGS_IS_READ_BIT);
+	openssl_uretprobe(ctx, &openssl_read_context, FLAGS_IS_READ_BIT, PROBE_ORIGIN_SSL_READ_EX);
 }
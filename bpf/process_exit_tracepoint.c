@@ -0,0 +1,68 @@
+/*
+SPDX-License-Identifier: GPL-3.0
+Copyright (C) Kubeshark
+*/
+
+#include "include/headers.h"
+#include "include/util.h"
+#include "include/maps.h"
+#include "include/log.h"
+#include "include/logger_messages.h"
+#include "include/pids.h"
+#include "include/common.h"
+
+// Deletes the contexts of the exiting thread. When the whole process is gone, the user mode
+// is notified through the chunks buffer, in order with the chunks of the process, to close
+// its streams and to delete the contexts that are keyed by the file descriptors and the goroutines.
+//
+SEC("tracepoint/sched/sched_process_exit")
+void sched_process_exit(void *ctx) {
+	__u64 id = bpf_get_current_pid_tgid();
+	__u32 pid = id >> 32;
+
+	if (!should_target(pid)) {
+		return;
+	}
+
+	bpf_map_delete_elem(&openssl_write_context, &id);
+	bpf_map_delete_elem(&openssl_read_context, &id);
+	bpf_map_delete_elem(&go_kernel_write_context, &id);
+	bpf_map_delete_elem(&go_kernel_read_context, &id);
+	bpf_map_delete_elem(&openssl_bio_calls, &id);
+	bpf_map_delete_elem(&syscall_payload_context, &id);
+	bpf_map_delete_elem(&openssl_call_started, &id);
+	bpf_map_delete_elem(&openssl_pending_write, &id);
+	bpf_map_delete_elem(&thread_read_socket, &id);
+	bpf_map_delete_elem(&thread_write_socket, &id);
+
+	// signal->live is decremented before the tracepoint, it's zero for the last thread
+	struct task_struct *task = (struct task_struct *) bpf_get_current_task();
+	if (BPF_CORE_READ(task, signal, live.counter) != 0) {
+		return;
+	}
+
+	bpf_map_delete_elem(&mbedtls_bio_offsets, &pid);
+	bpf_map_delete_elem(&openssl_bio_method_offsets, &pid);
+	bpf_map_delete_elem(&bio_capture_pids, &pid);
+	bpf_map_delete_elem(&goid_offsets_map, &pid);
+
+	int zero = 0;
+	struct tls_chunk *chunk = bpf_map_lookup_elem(&heap, &zero);
+
+	if (chunk == NULL) {
+		log_error(ctx, LOG_ERROR_ALLOCATING_CHUNK, id, 0l, 0l);
+		return;
+	}
+
+	chunk->pid = pid;
+	chunk->tgid = id;
+	chunk->len = 0;
+	chunk->start = 0;
+	chunk->recorded = 0;
+	chunk->fd = 0;
+	chunk->flags = FLAGS_IS_EXIT_BIT;
+	chunk->origin = 0;
+	__builtin_memset(&chunk->address_info, 0, sizeof(chunk->address_info));
+
+	output_chunk(ctx, chunk);
+}
@@ -0,0 +1,37 @@
+/*
+SPDX-License-Identifier: GPL-3.0
+Copyright (C) Kubeshark
+*/
+
+#include "include/headers.h"
+#include "include/util.h"
+#include "include/maps.h"
+#include "include/log.h"
+#include "include/logger_messages.h"
+#include "include/pids.h"
+#include "include/common.h"
+#include "include/openssl_uprobes.h"
+
+// s2n_send and s2n_recv take the connection, the buffer and its length like SSL_write and
+// SSL_read, the blocked status that follows is ignored. The fd is found by the syscall
+// tracepoints the same way.
+
+SEC("uprobe/s2n_send")
+void BPF_KPROBE(s2n_send, void* conn, void* buffer, ssize_t size) {
+	ssl_uprobe(ctx, conn, buffer, size, &openssl_write_context, 0);
+}
+
+SEC("uretprobe/s2n_send")
+void BPF_KPROBE(s2n_ret_send) {
+	ssl_uretprobe(ctx, &openssl_write_context, 0, PROBE_ORIGIN_S2N_SEND);
+}
+
+SEC("uprobe/s2n_recv")
+void BPF_KPROBE(s2n_recv, void* conn, void* buffer, ssize_t size) {
+	ssl_uprobe(ctx, conn, buffer, size, &openssl_read_context, 0);
+}
+
+SEC("uretprobe/s2n_recv")
+void BPF_KPROBE(s2n_ret_recv) {
+	ssl_uretprobe(ctx, &openssl_read_context, FLAGS_IS_READ_BIT, PROBE_ORIGIN_S2N_RECV);
+}
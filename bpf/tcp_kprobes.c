@@ -4,14 +4,15 @@
 #include "include/logger_messages.h"
 #include "include/pids.h"
 #include "include/common.h"
+#include "include/memory_bio.h"
 
 
-static __always_inline int tcp_kprobes_get_address_pair_from_ctx(struct pt_regs *ctx, __u64 id, struct address_info *address_info_ptr) {
+static __always_inline int tcp_kprobes_get_address_pair(void *ctx, struct sock *sk, __u64 id, struct address_info *address_info_ptr) {
 	long err;
-	struct sock *sk = (struct sock *) PT_REGS_PARM1(ctx);
 
+	// The fields of struct sock are relocated to their offsets in the running kernel
 	short unsigned int family;
-	err = bpf_probe_read(&family, sizeof(family), (void *)&sk->__sk_common.skc_family);
+	err = BPF_CORE_READ_INTO(&family, sk, __sk_common.skc_family);
 	if (err != 0) {
 		log_error(ctx, LOG_ERROR_READING_SOCKET_FAMILY, id, err, 0l);
 		return -1;
@@ -27,22 +28,22 @@ static __always_inline int tcp_kprobes_get_address_pair_from_ctx(struct pt_regs
 	__be16 dport;
 	__u16 sport;
 
-	err = bpf_probe_read(&saddr, sizeof(saddr), (void *)&sk->__sk_common.skc_rcv_saddr);
+	err = BPF_CORE_READ_INTO(&saddr, sk, __sk_common.skc_rcv_saddr);
 	if (err != 0) {
 		log_error(ctx, LOG_ERROR_READING_SOCKET_SADDR, id, err, 0l);
 		return -1;
 	}
-	err = bpf_probe_read(&daddr, sizeof(daddr), (void *)&sk->__sk_common.skc_daddr);
+	err = BPF_CORE_READ_INTO(&daddr, sk, __sk_common.skc_daddr);
 	if (err != 0) {
 		log_error(ctx, LOG_ERROR_READING_SOCKET_DADDR, id, err, 0l);
 		return -1;
 	}
-	err = bpf_probe_read(&dport, sizeof(dport), (void *)&sk->__sk_common.skc_dport);
+	err = BPF_CORE_READ_INTO(&dport, sk, __sk_common.skc_dport);
 	if (err != 0) {
 		log_error(ctx, LOG_ERROR_READING_SOCKET_DPORT, id, err, 0l);
 		return -1;
 	}
-	err = bpf_probe_read(&sport, sizeof(sport), (void *)&sk->__sk_common.skc_num);
+	err = BPF_CORE_READ_INTO(&sport, sk, __sk_common.skc_num);
 	if (err != 0) {
 		log_error(ctx, LOG_ERROR_READING_SOCKET_SPORT, id, err, 0l);
 		return -1;
@@ -56,7 +57,7 @@ static __always_inline int tcp_kprobes_get_address_pair_from_ctx(struct pt_regs
 	return 0;
 }
 
-static __always_inline void tcp_kprobes_forward_go(struct pt_regs *ctx, __u64 id, __u32 fd, struct address_info address_info, struct bpf_map_def *map_fd_go_user_kernel) {
+static __always_inline void tcp_kprobes_forward_go(void *ctx, __u64 id, __u32 fd, struct address_info address_info, struct bpf_map_def *map_fd_go_user_kernel) {
 		__u32 pid = id >> 32;
 		__u64 key = (__u64) pid << 32 | fd;
 
@@ -74,7 +75,7 @@ static void __always_inline tcp_kprobes_forward_openssl(struct ssl_info *info_pt
 		info_ptr->address_info.sport = address_info.sport;
 }
 
-static __always_inline void tcp_kprobe(struct pt_regs *ctx, struct bpf_map_def *map_fd_openssl, struct bpf_map_def *map_fd_go_kernel, struct bpf_map_def *map_fd_go_user_kernel) {
+static __always_inline void tcp_kprobe(void *ctx, struct sock *sk, struct bpf_map_def *map_fd_openssl, struct bpf_map_def *map_fd_go_kernel, struct bpf_map_def *map_fd_go_user_kernel, struct bpf_map_def *map_fd_thread_socket) {
 	long err;
 
 	__u64 id = bpf_get_current_pid_tgid();
@@ -84,10 +85,12 @@ static __always_inline void tcp_kprobe(struct pt_regs *ctx, struct bpf_map_def *
 	}
 
 	struct address_info address_info;
-	if (0 != tcp_kprobes_get_address_pair_from_ctx(ctx, id, &address_info)) {
+	if (0 != tcp_kprobes_get_address_pair(ctx, sk, id, &address_info)) {
 		return;
 	}
 
+	forward_thread_socket_address(id, map_fd_thread_socket, address_info);
+
 	struct ssl_info *info_ptr = bpf_map_lookup_elem(map_fd_openssl, &id);
 	__u32 *fd_ptr;
 	if (info_ptr == NULL) {
@@ -106,13 +109,26 @@ static __always_inline void tcp_kprobe(struct pt_regs *ctx, struct bpf_map_def *
 }
 
 SEC("kprobe/tcp_sendmsg")
-void BPF_KPROBE(tcp_sendmsg) {
-	__u64 id = bpf_get_current_pid_tgid();
-	tcp_kprobe(ctx, &openssl_write_context, &go_kernel_write_context, &go_user_kernel_write_context);
+void BPF_KPROBE(tcp_sendmsg, struct sock *sk) {
+	tcp_kprobe(ctx, sk, &openssl_write_context, &go_kernel_write_context, &go_user_kernel_write_context, &thread_write_socket);
 }
 
 SEC("kprobe/tcp_recvmsg")
-void BPF_KPROBE(tcp_recvmsg) {
-	__u64 id = bpf_get_current_pid_tgid();
-	tcp_kprobe(ctx, &openssl_read_context, &go_kernel_read_context, &go_user_kernel_read_context);
+void BPF_KPROBE(tcp_recvmsg, struct sock *sk) {
+	tcp_kprobe(ctx, sk, &openssl_read_context, &go_kernel_read_context, &go_user_kernel_read_context, &thread_read_socket);
+}
+
+// The same hooks through the BPF trampolines, which are cheaper than the kprobes. They are
+// attached instead of the kprobes if the kernel supports them, see probeFentry.
+
+SEC("fentry/tcp_sendmsg")
+int BPF_PROG(tcp_sendmsg_fentry, struct sock *sk) {
+	tcp_kprobe(ctx, sk, &openssl_write_context, &go_kernel_write_context, &go_user_kernel_write_context, &thread_write_socket);
+	return 0;
+}
+
+SEC("fentry/tcp_recvmsg")
+int BPF_PROG(tcp_recvmsg_fentry, struct sock *sk) {
+	tcp_kprobe(ctx, sk, &openssl_read_context, &go_kernel_read_context, &go_user_kernel_read_context, &thread_read_socket);
+	return 0;
 }
@@ -0,0 +1,77 @@
+/*
+SPDX-License-Identifier: GPL-3.0
+Copyright (C) Kubeshark
+*/
+
+#include "include/headers.h"
+
+#define ETH_P_IP (0x0800)
+#define ETH_P_IPV6 (0x86DD)
+#define IPV6_HEADER_LEN (40)
+#define IPV6_NEXT_HEADER_OFFSET (6)
+#define IPPROTO_TCP_NUMBER (6)
+
+#define TLS_CONTENT_HANDSHAKE (0x16)
+#define TLS_MAJOR_VERSION (0x03)
+#define TLS_HANDSHAKE_CLIENT_HELLO (0x01)
+#define TLS_HANDSHAKE_SERVER_HELLO (0x02)
+
+// Socket filter, keeps only the IPv4 and IPv6 TCP packets that start with a ClientHello or a
+//	ServerHello record. The payloads are decrypted by the uprobes, this only adds the handshake
+//	records. The offsets are relative to the network header, the length of the link-layer
+//	header depends on the type of the interface. The IPv6 packets with extension headers
+//	are dropped.
+//
+SEC("socket/tls_handshake_filter")
+int tls_handshake_filter(struct __sk_buff *skb) {
+    __u8 protocol;
+    __u32 tcp_offset;
+
+    if (skb->protocol == bpf_htons(ETH_P_IP)) {
+        __u8 version_ihl;
+        if (bpf_skb_load_bytes_relative(skb, 0, &version_ihl, sizeof(version_ihl), BPF_HDR_START_NET) != 0) {
+            return 0;
+        }
+
+        if (bpf_skb_load_bytes_relative(skb, offsetof(struct iphdr, protocol), &protocol, sizeof(protocol), BPF_HDR_START_NET) != 0) {
+            return 0;
+        }
+
+        tcp_offset = (version_ihl & 0x0f) * 4;
+    } else if (skb->protocol == bpf_htons(ETH_P_IPV6)) {
+        if (bpf_skb_load_bytes_relative(skb, IPV6_NEXT_HEADER_OFFSET, &protocol, sizeof(protocol), BPF_HDR_START_NET) != 0) {
+            return 0;
+        }
+
+        tcp_offset = IPV6_HEADER_LEN;
+    } else {
+        return 0;
+    }
+
+    if (protocol != IPPROTO_TCP_NUMBER) {
+        return 0;
+    }
+
+    __u8 data_offset;
+    if (bpf_skb_load_bytes_relative(skb, tcp_offset + 12, &data_offset, sizeof(data_offset), BPF_HDR_START_NET) != 0) {
+        return 0;
+    }
+
+    __u32 payload_offset = tcp_offset + (data_offset >> 4) * 4;
+
+    // Content type, version (2 bytes), length (2 bytes) and the handshake type
+    __u8 record[6];
+    if (bpf_skb_load_bytes_relative(skb, payload_offset, record, sizeof(record), BPF_HDR_START_NET) != 0) {
+        return 0;
+    }
+
+    if (record[0] != TLS_CONTENT_HANDSHAKE || record[1] != TLS_MAJOR_VERSION) {
+        return 0;
+    }
+
+    if (record[5] != TLS_HANDSHAKE_CLIENT_HELLO && record[5] != TLS_HANDSHAKE_SERVER_HELLO) {
+        return 0;
+    }
+
+    return skb->len;
+}
@@ -13,10 +13,18 @@ Copyright (C) Kubeshark
 // To avoid multiple .o files
 //
 #include "common.c"
+#include "memory_bio.c"
 #include "openssl_uprobes.c"
+#include "openssl_bio_uprobes.c"
+#include "mbedtls_uprobes.c"
+#include "wolfssl_uprobes.c"
+#include "s2n_uprobes.c"
 #include "tcp_kprobes.c"
+#include "udp_kprobes.c"
 #include "go_uprobes.c"
 #include "fd_tracepoints.c"
 #include "fd_to_address_tracepoints.c"
+#include "tls_handshake_filter.c"
+#include "process_exit_tracepoint.c"
 
 char _license[] SEC("license") = "GPL";
@@ -0,0 +1,102 @@
+/*
+SPDX-License-Identifier: GPL-3.0
+Copyright (C) Kubeshark
+*/
+
+#include "include/headers.h"
+#include "include/maps.h"
+#include "include/log.h"
+#include "include/logger_messages.h"
+#include "include/pids.h"
+#include "include/common.h"
+
+// The UDP sockets of DTLS. The address of a connected socket is read from the socket as the
+// tcp hooks do. An unconnected socket has the destination of sendto in the message, which is
+// kept as the peer of the following receives. The connection is marked as UDP.
+
+// The socket of a lingering SSL context isn't the one of the call, e.g. a DNS query of the thread
+static __always_inline int is_socket_of_fd(struct sock *sk, __u32 fd) {
+	return get_fd_sock(fd) == sk;
+}
+
+static __always_inline void udp_mark_connection(void *ctx, __u64 id, __u32 fd) {
+	__u32 pid = id >> 32;
+	__u64 key = (__u64) pid << 32 | fd;
+
+	conn_flags *flags = bpf_map_lookup_elem(&connection_context, &key);
+
+	if (flags != NULL) {
+		*flags |= FLAGS_IS_UDP_BIT;
+		return;
+	}
+
+	// Not connected, the side that waits for the datagrams is the server
+	conn_flags new_flags = FLAGS_IS_UDP_BIT;
+	long err = bpf_map_update_elem(&connection_context, &key, &new_flags, BPF_ANY);
+
+	if (err != 0) {
+		log_error(ctx, LOG_ERROR_PUTTING_CONNECTION_CONTEXT, id, err, ORIGIN_UDP_KPROBE_CODE);
+	}
+}
+
+static __always_inline void udp_kprobe(void *ctx, struct sock *sk, struct msghdr *msg, struct bpf_map_def *map_fd_openssl, int is_send) {
+	__u64 id = bpf_get_current_pid_tgid();
+
+	if (!should_target(id >> 32)) {
+		return;
+	}
+
+	struct ssl_info *info_ptr = bpf_map_lookup_elem(map_fd_openssl, &id);
+
+	if (info_ptr == NULL || info_ptr->fd == invalid_fd || !is_socket_of_fd(sk, info_ptr->fd)) {
+		return;
+	}
+
+	struct address_info address_info;
+	if (0 != tcp_kprobes_get_address_pair(ctx, sk, id, &address_info)) {
+		return;
+	}
+
+	__u32 pid = id >> 32;
+	__u64 key = (__u64) pid << 32 | info_ptr->fd;
+
+	if (address_info.daddr == 0) {
+		if (is_send) {
+			struct sockaddr_in *name = (struct sockaddr_in *) BPF_CORE_READ(msg, msg_name);
+
+			if (name == NULL) {
+				return;
+			}
+
+			if (bpf_probe_read_kernel(&address_info.daddr, sizeof(address_info.daddr), &name->sin_addr.s_addr) != 0 ||
+				bpf_probe_read_kernel(&address_info.dport, sizeof(address_info.dport), &name->sin_port) != 0) {
+				return;
+			}
+
+			bpf_map_update_elem(&udp_peers, &key, &address_info, BPF_ANY);
+		} else {
+			struct address_info *peer = bpf_map_lookup_elem(&udp_peers, &key);
+
+			// Nothing was sent to the peer yet
+			if (peer == NULL) {
+				return;
+			}
+
+			address_info.daddr = peer->daddr;
+			address_info.dport = peer->dport;
+		}
+	}
+
+	tcp_kprobes_forward_openssl(info_ptr, address_info);
+	udp_mark_connection(ctx, id, info_ptr->fd);
+}
+
+SEC("kprobe/udp_sendmsg")
+void BPF_KPROBE(udp_sendmsg, struct sock *sk, struct msghdr *msg) {
+	udp_kprobe(ctx, sk, msg, &openssl_write_context, 1);
+}
+
+SEC("kprobe/udp_recvmsg")
+void BPF_KPROBE(udp_recvmsg, struct sock *sk, struct msghdr *msg) {
+	udp_kprobe(ctx, sk, msg, &openssl_read_context, 0);
+}
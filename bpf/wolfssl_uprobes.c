@@ -0,0 +1,37 @@
+/*
+SPDX-License-Identifier: GPL-3.0
+Copyright (C) Kubeshark
+*/
+
+#include "include/headers.h"
+#include "include/util.h"
+#include "include/maps.h"
+#include "include/log.h"
+#include "include/logger_messages.h"
+#include "include/pids.h"
+#include "include/common.h"
+#include "include/openssl_uprobes.h"
+
+// wolfSSL_write and wolfSSL_read have the signature of SSL_write and SSL_read, the fd is found
+// by the syscall tracepoints the same way. The layout of WOLFSSL depends on too many build
+// options to read its fd, so a read that is served from the buffered records has no fd.
+
+SEC("uprobe/wolfssl_write")
+void BPF_KPROBE(wolfssl_write, void* ssl, void* buffer, int num) {
+	ssl_uprobe(ctx, ssl, buffer, num, &openssl_write_context, 0);
+}
+
+SEC("uretprobe/wolfssl_write")
+void BPF_KPROBE(wolfssl_ret_write) {
+	ssl_uretprobe(ctx, &openssl_write_context, 0, PROBE_ORIGIN_WOLFSSL_WRITE);
+}
+
+SEC("uprobe/wolfssl_read")
+void BPF_KPROBE(wolfssl_read, void* ssl, void* buffer, int num) {
+	ssl_uprobe(ctx, ssl, buffer, num, &openssl_read_context, 0);
+}
+
+SEC("uretprobe/wolfssl_read")
+void BPF_KPROBE(wolfssl_ret_read) {
+	ssl_uretprobe(ctx, &openssl_read_context, FLAGS_IS_READ_BIT, PROBE_ORIGIN_WOLFSSL_READ);
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/kubeshark/tracer/pkg/api"
+	"github.com/kubeshark/tracer/pkg/server"
+	"github.com/kubeshark/tracer/pkg/storage"
+	"github.com/kubeshark/tracer/pkg/tracer"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// A line has the base64 of a chunk of at most 4 KiB and its metadata
+const maxChunkLine = 1024 * 1024
+
+// recordChunks writes the events of the tracer to path as the JSON lines of the versioned
+// api.Chunk until the tracer is stopped or the returned stop is called, stop returns when
+// the file is flushed
+func recordChunks(t *tracer.Tracer, path string, maxSize int64, storageManager *storage.Manager) (func(), error) {
+	sink := storageManager.AddSink("chunks-file", filepath.Dir(path), getChunksFilePatterns(path)...)
+	writer, err := newChunksWriter(path, maxSize, sink)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := t.Subscribe(tracer.EventFilter{})
+	if err != nil {
+		writer.close()
+		return nil, err
+	}
+
+	log.Info().Str("path", path).Int64("max-size", maxSize).Msg("Recording the chunks:")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for event := range events {
+			if err := writer.write(server.BuildChunk(&event)); err != nil {
+				tracer.LogError(err)
+				t.Unsubscribe(events)
+				break
+			}
+		}
+
+		if err := writer.close(); err != nil {
+			tracer.LogError(err)
+		}
+	}()
+
+	return func() {
+		t.Unsubscribe(events)
+		<-done
+	}, nil
+}
+
+// chunksWriter writes the chunks file, which is renamed to a segment beside it when it
+// reaches maxSize, 0 disables the rotation. With the rotation the file of the previous run
+// is kept as a segment as well, the segments are pruned by the storage manager.
+type chunksWriter struct {
+	path    string
+	maxSize int64
+	sink    *storage.Sink
+	file    *os.File
+	writer  *bufio.Writer
+	size    int64
+}
+
+func newChunksWriter(path string, maxSize int64, sink *storage.Sink) (*chunksWriter, error) {
+	if maxSize < 0 {
+		return nil, errors.Errorf("Invalid chunks file max size %d", maxSize)
+	}
+
+	w := &chunksWriter{path: path, maxSize: maxSize, sink: sink}
+	if maxSize > 0 {
+		if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+			if err := w.rename(info.ModTime()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := w.create(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *chunksWriter) create() error {
+	file, err := os.Create(w.path)
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	w.sink.Open(w.path)
+	w.file = file
+	w.writer = bufio.NewWriter(file)
+	w.size = 0
+
+	return nil
+}
+
+func (w *chunksWriter) write(chunk *api.Chunk) error {
+	if err := server.WriteChunk(w, chunk); err != nil {
+		return err
+	}
+
+	if w.maxSize > 0 && w.size >= w.maxSize {
+		if err := w.close(); err != nil {
+			return err
+		}
+
+		if err := w.rename(time.Now()); err != nil {
+			return err
+		}
+
+		return w.create()
+	}
+
+	return nil
+}
+
+// Write counts the written bytes for the rotation
+func (w *chunksWriter) Write(data []byte) (int, error) {
+	n, err := w.writer.Write(data)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *chunksWriter) close() error {
+	defer w.sink.Close(w.path)
+
+	if err := w.writer.Flush(); err != nil {
+		w.file.Close()
+		return errors.Wrap(err, 0)
+	}
+
+	if err := w.file.Close(); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	return nil
+}
+
+func (w *chunksWriter) rename(end time.Time) error {
+	segment := getChunksSegmentPath(w.path, end)
+	for _, err := os.Stat(segment); err == nil; _, err = os.Stat(segment) {
+		end = end.Add(time.Microsecond)
+		segment = getChunksSegmentPath(w.path, end)
+	}
+
+	if err := os.Rename(w.path, segment); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	w.sink.Add(segment)
+	log.Info().Str("path", segment).Msg("Rotated chunks file:")
+
+	return nil
+}
+
+// getChunksSegmentPath is the segment of a chunks file that ended at end, e.g.
+// chunks-20240101T120000.000000Z.jsonl for chunks.jsonl, which sort by time
+func getChunksSegmentPath(path string, end time.Time) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + end.UTC().Format("20060102T150405.000000Z") + ext
+}
+
+// getChunksFilePatterns are the patterns of the storage sink of a chunks file and its segments
+func getChunksFilePatterns(path string) []string {
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	return []string{base, strings.TrimSuffix(base, ext) + "-*" + ext}
+}
+
+// runReplay prints the chunks of the chunk files, "-" reads the standard input
+func runReplay(args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing the chunks file")
+	}
+
+	for _, path := range args {
+		if err := replayChunks(path, os.Stdout); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func replayChunks(path string, out io.Writer) error {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return errors.Wrap(err, 0)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	streams := make(map[int64]bool)
+	chunks := 0
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxChunkLine)
+	for scanner.Scan() {
+		chunk, err := readChunk(scanner.Bytes())
+		if err != nil {
+			return errors.Errorf("Error reading chunk %d of %s: %v", chunks+1, path, err)
+		}
+
+		printChunk(out, chunk)
+		if chunk.Notice == nil {
+			streams[chunk.StreamId] = true
+		}
+		chunks++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	log.Info().Str("path", path).Int("chunks", chunks).Int("streams", len(streams)).Msg("Replayed chunks file:")
+
+	return nil
+}
+
+// readChunk reads a line of a chunk file, the files that are recorded before the schema
+// have the JSON of tracer.Event, whose origin is a number
+func readChunk(line []byte) (*api.Chunk, error) {
+	chunk := &api.Chunk{}
+	err := protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(line, chunk)
+	if err == nil {
+		return chunk, nil
+	}
+
+	var event tracer.Event
+	if json.Unmarshal(line, &event) != nil {
+		return nil, err
+	}
+
+	chunk = server.BuildChunk(&event)
+	chunk.SchemaVersion = uint32(api.SchemaVersion_SCHEMA_VERSION_UNSPECIFIED)
+
+	return chunk, nil
+}
+
+func printChunk(out io.Writer, chunk *api.Chunk) {
+	annotations := ""
+	if len(chunk.Annotations) > 0 {
+		annotations = " annotations=" + strings.Join(chunk.Annotations, ",")
+	}
+
+	if chunk.Notice != nil {
+		fmt.Fprintf(out, "%s notice=%s stream=%d pid=%d namespace=%s destination=%s%s\n",
+			chunk.Timestamp.AsTime().Format("2006-01-02T15:04:05.000000Z07:00"), chunk.Notice.Type, chunk.StreamId, chunk.Pid, chunk.Namespace, chunk.Notice.Destination, annotations)
+		return
+	}
+
+	direction := "write"
+	if chunk.IsRead {
+		direction = "read"
+	}
+
+	fmt.Fprintf(out, "%s stream=%d pid=%d fd=%d %s -> %s %s %d bytes (%s)%s\n",
+		chunk.Timestamp.AsTime().Format("2006-01-02T15:04:05.000000Z07:00"),
+		chunk.StreamId,
+		chunk.Pid,
+		chunk.Fd,
+		net.JoinHostPort(chunk.SrcIp, strconv.Itoa(int(chunk.SrcPort))),
+		net.JoinHostPort(chunk.DstIp, strconv.Itoa(int(chunk.DstPort))),
+		direction,
+		chunk.Size,
+		chunk.Origin,
+		annotations,
+	)
+
+	if replayData {
+		fmt.Fprint(out, hex.Dump(chunk.Data))
+	}
+}
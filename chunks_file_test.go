@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/tracer/pkg/api"
+	"github.com/kubeshark/tracer/pkg/storage"
+)
+
+func TestChunksWriterRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "chunks.jsonl")
+	if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := storage.NewManager(storage.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := m.AddSink("chunks-file", dir, getChunksFilePatterns(path)...)
+
+	// Each chunk is rotated to a segment
+	writer, err := newChunksWriter(path, 1, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id := int64(1); id <= 3; id++ {
+		if err := writer.write(&api.Chunk{StreamId: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	segments, _ := filepath.Glob(filepath.Join(dir, "chunks-*.jsonl"))
+	sort.Strings(segments)
+	// The file of the previous run and the 3 chunks
+	if len(segments) != 4 {
+		t.Fatalf("got the segments %v", segments)
+	}
+
+	var out bytes.Buffer
+	for _, segment := range segments {
+		if err := replayChunks(segment, &out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 4 {
+		t.Fatalf("replayed %d chunks:\n%s", lines, out.String())
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+		t.Fatalf("got the chunks file %v, %v", info, err)
+	}
+
+	m.Prune(time.Now())
+	if metrics := m.Metrics(); metrics.Files != 5 || metrics.Sinks[0].Name != "chunks-file" {
+		t.Fatalf("got the metrics %+v", metrics)
+	}
+}
+
+func TestChunksWriterNoRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "chunks.jsonl")
+	if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := storage.NewManager(storage.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer, err := newChunksWriter(path, 0, m.AddSink("chunks-file", dir, getChunksFilePatterns(path)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.write(&api.Chunk{StreamId: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if segments, _ := filepath.Glob(filepath.Join(dir, "chunks-*.jsonl")); len(segments) != 0 {
+		t.Fatalf("got the segments %v", segments)
+	}
+}
+
+func TestGetChunksSegmentPath(t *testing.T) {
+	end := time.Date(2024, 1, 1, 12, 0, 0, 123456789, time.FixedZone("CET", 3600))
+
+	tests := map[string]string{
+		"/data/chunks.jsonl": "/data/chunks-20240101T110000.123456Z.jsonl",
+		"/data/chunks":       "/data/chunks-20240101T110000.123456Z",
+	}
+
+	for path, want := range tests {
+		if got := getChunksSegmentPath(path, end); got != want {
+			t.Errorf("got %s for %s, want %s", got, path, want)
+		}
+
+		if ok, _ := filepath.Match(getChunksFilePatterns(path)[1], filepath.Base(want)); !ok {
+			t.Errorf("the segment %s doesn't match the patterns of %s", want, path)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-errors/errors"
+	"github.com/kubeshark/tracer/misc"
+	"github.com/kubeshark/tracer/pkg/tracer"
+)
+
+// Set with -ldflags "-X main.version=<version>"
+var version = "dev"
+
+type command struct {
+	name        string
+	usage       string
+	description string
+	flags       *flag.FlagSet
+	run         func(args []string) error
+}
+
+var commands []command
+
+// The commands are set in init, the reload of the config file looks up their flags
+func init() {
+	commands = []command{
+		{"capture", "capture [flags]", "Trace the targeted processes live, the default command",
+			newCommandFlags("capture", addCaptureFlags, addServiceFlags, addDryRunFlag), runCapture},
+		{"daemon", "daemon [flags]", "Same as capture, controlled over the -control-socket by the ctl command",
+			newCommandFlags("daemon", addCaptureFlags, addServiceFlags), runDaemon},
+		{"ctl", "ctl [flags] <action> [args]", "Controls a daemon: attach <pid> [ssllib|go], detach <pid>, stats, log-level [<module> <level>], probes [<group> on|off], stop",
+			newCommandFlags("ctl", addControlSocketFlag), runCtl},
+		{"check", "check [flags]", "Validate the kernel and the environment and print the plan, with -check-attach the probes are attached and detached",
+			newCommandFlags("check", addCaptureFlags, addCheckAttachFlag), runCheck},
+		{"replay", "replay [flags] <chunks file>...", "Print the chunks recorded with capture -chunks-file",
+			newCommandFlags("replay", addReplayDataFlag), runReplay},
+		{"version", "version", "Print the version", newCommandFlags("version"), runVersion},
+	}
+
+	for i := range commands {
+		commands[i].flags.Usage = commands[i].printUsage
+	}
+}
+
+const defaultCommand = "capture"
+
+// parseCommand splits the shared flags, the subcommand and its arguments. The arguments are
+// given to the default command when they don't start with the shared flags and a command,
+// so the invocations without a subcommand keep working.
+func parseCommand(args []string) (*command, []string, error) {
+	name := defaultCommand
+	if err := rootFlags.Parse(args); err == flag.ErrHelp {
+		usage()
+		os.Exit(0)
+	} else if err == nil && rootFlags.NArg() > 0 {
+		name, args = rootFlags.Arg(0), rootFlags.Args()[1:]
+	} else if err == nil {
+		args = nil
+	}
+
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i], args, nil
+		}
+	}
+
+	return nil, args, errors.Errorf("unknown command %q", name)
+}
+
+// lookupFlag returns the flag of any command, the config file may have the flags of the
+// other commands
+func lookupFlag(name string) *flag.Flag {
+	for _, c := range commands {
+		if f := c.flags.Lookup(name); f != nil {
+			return f
+		}
+	}
+
+	return nil
+}
+
+func usage() {
+	out := os.Stderr
+	fmt.Fprintf(out, "Usage: %s [shared flags] <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-36s %s\n", c.usage, c.description)
+	}
+	fmt.Fprintf(out, "\nShared flags:\n")
+	rootFlags.SetOutput(out)
+	rootFlags.PrintDefaults()
+	fmt.Fprintf(out, "\nRun %s <command> -h for the flags of a command\n", os.Args[0])
+}
+
+func (c *command) printUsage() {
+	out := c.flags.Output()
+	fmt.Fprintf(out, "Usage: %s %s\n\n%s\n\nFlags:\n", os.Args[0], c.usage, c.description)
+	c.flags.PrintDefaults()
+}
+
+func runCapture(args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("unexpected arguments %v", args)
+	}
+
+	if dryRunFlag {
+		return runCheck(args)
+	}
+
+	initDataDir()
+	run()
+	return nil
+}
+
+func runDaemon(args []string) error {
+	if controlSocket == "" {
+		controlSocket = getDefaultControlSocket()
+	}
+
+	return runCapture(args)
+}
+
+func getDefaultControlSocket() string {
+	return filepath.Join(dataDir, "tracer.sock")
+}
+
+func runCheck(args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("unexpected arguments %v", args)
+	}
+
+	initDataDir()
+	if checkAttach {
+		return tracer.DryRunAttach(buildConfig(), nil)
+	}
+
+	return tracer.DryRun(buildConfig())
+}
+
+func initDataDir() {
+	misc.SetDataDir(dataDir)
+	misc.InitDataDir()
+}
+
+func runVersion(args []string) error {
+	fmt.Println(version)
+	return nil
+}
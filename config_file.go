@@ -0,0 +1,290 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/kubeshark/tracer/pkg/tracer"
+	"sigs.k8s.io/yaml"
+)
+
+// loadConfigFile sets the flags that are not given on the command line from a YAML or JSON
+// file, whose keys are the flag names, the flags missing in the file are reset to their
+// defaults so the file can be reloaded. The flags are changed only if the whole file is
+// valid, a reload of an invalid file keeps the previous values. The keys of the flags of the other commands are
+// ignored, so a file is shared by the commands. Lists are accepted for the comma separated
+// flags:
+//
+//	procfs: /hostproc
+//	chunks-buffer-size: 819200
+//	pids: [1234, 5678]
+//	grpc-address: ":8897"
+func loadConfigFile(path string, flags *flag.FlagSet, commandLine map[string]bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return errors.Errorf("Error parsing config file %s: %v", path, err)
+	}
+
+	// The values are set on fresh copies of the flags first
+	staged := flag.NewFlagSet(flags.Name(), flag.ContinueOnError)
+	staged.SetOutput(io.Discard)
+
+	var resetErr error
+	flags.VisitAll(func(f *flag.Flag) {
+		if commandLine[f.Name] || resetErr != nil {
+			return
+		}
+
+		value := newFlagValue(f.Value)
+		resetErr = value.Set(f.DefValue)
+		staged.Var(value, f.Name, f.Usage)
+	})
+	if resetErr != nil {
+		return errors.Wrap(resetErr, 0)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == "config" || lookupFlag(name) == nil {
+			return errors.Errorf("Unknown key %q in config file %s", name, path)
+		}
+
+		if staged.Lookup(name) == nil {
+			continue
+		}
+
+		value, err := formatConfigValue(values[name])
+		if err != nil {
+			return errors.Errorf("Invalid value of %q in config file %s: %v", name, path, err)
+		}
+
+		if err := staged.Set(name, value); err != nil {
+			return errors.Errorf("Invalid value of %q in config file %s: %v", name, path, err)
+		}
+	}
+
+	staged.VisitAll(func(f *flag.Flag) {
+		reflect.ValueOf(flags.Lookup(f.Name).Value).Elem().Set(reflect.ValueOf(f.Value).Elem())
+	})
+
+	return nil
+}
+
+// newFlagValue returns a zero value of the type of a flag, the flag values are pointers to
+// the variables they set
+func newFlagValue(value flag.Value) flag.Value {
+	return reflect.New(reflect.TypeOf(value).Elem()).Interface().(flag.Value)
+}
+
+func formatConfigValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if _, ok := item.([]interface{}); ok {
+				return "", errors.New("nested lists are not supported")
+			}
+
+			s, err := formatConfigValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", errors.Errorf("unexpected %T", value)
+	}
+}
+
+// pidList is a comma separated list of PIDs flag
+type pidList []uint32
+
+func (l *pidList) String() string {
+	items := make([]string, 0, len(*l))
+	for _, pid := range *l {
+		items = append(items, strconv.FormatUint(uint64(pid), 10))
+	}
+
+	return strings.Join(items, ",")
+}
+
+func (l *pidList) Set(value string) error {
+	pids := make(pidList, 0)
+	for _, item := range splitList(value) {
+		pid, err := strconv.ParseUint(item, 10, 32)
+		if err != nil {
+			return errors.Errorf("invalid PID %q", item)
+		}
+		pids = append(pids, uint32(pid))
+	}
+
+	*l = pids
+	return nil
+}
+
+// symbolOffsetList is a comma separated list of <path>:<symbol>=<offset>[:<return offset>...] flag
+type symbolOffsetList []tracer.SymbolOffset
+
+func (l *symbolOffsetList) String() string {
+	items := make([]string, 0, len(*l))
+	for _, o := range *l {
+		offsets := []string{fmt.Sprintf("0x%x", o.Offset)}
+		for _, offset := range o.Returns {
+			offsets = append(offsets, fmt.Sprintf("0x%x", offset))
+		}
+		items = append(items, fmt.Sprintf("%s:%s=%s", o.Path, o.Symbol, strings.Join(offsets, ":")))
+	}
+
+	return strings.Join(items, ",")
+}
+
+func (l *symbolOffsetList) Set(value string) error {
+	offsets := make(symbolOffsetList, 0)
+	for _, item := range splitList(value) {
+		offset, err := tracer.ParseSymbolOffset(item)
+		if err != nil {
+			return err
+		}
+		offsets = append(offsets, offset)
+	}
+
+	*l = offsets
+	return nil
+}
+
+// stringList is a comma separated list flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = splitList(value)
+	return nil
+}
+
+func splitList(value string) []string {
+	result := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+// dissectorPluginList is a comma separated list of <protocol>=<path> flag
+type dissectorPluginList []tracer.DissectorPlugin
+
+func (l *dissectorPluginList) String() string {
+	items := make([]string, 0, len(*l))
+	for _, plugin := range *l {
+		items = append(items, plugin.Name+"="+plugin.Path)
+	}
+
+	return strings.Join(items, ",")
+}
+
+func (l *dissectorPluginList) Set(value string) error {
+	plugins := make(dissectorPluginList, 0)
+	for _, item := range splitList(value) {
+		name, path, ok := strings.Cut(item, "=")
+		if !ok {
+			return errors.Errorf("invalid dissector plugin %q, expected <protocol>=<path>", item)
+		}
+		plugins = append(plugins, tracer.DissectorPlugin{Name: strings.TrimSpace(name), Path: strings.TrimSpace(path)})
+	}
+
+	*l = plugins
+	return nil
+}
+
+// logLevelList is a comma separated list of <module>=<level> flag
+type logLevelList map[string]string
+
+func (l *logLevelList) String() string {
+	items := make([]string, 0, len(*l))
+	for module, level := range *l {
+		items = append(items, module+"="+level)
+	}
+	sort.Strings(items)
+
+	return strings.Join(items, ",")
+}
+
+func (l *logLevelList) Set(value string) error {
+	levels := make(logLevelList)
+	for _, item := range splitList(value) {
+		module, level, ok := strings.Cut(item, "=")
+		if !ok {
+			return errors.Errorf("invalid log level %q, expected <module>=<level>", item)
+		}
+		levels[strings.TrimSpace(module)] = strings.TrimSpace(level)
+	}
+
+	*l = levels
+	return nil
+}
+
+// labelRulesFile is the path of a YAML or JSON file of label rules flag, the rules are
+// loaded when the flag is set:
+//
+//	# label-rules.yaml
+//	- label: tenant
+//	  header: X-Tenant-Id
+//	- label: customer_id
+//	  jsonPath: $.customer.id
+type labelRulesFile struct {
+	path  string
+	rules []tracer.LabelRule
+}
+
+func (f *labelRulesFile) String() string {
+	return f.path
+}
+
+func (f *labelRulesFile) Set(value string) error {
+	if value == "" {
+		f.path, f.rules = "", nil
+		return nil
+	}
+
+	data, err := os.ReadFile(value)
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	var rules []tracer.LabelRule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return errors.Errorf("Error parsing label rules %s: %v", value, err)
+	}
+
+	f.path, f.rules = value, rules
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFormatConfigValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+		err   bool
+	}{
+		{"string", "/hostproc", "/hostproc", false},
+		{"bool", true, "true", false},
+		{"integer", float64(819200), "819200", false},
+		{"fraction", 0.25, "0.25", false},
+		{"list", []interface{}{float64(1234), float64(5678)}, "1234,5678", false},
+		{"mixed list", []interface{}{"a", true, float64(1)}, "a,true,1", false},
+		{"empty list", []interface{}{}, "", false},
+		{"nested list", []interface{}{[]interface{}{"a"}}, "", true},
+		{"map", map[string]interface{}{"a": "b"}, "", true},
+		{"null", nil, "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := formatConfigValue(test.value)
+			if (err != nil) != test.err {
+				t.Fatalf("got %v, want an error %v", err, test.err)
+			}
+
+			if got != test.want {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		commandLine []string
+		procfs      string
+		pids        pidList
+		err         bool
+	}{
+		{"yaml", "procfs: /hostproc\npids: [1234, 5678]\n", nil, "/hostproc", pidList{1234, 5678}, false},
+		{"json", `{"procfs": "/hostproc", "pids": "1234"}`, nil, "/hostproc", pidList{1234}, false},
+		{"missing keys are reset", "pids: [1]\n", nil, defaults.Procfs, pidList{1}, false},
+		{"command line takes precedence", "procfs: /hostproc\n", []string{"-procfs", "/proc2"}, "/proc2", pidList{}, false},
+		{"flag of another command", "procfs: /hostproc\nreplay-data: true\n", nil, "/hostproc", pidList{}, false},
+		{"unknown key", "unknown: 1\n", nil, "", nil, true},
+		{"config key", "config: other.yaml\n", nil, "", nil, true},
+		{"invalid value", "pids: [abc]\n", nil, "", nil, true},
+		{"invalid yaml", "procfs: [\n", nil, "", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(test.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			// The defaults of the list flags are their values when they are added
+			targetPids = nil
+			flags := newCommandFlags("test", addCaptureFlags)
+			if err := flags.Parse(test.commandLine); err != nil {
+				t.Fatal(err)
+			}
+
+			commandLine := make(map[string]bool)
+			flags.Visit(func(f *flag.Flag) {
+				commandLine[f.Name] = true
+			})
+
+			err := loadConfigFile(path, flags, commandLine)
+			if (err != nil) != test.err {
+				t.Fatalf("got %v, want an error %v", err, test.err)
+			}
+			if test.err {
+				return
+			}
+
+			if procfs != test.procfs {
+				t.Fatalf("got procfs %q, want %q", procfs, test.procfs)
+			}
+
+			if !reflect.DeepEqual(targetPids, test.pids) {
+				t.Fatalf("got pids %v, want %v", targetPids, test.pids)
+			}
+
+			if replayData {
+				t.Fatal("the flag of another command was set")
+			}
+		})
+	}
+}
+
+func TestLoadConfigFileInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		// procfs is set before pids
+		{"invalid value after a valid one", "procfs: /hostproc\npids: [abc]\n"},
+		{"unknown key after a valid one", "pids: [1]\nprocfs: /hostproc\nzzz: 1\n"},
+		{"invalid label rules", "procfs: /hostproc\nlabel-rules: /missing.yaml\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(test.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			targetPids = nil
+			flags := newCommandFlags("test", addCaptureFlags)
+			if err := flags.Parse([]string{"-procfs", "/proc2", "-pids", "7"}); err != nil {
+				t.Fatal(err)
+			}
+
+			// As loaded before the reload
+			procfs, targetPids = "/proc2", pidList{7}
+
+			if err := loadConfigFile(path, flags, map[string]bool{}); err == nil {
+				t.Fatal("got no error")
+			}
+
+			if procfs != "/proc2" || !reflect.DeepEqual(targetPids, pidList{7}) {
+				t.Fatalf("got procfs %q and pids %v after the error", procfs, targetPids)
+			}
+		})
+	}
+}
+
+func TestDissectorPluginList(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  dissectorPluginList
+		err   bool
+	}{
+		{"one", "mqtt=/plugins/mqtt.wasm", dissectorPluginList{{Name: "mqtt", Path: "/plugins/mqtt.wasm"}}, false},
+		{"two", "mqtt=/a.wasm, amqp = /b.wasm", dissectorPluginList{{Name: "mqtt", Path: "/a.wasm"}, {Name: "amqp", Path: "/b.wasm"}}, false},
+		{"empty", "", dissectorPluginList{}, false},
+		{"no name", "/plugins/mqtt.wasm", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var list dissectorPluginList
+			err := list.Set(test.value)
+			if (err != nil) != test.err {
+				t.Fatalf("got %v, want an error %v", err, test.err)
+			}
+
+			if !test.err && !reflect.DeepEqual(list, test.want) {
+				t.Fatalf("got %v, want %v", list, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/go-errors/errors"
+)
+
+// The host is ignored, the requests are sent over the control socket
+const ctlBaseUrl = "http://tracer"
+
+// runCtl sends an action to the HTTP API of a daemon over its control socket and prints
+// the JSON response
+func runCtl(args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing the action, expected attach, detach, stats, log-level, probes or stop")
+	}
+
+	path := controlSocket
+	if path == "" {
+		path = getDefaultControlSocket()
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+
+	request, err := buildCtlRequest(args[0], args[1:])
+	if err != nil {
+		return err
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+	defer response.Body.Close()
+
+	if _, err := io.Copy(os.Stdout, response.Body); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return errors.Errorf("%s failed: %s", args[0], response.Status)
+	}
+
+	return nil
+}
+
+func buildCtlRequest(action string, args []string) (*http.Request, error) {
+	switch action {
+	case "attach":
+		if len(args) < 1 || len(args) > 2 {
+			return nil, errors.New("usage: attach <pid> [ssllib|go]")
+		}
+
+		pid, err := strconv.ParseUint(args[0], 10, 32)
+		if err != nil {
+			return nil, errors.Errorf("Invalid PID %q", args[0])
+		}
+
+		pidType := "ssllib"
+		if len(args) == 2 {
+			pidType = args[1]
+		}
+
+		body, err := json.Marshal(map[string]interface{}{"pid": pid, "type": pidType})
+		if err != nil {
+			return nil, errors.Wrap(err, 0)
+		}
+
+		return newCtlRequest(http.MethodPost, "/pids", body)
+	case "detach":
+		if len(args) != 1 {
+			return nil, errors.New("usage: detach <pid>")
+		}
+
+		if _, err := strconv.ParseUint(args[0], 10, 32); err != nil {
+			return nil, errors.Errorf("Invalid PID %q", args[0])
+		}
+
+		return newCtlRequest(http.MethodDelete, "/pids/"+args[0], nil)
+	case "stats":
+		return newCtlRequest(http.MethodGet, "/stats", nil)
+	case "log-level":
+		if len(args) == 0 {
+			return newCtlRequest(http.MethodGet, "/log-levels", nil)
+		}
+
+		if len(args) != 2 {
+			return nil, errors.New("usage: log-level [<module> <level>]")
+		}
+
+		body, err := json.Marshal(map[string]string{args[0]: args[1]})
+		if err != nil {
+			return nil, errors.Wrap(err, 0)
+		}
+
+		return newCtlRequest(http.MethodPut, "/log-levels", body)
+	case "probes":
+		if len(args) == 0 {
+			return newCtlRequest(http.MethodGet, "/probe-groups", nil)
+		}
+
+		if len(args) != 2 || args[1] != "on" && args[1] != "off" {
+			return nil, errors.New("usage: probes [<group> on|off]")
+		}
+
+		body, err := json.Marshal(map[string]bool{args[0]: args[1] == "on"})
+		if err != nil {
+			return nil, errors.Wrap(err, 0)
+		}
+
+		return newCtlRequest(http.MethodPut, "/probe-groups", body)
+	case "stop":
+		return newCtlRequest(http.MethodPost, "/stop", nil)
+	default:
+		return nil, errors.Errorf("Unknown action %q, expected attach, detach, stats, log-level, probes or stop", action)
+	}
+}
+
+func newCtlRequest(method string, path string, body []byte) (*http.Request, error) {
+	request, err := http.NewRequest(method, ctlBaseUrl+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	return request, nil
+}
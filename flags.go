@@ -0,0 +1,205 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/kubeshark/tracer/misc"
+	"github.com/kubeshark/tracer/pkg/report"
+	"github.com/kubeshark/tracer/pkg/tracer"
+)
+
+var defaults = tracer.DefaultConfig()
+
+// shared by the commands, accepted before and after the command
+var configFile string
+var debug bool
+var dataDir string
+
+// levels of the log modules, apart from -debug
+var logLevels logLevelList
+
+// capture
+var procfs string
+var btfPath string
+var chunksBufferSize int
+var ringBufferSize int
+var fdCacheSize int
+var offsetsCacheDir string
+var analysisWorkers int
+var analysisTimeout time.Duration
+var enrichmentHoldTime time.Duration
+var enrichmentHoldChunks int
+var maxStreams int
+var maxCpu float64
+var probeStatsInterval time.Duration
+var namespaceQuotaBytes uint64
+var namespaceQuotaTransactions uint64
+var namespaceQuotaWindow time.Duration
+var meshLeg string
+var skipNestedTls bool
+var syscallsOnly bool
+var metadataOnly bool
+var metadataInterval time.Duration
+var captureHandshakes bool
+var checkpoint bool
+var pinPath string
+var migrationPath string
+var chaosLoss float64
+var chaosReorder float64
+var chaosTruncate float64
+var chaosCorrupt float64
+
+// targets, in addition to the pods
+var targetPids pidList
+var bioCapturePids pidList
+var targetCgroups stringList
+var probeGroups stringList
+var symbolOffsets symbolOffsetList
+
+// peers whose payloads may be captured
+var payloadCidrs stringList
+var payloadNamespaces stringList
+
+var labelRules labelRulesFile
+var dissectorPlugins dissectorPluginList
+
+// api and sinks of the running tracer
+var httpAddress string
+var controlSocket string
+var grpcAddress string
+var grpcMaxSubscribers int
+var spoolDir string
+var spoolWindow time.Duration
+var chunksFile string
+var chunksFileMaxSize int64
+var reportInterval time.Duration
+var reportDir string
+var reportFormat string
+var egressBaseline string
+var egressLearningPeriod time.Duration
+var correlationRetention time.Duration
+var correlationMaxChains int
+var storageMaxBytes int64
+var storageMaxAge time.Duration
+var storageSessionBytes int64
+
+// headers whose values stitch the requests of a transaction
+var correlationHeaders stringList
+
+// of a single command
+var checkAttach bool
+var dryRunFlag bool
+var replayData bool
+
+// The flags that precede the command
+var rootFlags = newRootFlags()
+
+func newRootFlags() *flag.FlagSet {
+	fs := flag.NewFlagSet("tracer", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	addSharedFlags(fs)
+	return fs
+}
+
+// newCommandFlags returns the flags of a command, the shared flags and the given groups
+func newCommandFlags(name string, groups ...func(fs *flag.FlagSet)) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	addSharedFlags(fs)
+	for _, group := range groups {
+		group(fs)
+	}
+
+	return fs
+}
+
+func addSharedFlags(fs *flag.FlagSet) {
+	fs.StringVar(&configFile, "config", "", "YAML or JSON file whose keys are the flag names, the command line takes precedence")
+	fs.BoolVar(&debug, "debug", false, "Enable debug mode")
+	fs.StringVar(&dataDir, "data-dir", misc.GetDataDir(), "The directory of the master PCAP and the checkpoint")
+	fs.Var(&logLevels, "log-levels", fmt.Sprintf("Comma separated <module>=<level>, e.g. poller=debug, of the modules %v whose level differs from the others, also set with PUT /log-levels of the HTTP API", tracer.LogModules))
+}
+
+// addCaptureFlags adds the flags of tracer.Config, used by the commands that run or check it
+func addCaptureFlags(fs *flag.FlagSet) {
+	fs.StringVar(&procfs, "procfs", defaults.Procfs, "The procfs directory, used when mapping host volumes into a container")
+	fs.StringVar(&btfPath, "btf", "", "The BTF of the kernel, e.g. from BTFHub, for the kernels without CONFIG_DEBUG_INFO_BTF")
+	fs.IntVar(&chunksBufferSize, "chunks-buffer-size", defaults.ChunksBufferSize, "Size of the chunks perf buffer per CPU in bytes")
+	fs.IntVar(&ringBufferSize, "ring-buffer-size", defaults.RingBufferSize, "Size of the chunks ring buffer shared by the CPUs in bytes, used instead of the perf buffer on kernels 5.8+, 0 disables")
+	fs.IntVar(&fdCacheSize, "fd-cache-size", defaults.FdCacheSize, "Maximum number of the cached connection addresses")
+	fs.StringVar(&offsetsCacheDir, "offsets-cache-dir", "", "The directory to cache the analysis of the Go binaries in by their build ID, empty disables")
+	fs.IntVar(&analysisWorkers, "analysis-workers", defaults.AnalysisWorkers, "Number of the binaries analyzed in parallel when the targets are updated")
+	fs.DurationVar(&analysisTimeout, "analysis-timeout", defaults.AnalysisTimeout, "The time after which the analysis of a binary is given up, 0 disables")
+	fs.DurationVar(&enrichmentHoldTime, "enrichment-hold-time", defaults.EnrichmentHoldTime, "The chunks are held at most this long after start until the pods of the targets are known, 0 disables")
+	fs.IntVar(&enrichmentHoldChunks, "enrichment-hold-chunks", defaults.EnrichmentHoldChunks, "Maximum number of the held chunks, the oldest is released without its pod when exceeded")
+	fs.IntVar(&maxStreams, "max-streams", 0, "Maximum number of the tracked streams, the least recently active one is shed for a new stream, 0 disables")
+	fs.Float64Var(&maxCpu, "max-cpu", 0, "Sample new streams when the tracer uses more than this percentage of a CPU core, 0 disables")
+	fs.DurationVar(&probeStatsInterval, "probe-stats-interval", 0, "Interval for estimating and logging the CPU overhead of each eBPF probe, 0 disables")
+	fs.Uint64Var(&namespaceQuotaBytes, "namespace-quota-bytes", 0, "Maximum captured bytes per namespace within the quota window, the new streams of a namespace over a limit aren't captured, 0 disables")
+	fs.Uint64Var(&namespaceQuotaTransactions, "namespace-quota-transactions", 0, "Maximum captured transactions per namespace within the quota window, the HTTP/1.x requests and the streams of the other protocols, 0 disables")
+	fs.DurationVar(&namespaceQuotaWindow, "namespace-quota-window", defaults.NamespaceQuotaWindow, "The window of the namespace quota")
+	fs.StringVar(&meshLeg, "mesh-leg", defaults.MeshLeg, "The leg to capture in Istio/Linkerd meshed pods, app (app to sidecar) or sidecar (sidecar to upstream)")
+	fs.BoolVar(&skipNestedTls, "skip-nested-tls", false, "Don't write the streams whose decrypted payload is TLS again (TLS-in-TLS)")
+	fs.BoolVar(&syscallsOnly, "syscalls-only", false, "Attach only the syscall tracepoints, for the kernels without kprobes and uprobes, the payloads of TLS stay encrypted")
+	fs.BoolVar(&metadataOnly, "metadata-only", false, "Only count the bytes and messages of each connection in kernel, without capturing the payloads")
+	fs.DurationVar(&metadataInterval, "metadata-interval", defaults.MetadataInterval, "Interval for reading the connection counters in metadata mode")
+	fs.BoolVar(&captureHandshakes, "capture-handshakes", false, "Write the TLS ClientHello and ServerHello packets in the network namespaces of the targets to the master PCAP as well, of the peers that the payload policy allows, not with -metadata-only. The events and the chunks get the JA3 and the JA4 of the ClientHellos")
+	fs.BoolVar(&checkpoint, "checkpoint", defaults.Checkpoint, "Save the stream state on shutdown and resume the streams on the next start")
+	fs.StringVar(&pinPath, "pin-path", "", "The bpffs directory to pin the maps and the syscall and tcp hooks in, e.g. /sys/fs/bpf/tracer, a restarted tracer continues with them, empty disables")
+	fs.StringVar(&migrationPath, "migration-path", "", "The bpffs directory to pin the connection and target maps in, the next tracer migrates them on upgrade, empty disables")
+	fs.Float64Var(&chaosLoss, "chaos-loss", 0, "Rate (0-1) of the chunks that are dropped on purpose, for testing the consumers")
+	fs.Float64Var(&chaosReorder, "chaos-reorder", 0, "Rate (0-1) of the chunks that are reordered on purpose, for testing the consumers")
+	fs.Float64Var(&chaosTruncate, "chaos-truncate", 0, "Rate (0-1) of the chunks that are truncated on purpose, for testing the consumers")
+	fs.Float64Var(&chaosCorrupt, "chaos-corrupt", 0, "Rate (0-1) of the chunks that are corrupted on purpose, for testing the consumers")
+
+	fs.Var(&targetPids, "pids", "Comma separated PIDs to target in addition to the pods")
+	fs.Var(&bioCapturePids, "bio-capture-pids", "Comma separated targeted PIDs whose OpenSSL records are captured at BIO level instead of the plaintext, with the secrets in tls.keylog of the data directory")
+	fs.Var(&probeGroups, "probe-groups", fmt.Sprintf("Comma separated probe groups of %v to attach, all if empty, also set with PUT /probe-groups of the HTTP API", tracer.ProbeGroups))
+	fs.Var(&targetCgroups, "cgroups", "Comma separated container IDs to target the processes of, as they appear in /proc/<pid>/cgroup")
+	fs.Var(&payloadCidrs, "payload-cidrs", "Comma separated CIDRs of the peers whose payloads are captured, the other connections are metadata only unless in -payload-namespaces")
+	fs.Var(&payloadNamespaces, "payload-namespaces", "Comma separated namespaces of the targeted pods whose payloads are captured as peers, the other connections are metadata only unless in -payload-cidrs")
+	fs.Var(&labelRules, "label-rules", "YAML or JSON file of the rules that label the events by a header, a regex or a JSONPath in their payloads, e.g. a tenant")
+	fs.Var(&dissectorPlugins, "dissector-plugins", "Comma separated <protocol>=<path> of the WebAssembly modules that detect and parse the protocols that aren't built in, see the README")
+	fs.Var(&symbolOffsets, "symbol-offsets", "Comma separated <path>:<symbol>=<offset>[:<return offset>...] for the binaries whose symbols can't be discovered, the return offsets are required for Go")
+}
+
+// addServiceFlags adds the flags of the APIs and the sinks of a running tracer
+func addServiceFlags(fs *flag.FlagSet) {
+	fs.StringVar(&httpAddress, "http-address", "", "Address of the HTTP API that controls the capture and the targets at runtime, PIDs are only added through the control socket, empty disables")
+	fs.StringVar(&grpcAddress, "grpc-address", "", "Address of the gRPC server that streams the captured chunks to the subscribers, empty disables")
+	fs.IntVar(&grpcMaxSubscribers, "grpc-max-subscribers", 0, "Maximum concurrent subscribers of the gRPC server, 0 is unlimited")
+	fs.StringVar(&spoolDir, "spool-dir", "", "The directory of the spool of the captured chunks that the durable gRPC subscribers resume from after a reconnect, empty disables")
+	fs.DurationVar(&spoolWindow, "spool-window", time.Hour, "The spooled chunks are pruned this long after they were written")
+	fs.StringVar(&chunksFile, "chunks-file", "", "Record the captured chunks to this file as JSON lines for the replay command, empty disables")
+	fs.Int64Var(&chunksFileMaxSize, "chunks-file-max-size", 0, "The chunks file is rotated to a segment beside it, e.g. chunks-20240101T120000.000000Z.jsonl, when it reaches this size in bytes, 0 disables")
+	fs.DurationVar(&reportInterval, "report-interval", 0, "Write a report of the captured traffic at every multiple of this interval on the wall clock, e.g. 1h, 0 disables")
+	fs.StringVar(&reportDir, "report-dir", "", "The directory of the reports, defaults to reports under the data directory")
+	fs.StringVar(&reportFormat, "report-format", report.FormatJson, "The format of the reports, json or markdown")
+	fs.StringVar(&egressBaseline, "egress-baseline", "", "The file of the external destinations that each workload contacted, a new destination is logged and sent to the subscribers as a new-destination notice, empty disables")
+	fs.DurationVar(&egressLearningPeriod, "egress-learning-period", 0, "The new destinations are added to the egress baseline without being logged for this duration after start")
+	fs.DurationVar(&correlationRetention, "correlation-retention", 10*time.Minute, "The time the chains of the correlation IDs are kept after their last request")
+	fs.IntVar(&correlationMaxChains, "correlation-max-chains", 100000, "Maximum number of the kept chains of the correlation IDs, the least recently extended one is evicted for a new one")
+	fs.Var(&correlationHeaders, "correlation-headers", "Comma separated headers, e.g. X-Request-ID,traceparent, that stitch the HTTP/1.x requests into chains served on /correlations of the HTTP API, empty disables")
+	fs.Int64Var(&storageMaxBytes, "storage-max-bytes", 0, "Maximum total size in bytes of the files of the sinks, the reports and the chunks file segments, the oldest are pruned beyond it, 0 is unlimited")
+	fs.DurationVar(&storageMaxAge, "storage-max-age", 0, "The files of the sinks are pruned this long after they were last written, 0 is unlimited")
+	fs.Int64Var(&storageSessionBytes, "storage-session-bytes", 0, "Maximum total size in bytes of the files of the sinks that this run of the tracer wrote, its oldest are pruned beyond it, 0 is unlimited")
+	addControlSocketFlag(fs)
+}
+
+func addControlSocketFlag(fs *flag.FlagSet) {
+	fs.StringVar(&controlSocket, "control-socket", "", "Unix domain socket of the HTTP API for the ctl command, the daemon command defaults to tracer.sock in the data directory")
+}
+
+func addDryRunFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&dryRunFlag, "dry-run", false, "Same as the check command, kept for compatibility")
+}
+
+func addCheckAttachFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&checkAttach, "check-attach", false, "Attach the probes to the -pids and -cgroups targets and detach them, without capturing")
+}
+
+func addReplayDataFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&replayData, "replay-data", false, "Print the hex dump of the payload of each replayed chunk")
+}
@@ -3,38 +3,137 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	_ "net/http/pprof" // Blank import to pprof
 	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"syscall"
 	"time"
 
 	"github.com/kubeshark/tracer/misc"
+	"github.com/kubeshark/tracer/pkg/correlation"
+	"github.com/kubeshark/tracer/pkg/egress"
 	"github.com/kubeshark/tracer/pkg/kubernetes"
+	"github.com/kubeshark/tracer/pkg/report"
+	"github.com/kubeshark/tracer/pkg/server"
+	"github.com/kubeshark/tracer/pkg/storage"
+	"github.com/kubeshark/tracer/pkg/tracer"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/rest"
 )
 
-// capture
-var procfs = flag.String("procfs", "/proc", "The procfs directory, used when mapping host volumes into a container")
+// The flags given on the command line, they take precedence over the config file
+var commandLine = make(map[string]bool)
 
-// development
-var debug = flag.Bool("debug", false, "Enable debug mode")
-
-var tracer *Tracer
+// The flags of the command being run
+var commandFlags *flag.FlagSet
 
 func main() {
-	flag.Parse()
+	c, args, err := parseCommand(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		usage()
+		os.Exit(2)
+	}
+
+	// Exits on error
+	commandFlags = c.flags
+	_ = commandFlags.Parse(args)
+	for _, fs := range []*flag.FlagSet{rootFlags, commandFlags} {
+		fs.Visit(func(f *flag.Flag) {
+			commandLine[f.Name] = true
+		})
+	}
 
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339}).With().Caller().Logger()
 
-	if *debug {
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	if configFile != "" {
+		if err := loadConfigFile(configFile, commandFlags, commandLine); err != nil {
+			tracer.LogError(err)
+			os.Exit(1)
+		}
+	}
+
+	if err := setLogLevel(); err != nil {
+		tracer.LogError(err)
+		os.Exit(1)
+	}
+
+	if err := c.run(commandFlags.Args()); err != nil {
+		tracer.LogError(err)
+		os.Exit(1)
+	}
+}
+
+// setLogLevel sets the level of log.Logger, the global level is trace so the modules can
+// log below it
+func setLogLevel() error {
+	level := zerolog.InfoLevel
+	if debug {
+		level = zerolog.DebugLevel
+	}
+
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	log.Logger = log.Logger.Level(level)
+
+	// The modules missing in the flag follow log.Logger again
+	levels := make(map[string]string)
+	for _, module := range tracer.LogModules {
+		levels[module] = ""
+	}
+	for module, level := range logLevels {
+		levels[module] = level
 	}
 
-	misc.InitDataDir()
+	return tracer.SetLogLevels(levels)
+}
 
-	run()
+func buildConfig() tracer.Config {
+	config := tracer.DefaultConfig()
+	config.Procfs = procfs
+	config.BtfPath = btfPath
+	config.ChunksBufferSize = chunksBufferSize
+	config.RingBufferSize = ringBufferSize
+	config.FdCacheSize = fdCacheSize
+	config.Pids = targetPids
+	config.BioCapturePids = bioCapturePids
+	config.Cgroups = targetCgroups
+	config.ProbeGroups = probeGroups
+	config.SyscallsOnly = syscallsOnly
+	config.SymbolOffsets = symbolOffsets
+	config.PayloadCidrs = payloadCidrs
+	config.PayloadNamespaces = payloadNamespaces
+	config.LabelRules = labelRules.rules
+	config.DissectorPlugins = dissectorPlugins
+	config.OffsetsCachePath = offsetsCacheDir
+	config.AnalysisWorkers = analysisWorkers
+	config.AnalysisTimeout = analysisTimeout
+	config.EnrichmentHoldTime = enrichmentHoldTime
+	config.EnrichmentHoldChunks = enrichmentHoldChunks
+	config.MaxCpu = maxCpu
+	config.MaxStreams = maxStreams
+	config.ProbeStatsInterval = probeStatsInterval
+	config.NamespaceQuotaBytes = namespaceQuotaBytes
+	config.NamespaceQuotaTransactions = namespaceQuotaTransactions
+	config.NamespaceQuotaWindow = namespaceQuotaWindow
+	config.MeshLeg = meshLeg
+	config.SkipNestedTls = skipNestedTls
+	config.MetadataOnly = metadataOnly
+	config.MetadataInterval = metadataInterval
+	config.CaptureHandshakes = captureHandshakes
+	config.Checkpoint = checkpoint
+	config.MigrationPath = migrationPath
+	config.PinPath = pinPath
+	config.ChaosLossRate = chaosLoss
+	config.ChaosReorderRate = chaosReorder
+	config.ChaosTruncateRate = chaosTruncate
+	config.ChaosCorruptRate = chaosCorrupt
+	return config
 }
 
 func run() {
@@ -42,58 +141,347 @@ func run() {
 
 	misc.RunID = time.Now().Unix()
 
-	streamsMap := NewTcpStreamMap()
-
-	createTracer(streamsMap)
+	t, err := createTracer()
+	if err != nil {
+		tracer.LogError(err)
+		os.Exit(1)
+	}
 
-	_, err := rest.InClusterConfig()
+	_, err = rest.InClusterConfig()
 	clusterMode := err == nil
 	errOut := make(chan error, 100)
-	watcher := kubernetes.NewFromInCluster(errOut, UpdateTargets)
+	watcher := kubernetes.NewFromInCluster(errOut, func(pods []v1.Pod) error {
+		err := t.UpdateTargets(pods)
+		t.SetEnrichmentReady()
+		return err
+	})
+	if !clusterMode {
+		t.SetEnrichmentReady()
+	}
 	ctx := context.Background()
 	watcher.Start(ctx, clusterMode)
 
-	go tracer.PollForLogging()
-	tracer.Poll(streamsMap)
+	storageManager, err := storage.NewManager(storage.Config{MaxBytes: storageMaxBytes, MaxAge: storageMaxAge, SessionBytes: storageSessionBytes})
+	if err != nil {
+		tracer.LogError(err)
+		os.Exit(1)
+	}
+	storageManager.Start()
+
+	stopRecording := startRecording(t, storageManager)
+	reporter := startReporter(t, storageManager)
+	egressMonitor := startEgressMonitor(t)
+	correlator := startCorrelator(t)
+	spool := startSpool(t, storageManager)
+
+	t.Start(ctx)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	grpcServer := startGrpcServer(t, grpcAddress, spool)
+	httpServer := startHttpServer(t, httpAddress, correlator, storageManager)
+	controlServer := startControlServer(t, controlSocket, correlator, storageManager, func() {
+		signals <- syscall.SIGTERM
+	})
+
+	watchdogCtx, stopWatchdog := context.WithCancel(ctx)
+	startWatchdog(watchdogCtx, t)
+	sdNotify("READY=1")
+
+	for s := <-signals; s == syscall.SIGHUP; s = <-signals {
+		previousGrpcAddress, previousHttpAddress := grpcAddress, httpAddress
+		previousChunksFile := []interface{}{chunksFile, chunksFileMaxSize}
+		previousReport := []interface{}{reportInterval, reportDir, reportFormat}
+		previousEgress := []interface{}{egressBaseline, egressLearningPeriod}
+		previousCorrelation := []interface{}{correlationHeaders, correlationRetention, correlationMaxChains}
+		previousStorage := []interface{}{storageMaxBytes, storageMaxAge, storageSessionBytes, spoolDir, spoolWindow}
+
+		sdNotify("RELOADING=1")
+		err := reload(t)
+		sdNotify("READY=1")
+		if err != nil {
+			tracer.LogError(err)
+			continue
+		}
+
+		if grpcAddress != previousGrpcAddress {
+			if grpcServer != nil {
+				grpcServer.Stop()
+			}
+			grpcServer = startGrpcServer(t, grpcAddress, spool)
+		}
+
+		if httpAddress != previousHttpAddress {
+			if httpServer != nil {
+				httpServer.Stop()
+			}
+			httpServer = startHttpServer(t, httpAddress, correlator, storageManager)
+		}
+
+		// The sinks are restarted with the new settings, the recorded file is created again
+		if !reflect.DeepEqual(previousChunksFile, []interface{}{chunksFile, chunksFileMaxSize}) {
+			if stopRecording != nil {
+				stopRecording()
+			}
+			stopRecording = startRecording(t, storageManager)
+		}
+
+		if !reflect.DeepEqual(previousReport, []interface{}{reportInterval, reportDir, reportFormat}) {
+			if reporter != nil {
+				reporter.Stop()
+			}
+			reporter = startReporter(t, storageManager)
+		}
+
+		if !reflect.DeepEqual(previousEgress, []interface{}{egressBaseline, egressLearningPeriod}) {
+			if egressMonitor != nil {
+				egressMonitor.Stop()
+			}
+			egressMonitor = startEgressMonitor(t)
+		}
+
+		// The servers hold the correlator and its chains would be lost
+		if !reflect.DeepEqual(previousCorrelation, []interface{}{correlationHeaders, correlationRetention, correlationMaxChains}) {
+			log.Warn().Msg("The correlation settings are applied on restart only")
+		}
+
+		// The spool keeps the offsets and the cursors of the durable subscribers
+		if !reflect.DeepEqual(previousStorage, []interface{}{storageMaxBytes, storageMaxAge, storageSessionBytes, spoolDir, spoolWindow}) {
+			log.Warn().Msg("The storage and the spool settings are applied on restart only")
+		}
+	}
+
+	log.Info().Msg("Shutting down tracer...")
+	sdNotify("STOPPING=1")
+	stopWatchdog()
+
+	if httpServer != nil {
+		httpServer.Stop()
+	}
+
+	if controlServer != nil {
+		controlServer.Stop()
+	}
+
+	for _, err := range t.Stop() {
+		tracer.LogError(err)
+	}
+
+	if stopRecording != nil {
+		stopRecording()
+	}
+
+	if reporter != nil {
+		reporter.Wait()
+	}
+
+	if egressMonitor != nil {
+		egressMonitor.Wait()
+	}
+
+	if correlator != nil {
+		correlator.Wait()
+	}
+
+	if spool != nil {
+		spool.Wait()
+	}
+
+	if grpcServer != nil {
+		grpcServer.Stop()
+	}
+
+	storageManager.Stop()
+}
+
+// reload reads the config file again and applies it to the tracer, on SIGHUP
+func reload(t *tracer.Tracer) error {
+	log.Info().Msg("Reloading configuration...")
+
+	if configFile != "" {
+		if err := loadConfigFile(configFile, commandFlags, commandLine); err != nil {
+			return err
+		}
+	}
+
+	if err := setLogLevel(); err != nil {
+		return err
+	}
+
+	return t.Reload(buildConfig())
+}
+
+func startGrpcServer(t *tracer.Tracer, address string, spool *server.Spool) *server.GrpcServer {
+	if address == "" {
+		return nil
+	}
+
+	s := server.NewGrpcServer(t)
+	s.SetMaxSubscribers(grpcMaxSubscribers)
+	s.SetSpool(spool)
+	go func() {
+		if err := s.Serve(address); err != nil {
+			tracer.LogError(err)
+		}
+	}()
+
+	return s
+}
+
+// startControlServer serves the HTTP API on a Unix domain socket for the ctl command, stop
+// is called on POST /stop
+func startControlServer(t *tracer.Tracer, path string, correlator *correlation.Correlator, storageManager *storage.Manager, stop func()) *server.HttpServer {
+	if path == "" {
+		return nil
+	}
+
+	s := server.NewHttpServer(t)
+	s.OnStop(stop)
+	s.SetCorrelator(correlator)
+	s.SetStorage(storageManager)
+	go func() {
+		if err := s.ServeUnix(path); err != nil {
+			tracer.LogError(err)
+		}
+	}()
+
+	return s
+}
+
+func startHttpServer(t *tracer.Tracer, address string, correlator *correlation.Correlator, storageManager *storage.Manager) *server.HttpServer {
+	if address == "" {
+		return nil
+	}
+
+	s := server.NewHttpServer(t)
+	s.SetCorrelator(correlator)
+	s.SetStorage(storageManager)
+	go func() {
+		if err := s.Serve(address); err != nil {
+			tracer.LogError(err)
+		}
+	}()
+
+	return s
+}
+
+func startRecording(t *tracer.Tracer, storageManager *storage.Manager) func() {
+	if chunksFile == "" {
+		return nil
+	}
+
+	stop, err := recordChunks(t, chunksFile, chunksFileMaxSize, storageManager)
+	if err != nil {
+		tracer.LogError(err)
+		return nil
+	}
+
+	return stop
+}
+
+func startReporter(t *tracer.Tracer, storageManager *storage.Manager) *report.Reporter {
+	if reportInterval == 0 {
+		return nil
+	}
+
+	dir := reportDir
+	if dir == "" {
+		dir = filepath.Join(misc.GetDataDir(), "reports")
+	}
+
+	r, err := report.NewReporter(t, reportInterval, dir, reportFormat, storageManager)
+	if err == nil {
+		err = r.Start()
+	}
+	if err != nil {
+		tracer.LogError(err)
+		return nil
+	}
+
+	return r
 }
 
-func createTracer(streamsMap *TcpStreamMap) {
-	tracer = &Tracer{
-		procfs: *procfs,
+func startEgressMonitor(t *tracer.Tracer) *egress.Monitor {
+	if egressBaseline == "" {
+		return nil
+	}
+
+	m, err := egress.NewMonitor(t, procfs, egressBaseline, egressLearningPeriod)
+	if err == nil {
+		err = m.Start()
+	}
+	if err != nil {
+		tracer.LogError(err)
+		return nil
 	}
-	chunksBufferSize := os.Getpagesize() * 100
-	logBufferSize := os.Getpagesize()
 
-	if err := tracer.Init(
-		chunksBufferSize,
-		logBufferSize,
-		*procfs,
-	); err != nil {
-		LogError(err)
-		return
+	return m
+}
+
+func startSpool(t *tracer.Tracer, storageManager *storage.Manager) *server.Spool {
+	if spoolDir == "" {
+		return nil
+	}
+
+	s, err := server.NewSpool(t, spoolDir, spoolWindow, storageManager)
+	if err == nil {
+		err = s.Start()
+	}
+	if err != nil {
+		tracer.LogError(err)
+		return nil
+	}
+
+	return s
+}
+
+func startCorrelator(t *tracer.Tracer) *correlation.Correlator {
+	if len(correlationHeaders) == 0 {
+		return nil
+	}
+
+	c, err := correlation.NewCorrelator(t, correlationHeaders, correlationRetention, correlationMaxChains)
+	if err == nil {
+		err = c.Start()
+	}
+	if err != nil {
+		tracer.LogError(err)
+		return nil
+	}
+
+	return c
+}
+
+func createTracer() (*tracer.Tracer, error) {
+	t, err := tracer.New(buildConfig())
+	if err != nil {
+		return nil, err
 	}
 
 	podList := kubernetes.GetTargetedPods()
-	if err := UpdateTargets(podList); err != nil {
+	if err := t.UpdateTargets(podList); err != nil {
 		log.Error().Err(err).Send()
-		return
+		return t, nil
 	}
 
 	// A quick way to instrument libssl.so without PID filtering - used for debuging and troubleshooting
 	//
 	if os.Getenv("KUBESHARK_GLOBAL_LIBSSL_PID") != "" {
-		if err := tracer.GlobalSSLLibTarget(*procfs, os.Getenv("KUBESHARK_GLOBAL_LIBSSL_PID")); err != nil {
-			LogError(err)
-			return
+		if err := t.GlobalSSLLibTarget(procfs, os.Getenv("KUBESHARK_GLOBAL_LIBSSL_PID")); err != nil {
+			tracer.LogError(err)
+			return t, nil
 		}
 	}
 
 	// A quick way to instrument Go `crypto/tls` without PID filtering - used for debuging and troubleshooting
 	//
 	if os.Getenv("KUBESHARK_GLOBAL_GOLANG_PID") != "" {
-		if err := tracer.GlobalGoTarget(*procfs, os.Getenv("KUBESHARK_GLOBAL_GOLANG_PID")); err != nil {
-			LogError(err)
-			return
+		if err := t.GlobalGoTarget(procfs, os.Getenv("KUBESHARK_GLOBAL_GOLANG_PID")); err != nil {
+			tracer.LogError(err)
+			return t, nil
 		}
 	}
+
+	return t, nil
 }
@@ -19,6 +19,10 @@ func InitDataDir() {
 	}
 }
 
+func SetDataDir(dir string) {
+	dataDir = dir
+}
+
 func GetDataDir() string {
 	return dataDir
 }
@@ -26,3 +30,11 @@ func GetDataDir() string {
 func GetMasterPcapPath() string {
 	return fmt.Sprintf("%s/tls.pcap", GetDataDir())
 }
+
+func GetCheckpointPath() string {
+	return fmt.Sprintf("%s/streams.checkpoint.json", GetDataDir())
+}
+
+func GetKeylogPath() string {
+	return fmt.Sprintf("%s/tls.keylog", GetDataDir())
+}
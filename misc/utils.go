@@ -1,5 +1,7 @@
 package misc
 
+import "net"
+
 func Contains(s []string, str string) bool {
 	for _, v := range s {
 		if v == str {
@@ -9,3 +11,8 @@ func Contains(s []string, str string) bool {
 
 	return false
 }
+
+// IsExternalIP is false for the private, loopback, link local and unspecified addresses
+func IsExternalIP(ip net.IP) bool {
+	return ip != nil && !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsUnspecified()
+}
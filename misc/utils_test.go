@@ -0,0 +1,35 @@
+package misc
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIsExternalIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+	}
+
+	for _, test := range tests {
+		if got := IsExternalIP(net.ParseIP(test.ip)); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.ip, got, test.want)
+		}
+	}
+
+	if IsExternalIP(nil) {
+		t.Error("nil: got true, want false")
+	}
+}
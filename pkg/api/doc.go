@@ -0,0 +1,4 @@
+// Package api contains the gRPC API of the tracer, generated from tracer.proto
+package api
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative tracer.proto
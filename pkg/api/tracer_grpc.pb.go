@@ -0,0 +1,334 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.24.4
+// source: tracer.proto
+
+package api
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Tracer_Subscribe_FullMethodName     = "/tracer.Tracer/Subscribe"
+	Tracer_Pause_FullMethodName         = "/tracer.Tracer/Pause"
+	Tracer_Resume_FullMethodName        = "/tracer.Tracer/Resume"
+	Tracer_GetQuotaUsage_FullMethodName = "/tracer.Tracer/GetQuotaUsage"
+	Tracer_Ack_FullMethodName           = "/tracer.Tracer/Ack"
+	Tracer_Annotate_FullMethodName      = "/tracer.Tracer/Annotate"
+)
+
+// TracerClient is the client API for Tracer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TracerClient interface {
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Tracer_SubscribeClient, error)
+	// Stops sending the chunks in kernel, the probes stay attached
+	Pause(ctx context.Context, in *PauseRequest, opts ...grpc.CallOption) (*CaptureState, error)
+	Resume(ctx context.Context, in *ResumeRequest, opts ...grpc.CallOption) (*CaptureState, error)
+	// The usage of the namespaces in the current window of the namespace quota
+	GetQuotaUsage(ctx context.Context, in *QuotaUsageRequest, opts ...grpc.CallOption) (*QuotaUsageList, error)
+	// Acknowledges the chunks of a durable subscriber up to an offset, its next subscription
+	// resumes after it
+	Ack(ctx context.Context, in *AckRequest, opts ...grpc.CallOption) (*Cursor, error)
+	// Attaches annotations, e.g. incident-1234, to a live stream, they are set on its later
+	// chunks and sent at once in an annotation notice
+	Annotate(ctx context.Context, in *AnnotateRequest, opts ...grpc.CallOption) (*StreamAnnotations, error)
+}
+
+type tracerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTracerClient(cc grpc.ClientConnInterface) TracerClient {
+	return &tracerClient{cc}
+}
+
+func (c *tracerClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Tracer_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Tracer_ServiceDesc.Streams[0], Tracer_Subscribe_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tracerSubscribeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Tracer_SubscribeClient interface {
+	Recv() (*Chunk, error)
+	grpc.ClientStream
+}
+
+type tracerSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *tracerSubscribeClient) Recv() (*Chunk, error) {
+	m := new(Chunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *tracerClient) Pause(ctx context.Context, in *PauseRequest, opts ...grpc.CallOption) (*CaptureState, error) {
+	out := new(CaptureState)
+	err := c.cc.Invoke(ctx, Tracer_Pause_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tracerClient) Resume(ctx context.Context, in *ResumeRequest, opts ...grpc.CallOption) (*CaptureState, error) {
+	out := new(CaptureState)
+	err := c.cc.Invoke(ctx, Tracer_Resume_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tracerClient) GetQuotaUsage(ctx context.Context, in *QuotaUsageRequest, opts ...grpc.CallOption) (*QuotaUsageList, error) {
+	out := new(QuotaUsageList)
+	err := c.cc.Invoke(ctx, Tracer_GetQuotaUsage_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tracerClient) Ack(ctx context.Context, in *AckRequest, opts ...grpc.CallOption) (*Cursor, error) {
+	out := new(Cursor)
+	err := c.cc.Invoke(ctx, Tracer_Ack_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tracerClient) Annotate(ctx context.Context, in *AnnotateRequest, opts ...grpc.CallOption) (*StreamAnnotations, error) {
+	out := new(StreamAnnotations)
+	err := c.cc.Invoke(ctx, Tracer_Annotate_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TracerServer is the server API for Tracer service.
+// All implementations must embed UnimplementedTracerServer
+// for forward compatibility
+type TracerServer interface {
+	Subscribe(*SubscribeRequest, Tracer_SubscribeServer) error
+	// Stops sending the chunks in kernel, the probes stay attached
+	Pause(context.Context, *PauseRequest) (*CaptureState, error)
+	Resume(context.Context, *ResumeRequest) (*CaptureState, error)
+	// The usage of the namespaces in the current window of the namespace quota
+	GetQuotaUsage(context.Context, *QuotaUsageRequest) (*QuotaUsageList, error)
+	// Acknowledges the chunks of a durable subscriber up to an offset, its next subscription
+	// resumes after it
+	Ack(context.Context, *AckRequest) (*Cursor, error)
+	// Attaches annotations, e.g. incident-1234, to a live stream, they are set on its later
+	// chunks and sent at once in an annotation notice
+	Annotate(context.Context, *AnnotateRequest) (*StreamAnnotations, error)
+	mustEmbedUnimplementedTracerServer()
+}
+
+// UnimplementedTracerServer must be embedded to have forward compatible implementations.
+type UnimplementedTracerServer struct {
+}
+
+func (UnimplementedTracerServer) Subscribe(*SubscribeRequest, Tracer_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedTracerServer) Pause(context.Context, *PauseRequest) (*CaptureState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Pause not implemented")
+}
+func (UnimplementedTracerServer) Resume(context.Context, *ResumeRequest) (*CaptureState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Resume not implemented")
+}
+func (UnimplementedTracerServer) GetQuotaUsage(context.Context, *QuotaUsageRequest) (*QuotaUsageList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetQuotaUsage not implemented")
+}
+func (UnimplementedTracerServer) Ack(context.Context, *AckRequest) (*Cursor, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Ack not implemented")
+}
+func (UnimplementedTracerServer) Annotate(context.Context, *AnnotateRequest) (*StreamAnnotations, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Annotate not implemented")
+}
+func (UnimplementedTracerServer) mustEmbedUnimplementedTracerServer() {}
+
+// UnsafeTracerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TracerServer will
+// result in compilation errors.
+type UnsafeTracerServer interface {
+	mustEmbedUnimplementedTracerServer()
+}
+
+func RegisterTracerServer(s grpc.ServiceRegistrar, srv TracerServer) {
+	s.RegisterService(&Tracer_ServiceDesc, srv)
+}
+
+func _Tracer_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TracerServer).Subscribe(m, &tracerSubscribeServer{stream})
+}
+
+type Tracer_SubscribeServer interface {
+	Send(*Chunk) error
+	grpc.ServerStream
+}
+
+type tracerSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *tracerSubscribeServer) Send(m *Chunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Tracer_Pause_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PauseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TracerServer).Pause(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Tracer_Pause_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TracerServer).Pause(ctx, req.(*PauseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tracer_Resume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TracerServer).Resume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Tracer_Resume_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TracerServer).Resume(ctx, req.(*ResumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tracer_GetQuotaUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QuotaUsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TracerServer).GetQuotaUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Tracer_GetQuotaUsage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TracerServer).GetQuotaUsage(ctx, req.(*QuotaUsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tracer_Ack_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TracerServer).Ack(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Tracer_Ack_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TracerServer).Ack(ctx, req.(*AckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tracer_Annotate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AnnotateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TracerServer).Annotate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Tracer_Annotate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TracerServer).Annotate(ctx, req.(*AnnotateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Tracer_ServiceDesc is the grpc.ServiceDesc for Tracer service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Tracer_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "tracer.Tracer",
+	HandlerType: (*TracerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Pause",
+			Handler:    _Tracer_Pause_Handler,
+		},
+		{
+			MethodName: "Resume",
+			Handler:    _Tracer_Resume_Handler,
+		},
+		{
+			MethodName: "GetQuotaUsage",
+			Handler:    _Tracer_GetQuotaUsage_Handler,
+		},
+		{
+			MethodName: "Ack",
+			Handler:    _Tracer_Ack_Handler,
+		},
+		{
+			MethodName: "Annotate",
+			Handler:    _Tracer_Annotate_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _Tracer_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "tracer.proto",
+}
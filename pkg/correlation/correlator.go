@@ -0,0 +1,292 @@
+package correlation
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/hashicorp/golang-lru/simplelru"
+	"github.com/kubeshark/tracer/pkg/tracer"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	maxHops       = 100
+	expireTimeout = time.Minute
+)
+
+// Hop is an HTTP/1.x request that carried a correlation ID, as sent by a client or as
+// received by a server
+type Hop struct {
+	Time      time.Time `json:"time"`
+	Header    string    `json:"header"`
+	StreamId  int64     `json:"streamId"`
+	Pid       uint32    `json:"pid"`
+	Namespace string    `json:"namespace,omitempty"`
+	Workload  string    `json:"workload,omitempty"`
+	IsClient  bool      `json:"isClient"`
+	Src       string    `json:"src"`
+	Dst       string    `json:"dst"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+}
+
+// Chain is the hops of a correlation ID ordered by time
+type Chain struct {
+	Id        string    `json:"id"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Hops      []Hop     `json:"hops"`
+	// The hops after the first maxHops are counted only
+	Dropped int `json:"dropped"`
+}
+
+// Correlator stitches the requests of this node that carry the same correlation ID, e.g.
+// X-Request-ID or the trace ID of traceparent, into chains that are kept for the
+// retention after their last hop. Beyond maxChains the least recently extended chain is
+// evicted, e.g. when every request has a new ID.
+type Correlator struct {
+	tracer    *tracer.Tracer
+	headers   []string
+	retention time.Duration
+	// The *Chain of the IDs, the most recently extended first
+	chains    *simplelru.LRU
+	maxChains int
+	evicted   uint64
+	events    <-chan tracer.Event
+	done      chan struct{}
+	sync.RWMutex
+}
+
+func NewCorrelator(t *tracer.Tracer, headers []string, retention time.Duration, maxChains int) (*Correlator, error) {
+	if len(headers) == 0 {
+		return nil, errors.New("No correlation headers")
+	}
+
+	if retention <= 0 {
+		return nil, errors.Errorf("Invalid correlation retention %v", retention)
+	}
+
+	lowered := make([]string, 0, len(headers))
+	for _, header := range headers {
+		lowered = append(lowered, strings.ToLower(header))
+	}
+
+	c := &Correlator{
+		tracer:    t,
+		headers:   lowered,
+		retention: retention,
+		maxChains: maxChains,
+		done:      make(chan struct{}),
+	}
+
+	chains, err := simplelru.NewLRU(maxChains, nil)
+	if err != nil {
+		return nil, errors.Errorf("Invalid maximum correlation chains %d", maxChains)
+	}
+	c.chains = chains
+
+	return c, nil
+}
+
+// Start correlates until the tracer is stopped
+func (c *Correlator) Start() error {
+	events, err := c.tracer.Subscribe(tracer.EventFilter{Protocols: []string{tracer.ProtocolHttp1}})
+	if err != nil {
+		return err
+	}
+
+	log.Info().Strs("headers", c.headers).Dur("retention", c.retention).Msg("Starting correlator:")
+
+	c.events = events
+	go c.run(events)
+
+	return nil
+}
+
+// Wait returns when the correlator is stopped after the tracer
+func (c *Correlator) Wait() {
+	<-c.done
+}
+
+// Stop returns when the events are no longer correlated, the chains are kept
+func (c *Correlator) Stop() {
+	c.tracer.Unsubscribe(c.events)
+	<-c.done
+}
+
+// Get returns a copy of the chain of a correlation ID
+func (c *Correlator) Get(id string) (*Chain, bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	value, ok := c.chains.Peek(id)
+	if !ok {
+		return nil, false
+	}
+
+	chain := value.(*Chain)
+	result := *chain
+	result.Hops = append([]Hop(nil), chain.Hops...)
+
+	return &result, true
+}
+
+// List returns the IDs of the chains with at least minHops hops, the most recent first
+func (c *Correlator) List(minHops int) []string {
+	// The chains are extended after the lock is released
+	type listed struct {
+		id       string
+		lastSeen time.Time
+	}
+
+	c.RLock()
+	chains := make([]listed, 0, c.chains.Len())
+	for _, id := range c.chains.Keys() {
+		value, _ := c.chains.Peek(id)
+		if chain := value.(*Chain); len(chain.Hops) >= minHops {
+			chains = append(chains, listed{id: chain.Id, lastSeen: chain.LastSeen})
+		}
+	}
+	c.RUnlock()
+
+	sort.Slice(chains, func(i, j int) bool {
+		return chains[i].lastSeen.After(chains[j].lastSeen)
+	})
+
+	ids := make([]string, 0, len(chains))
+	for _, chain := range chains {
+		ids = append(ids, chain.id)
+	}
+
+	return ids
+}
+
+func (c *Correlator) run(events <-chan tracer.Event) {
+	defer close(c.done)
+
+	ticker := time.NewTicker(expireTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			c.handle(&event)
+		case now := <-ticker.C:
+			c.expire(now)
+		}
+	}
+}
+
+// handle adds the requests, the client writes them and the server reads them
+func (c *Correlator) handle(event *tracer.Event) {
+	if event.IsClient == event.IsRead {
+		return
+	}
+
+	request := event.Http
+	if request == nil || request.Method == "" {
+		return
+	}
+
+	for _, name := range c.headers {
+		value, ok := request.Headers[name]
+		if !ok {
+			continue
+		}
+
+		id := getCorrelationId(name, value)
+		if id == "" {
+			continue
+		}
+
+		c.add(id, Hop{
+			Time:      event.Timestamp,
+			Header:    name,
+			StreamId:  event.StreamId,
+			Pid:       event.Pid,
+			Namespace: event.Namespace,
+			Workload:  event.Workload,
+			IsClient:  event.IsClient,
+			Src:       net.JoinHostPort(event.SrcIP.String(), strconv.Itoa(int(event.SrcPort))),
+			Dst:       net.JoinHostPort(event.DstIP.String(), strconv.Itoa(int(event.DstPort))),
+			Method:    request.Method,
+			Path:      request.Path,
+		})
+
+		// The first header that is present identifies the request
+		return
+	}
+}
+
+func (c *Correlator) add(id string, hop Hop) {
+	c.Lock()
+	defer c.Unlock()
+
+	var chain *Chain
+	if value, ok := c.chains.Get(id); ok {
+		chain = value.(*Chain)
+	} else {
+		chain = &Chain{Id: id, FirstSeen: hop.Time, LastSeen: hop.Time}
+		if c.chains.Add(id, chain) {
+			c.evicted++
+			if c.evicted%10000 == 1 {
+				log.Warn().Int("max", c.maxChains).Uint64("evicted", c.evicted).
+					Msg("Too many correlation chains, evicting the least recently extended ones:")
+			}
+		}
+	}
+
+	// The chunks of the processes aren't read in order
+	if hop.Time.Before(chain.FirstSeen) {
+		chain.FirstSeen = hop.Time
+	}
+	if hop.Time.After(chain.LastSeen) {
+		chain.LastSeen = hop.Time
+	}
+
+	if len(chain.Hops) >= maxHops {
+		chain.Dropped++
+		return
+	}
+
+	chain.Hops = append(chain.Hops, hop)
+	if len(chain.Hops) > 1 && hop.Time.Before(chain.Hops[len(chain.Hops)-2].Time) {
+		sort.SliceStable(chain.Hops, func(i, j int) bool { return chain.Hops[i].Time.Before(chain.Hops[j].Time) })
+	}
+}
+
+func (c *Correlator) expire(now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, id := range c.chains.Keys() {
+		value, _ := c.chains.Peek(id)
+		if now.Sub(value.(*Chain).LastSeen) > c.retention {
+			c.chains.Remove(id)
+		}
+	}
+}
+
+// getCorrelationId returns the trace ID of a W3C traceparent, the value of the other headers
+func getCorrelationId(header string, value string) string {
+	if header != "traceparent" {
+		return value
+	}
+
+	// <version>-<trace ID>-<parent ID>-<flags>
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 || len(parts[1]) != 32 {
+		return ""
+	}
+
+	return parts[1]
+}
@@ -0,0 +1,118 @@
+package correlation
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/tracer/pkg/tracer"
+)
+
+func TestGetCorrelationId(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   string
+	}{
+		{"request id", "x-request-id", "f81d4fae-7dec-11d0-a765", "f81d4fae-7dec-11d0-a765"},
+		{"traceparent", "traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"traceparent of a later version", "traceparent", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"short trace id", "traceparent", "00-4bf92f35-00f067aa0ba902b7-01", ""},
+		{"missing parts", "traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736", ""},
+		{"empty", "traceparent", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := getCorrelationId(test.header, test.value); got != test.want {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCorrelatorMaxChains(t *testing.T) {
+	c, err := NewCorrelator(nil, []string{"X-Request-ID"}, time.Minute, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	c.add("a", Hop{Time: start})
+	c.add("b", Hop{Time: start.Add(time.Second)})
+	// a is extended, so b is the least recently extended one
+	c.add("a", Hop{Time: start.Add(2 * time.Second)})
+	c.add("c", Hop{Time: start.Add(3 * time.Second)})
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("b wasn't evicted")
+	}
+
+	if got := c.List(1); !reflect.DeepEqual(got, []string{"c", "a"}) {
+		t.Fatalf("got %v, want [c a]", got)
+	}
+
+	chain, ok := c.Get("a")
+	if !ok || len(chain.Hops) != 2 || !chain.LastSeen.Equal(start.Add(2*time.Second)) {
+		t.Fatalf("got chain %+v", chain)
+	}
+
+	if c.evicted != 1 {
+		t.Fatalf("got %d evicted, want 1", c.evicted)
+	}
+
+	c.expire(start.Add(2*time.Second + time.Minute + time.Millisecond))
+	if got := c.List(0); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Fatalf("got %v after the expiry, want [c]", got)
+	}
+
+	if _, err := NewCorrelator(nil, []string{"X-Request-ID"}, time.Minute, 0); err == nil {
+		t.Fatal("got no error for 0 chains")
+	}
+}
+
+// Run with -race, the chains are extended while they are listed
+func TestCorrelatorListConcurrent(t *testing.T) {
+	c, err := NewCorrelator(nil, []string{"X-Request-ID"}, time.Minute, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			c.add(strconv.Itoa(i%8), Hop{Time: start.Add(time.Duration(i) * time.Millisecond)})
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		c.List(0)
+	}
+	<-done
+
+	if got := c.List(0); len(got) != 8 || got[0] != "7" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestCorrelatorWait(t *testing.T) {
+	c, err := NewCorrelator(nil, []string{"X-Request-ID"}, time.Minute, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The channel of the subscription is closed when the tracer stops
+	events := make(chan tracer.Event)
+	go c.run(events)
+	close(events)
+
+	select {
+	case <-c.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the correlator didn't stop")
+	}
+}
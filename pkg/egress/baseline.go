@@ -0,0 +1,80 @@
+package egress
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-errors/errors"
+)
+
+// baseline is the destinations that each workload has ever contacted, with the time they
+// were first seen, persisted as JSON
+type baseline struct {
+	Workloads map[string]map[string]time.Time `json:"workloads"`
+}
+
+func newBaseline() *baseline {
+	return &baseline{Workloads: make(map[string]map[string]time.Time)}
+}
+
+func loadBaseline(path string) (*baseline, error) {
+	b := newBaseline()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, errors.Errorf("Error parsing egress baseline %s: %v", path, err)
+	}
+
+	if b.Workloads == nil {
+		b.Workloads = make(map[string]map[string]time.Time)
+	}
+
+	return b, nil
+}
+
+func (b *baseline) save(path string) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	return nil
+}
+
+// add returns true if the destination is new to the workload
+func (b *baseline) add(workload string, destination string, now time.Time) bool {
+	destinations, ok := b.Workloads[workload]
+	if !ok {
+		destinations = make(map[string]time.Time)
+		b.Workloads[workload] = destinations
+	}
+
+	if _, ok := destinations[destination]; ok {
+		return false
+	}
+
+	destinations[destination] = now
+	return true
+}
@@ -0,0 +1,184 @@
+package egress
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kubeshark/tracer/misc"
+	"github.com/kubeshark/tracer/pkg/tracer"
+	"github.com/rs/zerolog/log"
+)
+
+const saveInterval = time.Minute
+
+const hostWorkloadPrefix = "host:"
+
+// Monitor compares the external destinations of the workloads against a baseline that is
+// kept in a file. During the learning period the new destinations are added to the
+// baseline without being reported, so the first run doesn't report everything.
+type Monitor struct {
+	tracer   *tracer.Tracer
+	procfs   string
+	path     string
+	learnEnd time.Time
+	baseline *baseline
+	events   <-chan tracer.Event
+	done     chan struct{}
+}
+
+func NewMonitor(t *tracer.Tracer, procfs string, path string, learningPeriod time.Duration) (*Monitor, error) {
+	b, err := loadBaseline(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Monitor{
+		tracer:   t,
+		procfs:   procfs,
+		path:     path,
+		learnEnd: time.Now().Add(learningPeriod),
+		baseline: b,
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start monitors until the tracer is stopped
+func (m *Monitor) Start() error {
+	events, err := m.tracer.Subscribe(tracer.EventFilter{OnlyWrite: true})
+	if err != nil {
+		return err
+	}
+
+	log.Info().Str("path", m.path).Int("workloads", len(m.baseline.Workloads)).Time("learning-until", m.learnEnd).Msg("Starting egress monitor:")
+
+	m.events = events
+	go m.run(events)
+
+	return nil
+}
+
+// Wait returns when the baseline is saved after the tracer is stopped
+func (m *Monitor) Wait() {
+	<-m.done
+}
+
+// Stop returns when the baseline is saved, e.g. to start again with other settings on reload
+func (m *Monitor) Stop() {
+	m.tracer.Unsubscribe(m.events)
+	<-m.done
+}
+
+func (m *Monitor) run(events <-chan tracer.Event) {
+	defer close(m.done)
+
+	ticker := time.NewTicker(saveInterval)
+	defer ticker.Stop()
+
+	dirty := false
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				m.save(dirty)
+				return
+			}
+
+			if m.handle(&event) {
+				dirty = true
+			}
+		case <-ticker.C:
+			m.save(dirty)
+			dirty = false
+		}
+	}
+}
+
+// handle returns true if the baseline is changed
+func (m *Monitor) handle(event *tracer.Event) bool {
+	// Only the connections that are initiated by the process
+	if event.Notice != nil || !event.IsClient || !misc.IsExternalIP(event.DstIP) {
+		return false
+	}
+
+	workload := m.getWorkload(event)
+	if workload == "" {
+		return false
+	}
+
+	destinations := []string{net.JoinHostPort(event.DstIP.String(), strconv.Itoa(int(event.DstPort)))}
+	if host := getHttpHost(event.Http); host != "" {
+		destinations = append(destinations, host)
+	}
+
+	changed := false
+	for _, destination := range destinations {
+		if !m.baseline.add(workload, destination, event.Timestamp) {
+			continue
+		}
+		changed = true
+
+		if event.Timestamp.Before(m.learnEnd) {
+			continue
+		}
+
+		log.Warn().
+			Str("workload", workload).
+			Uint32("pid", event.Pid).
+			Str("destination", destination).
+			Msg("New egress destination:")
+
+		m.tracer.Publish(tracer.Event{
+			Pid:       event.Pid,
+			Namespace: event.Namespace,
+			Workload:  event.Workload,
+			Timestamp: event.Timestamp,
+			Notice:    &tracer.Notice{Type: tracer.NoticeNewDestination, Destination: destination},
+		})
+	}
+
+	return changed
+}
+
+func (m *Monitor) save(dirty bool) {
+	if !dirty {
+		return
+	}
+
+	if err := m.baseline.save(m.path); err != nil {
+		tracer.LogError(err)
+	}
+}
+
+// getWorkload is the key of the baseline, <namespace>/<controller> of a pod. The processes
+// that are not in a pod are identified by their executable, host:<path>, empty if it has
+// exited already.
+func (m *Monitor) getWorkload(event *tracer.Event) string {
+	if event.Workload != "" {
+		return event.Namespace + "/" + event.Workload
+	}
+
+	exe, err := os.Readlink(fmt.Sprintf("%s/%d/exe", m.procfs, event.Pid))
+	if err != nil {
+		return ""
+	}
+
+	return hostWorkloadPrefix + strings.TrimSuffix(exe, " (deleted)")
+}
+
+// getHttpHost returns the Host header of an HTTP/1.x request without the port
+func getHttpHost(message *tracer.HttpMessage) string {
+	if message == nil || message.Method == "" {
+		return ""
+	}
+
+	host := message.Headers["host"]
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.ToLower(host)
+}
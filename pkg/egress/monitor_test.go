@@ -0,0 +1,191 @@
+package egress
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/tracer/pkg/tracer"
+)
+
+func TestGetHttpHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		message *tracer.HttpMessage
+		want    string
+	}{
+		{"host", &tracer.HttpMessage{Method: "GET", Headers: map[string]string{"host": "API.example.com"}}, "api.example.com"},
+		{"host with port", &tracer.HttpMessage{Method: "GET", Headers: map[string]string{"host": "api.example.com:8443"}}, "api.example.com"},
+		{"ipv6 with port", &tracer.HttpMessage{Method: "GET", Headers: map[string]string{"host": "[2001:db8::1]:443"}}, "2001:db8::1"},
+		{"no host", &tracer.HttpMessage{Method: "GET", Headers: map[string]string{}}, ""},
+		{"response", &tracer.HttpMessage{Status: 200, Headers: map[string]string{"host": "api.example.com"}}, ""},
+		{"not http", nil, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := getHttpHost(test.message); got != test.want {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetWorkload(t *testing.T) {
+	procfs := t.TempDir()
+	for pid, exe := range map[int]string{10: "/usr/bin/curl", 11: "/usr/local/bin/agent (deleted)"} {
+		if err := os.MkdirAll(fmt.Sprintf("%s/%d", procfs, pid), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(exe, fmt.Sprintf("%s/%d/exe", procfs, pid)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := &Monitor{procfs: procfs}
+
+	tests := []struct {
+		name  string
+		event tracer.Event
+		want  string
+	}{
+		{"pod", tracer.Event{Pid: 10, Namespace: "shop", Workload: "cart"}, "shop/cart"},
+		{"host process", tracer.Event{Pid: 10}, "host:/usr/bin/curl"},
+		{"deleted executable", tracer.Event{Pid: 11}, "host:/usr/local/bin/agent"},
+		{"exited process", tracer.Event{Pid: 12}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := m.getWorkload(&test.event); got != test.want {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHandleNewDestination(t *testing.T) {
+	start := time.Now()
+
+	tests := []struct {
+		name         string
+		event        tracer.Event
+		known        []string
+		learning     bool
+		destinations []string
+	}{
+		{
+			"new",
+			tracer.Event{IsClient: true, DstIP: net.ParseIP("8.8.8.8"), DstPort: 443},
+			nil,
+			false,
+			[]string{"8.8.8.8:443"},
+		},
+		{
+			"new host",
+			tracer.Event{IsClient: true, DstIP: net.ParseIP("8.8.8.8"), DstPort: 443, Http: &tracer.HttpMessage{Method: "GET", Headers: map[string]string{"host": "dns.google"}}},
+			[]string{"8.8.8.8:443"},
+			false,
+			[]string{"dns.google"},
+		},
+		{
+			"known",
+			tracer.Event{IsClient: true, DstIP: net.ParseIP("8.8.8.8"), DstPort: 443},
+			[]string{"8.8.8.8:443"},
+			false,
+			nil,
+		},
+		{
+			"learning",
+			tracer.Event{IsClient: true, DstIP: net.ParseIP("8.8.8.8"), DstPort: 443},
+			nil,
+			true,
+			nil,
+		},
+		{
+			"internal",
+			tracer.Event{IsClient: true, DstIP: net.ParseIP("10.0.0.2"), DstPort: 443},
+			nil,
+			false,
+			nil,
+		},
+		{
+			"server",
+			tracer.Event{DstIP: net.ParseIP("8.8.8.8"), DstPort: 443},
+			nil,
+			false,
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tr := &tracer.Tracer{}
+			events, err := tr.Subscribe(tracer.EventFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m := &Monitor{tracer: tr, baseline: newBaseline(), learnEnd: start}
+			if test.learning {
+				m.learnEnd = start.Add(time.Hour)
+			}
+			for _, destination := range test.known {
+				m.baseline.add("shop/cart", destination, start)
+			}
+
+			test.event.Pid = 10
+			test.event.Namespace, test.event.Workload = "shop", "cart"
+			test.event.Timestamp = start.Add(time.Minute)
+			m.handle(&test.event)
+
+			var destinations []string
+			for len(events) > 0 {
+				event := <-events
+				if event.Notice == nil || event.Notice.Type != tracer.NoticeNewDestination || event.Pid != 10 || event.Workload != "cart" {
+					t.Fatalf("got the event %+v", event)
+				}
+				destinations = append(destinations, event.Notice.Destination)
+			}
+
+			if !reflect.DeepEqual(destinations, test.destinations) {
+				t.Fatalf("got the notices of %v, want %v", destinations, test.destinations)
+			}
+		})
+	}
+}
+
+func TestLoadBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+
+	b, err := loadBaseline(path)
+	if err != nil || len(b.Workloads) != 0 {
+		t.Fatalf("got %v and %v for a missing file", b, err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	b.add("shop/cart", "8.8.8.8:443", now)
+	if err := b.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(loaded, b) {
+		t.Fatalf("got %+v, want %+v", loaded, b)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadBaseline(path); err == nil {
+		t.Fatal("got no error for an invalid file")
+	}
+}
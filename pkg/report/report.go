@@ -0,0 +1,211 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kubeshark/tracer/misc"
+	"github.com/kubeshark/tracer/pkg/tracer"
+)
+
+const topEndpoints = 10
+
+// Report aggregates the chunks of a window, the endpoints are the server side of the
+// connections. The responses are counted for HTTP/1.x only, the other protocols only
+// contribute to the volumes.
+type Report struct {
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Chunks       uint64    `json:"chunks"`
+	ReadBytes    uint64    `json:"readBytes"`
+	WrittenBytes uint64    `json:"writtenBytes"`
+	Responses    uint64    `json:"responses"`
+	Errors       uint64    `json:"errors"`
+	ErrorRate    float64   `json:"errorRate"`
+	// By the number of bytes
+	TopEndpoints []*EndpointStats `json:"topEndpoints"`
+	// The public addresses that are connected to for the first time since the start
+	NewExternalDestinations []string `json:"newExternalDestinations"`
+	// The streams of the window that the consumers annotated
+	AnnotatedStreams []*AnnotatedStream `json:"annotatedStreams"`
+}
+
+type AnnotatedStream struct {
+	StreamId    int64    `json:"streamId"`
+	Annotations []string `json:"annotations"`
+}
+
+type EndpointStats struct {
+	Endpoint  string  `json:"endpoint"`
+	Streams   int     `json:"streams"`
+	Chunks    uint64  `json:"chunks"`
+	Bytes     uint64  `json:"bytes"`
+	Responses uint64  `json:"responses"`
+	Errors    uint64  `json:"errors"`
+	ErrorRate float64 `json:"errorRate"`
+
+	streams map[int64]bool
+}
+
+// window collects the events until the report is built
+type window struct {
+	report       Report
+	endpoints    map[string]*EndpointStats
+	destinations map[string]bool
+	annotations  map[int64][]string
+}
+
+func newWindow(start time.Time) *window {
+	return &window{
+		report:       Report{Start: start},
+		endpoints:    make(map[string]*EndpointStats),
+		destinations: make(map[string]bool),
+		annotations:  make(map[int64][]string),
+	}
+}
+
+func (w *window) add(event *tracer.Event) {
+	// The latest annotations of the stream have all of them
+	if len(event.Annotations) > 0 {
+		w.annotations[event.StreamId] = event.Annotations
+	}
+
+	if event.Notice != nil {
+		return
+	}
+
+	w.report.Chunks++
+	if event.IsRead {
+		w.report.ReadBytes += uint64(event.Size)
+	} else {
+		w.report.WrittenBytes += uint64(event.Size)
+	}
+
+	serverIP, serverPort := event.SrcIP, event.SrcPort
+	if event.IsClient {
+		serverIP, serverPort = event.DstIP, event.DstPort
+	}
+	endpoint := net.JoinHostPort(serverIP.String(), strconv.Itoa(int(serverPort)))
+
+	stats, ok := w.endpoints[endpoint]
+	if !ok {
+		stats = &EndpointStats{Endpoint: endpoint, streams: make(map[int64]bool)}
+		w.endpoints[endpoint] = stats
+	}
+
+	stats.streams[event.StreamId] = true
+	stats.Chunks++
+	stats.Bytes += uint64(event.Size)
+
+	// The process reads the response as a client and writes it as a server
+	if event.IsClient == event.IsRead {
+		if event.Http != nil && event.Http.Status != 0 {
+			stats.Responses++
+			w.report.Responses++
+			if event.Http.Status >= 400 {
+				stats.Errors++
+				w.report.Errors++
+			}
+		}
+	}
+
+	if event.IsClient && misc.IsExternalIP(serverIP) {
+		w.destinations[endpoint] = true
+	}
+}
+
+// build completes the report, the destinations that are in known are not new, the new
+// ones are added to known
+func (w *window) build(end time.Time, known map[string]bool) *Report {
+	report := w.report
+	report.End = end
+	report.ErrorRate = errorRate(report.Errors, report.Responses)
+
+	report.TopEndpoints = make([]*EndpointStats, 0, len(w.endpoints))
+	for _, stats := range w.endpoints {
+		stats.Streams = len(stats.streams)
+		stats.ErrorRate = errorRate(stats.Errors, stats.Responses)
+		report.TopEndpoints = append(report.TopEndpoints, stats)
+	}
+	sort.Slice(report.TopEndpoints, func(i, j int) bool {
+		a, b := report.TopEndpoints[i], report.TopEndpoints[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return a.Endpoint < b.Endpoint
+	})
+	if len(report.TopEndpoints) > topEndpoints {
+		report.TopEndpoints = report.TopEndpoints[:topEndpoints]
+	}
+
+	report.NewExternalDestinations = make([]string, 0)
+	for destination := range w.destinations {
+		if !known[destination] {
+			known[destination] = true
+			report.NewExternalDestinations = append(report.NewExternalDestinations, destination)
+		}
+	}
+	sort.Strings(report.NewExternalDestinations)
+
+	report.AnnotatedStreams = make([]*AnnotatedStream, 0, len(w.annotations))
+	for id, annotations := range w.annotations {
+		report.AnnotatedStreams = append(report.AnnotatedStreams, &AnnotatedStream{StreamId: id, Annotations: annotations})
+	}
+	sort.Slice(report.AnnotatedStreams, func(i, j int) bool {
+		return report.AnnotatedStreams[i].StreamId < report.AnnotatedStreams[j].StreamId
+	})
+
+	return &report
+}
+
+func errorRate(errors uint64, responses uint64) float64 {
+	if responses == 0 {
+		return 0
+	}
+
+	return float64(errors) / float64(responses)
+}
+
+func (r *Report) markdown() []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "# Traffic report %s - %s\n\n", r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339))
+	fmt.Fprintf(&buf, "| Chunks | Read bytes | Written bytes | Responses | Errors | Error rate |\n")
+	fmt.Fprintf(&buf, "|---|---|---|---|---|---|\n")
+	fmt.Fprintf(&buf, "| %d | %d | %d | %d | %d | %.2f%% |\n\n", r.Chunks, r.ReadBytes, r.WrittenBytes, r.Responses, r.Errors, r.ErrorRate*100)
+
+	fmt.Fprintf(&buf, "## Top endpoints\n\n")
+	if len(r.TopEndpoints) == 0 {
+		fmt.Fprintf(&buf, "None\n\n")
+	} else {
+		fmt.Fprintf(&buf, "| Endpoint | Streams | Chunks | Bytes | Responses | Errors | Error rate |\n")
+		fmt.Fprintf(&buf, "|---|---|---|---|---|---|---|\n")
+		for _, e := range r.TopEndpoints {
+			fmt.Fprintf(&buf, "| %s | %d | %d | %d | %d | %d | %.2f%% |\n", e.Endpoint, e.Streams, e.Chunks, e.Bytes, e.Responses, e.Errors, e.ErrorRate*100)
+		}
+		fmt.Fprintf(&buf, "\n")
+	}
+
+	fmt.Fprintf(&buf, "## New external destinations\n\n")
+	if len(r.NewExternalDestinations) == 0 {
+		fmt.Fprintf(&buf, "None\n")
+	}
+	for _, destination := range r.NewExternalDestinations {
+		fmt.Fprintf(&buf, "- %s\n", destination)
+	}
+
+	fmt.Fprintf(&buf, "\n## Annotated streams\n\n")
+	if len(r.AnnotatedStreams) == 0 {
+		fmt.Fprintf(&buf, "None\n")
+	}
+	for _, stream := range r.AnnotatedStreams {
+		fmt.Fprintf(&buf, "- %d: %s\n", stream.StreamId, strings.Join(stream.Annotations, ", "))
+	}
+
+	return buf.Bytes()
+}
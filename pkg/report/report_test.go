@@ -0,0 +1,273 @@
+package report
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/tracer/pkg/tracer"
+)
+
+func newTestEvent(streamId int64, dstIp string, isRead bool, size uint32, status int) tracer.Event {
+	event := tracer.Event{
+		StreamId: streamId,
+		SrcIP:    net.ParseIP("10.0.0.1"),
+		SrcPort:  40000,
+		DstIP:    net.ParseIP(dstIp),
+		DstPort:  443,
+		IsClient: true,
+		IsRead:   isRead,
+		Size:     size,
+	}
+
+	if status != 0 {
+		event.Http = &tracer.HttpMessage{Status: status}
+	}
+
+	return event
+}
+
+func TestWindow(t *testing.T) {
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		events       []tracer.Event
+		known        map[string]bool
+		report       Report
+		endpoints    []EndpointStats
+		destinations []string
+	}{
+		{
+			"empty",
+			nil,
+			map[string]bool{},
+			Report{},
+			[]EndpointStats{},
+			[]string{},
+		},
+		{
+			"responses",
+			[]tracer.Event{
+				newTestEvent(1, "10.0.0.2", false, 100, 0),
+				newTestEvent(1, "10.0.0.2", true, 300, 200),
+				newTestEvent(2, "10.0.0.2", true, 50, 503),
+			},
+			map[string]bool{},
+			Report{Chunks: 3, ReadBytes: 350, WrittenBytes: 100, Responses: 2, Errors: 1, ErrorRate: 0.5},
+			[]EndpointStats{{Endpoint: "10.0.0.2:443", Streams: 2, Chunks: 3, Bytes: 450, Responses: 2, Errors: 1, ErrorRate: 0.5}},
+			[]string{},
+		},
+		{
+			// A status in a request isn't a response
+			"written status",
+			[]tracer.Event{newTestEvent(1, "10.0.0.2", false, 10, 500)},
+			map[string]bool{},
+			Report{Chunks: 1, WrittenBytes: 10},
+			[]EndpointStats{{Endpoint: "10.0.0.2:443", Streams: 1, Chunks: 1, Bytes: 10}},
+			[]string{},
+		},
+		{
+			"top endpoints",
+			[]tracer.Event{
+				newTestEvent(1, "10.0.0.2", false, 10, 0),
+				newTestEvent(2, "10.0.0.3", false, 20, 0),
+			},
+			map[string]bool{},
+			Report{Chunks: 2, WrittenBytes: 30},
+			[]EndpointStats{
+				{Endpoint: "10.0.0.3:443", Streams: 1, Chunks: 1, Bytes: 20},
+				{Endpoint: "10.0.0.2:443", Streams: 1, Chunks: 1, Bytes: 10},
+			},
+			[]string{},
+		},
+		{
+			"new destinations",
+			[]tracer.Event{
+				newTestEvent(1, "8.8.8.8", false, 10, 0),
+				newTestEvent(2, "1.1.1.1", false, 10, 0),
+			},
+			map[string]bool{"1.1.1.1:443": true},
+			Report{Chunks: 2, WrittenBytes: 20},
+			[]EndpointStats{
+				{Endpoint: "1.1.1.1:443", Streams: 1, Chunks: 1, Bytes: 10},
+				{Endpoint: "8.8.8.8:443", Streams: 1, Chunks: 1, Bytes: 10},
+			},
+			[]string{"8.8.8.8:443"},
+		},
+		{
+			"notice",
+			[]tracer.Event{{Namespace: "shop", Notice: &tracer.Notice{Type: tracer.NoticeQuotaExceeded}}},
+			map[string]bool{},
+			Report{},
+			[]EndpointStats{},
+			[]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := newWindow(start)
+			for i := range test.events {
+				w.add(&test.events[i])
+			}
+
+			end := start.Add(time.Hour)
+			report := w.build(end, test.known)
+
+			if !report.Start.Equal(start) || !report.End.Equal(end) {
+				t.Errorf("got the window %v - %v", report.Start, report.End)
+			}
+
+			counts := Report{
+				Chunks:       report.Chunks,
+				ReadBytes:    report.ReadBytes,
+				WrittenBytes: report.WrittenBytes,
+				Responses:    report.Responses,
+				Errors:       report.Errors,
+				ErrorRate:    report.ErrorRate,
+			}
+			if !reflect.DeepEqual(counts, test.report) {
+				t.Errorf("got %+v, want %+v", counts, test.report)
+			}
+
+			endpoints := make([]EndpointStats, len(report.TopEndpoints))
+			for i, stats := range report.TopEndpoints {
+				endpoints[i] = *stats
+				endpoints[i].streams = nil
+			}
+			if !reflect.DeepEqual(endpoints, test.endpoints) {
+				t.Errorf("got the endpoints %+v, want %+v", endpoints, test.endpoints)
+			}
+
+			if !reflect.DeepEqual(report.NewExternalDestinations, test.destinations) {
+				t.Errorf("got the destinations %v, want %v", report.NewExternalDestinations, test.destinations)
+			}
+
+			for _, destination := range test.destinations {
+				if !test.known[destination] {
+					t.Errorf("%s isn't known after the report", destination)
+				}
+			}
+		})
+	}
+}
+
+func TestWindowTopEndpointsLimit(t *testing.T) {
+	w := newWindow(time.Now())
+	for i := 0; i < topEndpoints+5; i++ {
+		event := newTestEvent(int64(i), net.IPv4(10, 0, 1, byte(i)).String(), false, uint32(i+1), 0)
+		w.add(&event)
+	}
+
+	report := w.build(time.Now(), map[string]bool{})
+	if len(report.TopEndpoints) != topEndpoints || report.TopEndpoints[0].Bytes != topEndpoints+5 {
+		t.Fatalf("got %d endpoints, the first with %d bytes", len(report.TopEndpoints), report.TopEndpoints[0].Bytes)
+	}
+}
+
+func TestUntilNext(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		now      time.Time
+		want     time.Duration
+	}{
+		{"hour", time.Hour, time.Date(2026, 1, 1, 10, 15, 0, 0, time.UTC), 45 * time.Minute},
+		{"on the hour", time.Hour, time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC), time.Hour},
+		{"minutes", 15 * time.Minute, time.Date(2026, 1, 1, 10, 20, 30, 0, time.UTC), 9*time.Minute + 30*time.Second},
+		{"day", 24 * time.Hour, time.Date(2026, 1, 1, 18, 0, 0, 0, time.UTC), 6 * time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &Reporter{interval: test.interval}
+			if got := r.untilNext(test.now); got != test.want {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWindowAnnotations(t *testing.T) {
+	w := newWindow(time.Now())
+
+	first := newTestEvent(1, "10.0.0.2", false, 100, 0)
+	w.add(&first)
+
+	annotated := newTestEvent(1, "10.0.0.2", false, 0, 0)
+	annotated.Annotations = []string{"incident-1234"}
+	annotated.Notice = &tracer.Notice{Type: tracer.NoticeAnnotation}
+	w.add(&annotated)
+
+	later := newTestEvent(1, "10.0.0.2", true, 100, 0)
+	later.Annotations = []string{"incident-1234", "reviewed"}
+	w.add(&later)
+
+	report := w.build(time.Now(), map[string]bool{})
+	if report.Chunks != 2 {
+		t.Errorf("got %d chunks, the notice is counted", report.Chunks)
+	}
+
+	want := []*AnnotatedStream{{StreamId: 1, Annotations: []string{"incident-1234", "reviewed"}}}
+	if !reflect.DeepEqual(report.AnnotatedStreams, want) {
+		t.Fatalf("got the annotated streams %v, want %v", report.AnnotatedStreams, want)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		report   Report
+		contains []string
+	}{
+		{
+			"empty",
+			Report{Start: start, End: start.Add(time.Hour)},
+			[]string{
+				"# Traffic report 2026-01-01T10:00:00Z - 2026-01-01T11:00:00Z\n",
+				"| 0 | 0 | 0 | 0 | 0 | 0.00% |\n",
+				"## Top endpoints\n\nNone\n",
+				"## New external destinations\n\nNone\n",
+				"## Annotated streams\n\nNone\n",
+			},
+		},
+		{
+			"endpoints",
+			Report{
+				Start:                   start,
+				End:                     start.Add(time.Hour),
+				Chunks:                  3,
+				ReadBytes:               350,
+				WrittenBytes:            100,
+				Responses:               2,
+				Errors:                  1,
+				ErrorRate:               0.5,
+				TopEndpoints:            []*EndpointStats{{Endpoint: "10.0.0.2:443", Streams: 2, Chunks: 3, Bytes: 450, Responses: 2, Errors: 1, ErrorRate: 0.5}},
+				NewExternalDestinations: []string{"8.8.8.8:443"},
+				AnnotatedStreams:        []*AnnotatedStream{{StreamId: 7, Annotations: []string{"incident-1234", "reviewed"}}},
+			},
+			[]string{
+				"## Annotated streams\n\n- 7: incident-1234, reviewed\n",
+				"| 3 | 350 | 100 | 2 | 1 | 50.00% |\n",
+				"| 10.0.0.2:443 | 2 | 3 | 450 | 2 | 1 | 50.00% |\n",
+				"## New external destinations\n\n- 8.8.8.8:443\n",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			markdown := string(test.report.markdown())
+			for _, s := range test.contains {
+				if !strings.Contains(markdown, s) {
+					t.Errorf("%q isn't in:\n%s", s, markdown)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/kubeshark/tracer/pkg/storage"
+	"github.com/kubeshark/tracer/pkg/tracer"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	FormatJson     = "json"
+	FormatMarkdown = "markdown"
+)
+
+// Reporter writes a report of the captured chunks to a directory at every multiple of the
+// interval on the wall clock, e.g. at the start of every hour, and a partial report when
+// the tracer stops. The reports are pruned by the storage manager.
+type Reporter struct {
+	tracer   *tracer.Tracer
+	interval time.Duration
+	dir      string
+	format   string
+	sink     *storage.Sink
+	// The external destinations that were reported already
+	known  map[string]bool
+	events <-chan tracer.Event
+	done   chan struct{}
+}
+
+func NewReporter(t *tracer.Tracer, interval time.Duration, dir string, format string, storageManager *storage.Manager) (*Reporter, error) {
+	if interval <= 0 {
+		return nil, errors.Errorf("Invalid report interval %v", interval)
+	}
+
+	if format != FormatJson && format != FormatMarkdown {
+		return nil, errors.Errorf("Invalid report format %q, expected %q or %q", format, FormatJson, FormatMarkdown)
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	return &Reporter{
+		tracer:   t,
+		interval: interval,
+		dir:      dir,
+		format:   format,
+		sink:     storageManager.AddSink("reports", dir, "report-*.json", "report-*.md"),
+		known:    make(map[string]bool),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start reports until the tracer is stopped
+func (r *Reporter) Start() error {
+	events, err := r.tracer.Subscribe(tracer.EventFilter{})
+	if err != nil {
+		return err
+	}
+
+	log.Info().Str("dir", r.dir).Dur("interval", r.interval).Str("format", r.format).Msg("Starting reporter:")
+
+	r.events = events
+	go r.run(events)
+
+	return nil
+}
+
+// Wait returns when the last report is written after the tracer is stopped
+func (r *Reporter) Wait() {
+	<-r.done
+}
+
+// Stop returns when the partial report is written, e.g. to start again with other settings on reload
+func (r *Reporter) Stop() {
+	r.tracer.Unsubscribe(r.events)
+	<-r.done
+}
+
+func (r *Reporter) run(events <-chan tracer.Event) {
+	defer close(r.done)
+
+	current := newWindow(time.Now())
+	timer := time.NewTimer(r.untilNext(current.report.Start))
+	defer timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				r.write(current.build(time.Now(), r.known))
+				return
+			}
+
+			current.add(&event)
+		case now := <-timer.C:
+			r.write(current.build(now, r.known))
+			current = newWindow(now)
+			timer.Reset(r.untilNext(now))
+		}
+	}
+}
+
+// untilNext is the duration until the next multiple of the interval, aligned to the wall
+// clock in UTC
+func (r *Reporter) untilNext(now time.Time) time.Duration {
+	return now.Truncate(r.interval).Add(r.interval).Sub(now)
+}
+
+func (r *Reporter) write(report *Report) {
+	var data []byte
+	var ext string
+	switch r.format {
+	case FormatMarkdown:
+		data, ext = report.markdown(), ".md"
+	default:
+		var err error
+		if data, err = json.MarshalIndent(report, "", "  "); err != nil {
+			tracer.LogError(errors.Wrap(err, 0))
+			return
+		}
+		ext = ".json"
+	}
+
+	path := filepath.Join(r.dir, "report-"+report.End.UTC().Format("20060102T150405Z")+ext)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		tracer.LogError(errors.Wrap(err, 0))
+		return
+	}
+	r.sink.Add(path)
+
+	log.Info().Str("path", path).Uint64("chunks", report.Chunks).Msg("Wrote report:")
+}
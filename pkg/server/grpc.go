@@ -0,0 +1,321 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/kubeshark/tracer/pkg/api"
+	"github.com/kubeshark/tracer/pkg/tracer"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The domain of the ErrorInfo details
+const errorDomain = "tracer.kubeshark.co"
+
+// The delay that the clients are told to retry a subscription after when the limit of
+// the subscribers is reached
+const subscribersRetryDelay = 5 * time.Second
+
+// GrpcServer streams the captured chunks to the subscribers over the network,
+// each subscriber with its own filter. The server reflection is registered, so the
+// clients like grpcurl don't need the proto file, and the errors have the details of
+// google.rpc, e.g. BadRequest for an invalid filter. With the spool the subscribers that
+// have an ID are durable, they resume after their acknowledged chunks.
+type GrpcServer struct {
+	api.UnimplementedTracerServer
+	tracer         *tracer.Tracer
+	server         *grpc.Server
+	spool          *Spool
+	maxSubscribers int32
+	subscribers    atomic.Int32
+}
+
+func NewGrpcServer(t *tracer.Tracer) *GrpcServer {
+	s := &GrpcServer{
+		tracer: t,
+		server: grpc.NewServer(),
+	}
+
+	api.RegisterTracerServer(s.server, s)
+	reflection.Register(s.server)
+
+	return s
+}
+
+// SetMaxSubscribers limits the concurrent subscriptions, 0 is unlimited
+func (s *GrpcServer) SetMaxSubscribers(max int) {
+	s.maxSubscribers = int32(max)
+}
+
+// SetSpool enables the durable subscribers
+func (s *GrpcServer) SetSpool(spool *Spool) {
+	s.spool = spool
+}
+
+// Serve blocks until Stop is called
+func (s *GrpcServer) Serve(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	log.Info().Str("address", address).Msg("Starting gRPC server:")
+
+	return s.server.Serve(listener)
+}
+
+func (s *GrpcServer) Stop() {
+	s.server.Stop()
+}
+
+func (s *GrpcServer) Subscribe(request *api.SubscribeRequest, stream api.Tracer_SubscribeServer) error {
+	filter, err := s.buildEventFilter(request)
+	if err != nil {
+		return err
+	}
+
+	if subscribers := s.subscribers.Add(1); s.maxSubscribers > 0 && subscribers > s.maxSubscribers {
+		s.subscribers.Add(-1)
+		return quotaExceeded(s.maxSubscribers)
+	}
+	defer s.subscribers.Add(-1)
+
+	if request.SubscriberId != "" {
+		return s.subscribeDurable(request.SubscriberId, filter, stream)
+	}
+
+	events, err := s.tracer.Subscribe(filter)
+	if err != nil {
+		return errorWithInfo(codes.Unavailable, "TRACER_STOPPED", err)
+	}
+	defer s.tracer.Unsubscribe(events)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+
+			if err := stream.Send(BuildChunk(&event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *GrpcServer) subscribeDurable(id string, filter tracer.EventFilter, stream api.Tracer_SubscribeServer) error {
+	if s.spool == nil {
+		return errorWithInfo(codes.FailedPrecondition, "SPOOL_DISABLED", errors.New("The durable subscribers need the spool"))
+	}
+
+	offset, err := s.spool.Connect(id)
+	if err != nil {
+		return errorWithInfo(codes.AlreadyExists, "SUBSCRIBER_CONNECTED", err)
+	}
+	defer s.spool.Disconnect(id)
+
+	log.Info().Str("subscriber", id).Uint64("offset", offset).Msg("Resuming durable subscriber:")
+
+	if err := s.spool.Follow(stream.Context(), offset, filter, stream.Send); err != nil {
+		if _, ok := status.FromError(err); !ok {
+			return errorWithInfo(codes.Internal, "SPOOL_FAILED", err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *GrpcServer) Ack(ctx context.Context, request *api.AckRequest) (*api.Cursor, error) {
+	if s.spool == nil {
+		return nil, errorWithInfo(codes.FailedPrecondition, "SPOOL_DISABLED", errors.New("The durable subscribers need the spool"))
+	}
+
+	acked, err := s.spool.Ack(request.SubscriberId, request.Offset)
+	if errors.Is(err, ErrUnknownSubscriber) {
+		return nil, errorWithInfo(codes.NotFound, "UNKNOWN_SUBSCRIBER", err)
+	}
+	if errors.Is(err, ErrInvalidOffset) {
+		return nil, invalidArgument("offset", err.Error())
+	}
+
+	return &api.Cursor{SubscriberId: request.SubscriberId, AckedOffset: acked}, nil
+}
+
+func (s *GrpcServer) Annotate(ctx context.Context, request *api.AnnotateRequest) (*api.StreamAnnotations, error) {
+	annotations, err := s.tracer.Annotate(request.StreamId, request.Annotations)
+	if errors.Is(err, tracer.ErrUnknownStream) {
+		return nil, errorWithInfo(codes.NotFound, "UNKNOWN_STREAM", err)
+	}
+	if err != nil {
+		return nil, invalidArgument("annotations", err.Error())
+	}
+
+	return &api.StreamAnnotations{StreamId: request.StreamId, Annotations: annotations}, nil
+}
+
+func (s *GrpcServer) Pause(ctx context.Context, request *api.PauseRequest) (*api.CaptureState, error) {
+	if err := s.tracer.Pause(); err != nil {
+		return nil, errorWithInfo(codes.Internal, "PAUSE_FAILED", err)
+	}
+
+	return &api.CaptureState{Paused: true}, nil
+}
+
+func (s *GrpcServer) Resume(ctx context.Context, request *api.ResumeRequest) (*api.CaptureState, error) {
+	if err := s.tracer.Resume(); err != nil {
+		return nil, errorWithInfo(codes.Internal, "RESUME_FAILED", err)
+	}
+
+	return &api.CaptureState{Paused: false}, nil
+}
+
+func (s *GrpcServer) GetQuotaUsage(ctx context.Context, request *api.QuotaUsageRequest) (*api.QuotaUsageList, error) {
+	usages := s.tracer.QuotaUsage()
+
+	list := &api.QuotaUsageList{Usages: make([]*api.QuotaUsage, len(usages))}
+	for i := range usages {
+		list.Usages[i] = buildQuotaUsage(&usages[i])
+	}
+
+	return list, nil
+}
+
+func (s *GrpcServer) buildEventFilter(request *api.SubscribeRequest) (tracer.EventFilter, error) {
+	filter := tracer.EventFilter{
+		Pids:      request.Pids,
+		Protocols: request.Protocols,
+		OnlyRead:  request.Direction == api.Direction_DIRECTION_READ,
+		OnlyWrite: request.Direction == api.Direction_DIRECTION_WRITE,
+	}
+
+	for _, protocol := range request.Protocols {
+		if !s.tracer.IsKnownProtocol(protocol) {
+			return filter, invalidArgument("protocols", fmt.Sprintf("Unknown protocol %q", protocol))
+		}
+	}
+
+	for _, address := range request.Ips {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return filter, invalidArgument("ips", fmt.Sprintf("Invalid IP address %q", address))
+		}
+
+		filter.IPs = append(filter.IPs, ip)
+	}
+
+	for _, port := range request.Ports {
+		if port > 0xffff {
+			return filter, invalidArgument("ports", fmt.Sprintf("Invalid port %d", port))
+		}
+
+		filter.Ports = append(filter.Ports, uint16(port))
+	}
+
+	return filter, nil
+}
+
+func invalidArgument(field string, description string) error {
+	return withDetails(status.New(codes.InvalidArgument, description), &errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: field, Description: description}},
+	})
+}
+
+func quotaExceeded(max int32) error {
+	description := fmt.Sprintf("The limit of %d subscribers is reached", max)
+	return withDetails(status.New(codes.ResourceExhausted, description),
+		&errdetails.QuotaFailure{
+			Violations: []*errdetails.QuotaFailure_Violation{{Subject: "subscribers", Description: description}},
+		},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(subscribersRetryDelay)},
+	)
+}
+
+func errorWithInfo(code codes.Code, reason string, err error) error {
+	return withDetails(status.New(code, err.Error()), &errdetails.ErrorInfo{Reason: reason, Domain: errorDomain})
+}
+
+// withDetails returns the status without the details if they can't be added
+func withDetails(st *status.Status, details ...protoiface.MessageV1) error {
+	detailed, err := st.WithDetails(details...)
+	if err != nil {
+		tracer.LogError(errors.Wrap(err, 0))
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+// BuildChunk converts an event to the versioned schema of the API
+func BuildChunk(event *tracer.Event) *api.Chunk {
+	chunk := &api.Chunk{
+		SchemaVersion: uint32(api.SchemaVersion_SCHEMA_VERSION),
+		StreamId:      event.StreamId,
+		Pid:           event.Pid,
+		Fd:            event.Fd,
+		SrcIp:         event.SrcIP.String(),
+		SrcPort:       uint32(event.SrcPort),
+		DstIp:         event.DstIP.String(),
+		DstPort:       uint32(event.DstPort),
+		IsClient:      event.IsClient,
+		IsRead:        event.IsRead,
+		IsUdp:         event.IsUdp,
+		Shed:          event.Shed,
+		Data:          event.Data,
+		Size:          event.Size,
+		Timestamp:     timestamppb.New(event.Timestamp),
+		Origin:        event.Origin.String(),
+		Protocol:      event.Protocol,
+		Namespace:     event.Namespace,
+		Workload:      event.Workload,
+		MeshLeg:       event.MeshLeg,
+		Labels:        event.Labels,
+		Fields:        event.Fields,
+		Ja3:           event.Ja3,
+		Ja4:           event.Ja4,
+		Annotations:   event.Annotations,
+	}
+
+	if event.Http != nil {
+		chunk.Http = &api.HttpMessage{
+			Method:  event.Http.Method,
+			Path:    event.Http.Path,
+			Status:  uint32(event.Http.Status),
+			Headers: event.Http.Headers,
+		}
+	}
+
+	if event.Notice != nil {
+		chunk.Notice = &api.Notice{Type: event.Notice.Type, Destination: event.Notice.Destination}
+		if event.Notice.Quota != nil {
+			chunk.Notice.Quota = buildQuotaUsage(event.Notice.Quota)
+		}
+	}
+
+	return chunk
+}
+
+func buildQuotaUsage(usage *tracer.QuotaUsage) *api.QuotaUsage {
+	return &api.QuotaUsage{
+		Namespace:       usage.Namespace,
+		Bytes:           usage.Bytes,
+		Transactions:    usage.Transactions,
+		RejectedStreams: usage.RejectedStreams,
+		WindowStart:     timestamppb.New(usage.WindowStart),
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/kubeshark/tracer/pkg/tracer"
+)
+
+func TestBuildChunk(t *testing.T) {
+	tests := []struct {
+		name  string
+		event tracer.Event
+	}{
+		{"tcp", tracer.Event{SrcIP: net.IPv4(10, 0, 0, 1), DstIP: net.IPv4(10, 0, 0, 2), Data: []byte("GET /")}},
+		{"udp", tracer.Event{SrcIP: net.IPv4(10, 0, 0, 1), DstIP: net.IPv4(10, 0, 0, 2), IsUdp: true}},
+		{"shed", tracer.Event{SrcIP: net.IPv4(10, 0, 0, 1), DstIP: net.IPv4(10, 0, 0, 2), Shed: true, Namespace: "shop", Workload: "cart"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chunk := BuildChunk(&test.event)
+
+			if chunk.IsUdp != test.event.IsUdp || chunk.Shed != test.event.Shed {
+				t.Errorf("got udp %v and shed %v, want %v and %v", chunk.IsUdp, chunk.Shed, test.event.IsUdp, test.event.Shed)
+			}
+
+			if chunk.Namespace != test.event.Namespace || chunk.Workload != test.event.Workload {
+				t.Errorf("got %s/%s, want %s/%s", chunk.Namespace, chunk.Workload, test.event.Namespace, test.event.Workload)
+			}
+
+			if chunk.SrcIp != "10.0.0.1" || chunk.DstIp != "10.0.0.2" || string(chunk.Data) != string(test.event.Data) {
+				t.Errorf("got %s to %s with %q", chunk.SrcIp, chunk.DstIp, chunk.Data)
+			}
+		})
+	}
+}
+
+func TestBuildChunkNotice(t *testing.T) {
+	event := tracer.Event{
+		Namespace: "shop",
+		Notice:    &tracer.Notice{Type: tracer.NoticeQuotaExceeded, Quota: &tracer.QuotaUsage{Namespace: "shop", Bytes: 100, RejectedStreams: 1}},
+	}
+
+	chunk := BuildChunk(&event)
+	if chunk.Notice == nil || chunk.Notice.Type != tracer.NoticeQuotaExceeded {
+		t.Fatalf("got the notice %v", chunk.Notice)
+	}
+
+	if quota := chunk.Notice.Quota; quota == nil || quota.Namespace != "shop" || quota.Bytes != 100 || quota.RejectedStreams != 1 {
+		t.Fatalf("got the quota %v", quota)
+	}
+}
+
+func TestBuildChunkAnnotations(t *testing.T) {
+	event := tracer.Event{
+		StreamId:    7,
+		Annotations: []string{"incident-1234", "reviewed"},
+		Notice:      &tracer.Notice{Type: tracer.NoticeAnnotation},
+	}
+
+	chunk := BuildChunk(&event)
+	if chunk.StreamId != 7 || chunk.Notice.GetType() != tracer.NoticeAnnotation || len(chunk.Annotations) != 2 || chunk.Annotations[1] != "reviewed" {
+		t.Fatalf("got the chunk %v", chunk)
+	}
+}
@@ -0,0 +1,460 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/cilium/ebpf"
+	"github.com/go-errors/errors"
+	"github.com/kubeshark/tracer/pkg/correlation"
+	"github.com/kubeshark/tracer/pkg/storage"
+	"github.com/kubeshark/tracer/pkg/tracer"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	pidTypeSSLLib = "ssllib"
+	pidTypeGo     = "go"
+)
+
+type pidRequest struct {
+	Pid  uint32 `json:"pid"`
+	Type string `json:"type"`
+}
+
+type buffersRequest struct {
+	ChunksBufferSize int `json:"chunksBufferSize"`
+}
+
+// HttpServer manages a running tracer, so the targets and the capture can be changed
+// without restarting it:
+//
+//	GET    /status          the runtime state of the tracer
+//	POST   /capture/start   resumes the capture
+//	POST   /capture/stop    pauses the capture, the hooks stay attached
+//	POST   /pids            targets {"pid": 1234, "type": "ssllib" or "go"}, control socket only
+//	DELETE /pids/{pid}      removes the PID from the targets
+//	PUT    /buffers         resizes the buffers {"chunksBufferSize": 409600}
+//	GET    /healthz         200 if the chunks are read and written, 503 otherwise
+//	GET    /readyz          200 if the probes are attached as well, 503 otherwise
+//	GET    /stats           the runtime state and the overhead of the probes
+//	POST   /stop            stops the tracer, if OnStop is set
+//	GET    /correlations    the correlation IDs, ?minHops=2 for the ones seen more than once
+//	GET    /correlations/{id} the chain of the requests of a correlation ID
+//	GET    /log-levels      the levels of the log modules
+//	PUT    /log-levels      sets the levels {"poller": "debug"}, "" follows the others
+//	GET    /probe-groups    the probe groups and whether they're attached
+//	PUT    /probe-groups    attaches or detaches the groups {"go": false}
+//	GET    /storage         the disk usage and the pruned files of the sinks
+type HttpServer struct {
+	tracer     *tracer.Tracer
+	server     *http.Server
+	stop       func()
+	correlator *correlation.Correlator
+	storage    *storage.Manager
+}
+
+type statsResponse struct {
+	Status tracer.Status          `json:"status"`
+	Probes []tracer.ProbeOverhead `json:"probes"`
+}
+
+func NewHttpServer(t *tracer.Tracer) *HttpServer {
+	s := &HttpServer{
+		tracer: t,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/capture/start", s.handleCaptureStart)
+	mux.HandleFunc("/capture/stop", s.handleCaptureStop)
+	mux.HandleFunc("/pids", s.handleAddPid)
+	mux.HandleFunc("/pids/", s.handleRemovePid)
+	mux.HandleFunc("/buffers", s.handleBuffers)
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.HandleFunc("/stats", s.handleStats)
+	mux.HandleFunc("/stop", s.handleStop)
+	mux.HandleFunc("/correlations", s.handleCorrelations)
+	mux.HandleFunc("/correlations/", s.handleCorrelation)
+	mux.HandleFunc("/log-levels", s.handleLogLevels)
+	mux.HandleFunc("/probe-groups", s.handleProbeGroups)
+	mux.HandleFunc("/storage", s.handleStorage)
+
+	s.server = &http.Server{Handler: mux}
+
+	return s
+}
+
+// Serve blocks until Stop is called
+func (s *HttpServer) Serve(address string) error {
+	s.server.Addr = address
+
+	log.Info().Str("address", address).Msg("Starting HTTP control server:")
+
+	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return errors.Wrap(err, 0)
+	}
+
+	return nil
+}
+
+// ServeUnix serves on a Unix domain socket that only the owner can connect to, blocks
+// until Stop is called
+func (s *HttpServer) ServeUnix(path string) error {
+	// Left behind by a tracer that didn't stop gracefully
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return errors.Wrap(err, 0)
+		}
+	}
+
+	// The socket is created with the permissions of the umask, a chmod after listening would
+	// leave a window to connect in. The umask is of the process, the files that are created
+	// meanwhile are only more restricted.
+	umask := syscall.Umask(0077)
+	listener, err := net.Listen("unix", path)
+	syscall.Umask(umask)
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	log.Info().Str("path", path).Msg("Starting control socket:")
+
+	if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return errors.Wrap(err, 0)
+	}
+
+	return nil
+}
+
+// OnStop sets the function that POST /stop calls, in the background after responding
+func (s *HttpServer) OnStop(stop func()) {
+	s.stop = stop
+}
+
+// SetCorrelator enables the correlation routes
+func (s *HttpServer) SetCorrelator(c *correlation.Correlator) {
+	s.correlator = c
+}
+
+// SetStorage enables the storage route
+func (s *HttpServer) SetStorage(m *storage.Manager) {
+	s.storage = m
+}
+
+func (s *HttpServer) Stop() {
+	if err := s.server.Shutdown(context.Background()); err != nil {
+		tracer.LogError(errors.Wrap(err, 0))
+	}
+}
+
+func (s *HttpServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	writeJson(w, http.StatusOK, s.tracer.Status())
+}
+
+func (s *HttpServer) handleCaptureStart(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	if err := s.tracer.Resume(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJson(w, http.StatusOK, s.tracer.Status())
+}
+
+func (s *HttpServer) handleCaptureStop(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	if err := s.tracer.Pause(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJson(w, http.StatusOK, s.tracer.Status())
+}
+
+func (s *HttpServer) handleAddPid(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Attaching the probes to any process of the node is only allowed to the owner of the
+	// control socket, the TCP listener has no authentication
+	if _, ok := r.Context().Value(http.LocalAddrContextKey).(*net.UnixAddr); !ok {
+		writeError(w, http.StatusForbidden, errors.New("PIDs are only added through the control socket"))
+		return
+	}
+
+	var request pidRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if request.Pid == tracer.GlobalWorkerPid {
+		writeError(w, http.StatusBadRequest, errors.Errorf("Invalid PID %d", request.Pid))
+		return
+	}
+
+	procfs := s.tracer.Procfs()
+
+	var err error
+	switch request.Type {
+	case pidTypeSSLLib:
+		err = s.tracer.AddSSLLibPid(procfs, request.Pid)
+	case pidTypeGo:
+		err = s.tracer.AddGoPid(procfs, request.Pid)
+	default:
+		writeError(w, http.StatusBadRequest, errors.Errorf("Invalid PID type %q, expected %q or %q", request.Type, pidTypeSSLLib, pidTypeGo))
+		return
+	}
+
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJson(w, http.StatusOK, s.tracer.Status())
+}
+
+func (s *HttpServer) handleRemovePid(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodDelete) {
+		return
+	}
+
+	pid, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/pids/"), 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := s.tracer.RemovePid(uint32(pid)); err != nil {
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			writeError(w, http.StatusNotFound, err)
+		} else {
+			writeError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	writeJson(w, http.StatusOK, s.tracer.Status())
+}
+
+func (s *HttpServer) handleBuffers(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPut) {
+		return
+	}
+
+	var request buffersRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := s.tracer.SetChunksBufferSize(request.ChunksBufferSize); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJson(w, http.StatusOK, s.tracer.Status())
+}
+
+func (s *HttpServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	writeHealthChecks(w, s.tracer.Live())
+}
+
+func (s *HttpServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	writeHealthChecks(w, s.tracer.Ready())
+}
+
+func (s *HttpServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	writeJson(w, http.StatusOK, statsResponse{
+		Status: s.tracer.Status(),
+		Probes: s.tracer.ProbeOverheadReport(),
+	})
+}
+
+func (s *HttpServer) handleStop(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	if s.stop == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("Stopping is not supported"))
+		return
+	}
+
+	writeJson(w, http.StatusAccepted, map[string]string{"status": "stopping"})
+	go s.stop()
+}
+
+func (s *HttpServer) handleCorrelations(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) || !s.hasCorrelator(w) {
+		return
+	}
+
+	minHops := 1
+	if value := r.URL.Query().Get("minHops"); value != "" {
+		var err error
+		if minHops, err = strconv.Atoi(value); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	writeJson(w, http.StatusOK, map[string][]string{"ids": s.correlator.List(minHops)})
+}
+
+func (s *HttpServer) handleCorrelation(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) || !s.hasCorrelator(w) {
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/correlations/")
+	chain, ok := s.correlator.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.Errorf("Correlation ID %q is not found", id))
+		return
+	}
+
+	writeJson(w, http.StatusOK, chain)
+}
+
+func (s *HttpServer) handleLogLevels(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		writeJson(w, http.StatusOK, tracer.GetLogLevels())
+		return
+	}
+
+	if !allowMethod(w, r, http.MethodPut) {
+		return
+	}
+
+	var levels map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&levels); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := tracer.SetLogLevels(levels); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJson(w, http.StatusOK, tracer.GetLogLevels())
+}
+
+func (s *HttpServer) handleProbeGroups(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		writeJson(w, http.StatusOK, s.tracer.ProbeGroupStatuses())
+		return
+	}
+
+	if !allowMethod(w, r, http.MethodPut) {
+		return
+	}
+
+	var groups map[string]bool
+	if err := json.NewDecoder(r.Body).Decode(&groups); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := s.tracer.SetProbeGroup(name, groups[name]); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	writeJson(w, http.StatusOK, s.tracer.ProbeGroupStatuses())
+}
+
+func (s *HttpServer) handleStorage(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	if s.storage == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("Storage is not managed"))
+		return
+	}
+
+	writeJson(w, http.StatusOK, s.storage.Metrics())
+}
+
+func (s *HttpServer) hasCorrelator(w http.ResponseWriter) bool {
+	if s.correlator == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("Correlation is not enabled"))
+		return false
+	}
+
+	return true
+}
+
+func writeHealthChecks(w http.ResponseWriter, checks []tracer.HealthCheck) {
+	code := http.StatusOK
+	if !tracer.IsHealthy(checks) {
+		code = http.StatusServiceUnavailable
+	}
+
+	writeJson(w, code, map[string][]tracer.HealthCheck{"checks": checks})
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, errors.Errorf("Method %s is not allowed", r.Method))
+	return false
+}
+
+func writeJson(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warn().Err(err).Msg("Couldn't write the HTTP response:")
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJson(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeUnixPermissions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tracer.sock")
+	s := NewHttpServer(nil)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.ServeUnix(path)
+	}()
+
+	var info os.FileInfo
+	for deadline := time.Now().Add(5 * time.Second); info == nil; {
+		if time.Now().After(deadline) {
+			t.Fatal("the socket wasn't created")
+		}
+
+		info, _ = os.Stat(path)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm()&0077 != 0 {
+		t.Fatalf("got mode %v, want a socket of the owner only", info.Mode())
+	}
+
+	s.Stop()
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAddPidOverTcp(t *testing.T) {
+	s := NewHttpServer(nil)
+	server := httptest.NewServer(s.server.Handler)
+	defer server.Close()
+
+	response, err := http.Post(server.URL+"/pids", "application/json", strings.NewReader(`{"pid": 1234, "type": "go"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusForbidden {
+		t.Fatalf("got status %d, want %d", response.StatusCode, http.StatusForbidden)
+	}
+}
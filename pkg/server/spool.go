@@ -0,0 +1,553 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/kubeshark/tracer/pkg/api"
+	"github.com/kubeshark/tracer/pkg/storage"
+	"github.com/kubeshark/tracer/pkg/tracer"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	spoolSegmentSize  = 64 * 1024 * 1024
+	spoolCursorsFile  = "cursors.json"
+	spoolSaveInterval = time.Second
+	// A line has the base64 of a chunk of at most 4 KiB and its metadata
+	maxSpoolLine = 1024 * 1024
+)
+
+var (
+	ErrUnknownSubscriber   = errors.New("Unknown subscriber")
+	ErrSubscriberConnected = errors.New("The subscriber is connected already")
+	ErrInvalidOffset       = errors.New("The offset isn't spooled yet")
+)
+
+// Spool keeps the chunks of the window in segment files of the JSON lines of api.Chunk,
+// spool-<offset of the first chunk>.jsonl, which tracer replay reads as well. The chunks
+// are numbered from 1 by their offsets, which continue after a restart. The cursors of the
+// durable subscribers, their last acknowledged offsets, are saved to cursors.json every
+// second, so the chunks after the last saved acknowledgement are sent again after a
+// restart of the tracer.
+type Spool struct {
+	tracer *tracer.Tracer
+	dir    string
+	window time.Duration
+	sink   *storage.Sink
+	events <-chan tracer.Event
+	done   chan struct{}
+
+	// Of run
+	file   *os.File
+	writer *bufio.Writer
+	size   int64
+
+	// The offset of the next chunk
+	next uint64
+	// Closed and replaced when the chunks are flushed to the segment
+	changed   chan struct{}
+	closed    bool
+	cursors   map[string]uint64
+	connected map[string]bool
+	dirty     bool
+	sync.Mutex
+}
+
+func NewSpool(t *tracer.Tracer, dir string, window time.Duration, storageManager *storage.Manager) (*Spool, error) {
+	if window <= 0 {
+		return nil, errors.Errorf("Invalid spool window %v", window)
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	s := &Spool{
+		tracer:    t,
+		dir:       dir,
+		window:    window,
+		sink:      storageManager.AddSink("spool", dir, "spool-*.jsonl"),
+		done:      make(chan struct{}),
+		next:      1,
+		changed:   make(chan struct{}),
+		cursors:   make(map[string]uint64),
+		connected: make(map[string]bool),
+	}
+	s.sink.SetMaxAge(window)
+
+	if err := s.loadCursors(); err != nil {
+		return nil, err
+	}
+
+	firsts, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+	if len(firsts) > 0 {
+		if s.next, err = s.getSegmentEnd(firsts[len(firsts)-1]); err != nil {
+			return nil, err
+		}
+	}
+
+	// The offsets aren't reused when all the segments are pruned
+	for _, acked := range s.cursors {
+		if acked >= s.next {
+			s.next = acked + 1
+		}
+	}
+
+	return s, nil
+}
+
+// Start spools until the tracer is stopped
+func (s *Spool) Start() error {
+	if err := s.create(); err != nil {
+		return err
+	}
+
+	events, err := s.tracer.Subscribe(tracer.EventFilter{})
+	if err != nil {
+		s.file.Close()
+		s.sink.Close(s.file.Name())
+		return err
+	}
+
+	log.Info().Str("dir", s.dir).Dur("window", s.window).Uint64("offset", s.next).Msg("Starting spool:")
+
+	s.events = events
+	go s.run(events)
+
+	return nil
+}
+
+// Wait returns when the spool is flushed after the tracer is stopped
+func (s *Spool) Wait() {
+	<-s.done
+}
+
+func (s *Spool) run(events <-chan tracer.Event) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(spoolSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				s.close()
+				return
+			}
+
+			if err := s.write(&event); err != nil {
+				tracer.LogError(err)
+				s.tracer.Unsubscribe(events)
+				s.close()
+				return
+			}
+
+			// Flushed once the burst is written
+			if len(events) == 0 {
+				s.flush()
+			}
+		case <-ticker.C:
+			s.saveCursors()
+		}
+	}
+}
+
+func (s *Spool) write(event *tracer.Event) error {
+	s.Lock()
+	offset := s.next
+	s.next++
+	s.Unlock()
+
+	chunk := BuildChunk(event)
+	chunk.Offset = offset
+	if err := WriteChunk(s, chunk); err != nil {
+		return err
+	}
+
+	if s.size < spoolSegmentSize {
+		return nil
+	}
+
+	if err := s.closeSegment(); err != nil {
+		return err
+	}
+
+	return s.create()
+}
+
+// Write counts the written bytes of the segment
+func (s *Spool) Write(data []byte) (int, error) {
+	n, err := s.writer.Write(data)
+	s.size += int64(n)
+	return n, err
+}
+
+// create starts the segment of the next chunk
+func (s *Spool) create() error {
+	s.Lock()
+	path := getSpoolSegmentPath(s.dir, s.next)
+	s.Unlock()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	s.sink.Open(path)
+	s.file = file
+	s.writer = bufio.NewWriter(file)
+	s.size = 0
+
+	return nil
+}
+
+func (s *Spool) closeSegment() error {
+	defer s.sink.Close(s.file.Name())
+
+	if err := s.writer.Flush(); err != nil {
+		s.file.Close()
+		return errors.Wrap(err, 0)
+	}
+
+	if err := s.file.Close(); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	s.sink.Add(s.file.Name())
+
+	return nil
+}
+
+func (s *Spool) flush() {
+	if err := s.writer.Flush(); err != nil {
+		tracer.LogError(errors.Wrap(err, 0))
+	}
+
+	s.Lock()
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.Unlock()
+}
+
+func (s *Spool) close() {
+	if err := s.closeSegment(); err != nil {
+		tracer.LogError(err)
+	}
+	s.saveCursors()
+
+	s.Lock()
+	s.closed = true
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.Unlock()
+}
+
+// Connect returns the offset that a durable subscriber resumes from, and registers a new
+// subscriber at the end of the spool
+func (s *Spool) Connect(id string) (uint64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.connected[id] {
+		return 0, ErrSubscriberConnected
+	}
+	s.connected[id] = true
+
+	acked, ok := s.cursors[id]
+	if !ok {
+		acked = s.next - 1
+		s.cursors[id] = acked
+		s.dirty = true
+	}
+
+	return acked + 1, nil
+}
+
+func (s *Spool) Disconnect(id string) {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.connected, id)
+}
+
+// Ack moves the cursor of a subscriber forward to offset, and returns the cursor
+func (s *Spool) Ack(id string, offset uint64) (uint64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	acked, ok := s.cursors[id]
+	if !ok {
+		return 0, ErrUnknownSubscriber
+	}
+
+	if offset >= s.next {
+		return acked, ErrInvalidOffset
+	}
+
+	if offset > acked {
+		s.cursors[id] = offset
+		s.dirty = true
+	}
+
+	return s.cursors[id], nil
+}
+
+// Follow sends the spooled chunks from offset that match the filter, then the new ones as
+// they are spooled, until ctx is done or the spool is closed
+func (s *Spool) Follow(ctx context.Context, offset uint64, filter tracer.EventFilter, send func(*api.Chunk) error) error {
+	firsts, err := s.segments()
+	if err != nil {
+		return err
+	}
+
+	current := uint64(0)
+	for _, first := range firsts {
+		if first <= offset || current == 0 {
+			current = first
+		}
+	}
+	if current == 0 {
+		return errors.New("No spool segments")
+	}
+
+	file, err := os.Open(getSpoolSegmentPath(s.dir, current))
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+	defer func() { file.Close() }()
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	var pending []byte
+	expected := offset
+	// The segment is read once more after the next one is found, it is complete then
+	complete := false
+	for {
+		s.Lock()
+		changed, closed := s.changed, s.closed
+		s.Unlock()
+
+		for {
+			line, err := reader.ReadSlice('\n')
+			if err == bufio.ErrBufferFull || err == io.EOF {
+				if len(pending)+len(line) > maxSpoolLine {
+					return errors.Errorf("Too long line in spool segment %d", current)
+				}
+				pending = append(pending, line...)
+				if err == io.EOF {
+					break
+				}
+				continue
+			}
+			if err != nil {
+				return errors.Wrap(err, 0)
+			}
+			if len(pending) > 0 {
+				line = append(pending, line...)
+				pending = nil
+			}
+
+			chunk := &api.Chunk{}
+			if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(line, chunk); err != nil {
+				return errors.Errorf("Error reading spool segment %d: %v", current, err)
+			}
+
+			if chunk.Offset < expected {
+				continue
+			}
+			if chunk.Offset > expected {
+				log.Warn().Uint64("from", expected).Uint64("to", chunk.Offset-1).Msg("Skipped the pruned spooled chunks:")
+			}
+			expected = chunk.Offset + 1
+
+			event := parseEvent(chunk)
+			if !filter.Matches(&event) {
+				continue
+			}
+
+			if err := send(chunk); err != nil {
+				return err
+			}
+		}
+
+		firsts, err := s.segments()
+		if err != nil {
+			return err
+		}
+
+		next := uint64(0)
+		for _, first := range firsts {
+			if first > current {
+				next = first
+				break
+			}
+		}
+
+		if next != 0 && !complete {
+			complete = true
+			continue
+		}
+
+		if next != 0 {
+			nextFile, err := os.Open(getSpoolSegmentPath(s.dir, next))
+			if os.IsNotExist(err) {
+				// Pruned since it was listed
+				current = next
+				continue
+			}
+			if err != nil {
+				return errors.Wrap(err, 0)
+			}
+
+			file.Close()
+			file, current, complete, pending = nextFile, next, false, nil
+			reader.Reset(file)
+			continue
+		}
+
+		if closed {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		}
+	}
+}
+
+// segments returns the offsets of the first chunks of the segments in order
+func (s *Spool) segments() ([]uint64, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "spool-*.jsonl"))
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	firsts := make([]uint64, 0, len(paths))
+	for _, path := range paths {
+		var first uint64
+		if _, err := fmt.Sscanf(filepath.Base(path), "spool-%d.jsonl", &first); err == nil {
+			firsts = append(firsts, first)
+		}
+	}
+	sort.Slice(firsts, func(i, j int) bool { return firsts[i] < firsts[j] })
+
+	return firsts, nil
+}
+
+// getSegmentEnd returns the offset after the last chunk of a segment, a torn line at its
+// end of a crash is ignored
+func (s *Spool) getSegmentEnd(first uint64) (uint64, error) {
+	file, err := os.Open(getSpoolSegmentPath(s.dir, first))
+	if err != nil {
+		return 0, errors.Wrap(err, 0)
+	}
+	defer file.Close()
+
+	end := first
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSpoolLine)
+	for scanner.Scan() {
+		chunk := &api.Chunk{}
+		if (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(scanner.Bytes(), chunk) == nil && chunk.Offset >= end {
+			end = chunk.Offset + 1
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, errors.Wrap(err, 0)
+	}
+
+	return end, nil
+}
+
+func (s *Spool) loadCursors() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, spoolCursorsFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	if err := json.Unmarshal(data, &s.cursors); err != nil {
+		return errors.Errorf("Invalid spool cursors %s: %v", spoolCursorsFile, err)
+	}
+
+	return nil
+}
+
+// saveCursors writes the cursors if they changed, through a temporary file that replaces
+// the previous one
+func (s *Spool) saveCursors() {
+	s.Lock()
+	if !s.dirty {
+		s.Unlock()
+		return
+	}
+	data, err := json.Marshal(s.cursors)
+	s.dirty = false
+	s.Unlock()
+
+	if err != nil {
+		tracer.LogError(errors.Wrap(err, 0))
+		return
+	}
+
+	path := filepath.Join(s.dir, spoolCursorsFile)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		tracer.LogError(errors.Wrap(err, 0))
+		return
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		tracer.LogError(errors.Wrap(err, 0))
+	}
+}
+
+func getSpoolSegmentPath(dir string, first uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("spool-%020d.jsonl", first))
+}
+
+// parseEvent returns the fields of a chunk that the filters match
+func parseEvent(chunk *api.Chunk) tracer.Event {
+	return tracer.Event{
+		Pid:      chunk.Pid,
+		SrcIP:    net.ParseIP(chunk.SrcIp),
+		SrcPort:  uint16(chunk.SrcPort),
+		DstIP:    net.ParseIP(chunk.DstIp),
+		DstPort:  uint16(chunk.DstPort),
+		IsRead:   chunk.IsRead,
+		Protocol: chunk.Protocol,
+	}
+}
+
+// WriteChunk writes a chunk as a line of JSON
+func WriteChunk(writer io.Writer, chunk *api.Chunk) error {
+	data, err := protojson.Marshal(chunk)
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	if _, err := writer.Write(append(data, '\n')); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	return nil
+}
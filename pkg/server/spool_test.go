@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kubeshark/tracer/pkg/api"
+	"github.com/kubeshark/tracer/pkg/storage"
+	"github.com/kubeshark/tracer/pkg/tracer"
+)
+
+func startTestSpool(t *testing.T, dir string) (*tracer.Tracer, *Spool) {
+	m, err := storage.NewManager(storage.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := &tracer.Tracer{}
+	s, err := NewSpool(tr, dir, time.Hour, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	return tr, s
+}
+
+func publishTestEvents(tr *tracer.Tracer, pids ...uint32) {
+	for _, pid := range pids {
+		tr.Publish(tracer.Event{Pid: pid, SrcIP: net.IPv4(10, 0, 0, 1), DstIP: net.IPv4(10, 0, 0, 2)})
+	}
+}
+
+// follow returns the offsets of the chunks that are sent until count of them are, or 5
+// seconds pass
+func follow(s *Spool, offset uint64, filter tracer.EventFilter, count int) ([]uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var offsets []uint64
+	err := s.Follow(ctx, offset, filter, func(chunk *api.Chunk) error {
+		offsets = append(offsets, chunk.Offset)
+		if len(offsets) == count {
+			cancel()
+		}
+		return nil
+	})
+
+	return offsets, err
+}
+
+func checkFollow(t *testing.T, s *Spool, offset uint64, filter tracer.EventFilter, want []uint64) {
+	offsets, err := follow(s, offset, filter, len(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(offsets, want) {
+		t.Fatalf("got the chunks %v from %d, want %v", offsets, offset, want)
+	}
+}
+
+func TestSpoolResume(t *testing.T) {
+	dir := t.TempDir()
+	tr, s := startTestSpool(t, dir)
+
+	offset, err := s.Connect("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 1 {
+		t.Fatalf("got the offset %d of a new subscriber", offset)
+	}
+
+	if _, err := s.Connect("a"); err != ErrSubscriberConnected {
+		t.Fatalf("got %v for a connected subscriber", err)
+	}
+
+	// Followed live
+	done := make(chan []uint64)
+	go func() {
+		offsets, _ := follow(s, offset, tracer.EventFilter{}, 3)
+		done <- offsets
+	}()
+	publishTestEvents(tr, 1, 2, 3)
+	if offsets := <-done; !reflect.DeepEqual(offsets, []uint64{1, 2, 3}) {
+		t.Fatalf("got the chunks %v", offsets)
+	}
+
+	if acked, err := s.Ack("a", 2); err != nil || acked != 2 {
+		t.Fatalf("got the cursor %d, %v", acked, err)
+	}
+	if acked, err := s.Ack("a", 1); err != nil || acked != 2 {
+		t.Fatalf("got the cursor %d, %v after an older ack", acked, err)
+	}
+	if _, err := s.Ack("a", 4); err != ErrInvalidOffset {
+		t.Fatalf("got %v for an offset that isn't spooled", err)
+	}
+	if _, err := s.Ack("b", 1); err != ErrUnknownSubscriber {
+		t.Fatalf("got %v for an unknown subscriber", err)
+	}
+	s.Disconnect("a")
+
+	// The tracer restarts, the subscriber resumes after its acknowledged chunk
+	tr.Unsubscribe(s.events)
+	s.Wait()
+
+	tr, s = startTestSpool(t, dir)
+	publishTestEvents(tr, 4)
+
+	offset, err = s.Connect("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 3 {
+		t.Fatalf("got the offset %d after a restart", offset)
+	}
+
+	checkFollow(t, s, offset, tracer.EventFilter{}, []uint64{3, 4})
+	checkFollow(t, s, 1, tracer.EventFilter{Pids: []uint32{2, 4}}, []uint64{2, 4})
+
+	// Returns when the spool is closed
+	tr.Unsubscribe(s.events)
+	s.Wait()
+	checkFollow(t, s, 1, tracer.EventFilter{}, []uint64{1, 2, 3, 4})
+}
+
+func TestSpoolPruned(t *testing.T) {
+	dir := t.TempDir()
+	tr, s := startTestSpool(t, dir)
+	publishTestEvents(tr, 1, 2)
+	tr.Unsubscribe(s.events)
+	s.Wait()
+
+	// The segment of the chunks 1 and 2 is pruned
+	tr, s = startTestSpool(t, dir)
+	publishTestEvents(tr, 3)
+	tr.Unsubscribe(s.events)
+	s.Wait()
+	if err := os.Remove(getSpoolSegmentPath(dir, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	checkFollow(t, s, 1, tracer.EventFilter{}, []uint64{3})
+}
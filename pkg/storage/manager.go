@@ -0,0 +1,321 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/kubeshark/tracer/pkg/tracer"
+	"github.com/rs/zerolog/log"
+)
+
+const pruneInterval = time.Minute
+
+// Config limits the files of the sinks, 0 disables a limit. The session is this run of the
+// tracer, the files that are found on the disk at start belong to the earlier sessions.
+type Config struct {
+	MaxBytes     int64         `json:"maxBytes"`
+	MaxAge       time.Duration `json:"maxAge"`
+	SessionBytes int64         `json:"sessionBytes"`
+}
+
+// Metrics is the disk usage of the sinks at the last prune
+type Metrics struct {
+	Config       Config        `json:"config"`
+	Files        int           `json:"files"`
+	Bytes        int64         `json:"bytes"`
+	SessionBytes int64         `json:"sessionBytes"`
+	PrunedFiles  uint64        `json:"prunedFiles"`
+	PrunedBytes  uint64        `json:"prunedBytes"`
+	LastPrune    time.Time     `json:"lastPrune"`
+	Sinks        []SinkMetrics `json:"sinks"`
+}
+
+type SinkMetrics struct {
+	Name  string `json:"name"`
+	Dir   string `json:"dir"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"`
+}
+
+// Manager keeps the files of the sinks, e.g. the reports and the segments of the chunks
+// file, within the limits. The oldest files are pruned first, the files that are being
+// written are never pruned.
+type Manager struct {
+	config  Config
+	sinks   map[string]*Sink
+	metrics Metrics
+	prune   chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	sync.Mutex
+}
+
+// Sink is the files of a directory that match its patterns
+type Sink struct {
+	manager  *Manager
+	name     string
+	dir      string
+	patterns []string
+	maxAge   time.Duration
+	// The files of this session and the ones that are being written
+	session map[string]bool
+	active  map[string]bool
+}
+
+type file struct {
+	sink    *Sink
+	path    string
+	size    int64
+	modTime time.Time
+	session bool
+	active  bool
+}
+
+func NewManager(config Config) (*Manager, error) {
+	if config.MaxBytes < 0 || config.MaxAge < 0 || config.SessionBytes < 0 {
+		return nil, errors.Errorf("Invalid storage limits %+v", config)
+	}
+
+	return &Manager{
+		config:  config,
+		sinks:   make(map[string]*Sink),
+		metrics: Metrics{Config: config, Sinks: []SinkMetrics{}},
+		prune:   make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// AddSink manages the files of dir that match the patterns of filepath.Match, a sink of
+// the same name is replaced and its files of this session are kept in the session, e.g.
+// when it is restarted with other settings on reload
+func (m *Manager) AddSink(name string, dir string, patterns ...string) *Sink {
+	m.Lock()
+	defer m.Unlock()
+
+	sink := &Sink{
+		manager:  m,
+		name:     name,
+		dir:      dir,
+		patterns: patterns,
+		session:  make(map[string]bool),
+		active:   make(map[string]bool),
+	}
+	if previous, ok := m.sinks[name]; ok {
+		for path := range previous.session {
+			sink.session[path] = true
+		}
+	}
+	m.sinks[name] = sink
+
+	return sink
+}
+
+// Start prunes periodically and after the files are added until Stop is called
+func (m *Manager) Start() {
+	log.Info().Int64("max-bytes", m.config.MaxBytes).Dur("max-age", m.config.MaxAge).Int64("session-bytes", m.config.SessionBytes).Msg("Starting storage manager:")
+
+	go m.run()
+}
+
+func (m *Manager) Stop() {
+	close(m.stop)
+	<-m.done
+}
+
+func (m *Manager) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	m.Prune(time.Now())
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			m.Prune(now)
+		case <-m.prune:
+			m.Prune(time.Now())
+		}
+	}
+}
+
+// Metrics returns the usage at the last prune
+func (m *Manager) Metrics() Metrics {
+	m.Lock()
+	defer m.Unlock()
+
+	metrics := m.metrics
+	metrics.Sinks = append([]SinkMetrics(nil), m.metrics.Sinks...)
+
+	return metrics
+}
+
+// Prune removes the files that are older than the maximum age, then the oldest files of
+// this session beyond its quota, then the oldest files beyond the maximum total
+func (m *Manager) Prune(now time.Time) {
+	m.Lock()
+	defer m.Unlock()
+
+	files := m.scan()
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	var total, session int64
+	for _, f := range files {
+		total += f.size
+		if f.session {
+			session += f.size
+		}
+	}
+
+	kept := files[:0]
+	for _, f := range files {
+		remove := false
+		switch {
+		case f.active:
+		case m.getMaxAge(f.sink) > 0 && now.Sub(f.modTime) > m.getMaxAge(f.sink):
+			remove = true
+		case m.config.SessionBytes > 0 && f.session && session > m.config.SessionBytes:
+			remove = true
+		case m.config.MaxBytes > 0 && total > m.config.MaxBytes:
+			remove = true
+		}
+
+		if !remove || !m.remove(f) {
+			kept = append(kept, f)
+			continue
+		}
+
+		total -= f.size
+		if f.session {
+			session -= f.size
+		}
+	}
+
+	m.metrics.Files = len(kept)
+	m.metrics.Bytes = total
+	m.metrics.SessionBytes = session
+	m.metrics.LastPrune = now
+
+	m.metrics.Sinks = make([]SinkMetrics, 0, len(m.sinks))
+	for _, sink := range m.sinks {
+		metrics := SinkMetrics{Name: sink.name, Dir: sink.dir}
+		for _, f := range kept {
+			if f.sink == sink {
+				metrics.Files++
+				metrics.Bytes += f.size
+			}
+		}
+		m.metrics.Sinks = append(m.metrics.Sinks, metrics)
+	}
+	sort.Slice(m.metrics.Sinks, func(i, j int) bool {
+		return m.metrics.Sinks[i].Name < m.metrics.Sinks[j].Name
+	})
+}
+
+// getMaxAge is the smaller of the maximum ages of the manager and the sink
+func (m *Manager) getMaxAge(sink *Sink) time.Duration {
+	if sink.maxAge > 0 && (m.config.MaxAge == 0 || sink.maxAge < m.config.MaxAge) {
+		return sink.maxAge
+	}
+
+	return m.config.MaxAge
+}
+
+// scan lists the files of the sinks, a file that matches several sinks is listed once
+func (m *Manager) scan() []*file {
+	seen := make(map[string]bool)
+	var files []*file
+	for _, sink := range m.sinks {
+		for _, pattern := range sink.patterns {
+			paths, err := filepath.Glob(filepath.Join(sink.dir, pattern))
+			if err != nil {
+				tracer.LogError(errors.Wrap(err, 0))
+				continue
+			}
+
+			for _, path := range paths {
+				if seen[path] {
+					continue
+				}
+				seen[path] = true
+
+				info, err := os.Stat(path)
+				if err != nil || !info.Mode().IsRegular() {
+					continue
+				}
+
+				files = append(files, &file{
+					sink:    sink,
+					path:    path,
+					size:    info.Size(),
+					modTime: info.ModTime(),
+					session: sink.session[path],
+					active:  sink.active[path],
+				})
+			}
+		}
+	}
+
+	return files
+}
+
+func (m *Manager) remove(f *file) bool {
+	if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+		tracer.LogError(errors.Wrap(err, 0))
+		return false
+	}
+
+	delete(f.sink.session, f.path)
+	m.metrics.PrunedFiles++
+	m.metrics.PrunedBytes += uint64(f.size)
+
+	log.Debug().Str("sink", f.sink.name).Str("path", f.path).Int64("size", f.size).Msg("Pruned file:")
+
+	return true
+}
+
+// SetMaxAge prunes the files of the sink earlier than the other sinks, e.g. beyond the
+// window of the spool
+func (s *Sink) SetMaxAge(maxAge time.Duration) {
+	s.manager.Lock()
+	defer s.manager.Unlock()
+
+	s.maxAge = maxAge
+}
+
+// Open marks a file of this session that is being written, it isn't pruned until Close
+func (s *Sink) Open(path string) {
+	s.manager.Lock()
+	defer s.manager.Unlock()
+
+	s.session[path] = true
+	s.active[path] = true
+}
+
+func (s *Sink) Close(path string) {
+	s.manager.Lock()
+	defer s.manager.Unlock()
+
+	delete(s.active, path)
+}
+
+// Add records a file of this session that is written, and prunes the sinks
+func (s *Sink) Add(path string) {
+	s.manager.Lock()
+	s.session[path] = true
+	s.manager.Unlock()
+
+	select {
+	case s.manager.prune <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestPrune(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// The files of 100 bytes, report-1 is the oldest
+	type testFile struct {
+		name    string
+		age     time.Duration
+		session bool
+		active  bool
+	}
+	files := []testFile{
+		{"report-1.json", 4 * time.Hour, false, false},
+		{"report-2.json", 3 * time.Hour, false, false},
+		{"report-3.json", 2 * time.Hour, true, false},
+		{"report-4.json", time.Hour, true, false},
+		{"report-5.json", 0, true, true},
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+		kept   []string
+	}{
+		{"unlimited", Config{}, []string{"report-1.json", "report-2.json", "report-3.json", "report-4.json", "report-5.json"}},
+		{"max age", Config{MaxAge: 150 * time.Minute}, []string{"report-3.json", "report-4.json", "report-5.json"}},
+		{"max bytes", Config{MaxBytes: 300}, []string{"report-3.json", "report-4.json", "report-5.json"}},
+		{"session bytes", Config{SessionBytes: 200}, []string{"report-1.json", "report-2.json", "report-4.json", "report-5.json"}},
+		{"all", Config{MaxAge: 210 * time.Minute, SessionBytes: 200, MaxBytes: 250}, []string{"report-4.json", "report-5.json"}},
+		// The file that is being written is kept beyond the limits
+		{"active", Config{MaxBytes: 1}, []string{"report-5.json"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			m, err := NewManager(test.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sink := m.AddSink("reports", dir, "report-*.json")
+
+			for _, f := range files {
+				path := filepath.Join(dir, f.name)
+				if err := os.WriteFile(path, make([]byte, 100), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(path, now.Add(-f.age), now.Add(-f.age)); err != nil {
+					t.Fatal(err)
+				}
+				if f.session {
+					sink.Add(path)
+				}
+				if f.active {
+					sink.Open(path)
+				}
+			}
+			// Not of the sink
+			if err := os.WriteFile(filepath.Join(dir, "other.json"), make([]byte, 1000), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			m.Prune(now)
+
+			paths, _ := filepath.Glob(filepath.Join(dir, "report-*.json"))
+			kept := make([]string, 0, len(paths))
+			for _, path := range paths {
+				kept = append(kept, filepath.Base(path))
+			}
+			sort.Strings(kept)
+			if !reflect.DeepEqual(kept, test.kept) {
+				t.Fatalf("got the files %v, want %v", kept, test.kept)
+			}
+
+			metrics := m.Metrics()
+			pruned := uint64(len(files) - len(test.kept))
+			if metrics.Files != len(test.kept) || metrics.Bytes != int64(100*len(test.kept)) || metrics.PrunedFiles != pruned || metrics.PrunedBytes != 100*pruned {
+				t.Fatalf("got the metrics %+v", metrics)
+			}
+
+			if len(metrics.Sinks) != 1 || metrics.Sinks[0].Files != len(test.kept) {
+				t.Fatalf("got the sinks %+v", metrics.Sinks)
+			}
+		})
+	}
+}
+
+func TestAddSinkReplaced(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report-1.json")
+	if err := os.WriteFile(path, make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewManager(Config{SessionBytes: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.AddSink("reports", dir, "report-*.json").Add(path)
+
+	// Restarted on reload, the report is still of this session
+	m.AddSink("reports", dir, "report-*.json")
+	m.Prune(time.Now())
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("the report of the session isn't pruned: %v", err)
+	}
+}
+
+func TestNewManagerInvalid(t *testing.T) {
+	if _, err := NewManager(Config{MaxBytes: -1}); err == nil {
+		t.Fatal("created a manager with a negative limit")
+	}
+}
+
+func TestSinkMaxAge(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+
+	m, err := NewManager(Config{MaxAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.AddSink("spool", dir, "spool-*.jsonl").SetMaxAge(time.Hour)
+	m.AddSink("reports", dir, "report-*.json")
+
+	for _, name := range []string{"spool-1.jsonl", "report-1.json"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-2*time.Hour), now.Add(-2*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m.Prune(now)
+
+	if _, err := os.Stat(filepath.Join(dir, "spool-1.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("the spool segment beyond its window isn't pruned: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "report-1.json")); err != nil {
+		t.Errorf("the report within the maximum age is pruned: %v", err)
+	}
+}
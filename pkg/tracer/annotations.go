@@ -0,0 +1,95 @@
+package tracer
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/go-errors/errors"
+)
+
+const (
+	maxAnnotations      = 32
+	maxAnnotationLength = 256
+)
+
+var ErrUnknownStream = errors.New("Unknown stream")
+
+// Annotate attaches annotations, e.g. incident-1234 or reviewed, to a live stream. They are
+// set on the later events of the stream, and published at once in an annotation notice, so
+// the sinks have them even if the stream has no more chunks. The notice has the addresses
+// of the stream, not its protocol. Returns all the annotations of the stream.
+func (t *Tracer) Annotate(streamId int64, annotations []string) ([]string, error) {
+	if len(annotations) == 0 {
+		return nil, errors.New("No annotations")
+	}
+
+	for _, annotation := range annotations {
+		if annotation == "" || len(annotation) > maxAnnotationLength {
+			return nil, errors.Errorf("Invalid annotation %q, expected 1 to %d bytes", annotation, maxAnnotationLength)
+		}
+	}
+
+	value, ok := t.streamsMap.Load(streamId)
+	if !ok {
+		return nil, ErrUnknownStream
+	}
+	stream := value.(*tlsStream)
+
+	var merged []string
+	for {
+		previous := stream.annotations.Load()
+		merged = mergeAnnotations(previous, annotations)
+		if len(merged) > maxAnnotations {
+			return nil, errors.Errorf("Too many annotations, a stream has at most %d", maxAnnotations)
+		}
+
+		if stream.annotations.CompareAndSwap(previous, &merged) {
+			break
+		}
+	}
+
+	t.Publish(newAnnotationEvent(stream, merged))
+
+	return merged, nil
+}
+
+// mergeAnnotations returns the previous annotations and the new ones that aren't in them,
+// the previous ones are shared by the events and aren't modified
+func mergeAnnotations(previous *[]string, annotations []string) []string {
+	var merged []string
+	if previous != nil {
+		merged = append(merged, *previous...)
+	}
+
+	for _, annotation := range annotations {
+		if !containsString(merged, annotation) {
+			merged = append(merged, annotation)
+		}
+	}
+
+	return merged
+}
+
+// newAnnotationEvent reads the fields of the stream that don't change after it is stored
+func newAnnotationEvent(stream *tlsStream, annotations []string) Event {
+	id := stream.client.tcpID
+	srcPort, _ := strconv.ParseUint(id.SrcPort, 10, 16)
+	dstPort, _ := strconv.ParseUint(id.DstPort, 10, 16)
+
+	return Event{
+		StreamId:    stream.getId(),
+		SrcIP:       net.ParseIP(id.SrcIP),
+		SrcPort:     uint16(srcPort),
+		DstIP:       net.ParseIP(id.DstIP),
+		DstPort:     uint16(dstPort),
+		IsClient:    true,
+		IsUdp:       stream.udp,
+		Namespace:   stream.namespace,
+		Workload:    stream.workload,
+		MeshLeg:     stream.meshLeg,
+		Timestamp:   time.Now().UTC(),
+		Annotations: annotations,
+		Notice:      &Notice{Type: NoticeAnnotation},
+	}
+}
@@ -0,0 +1,91 @@
+package tracer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnnotate(t *testing.T) {
+	tracer := &Tracer{streamsMap: NewTcpStreamMap()}
+	events, err := tracer.Subscribe(EventFilter{Ports: []uint16{443}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &tlsPoller{tls: tracer, streams: make(map[string]*tlsStream)}
+	stream := NewTlsStream(p, "a")
+	stream.id = 7
+	stream.namespace = "shop"
+	stream.client = NewTlsReader(&TcpID{SrcIP: "10.0.0.1", DstIP: "10.0.0.2", SrcPort: "40000", DstPort: "443"}, stream, true)
+	tracer.streamsMap.Store(stream.id, stream)
+
+	tests := []struct {
+		name        string
+		streamId    int64
+		annotations []string
+		want        []string
+		err         string
+	}{
+		{"first", 7, []string{"incident-1234"}, []string{"incident-1234"}, ""},
+		{"merged", 7, []string{"reviewed", "incident-1234"}, []string{"incident-1234", "reviewed"}, ""},
+		{"unknown stream", 8, []string{"reviewed"}, nil, "Unknown stream"},
+		{"none", 7, nil, nil, "No annotations"},
+		{"empty", 7, []string{""}, nil, "Invalid annotation"},
+		{"too long", 7, []string{strings.Repeat("a", maxAnnotationLength+1)}, nil, "Invalid annotation"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			annotations, err := tracer.Annotate(test.streamId, test.annotations)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got %v, want an error %q", err, test.err)
+				}
+				if len(events) > 0 {
+					t.Fatalf("got the notice %+v of a failed annotation", <-events)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(annotations, test.want) {
+				t.Fatalf("got %v, want %v", annotations, test.want)
+			}
+
+			event := <-events
+			if event.Notice == nil || event.Notice.Type != NoticeAnnotation || event.StreamId != 7 || event.Namespace != "shop" || !reflect.DeepEqual(event.Annotations, test.want) {
+				t.Fatalf("got the notice %+v", event)
+			}
+		})
+	}
+
+	// The later events of the stream have them
+	event := newEvent(&tracerTlsChunk{}, stream, pidTarget{})
+	if !reflect.DeepEqual(event.Annotations, []string{"incident-1234", "reviewed"}) {
+		t.Fatalf("got the annotations %v of a later event", event.Annotations)
+	}
+}
+
+func TestAnnotateLimit(t *testing.T) {
+	tracer := &Tracer{streamsMap: NewTcpStreamMap()}
+	p := &tlsPoller{tls: tracer, streams: make(map[string]*tlsStream)}
+	stream := NewTlsStream(p, "a")
+	stream.client = NewTlsReader(&TcpID{SrcIP: "10.0.0.1", DstIP: "10.0.0.2", SrcPort: "40000", DstPort: "443"}, stream, true)
+	tracer.streamsMap.Store(stream.id, stream)
+
+	annotations := make([]string, maxAnnotations+1)
+	for i := range annotations {
+		annotations[i] = "a" + strings.Repeat("b", i)
+	}
+
+	if _, err := tracer.Annotate(stream.id, annotations[:maxAnnotations]); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tracer.Annotate(stream.id, annotations[maxAnnotations:]); err == nil || !strings.Contains(err.Error(), "Too many annotations") {
+		t.Fatalf("got %v beyond the limit", err)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package tracer
 
 import (
 	"bytes"
@@ -8,7 +8,6 @@ import (
 
 	"github.com/cilium/ebpf/perf"
 	"github.com/go-errors/errors"
-	"github.com/rs/zerolog/log"
 )
 
 const logPrefix = "[bpf] "
@@ -55,7 +54,7 @@ func (p *bpfLogger) close() error {
 }
 
 func (p *bpfLogger) poll() {
-	log.Info().Msg("Start polling for bpf logs")
+	bpfLog.get().Info().Msg("Start polling for bpf logs")
 
 	for {
 		record, err := p.logReader.Read()
@@ -70,7 +69,7 @@ func (p *bpfLogger) poll() {
 		}
 
 		if record.LostSamples != 0 {
-			log.Info().Msg(fmt.Sprintf("Log buffer is full, dropped %d logs", record.LostSamples))
+			bpfLog.get().Info().Msg(fmt.Sprintf("Log buffer is full, dropped %d logs", record.LostSamples))
 			continue
 		}
 
@@ -78,7 +77,7 @@ func (p *bpfLogger) poll() {
 
 		var log logMessage
 
-		if err := binary.Read(buffer, binary.LittleEndian, &log); err != nil {
+		if err := binary.Read(buffer, hostByteOrder, &log); err != nil {
 			LogError(errors.Errorf("Error parsing log %v", err))
 			continue
 		}
@@ -89,7 +88,7 @@ func (p *bpfLogger) poll() {
 
 func (p *bpfLogger) log(msg *logMessage) {
 	if int(msg.MessageCode) >= len(bpfLogMessages) {
-		log.Info().Msg(fmt.Sprintf("Unknown message code from bpf logger %d", msg.MessageCode))
+		bpfLog.get().Info().Msg(fmt.Sprintf("Unknown message code from bpf logger %d", msg.MessageCode))
 		return
 	}
 
@@ -109,10 +108,10 @@ func (p *bpfLogger) log(msg *logMessage) {
 
 func (p *bpfLogger) logLevel(level uint32, format string, args ...interface{}) {
 	if level == logLevelError {
-		log.Error().Msg(fmt.Sprintf(logPrefix+format, args...))
+		bpfLog.get().Error().Msgf(logPrefix+format, args...)
 	} else if level == logLevelInfo {
-		log.Info().Msg(fmt.Sprintf(logPrefix+format, args...))
+		bpfLog.get().Info().Msgf(logPrefix+format, args...)
 	} else if level == logLevelDebug {
-		log.Debug().Msg(fmt.Sprintf(logPrefix+format, args...))
+		bpfLog.get().Debug().Msgf(logPrefix+format, args...)
 	}
 }
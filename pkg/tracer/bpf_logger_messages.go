@@ -1,4 +1,4 @@
-package main
+package tracer
 
 // Must be synced with logger_messages.h
 //
@@ -26,4 +26,7 @@ var bpfLogMessages = []string{
 	/*0020*/ "[%d] Unable to read socket sport [err: %d]",
 	/*0021*/ "[%d] Unable to put go user-kernel context [fd: %d] [err: %d]",
 	/*0022*/ "[%d] Unable to get go user-kernel context [fd: %d]]",
+	/*0023*/ "[%d] Unable to put flow stats [err: %d]",
+	/*0024*/ "[%d] Unable to put thread socket [err: %d]",
+	/*0025*/ "[%d] Unable to put pending ssl write [err: %d]",
 }
@@ -0,0 +1,141 @@
+package tracer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// In a user namespace the programs are loaded and the maps are created with a BPF token
+// (Linux 6.9+). The privileged parent of the namespace mounts a bpffs with the delegate_cmds,
+// delegate_maps, delegate_progs and delegate_attachs options in it, cilium/ebpf creates the
+// token from such a mount for each BPF_PROG_LOAD, BPF_MAP_CREATE and BPF_BTF_LOAD.
+// The attachments through perf events, the tracepoints, the kprobes and the uprobes, aren't
+// covered by the token, they need kernel.perf_event_paranoid -1 on the host.
+
+const bpfTokenHint = "Mount a bpffs with delegate_cmds, delegate_maps, delegate_progs and delegate_attachs in the user namespace (Linux 6.9+) and set kernel.perf_event_paranoid to -1 on the host"
+
+// The initial user namespace maps all the uids
+const initUidMap = "0 0 4294967295"
+
+var mountPathUnescaper = strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+
+// checkBpfToken requires a delegated bpffs in a user namespace, the other processes load
+// with their capabilities
+func checkBpfToken(procfs string) error {
+	isInit, err := isInitUserNamespace(procfs)
+	if err != nil || isInit {
+		return err
+	}
+
+	mounts, err := getDelegatedBpffs(procfs)
+	if err != nil {
+		return err
+	}
+
+	if len(mounts) == 0 {
+		return errors.New("No bpffs with delegated permissions in the user namespace")
+	}
+
+	paranoid, err := os.ReadFile(fmt.Sprintf("%s/sys/kernel/perf_event_paranoid", procfs))
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	if level, err := strconv.Atoi(strings.TrimSpace(string(paranoid))); err != nil || level > -1 {
+		return errors.Errorf("The perf events can't be opened in the user namespace, kernel.perf_event_paranoid is %s", strings.TrimSpace(string(paranoid)))
+	}
+
+	return nil
+}
+
+// logBpfToken tells which bpffs the token of a process in a user namespace is created from
+func logBpfToken(procfs string) {
+	isInit, err := isInitUserNamespace(procfs)
+	if err != nil {
+		log.Debug().Err(err).Msg("Couldn't read the user namespace:")
+		return
+	}
+
+	if isInit {
+		return
+	}
+
+	mounts, err := getDelegatedBpffs(procfs)
+	if err != nil {
+		log.Warn().Err(err).Msg("Couldn't find the bpffs mounts:")
+		return
+	}
+
+	if len(mounts) == 0 {
+		log.Warn().Str("hint", bpfTokenHint).Msg("Running in a user namespace without a delegated bpffs, loading needs the capabilities of the host:")
+		return
+	}
+
+	log.Info().Strs("bpffs", mounts).Msg("Running in a user namespace, loading with a BPF token:")
+}
+
+func isInitUserNamespace(procfs string) (bool, error) {
+	data, err := os.ReadFile(fmt.Sprintf("%s/self/uid_map", procfs))
+	if err != nil {
+		return false, errors.Wrap(err, 0)
+	}
+
+	return strings.Join(strings.Fields(string(data)), " ") == initUidMap, nil
+}
+
+func getDelegatedBpffs(procfs string) ([]string, error) {
+	file, err := os.Open(fmt.Sprintf("%s/self/mountinfo", procfs))
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+	defer file.Close()
+
+	return findDelegatedBpffs(file)
+}
+
+// findDelegatedBpffs returns the mount points of the bpffs mounts with delegated commands,
+// see proc_pid_mountinfo(5)
+func findDelegatedBpffs(mountinfo io.Reader) ([]string, error) {
+	mounts := make([]string, 0)
+
+	scanner := bufio.NewScanner(mountinfo)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+
+		// The optional fields end with a separator, then the type, the source and the options
+		// of the filesystem
+		separator := -1
+		for i := 5; i < len(fields); i++ {
+			if fields[i] == "-" {
+				separator = i
+				break
+			}
+		}
+		if separator < 0 || len(fields) < separator+4 || fields[separator+1] != "bpf" {
+			continue
+		}
+
+		for _, option := range strings.Split(fields[separator+3], ",") {
+			if strings.HasPrefix(option, "delegate_cmds=") {
+				mounts = append(mounts, mountPathUnescaper.Replace(fields[4]))
+				break
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	return mounts, nil
+}
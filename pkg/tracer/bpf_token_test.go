@@ -0,0 +1,95 @@
+package tracer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindDelegatedBpffs(t *testing.T) {
+	tests := []struct {
+		name      string
+		mountinfo string
+		mounts    []string
+	}{
+		{
+			"delegated",
+			"41 30 0:36 / /sys/fs/bpf rw,relatime shared:17 - bpf bpf rw,delegate_cmds=any,delegate_maps=any,delegate_progs=any,delegate_attachs=any\n",
+			[]string{"/sys/fs/bpf"},
+		},
+		{
+			"not delegated",
+			"41 30 0:36 / /sys/fs/bpf rw,relatime shared:17 - bpf bpf rw,mode=700\n",
+			[]string{},
+		},
+		{
+			"no optional fields",
+			"41 30 0:36 / /run/bpf\\040fs rw - bpf none rw,delegate_cmds=prog_load:map_create\n",
+			[]string{"/run/bpf fs"},
+		},
+		{
+			"other filesystem",
+			"22 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw,delegate_cmds=any\n",
+			[]string{},
+		},
+		{
+			"truncated",
+			"22 1 8:1 / / rw,relatime - bpf\n",
+			[]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mounts, err := findDelegatedBpffs(strings.NewReader(test.mountinfo))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(mounts, test.mounts) {
+				t.Fatalf("got %v, want %v", mounts, test.mounts)
+			}
+		})
+	}
+}
+
+func TestCheckBpfToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		uidMap   string
+		options  string
+		paranoid string
+		err      bool
+	}{
+		{"initial namespace", "         0          0 4294967295\n", "rw", "2", false},
+		{"delegated", "0 100000 65536\n", "rw,delegate_cmds=any", "-1", false},
+		{"not delegated", "0 100000 65536\n", "rw", "-1", true},
+		{"paranoid", "0 100000 65536\n", "rw,delegate_cmds=any", "2", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			procfs := t.TempDir()
+			writeProcFile(t, procfs, "self/uid_map", test.uidMap)
+			writeProcFile(t, procfs, "self/mountinfo", "41 30 0:36 / /sys/fs/bpf rw - bpf bpf "+test.options+"\n")
+			writeProcFile(t, procfs, "sys/kernel/perf_event_paranoid", test.paranoid+"\n")
+
+			if err := checkBpfToken(procfs); (err != nil) != test.err {
+				t.Fatalf("got %v, want an error %v", err, test.err)
+			}
+		})
+	}
+}
+
+func writeProcFile(t *testing.T, procfs string, name string, data string) {
+	path := filepath.Join(procfs, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,77 @@
+package tracer
+
+import (
+	"math/rand"
+
+	"github.com/rs/zerolog/log"
+)
+
+// chaos degrades the chunks before they are handled, to test the consumers of the master
+// PCAP against loss, reordering, truncation and malformed data. Each rate is between 0 and 1.
+// It's only used by the poller goroutine, hence no locking.
+type chaos struct {
+	lossRate     float64
+	reorderRate  float64
+	truncateRate float64
+	corruptRate  float64
+	held         *tracerTlsChunk
+}
+
+func newChaos(config *Config) *chaos {
+	return &chaos{
+		lossRate:     config.ChaosLossRate,
+		reorderRate:  config.ChaosReorderRate,
+		truncateRate: config.ChaosTruncateRate,
+		corruptRate:  config.ChaosCorruptRate,
+	}
+}
+
+func (c *chaos) isEnabled() bool {
+	return c.lossRate > 0 || c.reorderRate > 0 || c.truncateRate > 0 || c.corruptRate > 0
+}
+
+// apply returns the chunks to handle instead of the given one, in order
+func (c *chaos) apply(chunk *tracerTlsChunk) []*tracerTlsChunk {
+	if rand.Float64() < c.lossRate {
+		log.Debug().Uint32("pid", chunk.Pid).Msg("Chaos: dropped chunk")
+		return nil
+	}
+
+	if chunk.Recorded > 1 && rand.Float64() < c.truncateRate {
+		chunk.Recorded = uint32(rand.Intn(int(chunk.Recorded-1))) + 1
+		log.Debug().Uint32("pid", chunk.Pid).Uint32("recorded", chunk.Recorded).Msg("Chaos: truncated chunk")
+	}
+
+	if chunk.Recorded > 0 && rand.Float64() < c.corruptRate {
+		for i := 0; i < 1+rand.Intn(8); i++ {
+			chunk.Data[rand.Intn(int(chunk.Recorded))] = byte(rand.Intn(256))
+		}
+		log.Debug().Uint32("pid", chunk.Pid).Msg("Chaos: corrupted chunk")
+	}
+
+	// The held chunk is released after the next one
+	if c.held != nil {
+		held := c.held
+		c.held = nil
+		return []*tracerTlsChunk{chunk, held}
+	}
+
+	if rand.Float64() < c.reorderRate {
+		log.Debug().Uint32("pid", chunk.Pid).Msg("Chaos: reordered chunk")
+		c.held = chunk
+		return nil
+	}
+
+	return []*tracerTlsChunk{chunk}
+}
+
+// flush returns the held chunk, on shutdown
+func (c *chaos) flush() []*tracerTlsChunk {
+	if c.held == nil {
+		return nil
+	}
+
+	held := c.held
+	c.held = nil
+	return []*tracerTlsChunk{held}
+}
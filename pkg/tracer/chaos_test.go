@@ -0,0 +1,75 @@
+package tracer
+
+import (
+	"testing"
+)
+
+func newChaosChunk(pid uint32, recorded uint32) *tracerTlsChunk {
+	chunk := &tracerTlsChunk{Pid: pid, Recorded: recorded}
+	copy(chunk.Data[:], "GET / HTTP/1.1\r\n\r\n")
+	return chunk
+}
+
+func TestChaos(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		// The PIDs of the chunks that are returned for the chunks 1, 2 and 3, and by flush
+		pids []uint32
+	}{
+		{"disabled", Config{}, []uint32{1, 2, 3}},
+		{"loss", Config{ChaosLossRate: 1}, []uint32{}},
+		// Each held chunk is released after the next one, which isn't held
+		{"reorder", Config{ChaosReorderRate: 1}, []uint32{2, 1, 3}},
+		{"truncate", Config{ChaosTruncateRate: 1}, []uint32{1, 2, 3}},
+		{"corrupt", Config{ChaosCorruptRate: 1}, []uint32{1, 2, 3}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newChaos(&test.config)
+			if c.isEnabled() != (test.name != "disabled") {
+				t.Fatalf("got enabled %v", c.isEnabled())
+			}
+
+			var chunks []*tracerTlsChunk
+			for pid := uint32(1); pid <= 3; pid++ {
+				chunks = append(chunks, c.apply(newChaosChunk(pid, 18))...)
+			}
+			chunks = append(chunks, c.flush()...)
+
+			pids := make([]uint32, 0, len(chunks))
+			for _, chunk := range chunks {
+				pids = append(pids, chunk.Pid)
+
+				if test.config.ChaosTruncateRate == 0 && chunk.Recorded != 18 {
+					t.Errorf("got %d recorded bytes of chunk %d", chunk.Recorded, chunk.Pid)
+				}
+
+				if test.config.ChaosTruncateRate > 0 && (chunk.Recorded == 0 || chunk.Recorded >= 18) {
+					t.Errorf("got %d recorded bytes of the truncated chunk %d", chunk.Recorded, chunk.Pid)
+				}
+			}
+
+			if len(pids) != len(test.pids) {
+				t.Fatalf("got the chunks %v, want %v", pids, test.pids)
+			}
+			for i := range pids {
+				if pids[i] != test.pids[i] {
+					t.Fatalf("got the chunks %v, want %v", pids, test.pids)
+				}
+			}
+		})
+	}
+}
+
+func TestChaosTruncateEmpty(t *testing.T) {
+	c := newChaos(&Config{ChaosTruncateRate: 1, ChaosCorruptRate: 1})
+
+	for _, recorded := range []uint32{0, 1} {
+		chunks := c.apply(newChaosChunk(1, recorded))
+		if len(chunks) != 1 || chunks[0].Recorded != recorded {
+			t.Fatalf("the chunk of %d bytes is truncated", recorded)
+		}
+	}
+}
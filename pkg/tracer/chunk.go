@@ -1,4 +1,4 @@
-package main
+package tracer
 
 import (
 	"encoding/binary"
@@ -8,6 +8,10 @@ import (
 
 const FlagsIsClientBit uint32 = 1 << 0
 const FlagsIsReadBit uint32 = 1 << 1
+const FlagsIsExitBit uint32 = 1 << 2
+const FlagsIsCiphertextBit uint32 = 1 << 3
+const FlagsIsKeylogBit uint32 = 1 << 4
+const FlagsIsUdpBit uint32 = 1 << 5
 
 type addressPair struct {
 	srcIp   net.IP
@@ -34,6 +38,10 @@ func (c *tracerTlsChunk) isClient() bool {
 	return c.Flags&FlagsIsClientBit != 0
 }
 
+func (c *tracerTlsChunk) getOrigin() ProbeOrigin {
+	return ProbeOrigin(c.Origin)
+}
+
 func (c *tracerTlsChunk) isServer() bool {
 	return !c.isClient()
 }
@@ -46,6 +54,26 @@ func (c *tracerTlsChunk) isWrite() bool {
 	return !c.isRead()
 }
 
+// isExit is true for the notification that the process exited, there is no data
+func (c *tracerTlsChunk) isExit() bool {
+	return c.Flags&FlagsIsExitBit != 0
+}
+
+// isCiphertext is true for the records of a process that is captured at BIO level
+func (c *tracerTlsChunk) isCiphertext() bool {
+	return c.Flags&FlagsIsCiphertextBit != 0
+}
+
+// isKeylog is true for a secret logged by OpenSSL, see keylogWriter
+func (c *tracerTlsChunk) isKeylog() bool {
+	return c.Flags&FlagsIsKeylogBit != 0
+}
+
+// isUdp is true for the chunks of a UDP connection, e.g. DTLS
+func (c *tracerTlsChunk) isUdp() bool {
+	return c.Flags&FlagsIsUdpBit != 0
+}
+
 func (c *tracerTlsChunk) getRecordedData() []byte {
 	return c.Data[:c.Recorded]
 }
@@ -84,12 +112,24 @@ func (c *tracerTlsChunk) getReader(stream *tlsStream) *tlsReader {
 	}
 }
 
-// intToIP converts IPv4 number to net.IP
+// hostByteOrder is the byte order of the chunks and the logs, the eBPF objects of s390x are
+// big endian
+var hostByteOrder binary.ByteOrder = func() binary.ByteOrder {
+	value := uint16(1)
+	if *(*byte)(unsafe.Pointer(&value)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// intToIP converts an address in network byte order that was read as a host integer
 func intToIP(ip32be uint32) net.IP {
-	return net.IPv4(uint8(ip32be), uint8(ip32be>>8), uint8(ip32be>>16), uint8(ip32be>>24))
+	b := make([]byte, 4)
+	hostByteOrder.PutUint32(b, ip32be)
+	return net.IPv4(b[0], b[1], b[2], b[3])
 }
 
-// ntohs converts big endian (network byte order) to little endian (assuming that's the host byte order)
+// ntohs converts big endian (network byte order) to the host byte order
 func ntohs(i16be uint16) uint16 {
 	b := make([]byte, 2)
 	binary.BigEndian.PutUint16(b, i16be)
@@ -0,0 +1,129 @@
+package tracer
+
+import (
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/features"
+	"github.com/cilium/ebpf/perf"
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// The drops of the ring buffer are counted in kernel and reported this often
+const ringbufDropsInterval = time.Second
+
+// prepareChunksRingbuf sizes chunks_ringbuf if the kernel supports the BPF ring buffers and
+// size isn't 0. Otherwise the map is replaced by an unused array and the calls to
+// bpf_ringbuf_output are removed, so the programs load on the kernels before 5.8 and the
+// chunks are sent through the perf buffer.
+func prepareChunksRingbuf(spec *ebpf.CollectionSpec, size int) (bool, error) {
+	mapSpec, ok := spec.Maps["chunks_ringbuf"]
+	if !ok {
+		return false, errors.Errorf("chunks_ringbuf isn't found in the eBPF objects")
+	}
+
+	if size > 0 {
+		err := features.HaveMapType(ebpf.RingBuf)
+		if err == nil {
+			mapSpec.MaxEntries = uint32(size)
+			return true, nil
+		}
+
+		if !errors.Is(err, ebpf.ErrNotSupported) {
+			return false, errors.Wrap(err, 0)
+		}
+
+		log.Info().Msg("The kernel doesn't support the BPF ring buffers, the chunks are sent through the perf buffer")
+	}
+
+	spec.Maps["chunks_ringbuf"] = &ebpf.MapSpec{
+		Name:       mapSpec.Name,
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	}
+
+	for _, program := range spec.Programs {
+		for i := range program.Instructions {
+			instruction := &program.Instructions[i]
+			if instruction.IsBuiltinCall() && instruction.Constant == int64(asm.FnRingbufOutput) {
+				*instruction = asm.Mov.Imm(asm.R0, 0).WithMetadata(instruction.Metadata)
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// chunksReader reads the chunks from the perf buffer or from the ring buffer
+type chunksReader interface {
+	SetDeadline(deadline time.Time)
+	// Returns the sample of a chunk, or the number of the chunks that were lost without a sample
+	read() ([]byte, uint64, error)
+	Close() error
+}
+
+type perfChunksReader struct {
+	*perf.Reader
+}
+
+func (r perfChunksReader) read() ([]byte, uint64, error) {
+	record, err := r.Read()
+	return record.RawSample, record.LostSamples, err
+}
+
+type ringbufChunksReader struct {
+	*ringbuf.Reader
+	drops      *ebpf.Map
+	reported   uint64
+	lastReport time.Time
+}
+
+func newRingbufChunksReader(bpfObjects *tracerObjects) (*ringbufChunksReader, error) {
+	reader, err := ringbuf.NewReader(bpfObjects.ChunksRingbuf)
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	return &ringbufChunksReader{
+		Reader:     reader,
+		drops:      bpfObjects.ChunksRingbufDrops,
+		lastReport: time.Now(),
+	}, nil
+}
+
+func (r *ringbufChunksReader) read() ([]byte, uint64, error) {
+	if time.Since(r.lastReport) >= ringbufDropsInterval {
+		r.lastReport = time.Now()
+
+		if lost := r.newDrops(); lost != 0 {
+			return nil, lost, nil
+		}
+	}
+
+	record, err := r.Read()
+	return record.RawSample, 0, err
+}
+
+// newDrops returns the number of the chunks dropped since the previous call
+func (r *ringbufChunksReader) newDrops() uint64 {
+	var perCpu []uint64
+	if err := r.drops.Lookup(uint32(0), &perCpu); err != nil {
+		LogError(errors.Wrap(err, 0))
+		return 0
+	}
+
+	var total uint64
+	for _, drops := range perCpu {
+		total += drops
+	}
+
+	lost := total - r.reported
+	r.reported = total
+
+	return lost
+}
@@ -0,0 +1,77 @@
+package tracer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/go-errors/errors"
+)
+
+// Name prefixes of the eBPF programs loaded by other capture agents. The kernel truncates
+// the names to 15 characters.
+var coexistingAgentPrograms = map[string][]string{
+	"cilium": {"cil_"},
+	"pixie":  {"syscall__probe_", "probe_entry_", "probe_ret_"},
+}
+
+var coexistingAgentPinPaths = map[string]string{
+	"cilium": "/sys/fs/bpf/tc/globals",
+}
+
+// detectCoexistingAgents returns the names of the other eBPF capture agents on the node,
+// whose probes may see the same traffic and duplicate it in the downstream systems.
+func detectCoexistingAgents() ([]string, error) {
+	found := make(map[string]bool)
+
+	for agent, path := range coexistingAgentPinPaths {
+		if _, err := os.Stat(path); err == nil {
+			found[agent] = true
+		}
+	}
+
+	var id ebpf.ProgramID
+	for {
+		var err error
+		id, err = ebpf.ProgramGetNextID(id)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, 0)
+		}
+
+		program, err := ebpf.NewProgramFromID(id)
+		if err != nil {
+			continue
+		}
+
+		info, err := program.Info()
+		program.Close()
+		if err != nil {
+			continue
+		}
+
+		for agent, prefixes := range coexistingAgentPrograms {
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(info.Name, prefix) {
+					found[agent] = true
+				}
+			}
+		}
+	}
+
+	agents := make([]string, 0, len(found))
+	for agent := range found {
+		agents = append(agents, agent)
+	}
+
+	return agents, nil
+}
+
+// The same key is computed for the stream on every restart of the tracer, downstream
+// pipelines can use it together with the TCP sequence numbers to drop duplicates.
+func buildDedupKey(pid uint32, fd uint32, key string) string {
+	return fmt.Sprintf("%d/%d/%s", pid, fd, key)
+}
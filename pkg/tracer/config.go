@@ -0,0 +1,193 @@
+package tracer
+
+import (
+	"os"
+	"time"
+
+	"github.com/go-errors/errors"
+)
+
+// Config is the configuration of a Tracer. The zero value of an optional
+// setting disables the feature.
+type Config struct {
+	// The procfs directory, used when mapping host volumes into a container
+	Procfs string
+	// The BTF of the kernel for the CO-RE relocations if the kernel doesn't provide it
+	BtfPath string
+	// Sizes of the perf buffers per CPU in bytes
+	ChunksBufferSize int
+	LogBufferSize    int
+	// Size of the ring buffer of the chunks shared by the CPUs, a power of 2 multiple of the
+	// page size. The chunks go through it instead of the perf buffer if the kernel supports it.
+	RingBufferSize int
+	// Maximum number of the cached connection addresses
+	FdCacheSize int
+
+	// Targeted with libssl and Go in addition to the processes of the pods, kept on UpdateTargets
+	Pids []uint32
+	// Processes using OpenSSL whose records are captured at BIO level instead of their plaintext,
+	// with the secrets written to the key log file, e.g. when SSL_write and SSL_read are bypassed
+	BioCapturePids []uint32
+	// Container IDs whose processes are targeted the same way, as they appear in /proc/<pid>/cgroup
+	Cgroups []string
+	// The probe groups that are attached, all of them if empty
+	ProbeGroups []string
+	// Attach only the syscall tracepoints, for the kernels where kprobes and uprobes are
+	// disabled. The payloads are read from read and write, so TLS stays encrypted.
+	SyscallsOnly bool
+	// Offsets of the hooked symbols for the binaries whose symbols can't be discovered
+	SymbolOffsets []SymbolOffset
+	// The directory where the analysis of the Go binaries is cached by their build ID
+	OffsetsCachePath string
+	// Number of the binaries analyzed in parallel on UpdateTargets, and the time after which
+	// the analysis of a binary is given up
+	AnalysisWorkers int
+	AnalysisTimeout time.Duration
+
+	// Size of the channels returned by Tracer.Events and Tracer.Subscribe, 0 disables Tracer.Events
+	EventBufferSize int
+	// The chunks are held after Start until SetEnrichmentReady is called, at most for the
+	// time and the number of chunks, so the first events have their pods. The held chunks
+	// are timestamped when they are released. Either 0 disables.
+	EnrichmentHoldTime   time.Duration
+	EnrichmentHoldChunks int
+
+	// Sample new streams when the tracer uses more than this percentage of a CPU core
+	MaxCpu float64
+	// Maximum number of the tracked streams, the least recently active one is shed for a new
+	// stream beyond it. 0 doesn't limit the streams.
+	MaxStreams int
+	// Interval for estimating and logging the CPU overhead of each eBPF probe
+	ProbeStatsInterval time.Duration
+
+	// Maximum captured bytes and transactions per namespace within NamespaceQuotaWindow,
+	// see namespaceQuota
+	NamespaceQuotaBytes        uint64
+	NamespaceQuotaTransactions uint64
+	NamespaceQuotaWindow       time.Duration
+
+	// Only the payloads of the connections to these CIDRs and to the targeted pods in these
+	// namespaces are captured, the others are metadata only. Both empty captures everything.
+	PayloadCidrs      []string
+	PayloadNamespaces []string
+	// Add labels to the events from the values in their payloads
+	LabelRules []LabelRule
+	// Detect and parse the protocols that aren't built in, tried in order
+	DissectorPlugins []DissectorPlugin
+
+	// The leg to capture in Istio/Linkerd meshed pods, "app" or "sidecar". The payloads of
+	// the sidecar leg aren't captured, the sidecars aren't hooked yet.
+	MeshLeg string
+	// Don't write the streams whose decrypted payload is TLS again
+	SkipNestedTls bool
+
+	// Only count the bytes and messages of each connection in kernel, read every MetadataInterval
+	MetadataOnly     bool
+	MetadataInterval time.Duration
+
+	// Write the TLS ClientHello and ServerHello packets in the network namespaces of the targets
+	// to the master PCAP as well and set the JA3 and the JA4 of the streams, the socket filter
+	// needs Linux 4.18
+	CaptureHandshakes bool
+	// Save the stream state on Stop and resume the streams on the next Start
+	Checkpoint bool
+	// The bpffs directory where the connection and target maps are pinned and migrated
+	// from on the next start, so an upgrade doesn't lose them
+	MigrationPath string
+	// The bpffs directory where all the maps and the links of the syscall and tcp hooks are
+	// pinned, e.g. /sys/fs/bpf/tracer. They stay attached when the tracer stops, so the next
+	// tracer continues with them. Exclusive with MigrationPath.
+	PinPath string
+
+	// Rates between 0 and 1 of the chunks that are dropped, reordered, truncated or
+	// corrupted on purpose, for testing the consumers against degraded conditions
+	ChaosLossRate     float64
+	ChaosReorderRate  float64
+	ChaosTruncateRate float64
+	ChaosCorruptRate  float64
+}
+
+// DefaultConfig returns the configuration that the tracer binary uses without flags
+func DefaultConfig() Config {
+	return Config{
+		Procfs:               "/proc",
+		ChunksBufferSize:     os.Getpagesize() * 100,
+		LogBufferSize:        os.Getpagesize(),
+		RingBufferSize:       os.Getpagesize() * 4096,
+		FdCacheSize:          defaultFdCacheSize,
+		AnalysisWorkers:      4,
+		AnalysisTimeout:      30 * time.Second,
+		EnrichmentHoldTime:   5 * time.Second,
+		EnrichmentHoldChunks: 4096,
+		NamespaceQuotaWindow: 24 * time.Hour,
+		MeshLeg:              meshLegApp,
+		MetadataInterval:     10 * time.Second,
+		Checkpoint:           true,
+	}
+}
+
+func (c *Config) validate() error {
+	if c.ChunksBufferSize <= 0 || c.LogBufferSize <= 0 {
+		return errors.Errorf("Invalid buffer sizes (chunks: %d) (log: %d)", c.ChunksBufferSize, c.LogBufferSize)
+	}
+
+	if c.RingBufferSize < 0 || c.RingBufferSize%os.Getpagesize() != 0 || c.RingBufferSize&(c.RingBufferSize-1) != 0 {
+		return errors.Errorf("Invalid ring buffer size %d, expected a power of 2 multiple of the page size", c.RingBufferSize)
+	}
+
+	if c.PinPath != "" && c.MigrationPath != "" {
+		return errors.Errorf("Either the pin path or the migration path can be set")
+	}
+
+	if c.FdCacheSize <= 0 {
+		return errors.Errorf("Invalid fd cache size %d", c.FdCacheSize)
+	}
+
+	if c.MaxStreams < 0 {
+		return errors.Errorf("Invalid maximum number of streams %d", c.MaxStreams)
+	}
+
+	if c.AnalysisWorkers <= 0 {
+		return errors.Errorf("Invalid number of analysis workers %d", c.AnalysisWorkers)
+	}
+
+	for _, rate := range []float64{c.ChaosLossRate, c.ChaosReorderRate, c.ChaosTruncateRate, c.ChaosCorruptRate} {
+		if rate < 0 || rate > 1 {
+			return errors.Errorf("Invalid chaos rate %v, expected a value between 0 and 1", rate)
+		}
+	}
+
+	if err := validateProbeGroups(c.ProbeGroups); err != nil {
+		return err
+	}
+
+	if c.SyscallsOnly {
+		for _, group := range c.ProbeGroups {
+			if group != ProbeGroupSyscalls {
+				return errors.Errorf("Probe group %s can't be attached in the syscalls-only mode", group)
+			}
+		}
+	}
+
+	if _, err := parseCidrs(c.PayloadCidrs); err != nil {
+		return err
+	}
+
+	if _, err := compileLabelRules(c.LabelRules); err != nil {
+		return err
+	}
+
+	for i := range c.DissectorPlugins {
+		if err := c.DissectorPlugins[i].validate(); err != nil {
+			return err
+		}
+	}
+
+	for i := range c.SymbolOffsets {
+		if err := c.SymbolOffsets[i].validate(); err != nil {
+			return err
+		}
+	}
+
+	return validateMeshLeg(c.MeshLeg)
+}
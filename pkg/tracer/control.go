@@ -0,0 +1,180 @@
+package tracer
+
+import (
+	"sort"
+
+	"github.com/cilium/ebpf/perf"
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Status is a snapshot of the runtime state of a Tracer
+type Status struct {
+	Paused           bool `json:"paused"`
+	ChunksBufferSize int  `json:"chunksBufferSize"`
+	// 0 if the chunks are sent through the perf buffer
+	RingBufferSize int      `json:"ringBufferSize"`
+	Pids           []uint32 `json:"pids"`
+	Subscriptions  int      `json:"subscriptions"`
+	// Each consumer with its own filter
+	Consumers []SubscriptionStatus `json:"consumers"`
+	// The chunks that wait for the enrichment of the targets after Start
+	HeldChunks int64 `json:"heldChunks"`
+	// The usage of the namespaces in the current window of -namespace-quota-window
+	NamespaceQuotas []QuotaUsage `json:"namespaceQuotas"`
+}
+
+// Pause stops sending the chunks in kernel until Resume is called, the hooks stay attached.
+// The chunks that are already in the perf buffer are dropped.
+func (t *Tracer) Pause() error {
+	t.settingsLock.Lock()
+	defer t.settingsLock.Unlock()
+
+	if t.paused.Load() {
+		return nil
+	}
+
+	if err := writeSettings(&t.bpfObjects, &t.config, true, t.poller.ringbuf); err != nil {
+		return err
+	}
+
+	t.paused.Store(true)
+	log.Info().Msg("Capture is paused")
+
+	return nil
+}
+
+func (t *Tracer) Resume() error {
+	t.settingsLock.Lock()
+	defer t.settingsLock.Unlock()
+
+	if !t.paused.Load() {
+		return nil
+	}
+
+	if err := writeSettings(&t.bpfObjects, &t.config, false, t.poller.ringbuf); err != nil {
+		return err
+	}
+
+	t.paused.Store(false)
+	log.Info().Msg("Capture is resumed")
+
+	return nil
+}
+
+func (t *Tracer) isPaused() bool {
+	return t.paused.Load()
+}
+
+// writeSettings passes the settings that the eBPF programs consult to settings_map
+func writeSettings(bpfObjects *tracerObjects, config *Config, paused bool, ringbuf bool) error {
+	settings := tracerSettings{}
+	if config.MetadataOnly {
+		settings.MetadataMode = 1
+	}
+	if config.SyscallsOnly {
+		settings.SyscallsOnly = 1
+	}
+	if paused {
+		settings.Paused = 1
+	}
+	if ringbuf {
+		settings.Ringbuf = 1
+	}
+
+	if err := bpfObjects.tracerMaps.SettingsMap.Put(uint32(0), settings); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	return nil
+}
+
+// SetChunksBufferSize replaces the perf buffer of the chunks with a buffer of the given
+// size per CPU, the chunks that are not read from the previous buffer are lost. The size
+// of the ring buffer is applied on restart only.
+func (t *Tracer) SetChunksBufferSize(size int) error {
+	if size <= 0 {
+		return errors.Errorf("Invalid chunks buffer size %d", size)
+	}
+
+	if t.poller.ringbuf {
+		return errors.Errorf("The chunks are sent through the ring buffer, its size is applied on restart")
+	}
+
+	if err := t.poller.resize(&t.bpfObjects, size); err != nil {
+		return err
+	}
+
+	log.Info().Int("size", size).Msg("Resized the chunks buffer:")
+
+	return nil
+}
+
+func (t *Tracer) Procfs() string {
+	return t.procfs
+}
+
+func (t *Tracer) Status() Status {
+	status := Status{
+		Paused:           t.isPaused(),
+		ChunksBufferSize: t.poller.getChunksBufferSize(),
+		Pids:             make([]uint32, 0),
+	}
+
+	if t.poller.ringbuf {
+		status.RingBufferSize = t.config.RingBufferSize
+	}
+
+	t.registeredPids.Range(func(key, v interface{}) bool {
+		if pid := key.(uint32); pid != GlobalWorkerPid {
+			status.Pids = append(status.Pids, pid)
+		}
+		return true
+	})
+	sort.Slice(status.Pids, func(i, j int) bool { return status.Pids[i] < status.Pids[j] })
+
+	status.HeldChunks = t.poller.hold.held.Load()
+	status.Consumers = t.getSubscriptionStatuses()
+	status.Subscriptions = len(status.Consumers)
+	status.NamespaceQuotas = t.QuotaUsage()
+
+	return status
+}
+
+// QuotaUsage returns the usage of the namespaces in the current window of the quota
+func (t *Tracer) QuotaUsage() []QuotaUsage {
+	return t.poller.quota.getUsages()
+}
+
+func (p *tlsPoller) resize(bpfObjects *tracerObjects, bufferSize int) error {
+	reader, err := perf.NewReader(bpfObjects.ChunksBuffer, bufferSize)
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	p.readerLock.Lock()
+	previous := p.chunksReader
+	p.chunksReader = perfChunksReader{reader}
+	p.bufferSize = bufferSize
+	p.readerLock.Unlock()
+
+	if err := previous.Close(); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	return nil
+}
+
+func (p *tlsPoller) getChunksReader() chunksReader {
+	p.readerLock.Lock()
+	defer p.readerLock.Unlock()
+
+	return p.chunksReader
+}
+
+func (p *tlsPoller) getChunksBufferSize() int {
+	p.readerLock.Lock()
+	defer p.readerLock.Unlock()
+
+	return p.bufferSize
+}
@@ -0,0 +1,120 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	cpuThrottleInterval      = 5 * time.Second
+	cpuThrottleMaxSampleRate = 64
+	// A stream that isn't admitted is considered again after this, e.g. its connection is
+	// closed and its addresses are reused, or the throttle is lifted
+	skippedStreamTtl = time.Minute
+)
+
+// cpuThrottle watches the CPU usage of the tracer itself and, while it is above the
+// configured percentage of a single core, admits only one out of sampleRate new streams.
+// Streams that are already admitted are never cut, so the PCAP stays consistent.
+type cpuThrottle struct {
+	maxPercent float64
+	sampleRate int32
+	counter    uint32
+}
+
+func newCpuThrottle(maxPercent float64) *cpuThrottle {
+	return &cpuThrottle{
+		maxPercent: maxPercent,
+		sampleRate: 1,
+	}
+}
+
+func (c *cpuThrottle) isEnabled() bool {
+	return c.maxPercent > 0
+}
+
+func (c *cpuThrottle) admit() bool {
+	rate := atomic.LoadInt32(&c.sampleRate)
+	if rate <= 1 {
+		return true
+	}
+
+	return atomic.AddUint32(&c.counter, 1)%uint32(rate) == 0
+}
+
+// isSkippedStream checks whether the stream of key wasn't admitted within skippedStreamTtl
+func (p *tlsPoller) isSkippedStream(key string, now time.Time) bool {
+	skippedAt, ok := p.skippedStreams.Get(key)
+	if !ok {
+		return false
+	}
+
+	if now.Sub(skippedAt.(time.Time)) < skippedStreamTtl {
+		return true
+	}
+
+	p.skippedStreams.Remove(key)
+	return false
+}
+
+func (c *cpuThrottle) watch(ctx context.Context) {
+	lastCpu, err := getCpuTime()
+	if err != nil {
+		LogError(err)
+		return
+	}
+	lastTime := time.Now()
+
+	ticker := time.NewTicker(cpuThrottleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cpu, err := getCpuTime()
+		if err != nil {
+			LogError(err)
+			return
+		}
+		now := time.Now()
+
+		percent := float64(cpu-lastCpu) / float64(now.Sub(lastTime)) * 100
+		lastCpu, lastTime = cpu, now
+
+		c.adjust(percent)
+	}
+}
+
+func (c *cpuThrottle) adjust(percent float64) {
+	rate := atomic.LoadInt32(&c.sampleRate)
+	newRate := rate
+
+	if percent > c.maxPercent && rate < cpuThrottleMaxSampleRate {
+		newRate = rate * 2
+	} else if percent < c.maxPercent/2 && rate > 1 {
+		newRate = rate / 2
+	}
+
+	if newRate != rate {
+		atomic.StoreInt32(&c.sampleRate, newRate)
+		log.Info().Msg(fmt.Sprintf("CPU usage %.1f%% (max: %.1f%%), sampling 1 out of %d new streams", percent, c.maxPercent, newRate))
+	}
+}
+
+func getCpuTime() (time.Duration, error) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, err
+	}
+
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()), nil
+}
@@ -0,0 +1,42 @@
+package tracer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+func TestIsSkippedStream(t *testing.T) {
+	skipped, err := simplelru.NewLRU(16, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &tlsPoller{skippedStreams: skipped}
+
+	now := time.Now()
+	skipped.Add("recent", now.Add(-time.Second))
+	skipped.Add("expired", now.Add(-skippedStreamTtl))
+
+	tests := []struct {
+		key     string
+		skipped bool
+		kept    bool
+	}{
+		{"recent", true, true},
+		{"expired", false, false},
+		{"unknown", false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			if got := p.isSkippedStream(test.key, now); got != test.skipped {
+				t.Fatalf("got %v, want %v", got, test.skipped)
+			}
+
+			if got := skipped.Contains(test.key); got != test.kept {
+				t.Fatalf("got kept %v, want %v", got, test.kept)
+			}
+		})
+	}
+}
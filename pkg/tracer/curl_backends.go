@@ -0,0 +1,72 @@
+package tracer
+
+import (
+	"debug/elf"
+	"regexp"
+	"strings"
+)
+
+// libcurl.so and the variants of the distributions built against other TLS backends, e.g.
+// libcurl-gnutls.so.4 and libcurl-nss.so.4 of Debian
+var curlLibraryRegex = regexp.MustCompile(`/libcurl(-[a-z]+)?\.so[^/]*$`)
+
+// The TLS backends libcurl can be built against
+const (
+	tlsBackendOpenssl = "openssl"
+	tlsBackendGnutls  = "gnutls"
+	tlsBackendNss     = "nss"
+	tlsBackendMbedtls = "mbedtls"
+	tlsBackendWolfssl = "wolfssl"
+)
+
+// The prefixes of the libraries of each backend in DT_NEEDED. NSS names its TLS library
+// libssl3.so, OpenSSL 3 is libssl.so.3.
+var tlsBackendLibraries = []struct {
+	backend string
+	prefix  string
+}{
+	{tlsBackendOpenssl, "libssl.so"},
+	{tlsBackendGnutls, "libgnutls.so"},
+	{tlsBackendNss, "libssl3.so"},
+	{tlsBackendNss, "libnss3.so"},
+	{tlsBackendMbedtls, "libmbedtls.so"},
+	{tlsBackendWolfssl, "libwolfssl.so"},
+}
+
+// findCurlTlsBackends returns the TLS backends libcurl is built against, more than one in
+// case of a MultiSSL build. OpenSSL is linked statically if SSL_write is defined in libcurl
+// itself, e.g. in the builds of PHP that bundle their libcurl.
+func findCurlTlsBackends(path string) ([]string, bool) {
+	file, err := elf.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+
+	libraries, err := file.ImportedLibraries()
+	if err != nil {
+		return nil, false
+	}
+
+	backends := make([]string, 0)
+	for _, library := range tlsBackendLibraries {
+		if containsString(backends, library.backend) {
+			continue
+		}
+
+		for _, imported := range libraries {
+			if strings.HasPrefix(imported, library.prefix) {
+				backends = append(backends, library.backend)
+				break
+			}
+		}
+	}
+
+	symbol, err := getSymbol(file, "SSL_write")
+	static := err == nil && symbol.Section != elf.SHN_UNDEF
+	if static && !containsString(backends, tlsBackendOpenssl) {
+		backends = append(backends, tlsBackendOpenssl)
+	}
+
+	return backends, static
+}
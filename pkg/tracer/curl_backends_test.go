@@ -0,0 +1,143 @@
+package tracer
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeCurlElf writes an ELF library that needs the libraries in its dynamic section, with
+// SSL_write defined in its text if static, or undefined as an import otherwise
+func writeCurlElf(t *testing.T, fpath string, needed []string, static bool) {
+	strtab := []byte("\x00SSL_write\x00")
+	shstrtab := []byte("\x00.text\x00.strtab\x00.symtab\x00.dynstr\x00.dynamic\x00.shstrtab\x00")
+	text := make([]byte, 16)
+
+	sym := elf.Sym64{Name: 1, Info: elf.ST_INFO(elf.STB_GLOBAL, elf.STT_FUNC), Size: 16}
+	if static {
+		sym.Shndx = 1
+	}
+
+	var symtab bytes.Buffer
+	_ = binary.Write(&symtab, binary.LittleEndian, []elf.Sym64{{}, sym})
+
+	dynstr := []byte{0}
+	dyns := make([]elf.Dyn64, 0, len(needed)+1)
+	for _, library := range needed {
+		dyns = append(dyns, elf.Dyn64{Tag: int64(elf.DT_NEEDED), Val: uint64(len(dynstr))})
+		dynstr = append(dynstr, library+"\x00"...)
+	}
+	dyns = append(dyns, elf.Dyn64{Tag: int64(elf.DT_NULL)})
+
+	var dynamic bytes.Buffer
+	_ = binary.Write(&dynamic, binary.LittleEndian, dyns)
+
+	headerSize := uint64(binary.Size(elf.Header64{}))
+	contents := [][]byte{text, strtab, symtab.Bytes(), dynstr, dynamic.Bytes(), shstrtab}
+	offsets := make([]uint64, len(contents))
+	offset := headerSize
+	for i, content := range contents {
+		offsets[i] = offset
+		offset += uint64(len(content))
+	}
+
+	sections := []elf.Section64{
+		{},
+		{Name: 1, Type: uint32(elf.SHT_PROGBITS), Flags: uint64(elf.SHF_ALLOC | elf.SHF_EXECINSTR), Off: offsets[0], Size: uint64(len(text)), Addralign: 16},
+		{Name: 7, Type: uint32(elf.SHT_STRTAB), Off: offsets[1], Size: uint64(len(strtab)), Addralign: 1},
+		{Name: 15, Type: uint32(elf.SHT_SYMTAB), Off: offsets[2], Size: uint64(symtab.Len()), Link: 2, Info: 1, Addralign: 8, Entsize: 24},
+		{Name: 23, Type: uint32(elf.SHT_STRTAB), Off: offsets[3], Size: uint64(len(dynstr)), Addralign: 1},
+		{Name: 31, Type: uint32(elf.SHT_DYNAMIC), Off: offsets[4], Size: uint64(dynamic.Len()), Link: 4, Addralign: 8, Entsize: 16},
+		{Name: 40, Type: uint32(elf.SHT_STRTAB), Off: offsets[5], Size: uint64(len(shstrtab)), Addralign: 1},
+	}
+
+	header := elf.Header64{
+		Type:      uint16(elf.ET_DYN),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     offset,
+		Ehsize:    uint16(headerSize),
+		Phentsize: uint16(binary.Size(elf.Prog64{})),
+		Shentsize: uint16(binary.Size(elf.Section64{})),
+		Shnum:     uint16(len(sections)),
+		Shstrndx:  6,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	var file bytes.Buffer
+	_ = binary.Write(&file, binary.LittleEndian, header)
+	for _, content := range contents {
+		file.Write(content)
+	}
+	_ = binary.Write(&file, binary.LittleEndian, sections)
+
+	writeFile(t, fpath, file.Bytes())
+}
+
+func TestFindCurlTlsBackends(t *testing.T) {
+	tests := []struct {
+		name     string
+		needed   []string
+		static   bool
+		backends []string
+	}{
+		{"openssl 3", []string{"libz.so.1", "libssl.so.3", "libcrypto.so.3"}, false, []string{tlsBackendOpenssl}},
+		{"openssl 1.0 of rhel 7", []string{"libssl.so.10"}, false, []string{tlsBackendOpenssl}},
+		{"gnutls", []string{"libgnutls.so.30", "libnettle.so.8"}, false, []string{tlsBackendGnutls}},
+		{"nss", []string{"libnss3.so", "libssl3.so", "libsmime3.so"}, false, []string{tlsBackendNss}},
+		{"mbedtls", []string{"libmbedtls.so.14"}, false, []string{tlsBackendMbedtls}},
+		{"wolfssl", []string{"libwolfssl.so.35"}, false, []string{tlsBackendWolfssl}},
+		{"multissl", []string{"libssl.so.3", "libgnutls.so.30"}, false, []string{tlsBackendOpenssl, tlsBackendGnutls}},
+		{"static openssl", []string{"libz.so.1"}, true, []string{tlsBackendOpenssl}},
+		{"static openssl and gnutls", []string{"libgnutls.so.30"}, true, []string{tlsBackendGnutls, tlsBackendOpenssl}},
+		{"no tls", []string{"libz.so.1"}, false, []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "libcurl.so.4")
+			writeCurlElf(t, path, test.needed, test.static)
+
+			backends, static := findCurlTlsBackends(path)
+			if !reflect.DeepEqual(backends, test.backends) {
+				t.Errorf("got %v, want %v", backends, test.backends)
+			}
+
+			if static != test.static {
+				t.Errorf("got static %v, want %v", static, test.static)
+			}
+		})
+	}
+}
+
+func TestFindCurlTlsBackendsNotElf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "libcurl.so.4")
+	writeFile(t, path, []byte("not an ELF file"))
+
+	if backends, static := findCurlTlsBackends(path); backends != nil || static {
+		t.Fatalf("got %v and static %v", backends, static)
+	}
+}
+
+func TestCurlLibraryRegex(t *testing.T) {
+	tests := map[string]bool{
+		"/usr/lib/x86_64-linux-gnu/libcurl.so.4.8.0":      true,
+		"/usr/lib/x86_64-linux-gnu/libcurl-gnutls.so.4":   true,
+		"/usr/lib64/libcurl-nss.so.4":                     true,
+		"/usr/lib/libcurl.so":                             true,
+		"/usr/lib/x86_64-linux-gnu/libcurlpp.so.0":        false,
+		"/usr/lib/x86_64-linux-gnu/libcurl.so.4/whatever": false,
+	}
+
+	for path, want := range tests {
+		if got := curlLibraryRegex.MatchString(path); got != want {
+			t.Errorf("got %v for %s, want %v", got, path, want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package tracer
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// A call of a plugin that takes longer is aborted and the plugin is disabled, the poller
+// waits for it
+const pluginCallTimeout = 50 * time.Millisecond
+
+// 16 MiB of memory of a plugin
+const pluginMemoryPages = 256
+
+// DissectorPlugin is a WebAssembly module that detects and parses a protocol. It exports
+// its memory and three functions:
+//
+//	alloc(size i32) i32              a buffer of size bytes in its memory for the payload
+//	detect(ptr i32, len i32) i32     non-zero if the first payload of a stream is of its protocol
+//	dissect(ptr i32, len i32) i64    the pointer (high 32 bits) and the length (low 32 bits) of
+//	                                 a JSON object of the string fields of a payload, 0 if none
+//
+// alloc is called before each detect and dissect, it may return the same buffer each time.
+// WASI is available to the modules, the reactors are initialized with _initialize.
+type DissectorPlugin struct {
+	// The protocol of the streams it detects
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+func (p *DissectorPlugin) validate() error {
+	if p.Name == "" || p.Path == "" {
+		return errors.Errorf("Invalid dissector plugin %q, expected a name and a path", p.Name+"="+p.Path)
+	}
+
+	if isBuiltinProtocol(p.Name) {
+		return errors.Errorf("Dissector plugin %q is named as a built-in protocol", p.Name)
+	}
+
+	return nil
+}
+
+// dissectorPlugins are tried in order on the streams whose protocol isn't detected otherwise
+type dissectorPlugins struct {
+	runtime wazero.Runtime
+	plugins []*dissectorPlugin
+}
+
+type dissectorPlugin struct {
+	name     string
+	module   api.Module
+	alloc    api.Function
+	detect   api.Function
+	dissect  api.Function
+	disabled bool
+	sync.Mutex
+}
+
+func loadDissectorPlugins(configs []DissectorPlugin) (*dissectorPlugins, error) {
+	if len(configs) == 0 {
+		return &dissectorPlugins{}, nil
+	}
+
+	ctx := context.Background()
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(pluginMemoryPages))
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		runtime.Close(ctx)
+		return nil, errors.Wrap(err, 0)
+	}
+
+	p := &dissectorPlugins{runtime: runtime}
+	for _, config := range configs {
+		plugin, err := loadDissectorPlugin(ctx, runtime, config)
+		if err != nil {
+			runtime.Close(ctx)
+			return nil, err
+		}
+
+		p.plugins = append(p.plugins, plugin)
+		dissectorsLog.get().Info().Str("name", config.Name).Str("path", config.Path).Msg("Loaded dissector plugin:")
+	}
+
+	return p, nil
+}
+
+func loadDissectorPlugin(ctx context.Context, runtime wazero.Runtime, config DissectorPlugin) (*dissectorPlugin, error) {
+	wasm, err := os.ReadFile(config.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	compiled, err := runtime.CompileModule(ctx, wasm)
+	if err != nil {
+		return nil, errors.Errorf("Error compiling dissector plugin %s: %v", config.Path, err)
+	}
+
+	module, err := runtime.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().
+		WithName(config.Name).
+		WithStartFunctions("_initialize"))
+	if err != nil {
+		return nil, errors.Errorf("Error instantiating dissector plugin %s: %v", config.Path, err)
+	}
+
+	plugin := &dissectorPlugin{
+		name:    config.Name,
+		module:  module,
+		alloc:   module.ExportedFunction("alloc"),
+		detect:  module.ExportedFunction("detect"),
+		dissect: module.ExportedFunction("dissect"),
+	}
+
+	if plugin.alloc == nil || plugin.detect == nil || plugin.dissect == nil || module.Memory() == nil {
+		return nil, errors.Errorf("Dissector plugin %s doesn't export memory, alloc, detect and dissect", config.Path)
+	}
+
+	return plugin, nil
+}
+
+func (p *dissectorPlugins) isLoaded(name string) bool {
+	for _, plugin := range p.plugins {
+		if plugin.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// detectProtocol returns the name of the first plugin that detects the data, "" if none
+func (p *dissectorPlugins) detectProtocol(data []byte) string {
+	for _, plugin := range p.plugins {
+		if result, ok := plugin.call(plugin.detect, data); ok && result != 0 {
+			return plugin.name
+		}
+	}
+
+	return ""
+}
+
+// dissect returns the fields of the data by the plugin of the protocol, nil if there are none
+func (p *dissectorPlugins) dissect(protocol string, data []byte) map[string]string {
+	for _, plugin := range p.plugins {
+		if plugin.name != protocol {
+			continue
+		}
+
+		result, ok := plugin.call(plugin.dissect, data)
+		if !ok || result == 0 {
+			return nil
+		}
+
+		plugin.Lock()
+		output, ok := plugin.module.Memory().Read(uint32(result>>32), uint32(result))
+		var fields map[string]string
+		if ok {
+			ok = json.Unmarshal(output, &fields) == nil
+		}
+		plugin.Unlock()
+
+		if !ok {
+			dissectorsLog.get().Debug().Str("name", plugin.name).Msg("Invalid output of dissector plugin:")
+			return nil
+		}
+
+		return fields
+	}
+
+	return nil
+}
+
+// call passes the data to a function of the plugin, false if the plugin is disabled or fails.
+// A plugin that fails is disabled, its state may be inconsistent.
+func (p *dissectorPlugin) call(function api.Function, data []byte) (uint64, bool) {
+	if len(data) == 0 {
+		return 0, false
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.disabled {
+		return 0, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pluginCallTimeout)
+	defer cancel()
+
+	results, err := p.alloc.Call(ctx, uint64(len(data)))
+	if err == nil {
+		if !p.module.Memory().Write(uint32(results[0]), data) {
+			err = errors.Errorf("Buffer 0x%x of %d bytes is out of memory", results[0], len(data))
+		}
+	}
+	if err == nil {
+		results, err = function.Call(ctx, results[0], uint64(len(data)))
+	}
+	if err != nil {
+		p.disabled = true
+		dissectorsLog.get().Warn().Err(err).Str("name", p.name).Msg("Disabled dissector plugin:")
+		return 0, false
+	}
+
+	return results[0], true
+}
+
+func (p *dissectorPlugins) close() error {
+	if p.runtime == nil {
+		return nil
+	}
+
+	return p.runtime.Close(context.Background())
+}
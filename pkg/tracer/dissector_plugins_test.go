@@ -0,0 +1,142 @@
+package tracer
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The plugin of the "DEMO" protocol:
+//
+//	(module
+//	  (memory (export "memory") 1)
+//	  (func (export "alloc") (param i32) (result i32) i32.const 1024)
+//	  (func (export "detect") (param i32 i32) (result i32)
+//	    (i32.and (i32.eq (i32.load (local.get 0)) (i32.const 0x4f4d4544))
+//	             (i32.ge_u (local.get 1) (i32.const 4))))
+//	  (func (export "dissect") (param i32 i32) (result i64) i64.const 0x8000000000f)
+//	  (data (i32.const 2048) "{\"kind\":\"demo\"}"))
+const demoPlugin = "0061736d0100000001120360017f017f60027f7f017f60027f7f017e03040300" +
+	"01020503010001072504066d656d6f7279020005616c6c6f6300000664657465" +
+	"63740001076469737365637400020a270305004180080b1400200028020041c4" +
+	"8ab5fa0446200141044f710b0a00428f8080808080020b0b1601004180100b0f" +
+	"7b226b696e64223a2264656d6f227d"
+
+// The same exports, detect and dissect trap with unreachable
+const trappingPlugin = "0061736d0100000001120360017f017f60027f7f017f60027f7f017e03040300" +
+	"01020503010001072504066d656d6f7279020005616c6c6f6300000664657465" +
+	"63740001076469737365637400020a0f0305004180080b0300000b0300000b"
+
+// Only the memory is exported
+const emptyPlugin = "0061736d010000000503010001070a01066d656d6f72790200"
+
+func writePlugin(t *testing.T, name string, module string) string {
+	data, err := hex.DecodeString(module)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), name+".wasm")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestDissectorPlugins(t *testing.T) {
+	plugins, err := loadDissectorPlugins([]DissectorPlugin{
+		{Name: "trapping", Path: writePlugin(t, "trapping", trappingPlugin)},
+		{Name: "demo", Path: writePlugin(t, "demo", demoPlugin)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugins.close()
+
+	if !plugins.isLoaded("demo") || plugins.isLoaded(ProtocolHttp1) {
+		t.Fatal("the loaded plugins are wrong")
+	}
+
+	// A reload validates the same plugins again
+	config := DissectorPlugin{Name: "demo", Path: "/plugins/demo.wasm"}
+	if err := config.validate(); err != nil {
+		t.Fatalf("the loaded plugin doesn't validate: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		data     string
+		protocol string
+	}{
+		{"demo", "DEMO 1", "demo"},
+		{"other", "OMED 1", ""},
+		{"short", "DEM", ""},
+		{"empty", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := plugins.detectProtocol([]byte(test.data)); got != test.protocol {
+				t.Fatalf("got %q, want %q", got, test.protocol)
+			}
+		})
+	}
+
+	if !plugins.plugins[0].disabled {
+		t.Error("the trapping plugin isn't disabled")
+	}
+
+	want := map[string]string{"kind": "demo"}
+	if got := plugins.dissect("demo", []byte("DEMO 1")); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if got := plugins.dissect("trapping", []byte("DEMO 1")); got != nil {
+		t.Errorf("got %v from a disabled plugin", got)
+	}
+}
+
+func TestLoadDissectorPluginErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		module string
+		err    string
+	}{
+		{"missing exports", emptyPlugin, "doesn't export"},
+		{"not wasm", hex.EncodeToString([]byte("#!/bin/sh")), "Error compiling"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := loadDissectorPlugins([]DissectorPlugin{{Name: "invalid", Path: writePlugin(t, "invalid", test.module)}})
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("got %v, want an error with %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestDissectorPluginValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		plugin DissectorPlugin
+		err    bool
+	}{
+		{"valid", DissectorPlugin{Name: "mqtt", Path: "/plugins/mqtt.wasm"}, false},
+		{"no name", DissectorPlugin{Path: "/plugins/mqtt.wasm"}, true},
+		{"no path", DissectorPlugin{Name: "mqtt"}, true},
+		{"built in", DissectorPlugin{Name: ProtocolHttp1, Path: "/plugins/http.wasm"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.plugin.validate(); (err != nil) != test.err {
+				t.Fatalf("got %v, want an error %v", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package tracer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/kubeshark/tracer/misc"
+	"github.com/rs/zerolog/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+var dryRunKprobeSymbols = []string{"tcp_sendmsg", "tcp_recvmsg", "udp_sendmsg", "udp_recvmsg"}
+
+// DryRun validates everything that the tracer needs to run and logs the effective plan,
+// without attaching any probe or opening the perf buffers.
+func DryRun(config Config) error {
+	log.Info().Msg("Dry run, nothing is going to be attached")
+
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	checks := &dryRunChecks{}
+	check := checks.check
+
+	check("procfs", checkProcfs(config.Procfs))
+	check("data-dir", checkDataDir())
+	check("kernel", runKernelChecks(os.Stdout, config.Procfs))
+
+	bpfObjects := tracerObjects{}
+	_, err := loadBpfObjects(&bpfObjects, config.BtfPath, config.RingBufferSize, "")
+	check("bpf-objects", err)
+	if err == nil {
+		if err := bpfObjects.Close(); err != nil {
+			LogError(err)
+		}
+	}
+
+	check("kprobe-symbols", checkKprobeSymbols(config.Procfs))
+
+	for _, env := range []string{"KUBESHARK_GLOBAL_LIBSSL_PID", "KUBESHARK_GLOBAL_GOLANG_PID"} {
+		if pid := os.Getenv(env); pid != "" {
+			check(env, dryRunTarget(config.Procfs, pid, newOffsetsCache(config.OffsetsCachePath)))
+		}
+	}
+
+	log.Info().
+		Str("procfs", config.Procfs).
+		Str("master-pcap", misc.GetMasterPcapPath()).
+		Bool("checkpoint", config.Checkpoint).
+		Bool("metadata-only", config.MetadataOnly).
+		Msg("Plan:")
+
+	return checks.result()
+}
+
+type dryRunChecks struct {
+	failed bool
+}
+
+func (c *dryRunChecks) check(name string, err error) {
+	if err != nil {
+		c.failed = true
+		log.Error().Err(err).Str("check", name).Msg("Dry run check failed:")
+	} else {
+		log.Info().Str("check", name).Msg("Dry run check passed:")
+	}
+}
+
+func (c *dryRunChecks) result() error {
+	if c.failed {
+		return errors.New("dry run failed")
+	}
+
+	return nil
+}
+
+// DryRunAttach loads the eBPF objects and attaches the syscall tracepoints, the kprobes
+// and the uprobes of the targets as the tracer would, then detaches them. The perf buffers
+// aren't opened, so nothing is captured. A target that uses neither libssl.so nor Go
+// crypto/tls isn't a failure.
+func DryRunAttach(config Config, pods []v1.Pod) error {
+	log.Info().Msg("Dry run, the probes are attached and detached without capturing")
+
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	checks := &dryRunChecks{}
+
+	t := &Tracer{
+		config:       config,
+		procfs:       config.Procfs,
+		offsetsCache: newOffsetsCache(config.OffsetsCachePath),
+	}
+
+	var err error
+	if t.bpfFeatures, err = loadBpfObjects(&t.bpfObjects, config.BtfPath, config.RingBufferSize, ""); err != nil {
+		checks.check("bpf-objects", err)
+		return checks.result()
+	}
+	checks.check("bpf-objects", nil)
+
+	defer func() {
+		if err := t.bpfObjects.Close(); err != nil {
+			LogError(err)
+		}
+	}()
+
+	t.initProbeGroups()
+
+	// The links of a partial install are released with the objects on exit
+	if t.isProbeGroupEnabled(ProbeGroupSyscalls) {
+		err := t.syscallHooks.installSyscallHooks(&t.bpfObjects, "")
+		checks.check("syscall-tracepoints", err)
+		if err == nil {
+			checks.check("syscall-tracepoints-detach", joinErrors(t.syscallHooks.close()))
+		}
+	}
+
+	if t.isProbeGroupEnabled(ProbeGroupTcpKprobes) {
+		checks.check("kprobes", t.tcpKprobeHooks.installTcpKprobeHooks(&t.bpfObjects, t.bpfFeatures.fentry, ""))
+		checks.check("kprobes-detach", joinErrors(t.tcpKprobeHooks.close()))
+	}
+
+	targets, err := findTargetPids(config.Procfs, pods, &config)
+	checks.check("targets", err)
+
+	pids := make([]uint32, 0, len(targets))
+	for pid := range targets {
+		pids = append(pids, pid)
+	}
+	sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+
+	attached := 0
+	for _, result := range t.analyzeTargets(pids, config.AnalysisWorkers, config.AnalysisTimeout) {
+		ok, err := t.attachTarget(result)
+		checks.check(fmt.Sprintf("pid-%d", result.pid), err)
+		if ok {
+			attached++
+		}
+	}
+
+	for _, hooks := range t.sslHooksStructs {
+		checks.check("uprobes-detach", joinErrors(hooks.close()))
+	}
+
+	for _, hooks := range t.goHooksStructs {
+		checks.check("uprobes-detach", joinErrors(hooks.close()))
+	}
+
+	for _, hooks := range t.tlsLibraryHooksStructs {
+		checks.check("uprobes-detach", joinErrors(hooks.close()))
+	}
+
+	log.Info().Int("targets", len(pids)).Int("attached", attached).Msg("Plan:")
+
+	return checks.result()
+}
+
+func joinErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+
+	return errors.New(strings.Join(messages, "; "))
+}
+
+func checkProcfs(procfs string) error {
+	if _, err := os.ReadDir(procfs); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	return nil
+}
+
+func checkDataDir() error {
+	file, err := os.CreateTemp(misc.GetDataDir(), ".dry-run-")
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+	file.Close()
+
+	return os.Remove(file.Name())
+}
+
+func checkKprobeSymbols(procfs string) error {
+	file, err := os.Open(fmt.Sprintf("%s/kallsyms", procfs))
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+	defer file.Close()
+
+	missing := make(map[string]bool)
+	for _, symbol := range dryRunKprobeSymbols {
+		missing[symbol] = true
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) < 3 {
+			continue
+		}
+
+		delete(missing, parts[2])
+	}
+
+	if len(missing) > 0 {
+		return errors.Errorf("Kernel symbols not found: %v", missing)
+	}
+
+	return nil
+}
+
+func dryRunTarget(procfs string, pid string, cache *offsetsCache) error {
+	var _pid uint32
+	if _, err := fmt.Sscan(pid, &_pid); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	if sslLibraries, err := findSsllibs(procfs, _pid); err == nil {
+		for _, sslLibrary := range sslLibraries {
+			version := "unknown"
+			if library, err := resolveSslLibrary(sslLibrary); err == nil {
+				version = library.version
+			}
+			log.Info().Str("pid", pid).Str("path", sslLibrary).Str("version", version).Msg("Would target libssl.so:")
+		}
+	}
+
+	for _, library := range tlsLibraries {
+		files, _ := findTlsLibraryFiles(procfs, _pid, library)
+		for _, file := range files {
+			log.Info().Str("pid", pid).Str("library", library.group).Str("path", file).Msg("Would target TLS library:")
+		}
+	}
+
+	exePath, err := findLibraryByPid(procfs, _pid, "")
+	if err != nil {
+		return err
+	}
+
+	offsets, err := cache.findGoOffsets(exePath)
+	if err != nil {
+		log.Info().Str("pid", pid).Str("path", exePath).Msg("Not a Go binary or symbol table is stripped:")
+		return nil
+	}
+
+	log.Info().Str("pid", pid).Str("path", exePath).Str("go-version", offsets.GoVersion).Msg("Would target Go crypto/tls:")
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package tracer
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-errors/errors"
+)
+
+// enrichmentHold keeps the chunks that arrive after Start until the pods of the targets are
+// known, so the first events have their namespace and workload. The chunks are released
+// in order when SetEnrichmentReady is called or the hold time passes, the oldest chunk is
+// released without waiting while the hold is full.
+type enrichmentHold struct {
+	holdTime  time.Duration
+	maxChunks int
+	ready     chan struct{}
+	readyOnce sync.Once
+	chunks    []*tracerTlsChunk
+	// For the status and the health checks, the chunks are only accessed by the poller
+	holding    atomic.Bool
+	held       atomic.Int64
+	overflowed int
+}
+
+func newEnrichmentHold(holdTime time.Duration, maxChunks int) *enrichmentHold {
+	return &enrichmentHold{
+		holdTime:  holdTime,
+		maxChunks: maxChunks,
+		ready:     make(chan struct{}),
+	}
+}
+
+func (h *enrichmentHold) setReady() {
+	h.readyOnce.Do(func() {
+		close(h.ready)
+	})
+}
+
+func (h *enrichmentHold) isReady() bool {
+	select {
+	case <-h.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// start returns false if there is nothing to wait for
+func (h *enrichmentHold) start() bool {
+	if h.holdTime <= 0 || h.maxChunks <= 0 || h.isReady() {
+		return false
+	}
+
+	h.holding.Store(true)
+	pollerLog.get().Info().Dur("hold-time", h.holdTime).Int("max-chunks", h.maxChunks).Msg("Holding the chunks until the targets are enriched:")
+
+	return true
+}
+
+func (h *enrichmentHold) isHolding() bool {
+	return h.holding.Load()
+}
+
+// add returns the chunk to handle now if the hold is full
+func (h *enrichmentHold) add(chunk *tracerTlsChunk) *tracerTlsChunk {
+	h.chunks = append(h.chunks, chunk)
+	if len(h.chunks) <= h.maxChunks {
+		h.held.Store(int64(len(h.chunks)))
+		return nil
+	}
+
+	oldest := h.chunks[0]
+	h.chunks[0] = nil
+	h.chunks = h.chunks[1:]
+	h.overflowed++
+
+	return oldest
+}
+
+// release ends the hold and returns the held chunks in order
+func (h *enrichmentHold) release(reason string) []*tracerTlsChunk {
+	chunks := h.chunks
+	h.chunks = nil
+	h.holding.Store(false)
+	h.held.Store(0)
+
+	pollerLog.get().Info().
+		Str("reason", reason).
+		Int("chunks", len(chunks)).
+		Int("released-early", h.overflowed).
+		Msg("Released the held chunks:")
+
+	return chunks
+}
+
+func (h *enrichmentHold) check() error {
+	if h.isHolding() {
+		return errors.Errorf("Waiting for the enrichment of the targets, %d chunks are held", h.held.Load())
+	}
+
+	return nil
+}
+
+// SetEnrichmentReady releases the chunks that are held since Start, it's called once the
+// pods of the targets are known. Nothing is held if it's called before Start.
+func (t *Tracer) SetEnrichmentReady() {
+	t.poller.hold.setReady()
+}
@@ -0,0 +1,384 @@
+package tracer
+
+import (
+	"net"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog/log"
+)
+
+const defaultEventBufferSize = 1024
+
+// Event is a decrypted chunk of a TLS stream, the JSON form is the format of the chunk files
+type Event struct {
+	StreamId int64  `json:"streamId"`
+	Pid      uint32 `json:"pid"`
+	Fd       uint32 `json:"fd"`
+	SrcIP    net.IP `json:"srcIp"`
+	SrcPort  uint16 `json:"srcPort"`
+	DstIP    net.IP `json:"dstIp"`
+	DstPort  uint16 `json:"dstPort"`
+	IsClient bool   `json:"isClient"`
+	IsRead   bool   `json:"isRead"`
+	IsUdp    bool   `json:"isUdp,omitempty"`
+	// The namespace and the controller of the pod of the process, empty for the processes
+	// that are not in a pod
+	Namespace string `json:"namespace,omitempty"`
+	Workload  string `json:"workload,omitempty"`
+	// The leg of a meshed pod, app (app to sidecar) or sidecar (sidecar to upstream), empty
+	// if the pod isn't meshed
+	MeshLeg string `json:"meshLeg,omitempty"`
+	// The eBPF probe that produced the chunk
+	Origin ProbeOrigin `json:"origin"`
+	// The application protocol of the stream, e.g. http/1
+	Protocol string `json:"protocol,omitempty"`
+	// The fingerprints of the ClientHello of the stream, with -capture-handshakes
+	Ja3 string `json:"ja3,omitempty"`
+	Ja4 string `json:"ja4,omitempty"`
+	// Shared by the subscriptions, must not be modified. Nil if the payload capture is not
+	// allowed for the peer, Size is set anyway.
+	Data      []byte    `json:"data"`
+	Size      uint32    `json:"size"`
+	Timestamp time.Time `json:"timestamp"`
+	// Extracted by the label rules from the chunks of the stream so far, nil if none of them
+	// matched
+	Labels map[string]string `json:"labels,omitempty"`
+	// Set if the chunk starts an HTTP/1.x message
+	Http *HttpMessage `json:"http,omitempty"`
+	// Parsed by the dissector plugin of the protocol of the stream
+	Fields map[string]string `json:"fields,omitempty"`
+	// The stream is no longer tracked because of MaxStreams, the event has no payload
+	Shed bool `json:"shed,omitempty"`
+	// Set on the events that aren't chunks of a stream, they have no payload
+	Notice *Notice `json:"notice,omitempty"`
+	// Attached to the stream by the consumers with Annotate, shared by the subscriptions,
+	// must not be modified
+	Annotations []string `json:"annotations,omitempty"`
+}
+
+// The types of the notices
+const (
+	// A namespace exceeded its quota, Event.Namespace
+	NoticeQuotaExceeded = "quota-exceeded"
+	// A process contacted an external destination that isn't in the egress baseline of its
+	// workload, Event.Pid
+	NoticeNewDestination = "new-destination"
+	// The annotations of a stream changed, Event.StreamId and Event.Annotations
+	NoticeAnnotation = "annotation"
+)
+
+// Notice is an event of the tracer itself
+type Notice struct {
+	Type string `json:"type"`
+	// Of NoticeQuotaExceeded
+	Quota *QuotaUsage `json:"quota,omitempty"`
+	// Of NoticeNewDestination, <ip>:<port> or the host of an HTTP/1.x request
+	Destination string `json:"destination,omitempty"`
+}
+
+func newEvent(chunk *tracerTlsChunk, stream *tlsStream, target pidTarget) Event {
+	srcIp, srcPort := chunk.getSrcAddress()
+	dstIp, dstPort := chunk.getDstAddress()
+
+	event := Event{
+		StreamId:  stream.getId(),
+		Pid:       chunk.Pid,
+		Fd:        chunk.Fd,
+		SrcIP:     srcIp,
+		SrcPort:   srcPort,
+		DstIP:     dstIp,
+		DstPort:   dstPort,
+		IsClient:  chunk.isClient(),
+		IsRead:    chunk.isRead(),
+		IsUdp:     chunk.isUdp(),
+		Namespace: target.namespace,
+		Workload:  target.workload,
+		MeshLeg:   target.meshLeg,
+		Origin:    chunk.getOrigin(),
+		Protocol:  stream.protocol,
+		Data:      chunk.getRecordedData(),
+		Size:      chunk.Recorded,
+		Timestamp: time.Now().UTC(),
+	}
+
+	if stream.fingerprint != nil {
+		event.Ja3 = stream.fingerprint.Ja3
+		event.Ja4 = stream.fingerprint.Ja4
+	}
+
+	if annotations := stream.annotations.Load(); annotations != nil {
+		event.Annotations = *annotations
+	}
+
+	return event
+}
+
+// EventFilter selects the events of a subscription, the zero value matches all events
+type EventFilter struct {
+	Pids []uint32 `json:"pids,omitempty"`
+	// Matches either the source or the destination address
+	IPs   []net.IP `json:"ips,omitempty"`
+	Ports []uint16 `json:"ports,omitempty"`
+	// The protocols of the streams, e.g. http/1, http/2 or the name of a dissector plugin
+	Protocols []string `json:"protocols,omitempty"`
+	// Only the chunks that are read or written by the targeted process
+	OnlyRead  bool `json:"onlyRead,omitempty"`
+	OnlyWrite bool `json:"onlyWrite,omitempty"`
+}
+
+// matchesStream checks the fields that are the same for all the events of a stream
+func (f *EventFilter) matchesStream(event *Event) bool {
+	if len(f.Protocols) > 0 && !containsString(f.Protocols, event.Protocol) {
+		return false
+	}
+
+	if len(f.IPs) > 0 && !containsIP(f.IPs, event.SrcIP) && !containsIP(f.IPs, event.DstIP) {
+		return false
+	}
+
+	if len(f.Ports) > 0 && !containsPort(f.Ports, event.SrcPort) && !containsPort(f.Ports, event.DstPort) {
+		return false
+	}
+
+	return true
+}
+
+// matchesChunk checks the rest of the fields, a stream can be shared by the processes
+func (f *EventFilter) matchesChunk(event *Event) bool {
+	if f.OnlyRead && !event.IsRead || f.OnlyWrite && event.IsRead {
+		return false
+	}
+
+	return len(f.Pids) == 0 || containsPid(f.Pids, event.Pid)
+}
+
+// Matches checks all the fields, e.g. of the events that are read back from the spool
+func (f *EventFilter) Matches(event *Event) bool {
+	return f.matchesStream(event) && f.matchesChunk(event)
+}
+
+type subscription struct {
+	id        uint64
+	filter    EventFilter
+	events    chan Event
+	delivered uint64
+	dropped   uint64
+	// The number of the streams whose events are fanned out to the subscription
+	streams int
+}
+
+// SubscriptionStatus is a snapshot of a subscription for the status of the Tracer
+type SubscriptionStatus struct {
+	Id        uint64      `json:"id"`
+	Filter    EventFilter `json:"filter"`
+	Streams   int         `json:"streams"`
+	Delivered uint64      `json:"delivered"`
+	Dropped   uint64      `json:"dropped"`
+}
+
+// streamFanout is the subscriptions that the events of a stream are delivered to, resolved
+// again when a subscription is added or removed, or the protocol of the stream is detected
+type streamFanout struct {
+	generation    uint64
+	subscriptions []*subscription
+}
+
+// Subscribe returns a channel of the events that match the filter. The events are dropped
+// while the channel is full. The channel is closed on Unsubscribe or Stop.
+func (t *Tracer) Subscribe(filter EventFilter) (<-chan Event, error) {
+	if filter.OnlyRead && filter.OnlyWrite {
+		return nil, errors.New("OnlyRead and OnlyWrite are mutually exclusive")
+	}
+
+	for _, protocol := range filter.Protocols {
+		if !t.IsKnownProtocol(protocol) {
+			return nil, errors.Errorf("Unknown protocol %q, expected one of %v or a dissector plugin", protocol, protocols)
+		}
+	}
+
+	t.subsLock.Lock()
+	defer t.subsLock.Unlock()
+
+	if t.isStopped {
+		return nil, errors.New("Tracer is stopped")
+	}
+
+	bufferSize := t.config.EventBufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultEventBufferSize
+	}
+
+	t.lastSubsId++
+	s := &subscription{
+		id:     t.lastSubsId,
+		filter: filter,
+		events: make(chan Event, bufferSize),
+	}
+	t.subscriptions = append(t.subscriptions, s)
+	t.subsGeneration++
+
+	log.Info().Uint64("id", s.id).Int("subscriptions", len(t.subscriptions)).Msg("Subscribed:")
+
+	return s.events, nil
+}
+
+// Unsubscribe closes the channel of a subscription
+func (t *Tracer) Unsubscribe(events <-chan Event) {
+	t.subsLock.Lock()
+	defer t.subsLock.Unlock()
+
+	for i, s := range t.subscriptions {
+		if s.events == events {
+			close(s.events)
+			t.subscriptions = append(t.subscriptions[:i], t.subscriptions[i+1:]...)
+			t.subsGeneration++
+			log.Info().Uint64("id", s.id).Uint64("delivered", s.delivered).Uint64("dropped", s.dropped).Msg("Unsubscribed:")
+			return
+		}
+	}
+}
+
+func (t *Tracer) closeSubscriptions() {
+	t.subsLock.Lock()
+	defer t.subsLock.Unlock()
+
+	for _, s := range t.subscriptions {
+		close(s.events)
+	}
+
+	t.subscriptions = nil
+	t.subsGeneration++
+	t.isStopped = true
+}
+
+// emit delivers the event to the subscriptions of its stream
+func (t *Tracer) emit(stream *tlsStream, event Event) {
+	t.subsLock.Lock()
+	defer t.subsLock.Unlock()
+
+	if stream.fanout.generation != t.subsGeneration {
+		t.resolveFanout(stream, &event)
+	}
+
+	for _, s := range stream.fanout.subscriptions {
+		if s.filter.matchesChunk(&event) {
+			s.deliver(event)
+		}
+	}
+}
+
+// Publish delivers a notice to the subscriptions whose filter matches it, e.g. of a consumer
+// of the events
+func (t *Tracer) Publish(event Event) {
+	t.subsLock.Lock()
+	defer t.subsLock.Unlock()
+
+	for _, s := range t.subscriptions {
+		if s.filter.Matches(&event) {
+			s.deliver(event)
+		}
+	}
+}
+
+// deliver is called with subsLock held, the event is dropped if the channel is full
+func (s *subscription) deliver(event Event) {
+	select {
+	case s.events <- event:
+		s.delivered++
+	default:
+		s.dropped++
+		if s.dropped%10000 == 1 {
+			log.Warn().Uint64("dropped", s.dropped).Msg("Event channel is full, dropping events:")
+		}
+	}
+}
+
+// resolveFanout is called with subsLock held, subsGeneration is incremented when the
+// subscriptions change so the streams resolve their fan-out again
+func (t *Tracer) resolveFanout(stream *tlsStream, event *Event) {
+	t.releaseFanout(stream)
+
+	for _, s := range t.subscriptions {
+		if s.filter.matchesStream(event) {
+			s.streams++
+			stream.fanout.subscriptions = append(stream.fanout.subscriptions, s)
+		}
+	}
+
+	stream.fanout.generation = t.subsGeneration
+}
+
+// releaseFanout is called with subsLock held
+func (t *Tracer) releaseFanout(stream *tlsStream) {
+	for _, s := range stream.fanout.subscriptions {
+		s.streams--
+	}
+
+	stream.fanout = streamFanout{}
+}
+
+// closeFanout is called when the stream is closed
+func (t *Tracer) closeFanout(stream *tlsStream) {
+	t.subsLock.Lock()
+	t.releaseFanout(stream)
+	t.subsLock.Unlock()
+}
+
+func (t *Tracer) getSubscriptionStatuses() []SubscriptionStatus {
+	t.subsLock.Lock()
+	defer t.subsLock.Unlock()
+
+	statuses := make([]SubscriptionStatus, 0, len(t.subscriptions))
+	for _, s := range t.subscriptions {
+		statuses = append(statuses, SubscriptionStatus{
+			Id:        s.id,
+			Filter:    s.filter,
+			Streams:   s.streams,
+			Delivered: s.delivered,
+			Dropped:   s.dropped,
+		})
+	}
+
+	return statuses
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsPid(pids []uint32, pid uint32) bool {
+	for _, p := range pids {
+		if p == pid {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, i := range ips {
+		if i.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsPort(ports []uint16, port uint16) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,51 @@
+package tracer
+
+import (
+	"net"
+	"testing"
+)
+
+func TestEventFilter(t *testing.T) {
+	event := Event{
+		Pid:      10,
+		SrcIP:    net.ParseIP("10.0.0.1"),
+		SrcPort:  40000,
+		DstIP:    net.ParseIP("10.0.0.2"),
+		DstPort:  443,
+		IsRead:   true,
+		Protocol: ProtocolHttp1,
+	}
+
+	tests := []struct {
+		name   string
+		filter EventFilter
+		stream bool
+		chunk  bool
+	}{
+		{"zero value", EventFilter{}, true, true},
+		{"source ip", EventFilter{IPs: []net.IP{net.ParseIP("10.0.0.1")}}, true, true},
+		{"destination ip", EventFilter{IPs: []net.IP{net.ParseIP("10.0.0.2")}}, true, true},
+		{"other ip", EventFilter{IPs: []net.IP{net.ParseIP("10.0.0.3")}}, false, true},
+		{"destination port", EventFilter{Ports: []uint16{443}}, true, true},
+		{"other port", EventFilter{Ports: []uint16{80}}, false, true},
+		{"protocol", EventFilter{Protocols: []string{ProtocolHttp1, ProtocolHttp2}}, true, true},
+		{"other protocol", EventFilter{Protocols: []string{ProtocolHttp2}}, false, true},
+		{"pid", EventFilter{Pids: []uint32{10}}, true, true},
+		{"other pid", EventFilter{Pids: []uint32{11}}, true, false},
+		{"only read", EventFilter{OnlyRead: true}, true, true},
+		{"only write", EventFilter{OnlyWrite: true}, true, false},
+		{"ip and other port", EventFilter{IPs: []net.IP{net.ParseIP("10.0.0.1")}, Ports: []uint16{80}}, false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.filter.matchesStream(&event); got != test.stream {
+				t.Fatalf("matchesStream got %v, want %v", got, test.stream)
+			}
+
+			if got := test.filter.matchesChunk(&event); got != test.chunk {
+				t.Fatalf("matchesChunk got %v, want %v", got, test.chunk)
+			}
+		})
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package tracer
 
 import (
 	"github.com/cilium/ebpf/link"
@@ -12,17 +12,21 @@ type goHooks struct {
 	goReadExProbes  []link.Link
 }
 
-func (s *goHooks) installUprobes(bpfObjects *tracerObjects, fpath string) error {
+func (s *goHooks) installUprobes(bpfObjects *tracerObjects, fpath string, offsets goOffsets, findErr error, overrides []SymbolOffset) error {
 	ex, err := link.OpenExecutable(fpath)
 
 	if err != nil {
 		return errors.Wrap(err, 0)
 	}
 
-	offsets, err := findGoOffsets(fpath)
+	overridden, err := overrideGoOffsets(overrides, fpath, &offsets)
 
 	if err != nil {
-		return errors.Wrap(err, 0)
+		return err
+	}
+
+	if findErr != nil && !overridden {
+		return errors.Wrap(findErr, 0)
 	}
 
 	return s.installHooks(bpfObjects, ex, offsets)
@@ -56,18 +60,14 @@ func (s *goHooks) installHooks(bpfObjects *tracerObjects, ex *link.Executable, o
 
 	// Symbol points to
 	// [`crypto/tls.(*Conn).Write`](https://github.com/golang/go/blob/go1.17.6/src/crypto/tls/conn.go#L1099)
-	s.goWriteProbe, err = ex.Uprobe(goWriteSymbol, goCryptoTlsWrite, &link.UprobeOptions{
-		Offset: offsets.GoWriteOffset.enter,
-	})
+	s.goWriteProbe, err = ex.Uprobe(goWriteSymbol, goCryptoTlsWrite, atFileOffset(offsets.GoWriteOffset.enter))
 
 	if err != nil {
 		return errors.Wrap(err, 0)
 	}
 
 	for _, offset := range offsets.GoWriteOffset.exits {
-		probe, err := ex.Uprobe(goWriteSymbol, goCryptoTlsWriteEx, &link.UprobeOptions{
-			Offset: offset,
-		})
+		probe, err := ex.Uprobe(goWriteSymbol, goCryptoTlsWriteEx, atFileOffset(offset))
 
 		if err != nil {
 			return errors.Wrap(err, 0)
@@ -78,18 +78,14 @@ func (s *goHooks) installHooks(bpfObjects *tracerObjects, ex *link.Executable, o
 
 	// Symbol points to
 	// [`crypto/tls.(*Conn).Read`](https://github.com/golang/go/blob/go1.17.6/src/crypto/tls/conn.go#L1263)
-	s.goReadProbe, err = ex.Uprobe(goReadSymbol, goCryptoTlsRead, &link.UprobeOptions{
-		Offset: offsets.GoReadOffset.enter,
-	})
+	s.goReadProbe, err = ex.Uprobe(goReadSymbol, goCryptoTlsRead, atFileOffset(offsets.GoReadOffset.enter))
 
 	if err != nil {
 		return errors.Wrap(err, 0)
 	}
 
 	for _, offset := range offsets.GoReadOffset.exits {
-		probe, err := ex.Uprobe(goReadSymbol, goCryptoTlsReadEx, &link.UprobeOptions{
-			Offset: offset,
-		})
+		probe, err := ex.Uprobe(goReadSymbol, goCryptoTlsReadEx, atFileOffset(offset))
 
 		if err != nil {
 			return errors.Wrap(err, 0)
@@ -101,6 +97,13 @@ func (s *goHooks) installHooks(bpfObjects *tracerObjects, ex *link.Executable, o
 	return nil
 }
 
+// atFileOffset attaches a uprobe at an offset in the file, findGoOffsets gives the file offsets
+// of the entry and of the RET instructions. UprobeOptions.Offset of cilium/ebpf is added to
+// the address of the symbol, only Address replaces it.
+func atFileOffset(offset uint64) *link.UprobeOptions {
+	return &link.UprobeOptions{Address: offset}
+}
+
 func (s *goHooks) close() []error {
 	errors := make([]error, 0)
 
@@ -1,4 +1,4 @@
-package main
+package tracer
 
 import (
 	"bufio"
@@ -39,12 +39,17 @@ type goExtendedOffset struct {
 }
 
 const (
-	minimumABIInternalGoVersion = "1.17.0"
-	goVersionSymbol             = "runtime.buildVersion.str" // symbol does not exist in Go (<=1.16)
-	goWriteSymbol               = "crypto/tls.(*Conn).Write"
-	goReadSymbol                = "crypto/tls.(*Conn).Read"
+	goVersionSymbol = "runtime.buildVersion.str" // symbol does not exist in Go (<=1.16)
+	goWriteSymbol   = "crypto/tls.(*Conn).Write"
+	goReadSymbol    = "crypto/tls.(*Conn).Read"
 )
 
+// The register-based calling convention came with Go 1.17 on amd64 and Go 1.18 on arm64
+var minimumABIInternalGoVersions = map[string]string{
+	"amd64": "1.17.0",
+	"arm64": "1.18.0",
+}
+
 func findGoOffsets(fpath string) (goOffsets, error) {
 	offsets, goidOffset, gStructOffset, err := getOffsets(fpath)
 	if err != nil {
@@ -199,22 +204,28 @@ func getGoidOffset(elfFile *elf.File) (goidOffset uint64, gStructOffset uint64,
 	return
 }
 
-func getOffsets(fpath string) (offsets map[string]*goExtendedOffset, goidOffset uint64, gStructOffset uint64, err error) {
-	var engine gapstone.Engine
+// newDisassembler returns a Capstone engine for the architecture of the tracer, which is the
+// one of the targets
+func newDisassembler() (gapstone.Engine, error) {
 	switch runtime.GOARCH {
 	case "amd64":
-		engine, err = gapstone.New(
+		return gapstone.New(
 			gapstone.CS_ARCH_X86,
 			gapstone.CS_MODE_64,
 		)
 	case "arm64":
-		engine, err = gapstone.New(
+		return gapstone.New(
 			gapstone.CS_ARCH_ARM64,
 			gapstone.CS_MODE_LITTLE_ENDIAN,
 		)
 	default:
-		err = fmt.Errorf("Unsupported architecture: %v", runtime.GOARCH)
+		return gapstone.Engine{}, fmt.Errorf("Unsupported architecture: %v", runtime.GOARCH)
 	}
+}
+
+func getOffsets(fpath string) (offsets map[string]*goExtendedOffset, goidOffset uint64, gStructOffset uint64, err error) {
+	var engine gapstone.Engine
+	engine, err = newDisassembler()
 	if err != nil {
 		return
 	}
@@ -364,6 +375,11 @@ func checkGoVersion(fpath string, offset *goExtendedOffset) (bool, string, error
 		return false, goVersionStr, err
 	}
 
+	minimumABIInternalGoVersion, ok := minimumABIInternalGoVersions[runtime.GOARCH]
+	if !ok {
+		return false, goVersionStr, fmt.Errorf("Unsupported architecture: %v", runtime.GOARCH)
+	}
+
 	goVersionConstraint, err := semver.NewConstraint(fmt.Sprintf(">= %s", minimumABIInternalGoVersion))
 	if err != nil {
 		return false, goVersionStr, err
@@ -0,0 +1,407 @@
+package tracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/hashicorp/golang-lru/simplelru"
+	"github.com/kubeshark/gopacket"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/unix"
+)
+
+const ethernetHeaderLen = 14
+
+// The packets that were written recently, a packet between two targets on the node is seen in
+// both of their network namespaces and a packet on the loopback is seen twice
+const handshakeSeenSize = 4096
+
+// handshakeCapture writes the ClientHello and ServerHello packets of the targets to the
+// master PCAP, so the handshake records are available next to the decrypted payloads, and
+// keeps the fingerprints of the ClientHellos for the streams. A packet socket is opened in the
+// network namespace of each target, the packets are filtered in kernel by the
+// tls_handshake_filter socket filter.
+type handshakeCapture struct {
+	program int
+	sorter  *PacketSorter
+	// The socket filter doesn't consult settings_map, the packets are dropped here
+	isPaused func() bool
+	// The payload policy of the peer
+	allows       func(peer net.IP) bool
+	fingerprints *tlsFingerprints
+	// By the inode of the network namespace
+	sockets map[uint64]*handshakeSocket
+	started bool
+	seen    *simplelru.LRU
+	seenMtx sync.Mutex
+	sync.Mutex
+}
+
+type handshakeSocket struct {
+	fd     int
+	netns  uint64
+	closed atomic.Bool
+}
+
+func newHandshakeCapture(bpfObjects *tracerObjects, sorter *PacketSorter, isPaused func() bool, allows func(peer net.IP) bool, fingerprints *tlsFingerprints) (*handshakeCapture, error) {
+	seen, err := simplelru.NewLRU(handshakeSeenSize, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	return &handshakeCapture{
+		program:      bpfObjects.tracerPrograms.TlsHandshakeFilter.FD(),
+		sorter:       sorter,
+		isPaused:     isPaused,
+		allows:       allows,
+		fingerprints: fingerprints,
+		sockets:      make(map[uint64]*handshakeSocket),
+		seen:         seen,
+	}, nil
+}
+
+// setTargets opens a socket in each network namespace of the processes that has none yet and
+// closes the sockets of the namespaces that are no longer targeted
+func (c *handshakeCapture) setTargets(procfs string, pids []uint32) {
+	namespaces := make(map[uint64]string)
+	for _, pid := range pids {
+		path := fmt.Sprintf("%s/%d/ns/net", procfs, pid)
+		var stat unix.Stat_t
+		if err := unix.Stat(path, &stat); err != nil {
+			log.Debug().Err(err).Uint32("pid", pid).Msg("Couldn't read the network namespace:")
+			continue
+		}
+		namespaces[stat.Ino] = path
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	for netns, socket := range c.sockets {
+		if _, ok := namespaces[netns]; !ok {
+			if err := c.closeSocket(socket); err != nil {
+				LogError(err)
+			}
+		}
+	}
+
+	for netns, path := range namespaces {
+		if _, ok := c.sockets[netns]; ok {
+			continue
+		}
+
+		fd, err := openHandshakeSocket(path, c.program)
+		if err != nil {
+			log.Warn().Err(err).Str("netns", path).Msg("Couldn't capture the TLS handshakes of the network namespace:")
+			continue
+		}
+
+		socket := &handshakeSocket{fd: fd, netns: netns}
+		c.sockets[netns] = socket
+		if c.started {
+			go c.poll(socket)
+		}
+	}
+
+	log.Debug().Int("namespaces", len(c.sockets)).Msg("Capturing the TLS handshakes:")
+}
+
+// openHandshakeSocket creates the packet socket in the network namespace of path, the socket
+// stays in it. The thread that fails to return to its namespace isn't unlocked, so it exits.
+func openHandshakeSocket(path string, program int) (int, error) {
+	type result struct {
+		fd  int
+		err error
+	}
+	results := make(chan result, 1)
+
+	go func() {
+		runtime.LockOSThread()
+
+		original, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()))
+		if err != nil {
+			runtime.UnlockOSThread()
+			results <- result{err: errors.Wrap(err, 0)}
+			return
+		}
+		defer original.Close()
+
+		target, err := os.Open(path)
+		if err != nil {
+			runtime.UnlockOSThread()
+			results <- result{err: errors.Wrap(err, 0)}
+			return
+		}
+		defer target.Close()
+
+		if err := unix.Setns(int(target.Fd()), unix.CLONE_NEWNET); err != nil {
+			runtime.UnlockOSThread()
+			results <- result{err: errors.Wrap(err, 0)}
+			return
+		}
+
+		fd, err := newHandshakeSocket(program)
+		results <- result{fd: fd, err: err}
+
+		if err := unix.Setns(int(original.Fd()), unix.CLONE_NEWNET); err != nil {
+			LogError(errors.Wrap(err, 0))
+			return
+		}
+		runtime.UnlockOSThread()
+	}()
+
+	r := <-results
+	return r.fd, r.err
+}
+
+// newHandshakeSocket receives the packets of all the interfaces of the network namespace of the
+// thread, the timeout lets a closed socket be noticed
+func newHandshakeSocket(program int) (int, error) {
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, int(htons(unix.ETH_P_ALL)))
+	if err != nil {
+		return 0, errors.Wrap(err, 0)
+	}
+
+	if err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_ATTACH_BPF, program); err != nil {
+		unix.Close(fd)
+		return 0, errors.Wrap(err, 0)
+	}
+
+	timeout := unix.NsecToTimeval(time.Second.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &timeout); err != nil {
+		unix.Close(fd)
+		return 0, errors.Wrap(err, 0)
+	}
+
+	return fd, nil
+}
+
+func (c *handshakeCapture) start() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.started = true
+	for _, socket := range c.sockets {
+		go c.poll(socket)
+	}
+}
+
+func (c *handshakeCapture) poll(socket *handshakeSocket) {
+	defer unix.Close(socket.fd)
+
+	buffer := make([]byte, 1<<16)
+	packet := make([]byte, 0, 1<<16)
+
+	for !socket.closed.Load() {
+		n, from, err := unix.Recvfrom(socket.fd, buffer, 0)
+		if errors.Is(err, unix.EINTR) || errors.Is(err, unix.EAGAIN) {
+			continue
+		}
+		if err != nil {
+			log.Info().Err(err).Uint64("netns", socket.netns).Msg("Stopped capturing TLS handshakes:")
+			return
+		}
+
+		if c.isPaused() {
+			continue
+		}
+
+		address, ok := from.(*unix.SockaddrLinklayer)
+		if !ok {
+			continue
+		}
+
+		packet, ok = toEthernetPacket(packet[:0], buffer[:n], address)
+		if !ok {
+			continue
+		}
+
+		header, ok := parseHandshakePacket(packet[ethernetHeaderLen:])
+		if !ok {
+			continue
+		}
+
+		// The namespace sends its packets to the peer. The policy is checked before the
+		// packet is marked as seen, its copy in the namespace of the peer has its own.
+		peer := header.src
+		if address.Pkttype == unix.PACKET_OUTGOING {
+			peer = header.dst
+		}
+		if !c.allows(peer) || !c.isFirstSeen(header.key) {
+			continue
+		}
+
+		info := gopacket.CaptureInfo{
+			Timestamp:     time.Now().UTC(),
+			Length:        len(packet),
+			CaptureLength: len(packet),
+		}
+
+		if err := c.sorter.GetMasterPcap().WritePacket(info, packet); err != nil {
+			log.Error().Err(err).Msg("Error writing PCAP:")
+		}
+
+		if fingerprint, ok := getTlsFingerprint(header.payload); ok {
+			c.fingerprints.add(getFingerprintKey(header.src, header.srcPort, header.dst, header.dstPort), fingerprint)
+		}
+	}
+}
+
+// linkHeaderLen is the length of the link-layer header of the packets of an interface type,
+// false if the type isn't supported
+func linkHeaderLen(hatype uint16) (int, bool) {
+	switch hatype {
+	case syscall.ARPHRD_ETHER, syscall.ARPHRD_LOOPBACK:
+		return ethernetHeaderLen, true
+	// E.g. WireGuard, tun and IPIP tunnels, the packets start with the IP header
+	case syscall.ARPHRD_NONE, unix.ARPHRD_RAWIP, syscall.ARPHRD_TUNNEL, syscall.ARPHRD_TUNNEL6, syscall.ARPHRD_PPP:
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// toEthernetPacket appends the packet to buffer with an Ethernet header, the type of the
+// master PCAP. The header of the interfaces without one has zero addresses.
+func toEthernetPacket(buffer []byte, data []byte, address *unix.SockaddrLinklayer) ([]byte, bool) {
+	headerLen, ok := linkHeaderLen(address.Hatype)
+	if !ok {
+		log.Debug().Uint16("type", address.Hatype).Int("interface", address.Ifindex).Msg("Unsupported interface type of a TLS handshake:")
+		return buffer, false
+	}
+
+	if len(data) <= headerLen {
+		return buffer, false
+	}
+
+	if headerLen == ethernetHeaderLen {
+		return append(buffer, data...), true
+	}
+
+	etherType := uint16(unix.ETH_P_IP)
+	if data[headerLen]>>4 == 6 {
+		etherType = unix.ETH_P_IPV6
+	}
+
+	var header [ethernetHeaderLen]byte
+	binary.BigEndian.PutUint16(header[12:], etherType)
+	buffer = append(buffer, header[:]...)
+
+	return append(buffer, data[headerLen:]...), true
+}
+
+func (c *handshakeCapture) isFirstSeen(key string) bool {
+	c.seenMtx.Lock()
+	defer c.seenMtx.Unlock()
+
+	if c.seen.Contains(key) {
+		return false
+	}
+
+	c.seen.Add(key, nil)
+	return true
+}
+
+// handshakePacket has the addresses and the payload of an IPv4 or IPv6 TCP packet
+type handshakePacket struct {
+	src     net.IP
+	dst     net.IP
+	srcPort uint16
+	dstPort uint16
+	// The addresses, the ports and the sequence number
+	key string
+	// Nil if the TCP header is truncated
+	payload []byte
+}
+
+// parseHandshakePacket reads the network header and the start of the TCP header, the socket
+// filter passes the IPv6 packets without extension headers only
+func parseHandshakePacket(packet []byte) (handshakePacket, bool) {
+	if len(packet) == 0 {
+		return handshakePacket{}, false
+	}
+
+	var src, dst []byte
+	var tcpOffset int
+
+	switch packet[0] >> 4 {
+	case 4:
+		if len(packet) < 20 {
+			return handshakePacket{}, false
+		}
+		src, dst = packet[12:16], packet[16:20]
+		tcpOffset = int(packet[0]&0x0f) * 4
+	case 6:
+		if len(packet) < 40 {
+			return handshakePacket{}, false
+		}
+		src, dst = packet[8:24], packet[24:40]
+		tcpOffset = 40
+	default:
+		return handshakePacket{}, false
+	}
+
+	if len(packet) < tcpOffset+8 {
+		return handshakePacket{}, false
+	}
+
+	header := handshakePacket{
+		src:     net.IP(bytes.Clone(src)),
+		dst:     net.IP(bytes.Clone(dst)),
+		srcPort: binary.BigEndian.Uint16(packet[tcpOffset:]),
+		dstPort: binary.BigEndian.Uint16(packet[tcpOffset+2:]),
+		key:     string(src) + string(dst) + string(packet[tcpOffset:tcpOffset+8]),
+	}
+
+	if len(packet) > tcpOffset+12 {
+		if payloadOffset := tcpOffset + int(packet[tcpOffset+12]>>4)*4; len(packet) >= payloadOffset {
+			header.payload = packet[payloadOffset:]
+		}
+	}
+
+	return header, true
+}
+
+// closeSocket stops the poller of the socket, which closes it, or closes the socket that isn't
+// polled yet
+func (c *handshakeCapture) closeSocket(socket *handshakeSocket) error {
+	socket.closed.Store(true)
+	delete(c.sockets, socket.netns)
+
+	if c.started {
+		return nil
+	}
+
+	if err := unix.Close(socket.fd); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	return nil
+}
+
+func (c *handshakeCapture) close() (err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, socket := range c.sockets {
+		if closeErr := c.closeSocket(socket); closeErr != nil {
+			err = closeErr
+		}
+	}
+
+	return
+}
+
+// The same swap as ntohs
+func htons(value uint16) uint16 {
+	return ntohs(value)
+}
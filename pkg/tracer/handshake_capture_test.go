@@ -0,0 +1,133 @@
+package tracer
+
+import (
+	"bytes"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// An IPv4 TCP packet from 10.0.0.1:443 to 10.0.0.2:50000 with a sequence number
+func newHandshakePacket(seq byte) []byte {
+	packet := make([]byte, 40)
+	packet[0] = 0x45
+	packet[9] = 6
+	copy(packet[12:], []byte{10, 0, 0, 1, 10, 0, 0, 2})
+	copy(packet[20:], []byte{0x01, 0xbb, 0xc3, 0x50, 0, 0, 0, seq})
+	return packet
+}
+
+// The same packet over IPv6, from fd00::1 to fd00::2
+func newHandshakePacket6(seq byte) []byte {
+	packet := make([]byte, 60)
+	packet[0] = 0x60
+	packet[6] = 6
+	packet[8], packet[23] = 0xfd, 1
+	packet[24], packet[39] = 0xfd, 2
+	copy(packet[40:], []byte{0x01, 0xbb, 0xc3, 0x50, 0, 0, 0, seq})
+	return packet
+}
+
+func TestToEthernetPacket(t *testing.T) {
+	ip := newHandshakePacket(1)
+	ethernet := append(bytes.Repeat([]byte{0xaa}, 12), 0x08, 0x00)
+	synthetic := append(make([]byte, 12), 0x08, 0x00)
+	ip6 := newHandshakePacket6(1)
+	synthetic6 := append(make([]byte, 12), 0x86, 0xdd)
+
+	tests := []struct {
+		name   string
+		hatype uint16
+		data   []byte
+		want   []byte
+	}{
+		{"ethernet", syscall.ARPHRD_ETHER, append(append([]byte{}, ethernet...), ip...), append(append([]byte{}, ethernet...), ip...)},
+		{"loopback", syscall.ARPHRD_LOOPBACK, append(append([]byte{}, ethernet...), ip...), append(append([]byte{}, ethernet...), ip...)},
+		{"wireguard", syscall.ARPHRD_NONE, ip, append(append([]byte{}, synthetic...), ip...)},
+		{"raw ip", unix.ARPHRD_RAWIP, ip, append(append([]byte{}, synthetic...), ip...)},
+		{"ipip", syscall.ARPHRD_TUNNEL, ip, append(append([]byte{}, synthetic...), ip...)},
+		{"wireguard ipv6", syscall.ARPHRD_NONE, ip6, append(append([]byte{}, synthetic6...), ip6...)},
+		{"empty", syscall.ARPHRD_NONE, nil, nil},
+		{"infiniband", syscall.ARPHRD_INFINIBAND, ip, nil},
+		{"truncated", syscall.ARPHRD_ETHER, ethernet[:10], nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := toEthernetPacket(nil, test.data, &unix.SockaddrLinklayer{Hatype: test.hatype})
+			if ok != (test.want != nil) {
+				t.Fatalf("got ok %v, want %v", ok, test.want != nil)
+			}
+
+			if ok && !bytes.Equal(got, test.want) {
+				t.Fatalf("got %x, want %x", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseHandshakePacket(t *testing.T) {
+	options := newHandshakePacket(1)
+	options[0] = 0x46
+	options = append(options[:20], append(make([]byte, 4), options[20:]...)...)
+
+	tests := []struct {
+		name string
+		a    []byte
+		b    []byte
+		same bool
+	}{
+		{"same packet", newHandshakePacket(1), newHandshakePacket(1), true},
+		{"other sequence", newHandshakePacket(1), newHandshakePacket(2), false},
+		{"ip options", newHandshakePacket(1), options, true},
+		{"ipv6", newHandshakePacket6(1), newHandshakePacket6(1), true},
+		{"ipv6 other sequence", newHandshakePacket6(1), newHandshakePacket6(2), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, okA := parseHandshakePacket(test.a)
+			b, okB := parseHandshakePacket(test.b)
+			if !okA || !okB {
+				t.Fatal("got no key")
+			}
+
+			if (a.key == b.key) != test.same {
+				t.Fatalf("got the same key %v, want %v", a.key == b.key, test.same)
+			}
+		})
+	}
+
+	addresses := []struct {
+		name   string
+		packet []byte
+		src    string
+		dst    string
+	}{
+		{"ipv4", newHandshakePacket(1), "10.0.0.1", "10.0.0.2"},
+		{"ipv6", newHandshakePacket6(1), "fd00::1", "fd00::2"},
+	}
+
+	for _, test := range addresses {
+		t.Run(test.name+" addresses", func(t *testing.T) {
+			header, _ := parseHandshakePacket(test.packet)
+			if header.src.String() != test.src || header.dst.String() != test.dst {
+				t.Fatalf("got %s to %s, want %s to %s", header.src, header.dst, test.src, test.dst)
+			}
+		})
+	}
+
+	invalid := map[string][]byte{
+		"truncated":      newHandshakePacket(1)[:24],
+		"truncated ipv6": newHandshakePacket6(1)[:44],
+		"not ip":         {0x10, 0, 0, 0},
+		"empty":          nil,
+	}
+
+	for name, packet := range invalid {
+		if _, ok := parseHandshakePacket(packet); ok {
+			t.Errorf("parsed the %s packet", name)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package tracer
+
+import (
+	"time"
+
+	"github.com/go-errors/errors"
+)
+
+const (
+	// The chunks perf buffer is read at least every chunksPollTimeout while it's alive
+	maxPollDelay = 5 * time.Second
+	// The master PCAP is a named pipe, a write blocks while nothing reads it
+	maxPcapWriteDuration = 10 * time.Second
+)
+
+// HealthCheck is the result of one of the checks of Live and Ready
+type HealthCheck struct {
+	Name  string `json:"name"`
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Live checks that the perf buffer of the chunks is read and that the master PCAP is
+// written, a failure means the tracer is stuck and has to be restarted
+func (t *Tracer) Live() []HealthCheck {
+	return []HealthCheck{
+		newHealthCheck("perf-reader", t.checkPerfReader()),
+		newHealthCheck("sorter", t.checkSorter()),
+	}
+}
+
+// Ready checks that the probes are attached and the chunks aren't held for the enrichment
+// in addition to the checks of Live
+func (t *Tracer) Ready() []HealthCheck {
+	checks := []HealthCheck{
+		newHealthCheck("probes", t.checkProbes()),
+		newHealthCheck("enrichment", t.poller.hold.check()),
+	}
+
+	return append(checks, t.Live()...)
+}
+
+// IsHealthy is true if all the checks passed
+func IsHealthy(checks []HealthCheck) bool {
+	for _, check := range checks {
+		if !check.Ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newHealthCheck(name string, err error) HealthCheck {
+	if err != nil {
+		return HealthCheck{Name: name, Error: err.Error()}
+	}
+
+	return HealthCheck{Name: name, Ok: true}
+}
+
+func (t *Tracer) checkProbes() error {
+	if !t.started.Load() {
+		return errors.New("Tracer is not started")
+	}
+
+	if t.detached.Load() {
+		return errors.New("Probes are detached")
+	}
+
+	if t.isProbeGroupEnabled(ProbeGroupSyscalls) && t.syscallHooks.sysEnterWrite == nil ||
+		t.isProbeGroupEnabled(ProbeGroupTcpKprobes) && t.tcpKprobeHooks.tcpSendmsg == nil {
+		return errors.New("Probes are not attached")
+	}
+
+	return nil
+}
+
+func (t *Tracer) checkPerfReader() error {
+	// Not started yet
+	if t.poller.lastPoll.Load() == 0 {
+		return nil
+	}
+
+	if !t.poller.polling.Load() {
+		return errors.New("Chunks perf buffer is not read")
+	}
+
+	if delay := time.Since(time.Unix(0, t.poller.lastPoll.Load())); delay > maxPollDelay {
+		return errors.Errorf("Chunks perf buffer was last read %v ago", delay.Round(time.Second))
+	}
+
+	return nil
+}
+
+func (t *Tracer) checkSorter() error {
+	masterPcap := t.poller.sorter.GetMasterPcap()
+	if masterPcap == nil {
+		return errors.New("Master PCAP is not open")
+	}
+
+	if since := masterPcap.writingSince.Load(); since != 0 {
+		if duration := time.Since(time.Unix(0, since)); duration > maxPcapWriteDuration {
+			return errors.Errorf("Master PCAP write is blocked for %v", duration.Round(time.Second))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package tracer
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// HttpMessage is the start line and the headers of an HTTP/1.x message, the chunk has to
+// start with them
+type HttpMessage struct {
+	// Of a request
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path,omitempty"`
+	// Of a response
+	Status int `json:"status,omitempty"`
+	// Lower cased names, the last value of the repeated headers
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// parseHttpMessage returns nil and the data as the body if it isn't HTTP/1.x
+func parseHttpMessage(data []byte) (*HttpMessage, []byte) {
+	firstLine, _, _ := bytes.Cut(data, []byte("\r\n"))
+	parts := strings.SplitN(string(firstLine), " ", 3)
+	if len(parts) < 2 {
+		return nil, data
+	}
+
+	message := &HttpMessage{}
+	switch {
+	case strings.HasPrefix(parts[0], "HTTP/1."):
+		status, err := strconv.Atoi(parts[1])
+		if err != nil || status < 100 || status > 599 {
+			return nil, data
+		}
+		message.Status = status
+	case len(parts) == 3 && strings.HasPrefix(parts[2], "HTTP/1."):
+		message.Method, message.Path = parts[0], parts[1]
+	default:
+		return nil, data
+	}
+
+	head, body, _ := bytes.Cut(data, []byte("\r\n\r\n"))
+
+	message.Headers = make(map[string]string)
+	for _, line := range strings.Split(string(head), "\r\n")[1:] {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		message.Headers[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+
+	return message, body
+}
@@ -0,0 +1,56 @@
+package tracer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHttpMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		message *HttpMessage
+		body    string
+	}{
+		{
+			"request",
+			"POST /orders HTTP/1.1\r\nHost: example.com\r\nContent-Type: application/json\r\n\r\n{\"id\":1}",
+			&HttpMessage{Method: "POST", Path: "/orders", Headers: map[string]string{"host": "example.com", "content-type": "application/json"}},
+			"{\"id\":1}",
+		},
+		{
+			"response",
+			"HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n",
+			&HttpMessage{Status: 404, Headers: map[string]string{"content-length": "0"}},
+			"",
+		},
+		{
+			"repeated header",
+			"GET / HTTP/1.0\r\nX-A: 1\r\nx-a: 2\r\n\r\n",
+			&HttpMessage{Method: "GET", Path: "/", Headers: map[string]string{"x-a": "2"}},
+			"",
+		},
+		{
+			"headers without the end",
+			"GET / HTTP/1.1\r\nHost: a\r\nAccept: */*",
+			&HttpMessage{Method: "GET", Path: "/", Headers: map[string]string{"host": "a", "accept": "*/*"}},
+			"",
+		},
+		{"invalid status", "HTTP/1.1 999 Nope\r\n\r\n", nil, "HTTP/1.1 999 Nope\r\n\r\n"},
+		{"http/2", "PRI * HTTP/2.0\r\n\r\n", nil, "PRI * HTTP/2.0\r\n\r\n"},
+		{"binary", "\x16\x03\x01\x00", nil, "\x16\x03\x01\x00"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message, body := parseHttpMessage([]byte(test.data))
+			if !reflect.DeepEqual(message, test.message) {
+				t.Fatalf("got %+v, want %+v", message, test.message)
+			}
+
+			if string(body) != test.body {
+				t.Fatalf("got body %q, want %q", body, test.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package tracer
+
+import (
+	"github.com/cilium/ebpf/btf"
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// loadKernelBtf returns the types the CO-RE relocations of the objects are resolved against,
+// so one object works across the kernel versions. The BTF of the running kernel is read from
+// /sys/kernel/btf/vmlinux or a vmlinux with debug info, btfPath is for the kernels that are
+// built without it, e.g. a file of BTFHub.
+func loadKernelBtf(btfPath string) (*btf.Spec, error) {
+	if btfPath != "" {
+		spec, err := btf.LoadSpec(btfPath)
+		if err != nil {
+			return nil, errors.Wrap(err, 0)
+		}
+
+		log.Info().Str("path", btfPath).Msg("Loaded the BTF of the kernel:")
+		return spec, nil
+	}
+
+	spec, err := btf.LoadKernelSpec()
+	if err != nil {
+		return nil, errors.Errorf("The BTF of the kernel isn't found, enable CONFIG_DEBUG_INFO_BTF or pass it with -btf: %v", err)
+	}
+
+	return spec, nil
+}
@@ -0,0 +1,231 @@
+package tracer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/features"
+	"github.com/go-errors/errors"
+)
+
+// The capabilities in /proc/<pid>/status, see capability.h
+const (
+	capSysAdmin = 21
+	capPerfmon  = 38
+	capBpf      = 39
+)
+
+const tracingEventsPath = "/sys/kernel/tracing/events"
+
+// The mount point of tracefs before Linux 4.1
+const debugTracingEventsPath = "/sys/kernel/debug/tracing/events"
+
+var requiredTracepoints = []string{
+	"syscalls/sys_enter_read",
+	"syscalls/sys_exit_read",
+	"syscalls/sys_enter_write",
+	"syscalls/sys_exit_write",
+	"syscalls/sys_enter_accept4",
+	"syscalls/sys_exit_accept4",
+	"syscalls/sys_enter_connect",
+	"syscalls/sys_exit_connect",
+	"sched/sched_process_exit",
+}
+
+// kernelCheck is a kernel feature or a privilege, the tracer can run without the
+// optional ones
+type kernelCheck struct {
+	name     string
+	optional bool
+	hint     string
+	run      func(procfs string) error
+}
+
+var kernelChecks = []kernelCheck{
+	{
+		name: "capabilities",
+		hint: "Run as root or grant CAP_BPF and CAP_PERFMON (CAP_SYS_ADMIN before Linux 5.8), e.g. with make setcap",
+		run:  checkCapabilities,
+	},
+	{
+		name:     "bpf-token",
+		optional: true,
+		hint:     bpfTokenHint,
+		run:      checkBpfToken,
+	},
+	{
+		name: "kprobes",
+		hint: "Enable CONFIG_KPROBES and CONFIG_KPROBE_EVENTS in the kernel",
+		run: func(procfs string) error {
+			return features.HaveProgramType(ebpf.Kprobe)
+		},
+	},
+	{
+		name: "uprobes",
+		hint: "Enable CONFIG_UPROBES and CONFIG_UPROBE_EVENTS in the kernel",
+		run: func(procfs string) error {
+			return checkPath("/sys/bus/event_source/devices/uprobe/type")
+		},
+	},
+	{
+		name: "uretprobes",
+		hint: "Enable CONFIG_UPROBES and CONFIG_UPROBE_EVENTS in the kernel",
+		run: func(procfs string) error {
+			return checkPath("/sys/bus/event_source/devices/uprobe/format/retprobe")
+		},
+	},
+	{
+		name: "tracepoints",
+		hint: "Enable CONFIG_FTRACE_SYSCALLS and mount tracefs, e.g. mount -t tracefs nodev /sys/kernel/tracing",
+		run:  checkTracepoints,
+	},
+	{
+		name:     "btf",
+		optional: true,
+		hint:     "Enable CONFIG_DEBUG_INFO_BTF for the CO-RE relocations, or pass the BTF of the kernel with -btf",
+		run: func(procfs string) error {
+			_, err := btf.LoadKernelSpec()
+			return err
+		},
+	},
+	{
+		name:     "ring-buffer",
+		optional: true,
+		hint:     "Linux 5.8 or later has the BPF ring buffer, the tracer falls back to the perf buffers",
+		run: func(procfs string) error {
+			return features.HaveMapType(ebpf.RingBuf)
+		},
+	},
+	{
+		name:     "fentry",
+		optional: true,
+		hint:     "Linux 5.5 or later with BTF has the BPF trampolines (6.0 on arm64), the tracer falls back to the kprobes",
+		run: func(procfs string) error {
+			if err := setupRLimit(); err != nil {
+				return err
+			}
+
+			return probeFentry(nil)
+		},
+	},
+}
+
+// runKernelChecks runs the checks and prints a report with the hints of the failed ones,
+// it returns an error if a required check failed
+func runKernelChecks(out io.Writer, procfs string) error {
+	failed := make([]string, 0)
+
+	fmt.Fprintf(out, "%-14s %-9s %s\n", "FEATURE", "STATUS", "DETAILS")
+	for _, c := range kernelChecks {
+		err := c.run(procfs)
+
+		status := "ok"
+		details := ""
+		switch {
+		case err == nil:
+		case c.optional:
+			status, details = "missing", fmt.Sprintf("%v, hint: %s", err, c.hint)
+		default:
+			status, details = "FAILED", fmt.Sprintf("%v, hint: %s", err, c.hint)
+			failed = append(failed, c.name)
+		}
+
+		fmt.Fprintf(out, "%-14s %-9s %s\n", c.name, status, details)
+	}
+
+	if len(failed) > 0 {
+		return errors.Errorf("Required kernel features are missing: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+func checkPath(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	return nil
+}
+
+func checkTracepoints(procfs string) error {
+	eventsPath := tracingEventsPath
+	if _, err := os.Stat(eventsPath); err != nil {
+		eventsPath = debugTracingEventsPath
+	}
+
+	missing := make([]string, 0)
+	for _, tracepoint := range requiredTracepoints {
+		if _, err := os.Stat(fmt.Sprintf("%s/%s/id", eventsPath, tracepoint)); err != nil {
+			missing = append(missing, tracepoint)
+		}
+	}
+
+	if len(missing) > 0 {
+		return errors.Errorf("Tracepoints not found in %s: %v", eventsPath, missing)
+	}
+
+	return nil
+}
+
+// checkCapabilities requires CAP_BPF and CAP_PERFMON, or CAP_SYS_ADMIN that includes both
+// on the kernels before 5.8
+func checkCapabilities(procfs string) error {
+	capabilities, err := getEffectiveCapabilities(fmt.Sprintf("%s/self/status", procfs))
+	if err != nil {
+		return err
+	}
+
+	has := func(capability uint) bool {
+		return capabilities&(1<<capability) != 0
+	}
+
+	if has(capSysAdmin) || has(capBpf) && has(capPerfmon) {
+		return nil
+	}
+
+	missing := make([]string, 0)
+	if !has(capBpf) {
+		missing = append(missing, "CAP_BPF")
+	}
+	if !has(capPerfmon) {
+		missing = append(missing, "CAP_PERFMON")
+	}
+
+	return errors.Errorf("Missing capabilities %v", missing)
+}
+
+func getEffectiveCapabilities(statusPath string) (uint64, error) {
+	file, err := os.Open(statusPath)
+	if err != nil {
+		return 0, errors.Wrap(err, 0)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "CapEff:")
+		if !ok {
+			continue
+		}
+
+		capabilities, err := strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		if err != nil {
+			return 0, errors.Wrap(err, 0)
+		}
+
+		return capabilities, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, errors.Wrap(err, 0)
+	}
+
+	return 0, errors.Errorf("CapEff not found in %s", statusPath)
+}
@@ -0,0 +1,137 @@
+package tracer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/go-errors/errors"
+	"github.com/hashicorp/golang-lru/simplelru"
+	"github.com/kubeshark/tracer/misc"
+)
+
+const (
+	// The label and the secret of a keylog chunk are at fixed offsets of its data, see maps.h
+	keylogLabelSize = 64
+	// Number of the threads whose last ClientHello is kept
+	keylogThreadsSize  = 4096
+	clientRandomOffset = 6
+	clientRandomLength = 32
+)
+
+// keylogThread is the last ClientHello seen on a thread
+type keylogThread struct {
+	clientRandom []byte
+	// The last record header was read without its body, as OpenSSL does without read ahead
+	pendingHeader bool
+	// The payload policy doesn't allow the peer of the last ClientHello
+	excluded bool
+}
+
+// keylogWriter writes the secrets that OpenSSL logs for the processes captured at BIO level
+// to a key log file in the NSS format, so their records in the PCAP can be decrypted, e.g.
+// by Wireshark. OpenSSL logs the secrets on the thread of the handshake after its ClientHello
+// went through a BIO, so they are paired with the client random of the last ClientHello of
+// the thread.
+type keylogWriter struct {
+	file    *os.File
+	threads *simplelru.LRU // Actual type is map[uint32]*keylogThread
+}
+
+func newKeylogWriter() (*keylogWriter, error) {
+	file, err := os.OpenFile(misc.GetKeylogPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	threads, err := simplelru.NewLRU(keylogThreadsSize, nil)
+	if err != nil {
+		file.Close()
+		return nil, errors.Wrap(err, 0)
+	}
+
+	return &keylogWriter{
+		file:    file,
+		threads: threads,
+	}, nil
+}
+
+func (w *keylogWriter) getThread(tid uint32) *keylogThread {
+	if thread, ok := w.threads.Get(tid); ok {
+		return thread.(*keylogThread)
+	}
+
+	thread := &keylogThread{}
+	w.threads.Add(tid, thread)
+
+	return thread
+}
+
+// handleRecords keeps the client random if the records of chunk start with a ClientHello,
+// it's forgotten if the payload policy doesn't allow the peer
+func (w *keylogWriter) handleRecords(chunk *tracerTlsChunk, allowed bool) {
+	data := chunk.getRecordedData()
+	thread := w.getThread(chunk.Tgid)
+
+	pendingHeader := thread.pendingHeader
+	thread.pendingHeader = false
+
+	var handshake []byte
+	switch {
+	case pendingHeader:
+		handshake = data
+	case len(data) >= tlsRecordHeaderLength && data[0] == tlsContentHandshake && data[1] == tlsMajorVersion:
+		if len(data) == tlsRecordHeaderLength {
+			thread.pendingHeader = true
+			return
+		}
+		handshake = data[tlsRecordHeaderLength:]
+	default:
+		return
+	}
+
+	if len(handshake) < clientRandomOffset+clientRandomLength || handshake[0] != tlsHandshakeClientHelo {
+		return
+	}
+
+	thread.excluded = !allowed
+	if thread.excluded {
+		thread.clientRandom = nil
+		return
+	}
+
+	thread.clientRandom = bytes.Clone(handshake[clientRandomOffset : clientRandomOffset+clientRandomLength])
+}
+
+// handleSecret writes the line of a secret, it's dropped if the thread had no ClientHello
+func (w *keylogWriter) handleSecret(chunk *tracerTlsChunk) {
+	data := chunk.getRecordedData()
+	if len(data) < keylogLabelSize+int(chunk.Len) {
+		return
+	}
+
+	label := data[:keylogLabelSize]
+	if end := bytes.IndexByte(label, 0); end >= 0 {
+		label = label[:end]
+	}
+	secret := data[keylogLabelSize : keylogLabelSize+int(chunk.Len)]
+
+	thread := w.getThread(chunk.Tgid)
+	if thread.excluded {
+		pollerLog.get().Debug().Uint32("pid", chunk.Pid).Str("label", string(label)).Msg("Dropped a secret of a peer without payload capture:")
+		return
+	}
+
+	if thread.clientRandom == nil {
+		pollerLog.get().Debug().Uint32("pid", chunk.Pid).Str("label", string(label)).Msg("Dropped a secret without a ClientHello:")
+		return
+	}
+
+	if _, err := fmt.Fprintf(w.file, "%s %x %x\n", label, thread.clientRandom, secret); err != nil {
+		LogError(errors.Wrap(err, 0))
+	}
+}
+
+func (w *keylogWriter) close() error {
+	return w.file.Close()
+}
@@ -0,0 +1,246 @@
+package tracer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-errors/errors"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// LabelRule adds a label to the events whose payload contains a value, e.g. a customer ID.
+// The value is taken from an HTTP/1.x header, from the first group of a regular expression
+// or from a JSONPath in a JSON body. The regular expression is matched against the header
+// if both are set, otherwise against the body, or the whole payload if it isn't HTTP/1.x.
+// A label that is found is kept on the stream, the later events of both directions have it.
+type LabelRule struct {
+	Label    string `json:"label"`
+	Header   string `json:"header,omitempty"`
+	Regex    string `json:"regex,omitempty"`
+	JsonPath string `json:"jsonPath,omitempty"`
+}
+
+type labelRule struct {
+	LabelRule
+	regex    *regexp.Regexp
+	jsonPath *jsonpath.JSONPath
+}
+
+func (r *LabelRule) compile() (*labelRule, error) {
+	if r.Label == "" {
+		return nil, errors.New("Label rule without a label")
+	}
+
+	if r.Header == "" && r.Regex == "" && r.JsonPath == "" {
+		return nil, errors.Errorf("Label rule %q needs a header, a regex or a JSONPath", r.Label)
+	}
+
+	if r.JsonPath != "" && (r.Header != "" || r.Regex != "") {
+		return nil, errors.Errorf("Label rule %q can't have a JSONPath with a header or a regex", r.Label)
+	}
+
+	rule := &labelRule{LabelRule: *r}
+
+	if r.Regex != "" {
+		regex, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return nil, errors.Errorf("Invalid regex of label rule %q: %v", r.Label, err)
+		}
+		rule.regex = regex
+	}
+
+	if r.JsonPath != "" {
+		rule.jsonPath = jsonpath.New(r.Label)
+		if err := rule.jsonPath.Parse(toJsonPathTemplate(r.JsonPath)); err != nil {
+			return nil, errors.Errorf("Invalid JSONPath of label rule %q: %v", r.Label, err)
+		}
+	}
+
+	return rule, nil
+}
+
+// toJsonPathTemplate accepts $.a.b besides the {.a.b} template syntax of the parser
+func toJsonPathTemplate(path string) string {
+	if strings.HasPrefix(path, "{") {
+		return path
+	}
+
+	return "{" + strings.TrimPrefix(path, "$") + "}"
+}
+
+func compileLabelRules(rules []LabelRule) ([]*labelRule, error) {
+	compiled := make([]*labelRule, 0, len(rules))
+	for i := range rules {
+		rule, err := rules[i].compile()
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, rule)
+	}
+
+	return compiled, nil
+}
+
+// labelExtractor applies the rules to each chunk separately, so a value that is split
+// across chunks isn't found. A JSONPath is only looked up in the HTTP/1.x messages with a
+// JSON content type, and in the other chunks that start as a JSON object or array.
+type labelExtractor struct {
+	rules []*labelRule
+	sync.RWMutex
+}
+
+func newLabelExtractor(rules []LabelRule) (*labelExtractor, error) {
+	e := &labelExtractor{}
+	if err := e.setRules(rules); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// setRules replaces the rules, on reload
+func (e *labelExtractor) setRules(rules []LabelRule) error {
+	compiled, err := compileLabelRules(rules)
+	if err != nil {
+		return err
+	}
+
+	e.Lock()
+	e.rules = compiled
+	e.Unlock()
+
+	return nil
+}
+
+// extract returns nil if none of the rules match
+func (e *labelExtractor) extract(data []byte) map[string]string {
+	e.RLock()
+	rules := e.rules
+	e.RUnlock()
+
+	if len(rules) == 0 || len(data) == 0 {
+		return nil
+	}
+
+	message, body := parseHttpMessage(data)
+
+	var decoded interface{}
+	var decodeErr error
+	var labels map[string]string
+	for _, rule := range rules {
+		var value string
+		var ok bool
+		switch {
+		case rule.jsonPath != nil:
+			if decoded == nil && decodeErr == nil {
+				if isJson(message, body) {
+					decodeErr = json.Unmarshal(body, &decoded)
+				} else {
+					decodeErr = errNotJson
+				}
+			}
+			if decodeErr == nil {
+				value, ok = findJsonPath(rule.jsonPath, decoded)
+			}
+		case rule.Header != "":
+			if message == nil {
+				continue
+			}
+			if value, ok = message.Headers[strings.ToLower(rule.Header)]; ok && rule.regex != nil {
+				value, ok = findRegex(rule.regex, []byte(value))
+			}
+		default:
+			value, ok = findRegex(rule.regex, body)
+		}
+
+		if !ok || value == "" {
+			continue
+		}
+
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[rule.Label] = value
+	}
+
+	return labels
+}
+
+var errNotJson = errors.New("Not JSON")
+
+// isJson checks the content type of an HTTP/1.x message, e.g. application/json or
+// application/problem+json, otherwise the first byte of the data
+func isJson(message *HttpMessage, body []byte) bool {
+	if message != nil {
+		contentType, _, _ := strings.Cut(message.Headers["content-type"], ";")
+		contentType = strings.ToLower(strings.TrimSpace(contentType))
+		return contentType == "application/json" || strings.HasSuffix(contentType, "+json")
+	}
+
+	body = bytes.TrimLeft(body, " \t\r\n")
+	return len(body) > 0 && (body[0] == '{' || body[0] == '[')
+}
+
+// addLabels keeps the labels found in a chunk on the stream and returns a copy of all the
+// labels of the stream for the event, nil if there are none
+func (t *tlsStream) addLabels(labels map[string]string) map[string]string {
+	if len(labels) == 0 && len(t.labels) == 0 {
+		return nil
+	}
+
+	if t.labels == nil {
+		t.labels = make(map[string]string, len(labels))
+	}
+	for label, value := range labels {
+		t.labels[label] = value
+	}
+
+	result := make(map[string]string, len(t.labels))
+	for label, value := range t.labels {
+		result[label] = value
+	}
+
+	return result
+}
+
+// findRegex returns the first group, or the whole match if there are no groups
+func findRegex(regex *regexp.Regexp, data []byte) (string, bool) {
+	match := regex.FindSubmatch(data)
+	if match == nil {
+		return "", false
+	}
+
+	if len(match) > 1 {
+		return string(match[1]), true
+	}
+
+	return string(match[0]), true
+}
+
+// findJsonPath returns the first result
+func findJsonPath(path *jsonpath.JSONPath, data interface{}) (string, bool) {
+	results, err := path.FindResults(data)
+	if err != nil || len(results) == 0 || len(results[0]) == 0 {
+		return "", false
+	}
+
+	value := results[0][0].Interface()
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case nil:
+		return "", false
+	case map[string]interface{}, []interface{}:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(encoded), true
+	default:
+		return fmt.Sprint(v), true
+	}
+}
@@ -0,0 +1,153 @@
+package tracer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompileLabelRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []LabelRule
+		err   bool
+	}{
+		{"header", []LabelRule{{Label: "tenant", Header: "X-Tenant"}}, false},
+		{"header and regex", []LabelRule{{Label: "tenant", Header: "Authorization", Regex: `tenant=(\w+)`}}, false},
+		{"regex", []LabelRule{{Label: "order", Regex: `"order":"(\w+)"`}}, false},
+		{"json path", []LabelRule{{Label: "customer", JsonPath: "$.customer.id"}}, false},
+		{"json path template", []LabelRule{{Label: "customer", JsonPath: "{.customer.id}"}}, false},
+		{"none", nil, false},
+		{"missing label", []LabelRule{{Header: "X-Tenant"}}, true},
+		{"missing source", []LabelRule{{Label: "tenant"}}, true},
+		{"json path and header", []LabelRule{{Label: "tenant", Header: "X-Tenant", JsonPath: "$.a"}}, true},
+		{"invalid regex", []LabelRule{{Label: "tenant", Regex: "("}}, true},
+		{"invalid json path", []LabelRule{{Label: "tenant", JsonPath: "$.a["}}, true},
+		{"one invalid", []LabelRule{{Label: "tenant", Header: "X-Tenant"}, {Label: "order"}}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			compiled, err := compileLabelRules(test.rules)
+			if (err != nil) != test.err {
+				t.Fatalf("got %v, want an error %v", err, test.err)
+			}
+
+			if !test.err && len(compiled) != len(test.rules) {
+				t.Fatalf("got %d rules, want %d", len(compiled), len(test.rules))
+			}
+		})
+	}
+}
+
+func TestLabelExtractor(t *testing.T) {
+	extractor, err := newLabelExtractor([]LabelRule{
+		{Label: "tenant", Header: "X-Tenant"},
+		{Label: "user", Header: "Authorization", Regex: `user=(\w+)`},
+		{Label: "customer", JsonPath: "$.customer.id"},
+		{Label: "order", Regex: `order-(\d+)`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want map[string]string
+	}{
+		{
+			"headers",
+			"GET / HTTP/1.1\r\nX-Tenant: acme\r\nAuthorization: Basic user=bob\r\n\r\n",
+			map[string]string{"tenant": "acme", "user": "bob"},
+		},
+		{
+			"json body",
+			"POST / HTTP/1.1\r\nContent-Type: application/json\r\n\r\n{\"customer\":{\"id\":\"c-1\"},\"ref\":\"order-42\"}",
+			map[string]string{"customer": "c-1", "order": "42"},
+		},
+		{
+			"json body of other content type",
+			"POST / HTTP/1.1\r\nContent-Type: text/plain\r\n\r\n{\"customer\":{\"id\":\"c-1\"}}",
+			nil,
+		},
+		{"json without headers", " {\"customer\":{\"id\":\"c-2\"}}", map[string]string{"customer": "c-2"}},
+		{"not http", "order-7", map[string]string{"order": "7"}},
+		{"no match", "GET / HTTP/1.1\r\nHost: a\r\n\r\n", nil},
+		{"empty", "", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := extractor.extract([]byte(test.data)); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsJson(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        bool
+	}{
+		{"json", "application/json", "{}", true},
+		{"json with charset", "Application/JSON; charset=utf-8", "{}", true},
+		{"json suffix", "application/problem+json", "{}", true},
+		{"form", "application/x-www-form-urlencoded", "{}", false},
+		{"no content type", "", "{}", false},
+		{"object", "-", "\n{\"a\":1}", true},
+		{"array", "-", "[1]", true},
+		{"text", "-", "a=1", false},
+		{"empty", "-", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// "-" is a chunk without HTTP/1.x headers
+			var message *HttpMessage
+			if test.contentType != "-" {
+				message = &HttpMessage{Method: "POST", Headers: map[string]string{}}
+				if test.contentType != "" {
+					message.Headers["content-type"] = test.contentType
+				}
+			}
+
+			if got := isJson(message, []byte(test.body)); got != test.want {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestStreamLabels(t *testing.T) {
+	stream := NewTlsStream(nil, "key")
+
+	steps := []struct {
+		name   string
+		labels map[string]string
+		want   map[string]string
+	}{
+		{"before a match", nil, nil},
+		{"request", map[string]string{"tenant": "acme"}, map[string]string{"tenant": "acme"}},
+		{"response", nil, map[string]string{"tenant": "acme"}},
+		{"next request", map[string]string{"tenant": "other", "order": "7"}, map[string]string{"tenant": "other", "order": "7"}},
+	}
+
+	results := make([]map[string]string, 0, len(steps))
+	for _, step := range steps {
+		got := stream.addLabels(step.labels)
+		if !reflect.DeepEqual(got, step.want) {
+			t.Fatalf("%s: got %v, want %v", step.name, got, step.want)
+		}
+		results = append(results, got)
+	}
+
+	// The events keep their own copies
+	for i, step := range steps {
+		if !reflect.DeepEqual(results[i], step.want) {
+			t.Fatalf("%s: the labels of the event changed to %v", step.name, results[i])
+		}
+	}
+}
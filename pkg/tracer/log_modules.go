@@ -0,0 +1,121 @@
+package tracer
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// The modules whose level can be set apart from the level of log.Logger, so debugging one
+// of them doesn't flood the logs with the others
+const (
+	LogModulePoller     = "poller"
+	LogModuleSorter     = "sorter"
+	LogModuleBpfLog     = "bpf-log"
+	LogModuleDissectors = "dissectors"
+)
+
+var LogModules = []string{LogModuleBpfLog, LogModuleDissectors, LogModulePoller, LogModuleSorter}
+
+// The levels of the modules only take effect above the global level of zerolog, the
+// caller sets it to trace and the level of log.Logger instead
+type logModule struct {
+	name   string
+	level  *zerolog.Level
+	logger atomic.Pointer[zerolog.Logger]
+}
+
+var (
+	pollerLog     = &logModule{name: LogModulePoller}
+	sorterLog     = &logModule{name: LogModuleSorter}
+	bpfLog        = &logModule{name: LogModuleBpfLog}
+	dissectorsLog = &logModule{name: LogModuleDissectors}
+)
+
+var logModules = map[string]*logModule{
+	LogModulePoller:     pollerLog,
+	LogModuleSorter:     sorterLog,
+	LogModuleBpfLog:     bpfLog,
+	LogModuleDissectors: dissectorsLog,
+}
+
+var logModulesMutex sync.Mutex
+
+// get is called on the hot path, the logger is built on the first call and when the
+// levels change
+func (m *logModule) get() *zerolog.Logger {
+	if logger := m.logger.Load(); logger != nil {
+		return logger
+	}
+
+	logModulesMutex.Lock()
+	defer logModulesMutex.Unlock()
+
+	return m.build()
+}
+
+func (m *logModule) build() *zerolog.Logger {
+	logger := log.Logger.With().Str("module", m.name).Logger()
+	if m.level != nil {
+		logger = logger.Level(*m.level)
+	}
+
+	m.logger.Store(&logger)
+
+	return &logger
+}
+
+// SetLogLevels sets the levels of the given modules, an empty level makes the module follow
+// log.Logger. The loggers of the other modules are rebuilt as well, so it's called after
+// log.Logger is changed. Nothing is changed if a module or a level is invalid.
+func SetLogLevels(levels map[string]string) error {
+	parsed := make(map[string]*zerolog.Level, len(levels))
+	for name, value := range levels {
+		if _, ok := logModules[name]; !ok {
+			return errors.Errorf("Unknown log module %q, expected one of %v", name, LogModules)
+		}
+
+		if value == "" {
+			parsed[name] = nil
+			continue
+		}
+
+		level, err := zerolog.ParseLevel(value)
+		if err != nil || level == zerolog.NoLevel {
+			return errors.Errorf("Invalid log level %q of module %q", value, name)
+		}
+		parsed[name] = &level
+	}
+
+	logModulesMutex.Lock()
+	defer logModulesMutex.Unlock()
+
+	for name, m := range logModules {
+		if level, ok := parsed[name]; ok {
+			m.level = level
+		}
+		m.build()
+	}
+
+	return nil
+}
+
+// GetLogLevels returns the effective level of each module, and of log.Logger as default
+func GetLogLevels() map[string]string {
+	logModulesMutex.Lock()
+	defer logModulesMutex.Unlock()
+
+	levels := map[string]string{"default": log.Logger.GetLevel().String()}
+	for name, m := range logModules {
+		level := log.Logger.GetLevel()
+		if m.level != nil {
+			level = *m.level
+		}
+		levels[name] = level.String()
+	}
+
+	return levels
+}
@@ -0,0 +1,182 @@
+package tracer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Incremented when the layout of a migrated map changes incompatibly
+const mapsVersion = 1
+
+// The maps that hold the state of the targets and the connections, the other maps are
+// either buffers or short lived syscall contexts.
+func getMigratedMaps(bpfObjects *tracerObjects) map[string]*ebpf.Map {
+	return map[string]*ebpf.Map{
+		"pids_map":                     bpfObjects.PidsMap,
+		"connection_context":           bpfObjects.ConnectionContext,
+		"goid_offsets_map":             bpfObjects.GoidOffsetsMap,
+		"openssl_write_context":        bpfObjects.OpensslWriteContext,
+		"openssl_read_context":         bpfObjects.OpensslReadContext,
+		"go_write_context":             bpfObjects.GoWriteContext,
+		"go_read_context":              bpfObjects.GoReadContext,
+		"go_kernel_write_context":      bpfObjects.GoKernelWriteContext,
+		"go_kernel_read_context":       bpfObjects.GoKernelReadContext,
+		"go_user_kernel_write_context": bpfObjects.GoUserKernelWriteContext,
+		"go_user_kernel_read_context":  bpfObjects.GoUserKernelReadContext,
+	}
+}
+
+// migrateMaps copies the contents of the maps pinned under path by the previous tracer
+// into the newly loaded maps and pins the new maps in their place, so an upgraded tracer
+// picks up the connections and the targets of the one it replaces. The maps are pinned
+// under a directory per mapsVersion, the maps of a newer tracer are never migrated. The
+// maps are left pinned on Close on purpose, the next tracer may be starting already.
+func migrateMaps(bpfObjects *tracerObjects, path string) error {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	previousVersion, err := findPreviousMapsVersion(path)
+	if err != nil {
+		return err
+	}
+	previousPath := filepath.Join(path, fmt.Sprintf("v%d", previousVersion))
+
+	currentPath := filepath.Join(path, fmt.Sprintf("v%d", mapsVersion))
+	if err := os.MkdirAll(currentPath, 0700); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	if previousVersion != mapsVersion {
+		log.Info().Int("previous", previousVersion).Int("current", mapsVersion).Msg("Migrating maps between versions:")
+	}
+
+	for name, m := range getMigratedMaps(bpfObjects) {
+		if err := migrateMap(name, filepath.Join(previousPath, name), m); err != nil {
+			log.Warn().Err(err).Str("map", name).Msg("Couldn't migrate the map:")
+		}
+
+		pinPath := filepath.Join(currentPath, name)
+		if err := os.Remove(pinPath); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, 0)
+		}
+
+		if err := m.Pin(pinPath); err != nil {
+			return errors.Wrap(err, 0)
+		}
+	}
+
+	if previousVersion != mapsVersion {
+		if err := os.RemoveAll(previousPath); err != nil {
+			return errors.Wrap(err, 0)
+		}
+	}
+
+	return nil
+}
+
+// registerMigratedPids adds the targets of the previous tracer to registeredPids, so ClearPids
+// removes them from pids_map like the targets of this one, otherwise a migrated process that
+// isn't targeted anymore is captured until it exits
+func (t *Tracer) registerMigratedPids() error {
+	var pid, value uint32
+	registered := 0
+	entries := t.bpfObjects.tracerMaps.PidsMap.Iterate()
+	for entries.Next(&pid, &value) {
+		t.registeredPids.Store(pid, true)
+		registered++
+	}
+
+	if err := entries.Err(); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	log.Debug().Int("pids", registered).Msg("Registered the migrated targets:")
+
+	return nil
+}
+
+// findPreviousMapsVersion returns the latest version under path that is not newer than
+// mapsVersion, mapsVersion if there is none
+func findPreviousMapsVersion(path string) (int, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return 0, errors.Wrap(err, 0)
+	}
+
+	previous := 0
+	for _, entry := range entries {
+		version, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), "v"))
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+
+		if version > mapsVersion {
+			log.Warn().Int("version", version).Msg("Skipping the maps of a newer tracer:")
+			continue
+		}
+
+		if version > previous {
+			previous = version
+		}
+	}
+
+	if previous == 0 {
+		return mapsVersion, nil
+	}
+
+	return previous, nil
+}
+
+func migrateMap(name string, pinPath string, to *ebpf.Map) error {
+	from, err := ebpf.LoadPinnedMap(pinPath, nil)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return errors.Wrap(err, 0)
+	}
+	defer from.Close()
+
+	if err := checkMapCompatibility(from, to); err != nil {
+		return err
+	}
+
+	var key, value []byte
+	migrated := 0
+	entries := from.Iterate()
+	for entries.Next(&key, &value) {
+		if err := to.Put(key, value); err != nil {
+			return errors.Errorf("Error migrating entry %d of %s: %v", migrated, name, err)
+		}
+		migrated++
+	}
+
+	if err := entries.Err(); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	log.Info().Str("map", name).Int("entries", migrated).Msg("Migrated map:")
+
+	return nil
+}
+
+func checkMapCompatibility(from *ebpf.Map, to *ebpf.Map) error {
+	if from.Type() != to.Type() {
+		return errors.Errorf("Incompatible map type %v, expected %v", from.Type(), to.Type())
+	}
+
+	if from.KeySize() != to.KeySize() || from.ValueSize() != to.ValueSize() {
+		return errors.Errorf("Incompatible map layout (key: %d, value: %d), expected (key: %d, value: %d)",
+			from.KeySize(), from.ValueSize(), to.KeySize(), to.ValueSize())
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package tracer
+
+import (
+	"debug/elf"
+	"regexp"
+	"runtime"
+	"strconv"
+
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog/log"
+)
+
+var mbedtlsLibrary = &tlsLibrary{
+	group:       ProbeGroupMbedtls,
+	fileRegex:   regexp.MustCompile(`/libmbedtls\.so[^/]*$`),
+	writeSymbol: "mbedtls_ssl_write",
+	readSymbol:  "mbedtls_ssl_read",
+	programs: func(bpfObjects *tracerObjects) tlsLibraryPrograms {
+		return tlsLibraryPrograms{
+			write:    bpfObjects.MbedtlsWrite,
+			writeRet: bpfObjects.MbedtlsRetWrite,
+			read:     bpfObjects.MbedtlsRead,
+			readRet:  bpfObjects.MbedtlsRetRead,
+		}
+	},
+	prepare: prepareMbedtls,
+}
+
+// The offset of p_bio in mbedtls_ssl_context with the default build options of 2.28 and 3.x
+// on the 64-bit architectures
+const defaultMbedtlsBioOffset = 72
+
+// mbedtls_ssl_set_bio stores its second argument in p_bio of the context in its first argument
+var mbedtlsSetBioStores = map[string]*regexp.Regexp{
+	"amd64": regexp.MustCompile(`^qword ptr \[rdi \+ (0x[0-9a-f]+)\], rsi$`),
+	"arm64": regexp.MustCompile(`^x1, \[x0, #(0x[0-9a-f]+)\]$`),
+}
+
+// prepareMbedtls passes the offset of p_bio to the probes, so they read the fd of the
+// mbedtls_net_context of the connection
+func prepareMbedtls(t *Tracer, pid uint32, path string) error {
+	offset, err := findMbedtlsBioOffset(path)
+	if err != nil {
+		log.Debug().Err(err).Str("path", path).Uint32("offset", defaultMbedtlsBioOffset).Msg("Using the default offset of p_bio:")
+		offset = defaultMbedtlsBioOffset
+	}
+
+	if err := t.bpfObjects.tracerMaps.MbedtlsBioOffsets.Put(pid, offset); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	return nil
+}
+
+// findMbedtlsBioOffset finds the offset of p_bio in mbedtls_ssl_set_bio, it differs between
+// 2.x and 3.x and with the build options
+func findMbedtlsBioOffset(path string) (uint32, error) {
+	store, ok := mbedtlsSetBioStores[runtime.GOARCH]
+	if !ok {
+		return 0, errors.Errorf("Unsupported architecture: %v", runtime.GOARCH)
+	}
+
+	file, err := elf.Open(path)
+	if err != nil {
+		return 0, errors.Wrap(err, 0)
+	}
+	defer file.Close()
+
+	symbol, err := getSymbol(file, "mbedtls_ssl_set_bio")
+	if err != nil {
+		return 0, err
+	}
+
+	if symbol.Section == elf.SHN_UNDEF || int(symbol.Section) >= len(file.Sections) {
+		return 0, errors.Errorf("mbedtls_ssl_set_bio isn't defined in %s", path)
+	}
+
+	section := file.Sections[symbol.Section]
+	data, err := section.Data()
+	if err != nil {
+		return 0, errors.Wrap(err, 0)
+	}
+
+	start := symbol.Value - section.Addr
+	if start+symbol.Size > uint64(len(data)) {
+		return 0, errors.Errorf("mbedtls_ssl_set_bio is out of its section in %s", path)
+	}
+
+	engine, err := newDisassembler()
+	if err != nil {
+		return 0, err
+	}
+	defer engine.Close()
+
+	instructions, err := engine.Disasm(data[start:start+symbol.Size], symbol.Value, 0)
+	if err != nil {
+		return 0, errors.Wrap(err, 0)
+	}
+
+	for _, instruction := range instructions {
+		match := store.FindStringSubmatch(instruction.OpStr)
+		if match == nil {
+			continue
+		}
+
+		offset, err := strconv.ParseUint(match[1], 0, 32)
+		if err != nil {
+			return 0, errors.Wrap(err, 0)
+		}
+
+		return uint32(offset), nil
+	}
+
+	return 0, errors.Errorf("The store of p_bio isn't found in mbedtls_ssl_set_bio of %s", path)
+}
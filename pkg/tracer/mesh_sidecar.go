@@ -0,0 +1,52 @@
+package tracer
+
+import (
+	"github.com/go-errors/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	meshLegApp     = "app"
+	meshLegSidecar = "sidecar"
+)
+
+// In a meshed pod the same transaction is seen twice, once between the app and the sidecar
+// over loopback and once between the sidecar and the upstream, so only one leg is targeted.
+// The sidecars aren't hooked yet, so the sidecar leg has no payloads: Envoy of Istio links
+// BoringSSL statically without its symbols and writes through its own BIO with readv and
+// writev, and linkerd-proxy uses rustls, which has no C functions to probe.
+var meshSidecarContainers = map[string]bool{
+	"istio-proxy":   true,
+	"linkerd-proxy": true,
+}
+
+func validateMeshLeg(leg string) error {
+	if leg != meshLegApp && leg != meshLegSidecar {
+		return errors.Errorf("Invalid mesh leg %q, expected %q or %q", leg, meshLegApp, meshLegSidecar)
+	}
+
+	return nil
+}
+
+func isMeshedPod(pod *v1.Pod) bool {
+	for _, container := range pod.Status.ContainerStatuses {
+		if meshSidecarContainers[container.Name] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the leg of the container if the pod is meshed, an empty string otherwise
+func getMeshLeg(pod *v1.Pod, containerName string) string {
+	if !isMeshedPod(pod) {
+		return ""
+	}
+
+	if meshSidecarContainers[containerName] {
+		return meshLegSidecar
+	}
+
+	return meshLegApp
+}
@@ -0,0 +1,57 @@
+package tracer
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func newTestPod(containers ...string) *v1.Pod {
+	pod := &v1.Pod{}
+	for _, container := range containers {
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, v1.ContainerStatus{Name: container})
+	}
+
+	return pod
+}
+
+func TestGetMeshLeg(t *testing.T) {
+	tests := []struct {
+		name      string
+		pod       *v1.Pod
+		container string
+		leg       string
+	}{
+		{"not meshed", newTestPod("app"), "app", ""},
+		{"no containers", newTestPod(), "app", ""},
+		{"istio app", newTestPod("app", "istio-proxy"), "app", meshLegApp},
+		{"istio sidecar", newTestPod("app", "istio-proxy"), "istio-proxy", meshLegSidecar},
+		{"linkerd app", newTestPod("linkerd-proxy", "app"), "app", meshLegApp},
+		{"linkerd sidecar", newTestPod("linkerd-proxy", "app"), "linkerd-proxy", meshLegSidecar},
+		// The init containers aren't in the statuses of the containers
+		{"linkerd init", newTestPod("app"), "linkerd-init", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if leg := getMeshLeg(test.pod, test.container); leg != test.leg {
+				t.Fatalf("got %q, want %q", leg, test.leg)
+			}
+		})
+	}
+}
+
+func TestValidateMeshLeg(t *testing.T) {
+	tests := map[string]bool{
+		meshLegApp:     true,
+		meshLegSidecar: true,
+		"":             false,
+		"upstream":     false,
+	}
+
+	for leg, valid := range tests {
+		if err := validateMeshLeg(leg); (err == nil) != valid {
+			t.Errorf("got %v for %q, want valid %v", err, leg, valid)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package tracer
+
+import (
+	"context"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// In metadata mode the probes don't send chunks, they count the bytes and messages
+// per connection and direction in a per-CPU map, which is read on an interval.
+type flowPoller struct {
+	flows *ebpf.Map
+	last  map[tracerFlowKey]tracerFlowStats
+}
+
+func newFlowPoller(bpfObjects *tracerObjects) *flowPoller {
+	return &flowPoller{
+		flows: bpfObjects.tracerMaps.FlowStatsMap,
+		last:  make(map[tracerFlowKey]tracerFlowStats),
+	}
+}
+
+func (p *flowPoller) poll(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := p.collect(); err != nil {
+			LogError(err)
+		}
+	}
+}
+
+func (p *flowPoller) collect() error {
+	current := make(map[tracerFlowKey]tracerFlowStats)
+
+	var key tracerFlowKey
+	var perCpu []tracerFlowStats
+
+	entries := p.flows.Iterate()
+	for entries.Next(&key, &perCpu) {
+		var stats tracerFlowStats
+		for _, cpuStats := range perCpu {
+			stats.Bytes += cpuStats.Bytes
+			stats.Messages += cpuStats.Messages
+		}
+
+		current[key] = stats
+
+		// The counters only grow until the flow is evicted from the LRU map
+		last := p.last[key]
+		if stats.Messages <= last.Messages {
+			continue
+		}
+
+		p.logFlow(&key, stats.Bytes-last.Bytes, stats.Messages-last.Messages)
+	}
+
+	if err := entries.Err(); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	p.last = current
+
+	return nil
+}
+
+func (p *flowPoller) logFlow(key *tracerFlowKey, bytes uint64, messages uint64) {
+	srcIp, dstIp := intToIP(key.AddressInfo.Saddr), intToIP(key.AddressInfo.Daddr)
+	srcPort, dstPort := ntohs(key.AddressInfo.Sport), ntohs(key.AddressInfo.Dport)
+
+	direction := "write"
+	if key.Flags&FlagsIsReadBit != 0 {
+		direction = "read"
+	}
+
+	log.Info().
+		Uint32("pid", key.Pid).
+		Str("src", srcIp.String()).
+		Uint16("src-port", srcPort).
+		Str("dst", dstIp.String()).
+		Uint16("dst-port", dstPort).
+		Bool("client", key.Flags&FlagsIsClientBit != 0).
+		Bool("udp", key.Flags&FlagsIsUdpBit != 0).
+		Str("direction", direction).
+		Uint64("bytes", bytes).
+		Uint64("messages", messages).
+		Msg("Flow:")
+}
@@ -0,0 +1,150 @@
+package tracer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// namespaceQuota limits the captured bytes and transactions per namespace within a window,
+// so a single noisy namespace can't monopolize the tracer on a shared cluster. The decision
+// is made once per stream: a new stream is admitted while its namespace is below the limits,
+// then all of its chunks are captured and counted, so the streams in flight can exceed them.
+// A transaction is an HTTP/1.x request, or a stream of the other protocols.
+type namespaceQuota struct {
+	bytesLimit        uint64
+	transactionsLimit uint64
+	window            time.Duration
+	windowStart       time.Time
+	usage             map[string]*QuotaUsage
+	// Status reads the usage
+	sync.Mutex
+}
+
+// QuotaUsage is the usage of a namespace in the current window of the quota
+type QuotaUsage struct {
+	Namespace    string `json:"namespace"`
+	Bytes        uint64 `json:"bytes"`
+	Transactions uint64 `json:"transactions"`
+	// The new streams that weren't captured since the quota was exceeded
+	RejectedStreams uint64    `json:"rejectedStreams"`
+	WindowStart     time.Time `json:"windowStart"`
+}
+
+func newNamespaceQuota(bytesLimit uint64, transactionsLimit uint64, window time.Duration) *namespaceQuota {
+	return &namespaceQuota{
+		bytesLimit:        bytesLimit,
+		transactionsLimit: transactionsLimit,
+		window:            window,
+		windowStart:       time.Now(),
+		usage:             make(map[string]*QuotaUsage),
+	}
+}
+
+func (q *namespaceQuota) isEnabled() bool {
+	return q.bytesLimit > 0 || q.transactionsLimit > 0
+}
+
+// admit decides whether a new stream of the namespace is captured. The usage is returned on
+// the first stream that is rejected in the window, for the quota-exceeded event.
+func (q *namespaceQuota) admit(namespace string, now time.Time) (bool, *QuotaUsage) {
+	if !q.isEnabled() || namespace == "" {
+		return true, nil
+	}
+
+	q.Lock()
+	defer q.Unlock()
+
+	usage := q.getUsage(namespace, now)
+	if !q.isExceeded(usage) {
+		return true, nil
+	}
+
+	usage.RejectedStreams++
+	if usage.RejectedStreams > 1 {
+		return false, nil
+	}
+
+	log.Warn().
+		Str("namespace", namespace).
+		Uint64("bytes", usage.Bytes).
+		Uint64("transactions", usage.Transactions).
+		Dur("window", q.window).
+		Msg("Quota exceeded, not capturing new streams until the window ends:")
+
+	exceeded := *usage
+	return false, &exceeded
+}
+
+// add counts the usage of an admitted stream
+func (q *namespaceQuota) add(namespace string, bytes uint64, transactions uint64, now time.Time) {
+	if !q.isEnabled() || namespace == "" {
+		return
+	}
+
+	q.Lock()
+	defer q.Unlock()
+
+	usage := q.getUsage(namespace, now)
+	usage.Bytes += bytes
+	usage.Transactions += transactions
+}
+
+func (q *namespaceQuota) isExceeded(usage *QuotaUsage) bool {
+	return q.bytesLimit > 0 && usage.Bytes >= q.bytesLimit ||
+		q.transactionsLimit > 0 && usage.Transactions >= q.transactionsLimit
+}
+
+// getUsage is called with the lock held, the window is reset when it ends
+func (q *namespaceQuota) getUsage(namespace string, now time.Time) *QuotaUsage {
+	if now.Sub(q.windowStart) > q.window {
+		q.reset(now)
+	}
+
+	usage, ok := q.usage[namespace]
+	if !ok {
+		usage = &QuotaUsage{Namespace: namespace, WindowStart: q.windowStart}
+		q.usage[namespace] = usage
+	}
+
+	return usage
+}
+
+func (q *namespaceQuota) reset(now time.Time) {
+	for namespace, usage := range q.usage {
+		log.Info().Msg(fmt.Sprintf("Quota usage (namespace: %s) (bytes: %d) (transactions: %d) (rejected streams: %d)",
+			namespace, usage.Bytes, usage.Transactions, usage.RejectedStreams))
+	}
+
+	q.windowStart = now
+	q.usage = make(map[string]*QuotaUsage)
+}
+
+// getUsages returns the usage of the namespaces in the current window by their name
+func (q *namespaceQuota) getUsages() []QuotaUsage {
+	q.Lock()
+	defer q.Unlock()
+
+	usages := make([]QuotaUsage, 0, len(q.usage))
+	if time.Since(q.windowStart) > q.window {
+		return usages
+	}
+
+	for _, usage := range q.usage {
+		usages = append(usages, *usage)
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].Namespace < usages[j].Namespace })
+
+	return usages
+}
+
+func newQuotaExceededEvent(usage *QuotaUsage) Event {
+	return Event{
+		Namespace: usage.Namespace,
+		Timestamp: time.Now(),
+		Notice:    &Notice{Type: NoticeQuotaExceeded, Quota: usage},
+	}
+}
@@ -0,0 +1,82 @@
+package tracer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNamespaceQuota(t *testing.T) {
+	type usage struct {
+		bytes        uint64
+		transactions uint64
+	}
+
+	tests := []struct {
+		name              string
+		bytesLimit        uint64
+		transactionsLimit uint64
+		namespace         string
+		usage             usage
+		admitted          bool
+	}{
+		{"disabled", 0, 0, "shop", usage{1 << 30, 1000}, true},
+		{"no namespace", 100, 10, "", usage{1000, 100}, true},
+		{"below", 100, 10, "shop", usage{99, 9}, true},
+		{"bytes", 100, 0, "shop", usage{100, 0}, false},
+		{"transactions", 0, 10, "shop", usage{0, 10}, false},
+		{"either", 100, 10, "shop", usage{10, 10}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now := time.Now()
+			quota := newNamespaceQuota(test.bytesLimit, test.transactionsLimit, time.Minute)
+			quota.windowStart = now
+
+			quota.add(test.namespace, test.usage.bytes, test.usage.transactions, now)
+
+			admitted, exceeded := quota.admit(test.namespace, now)
+			if admitted != test.admitted {
+				t.Fatalf("got admitted %v, want %v", admitted, test.admitted)
+			}
+
+			if (exceeded != nil) == admitted {
+				t.Fatalf("got the exceeded usage %v on admitted %v", exceeded, admitted)
+			}
+
+			if exceeded != nil && (exceeded.Bytes != test.usage.bytes || exceeded.Transactions != test.usage.transactions || exceeded.RejectedStreams != 1) {
+				t.Fatalf("got the exceeded usage %+v", *exceeded)
+			}
+
+			// Only the first rejection of the window is reported
+			if admitted, exceeded := quota.admit(test.namespace, now); admitted != test.admitted || exceeded != nil {
+				t.Fatalf("got admitted %v and %v again", admitted, exceeded)
+			}
+
+			// The window ends
+			if admitted, _ := quota.admit(test.namespace, now.Add(2*time.Minute)); !admitted {
+				t.Fatal("not admitted in the next window")
+			}
+		})
+	}
+}
+
+func TestNamespaceQuotaUsages(t *testing.T) {
+	now := time.Now()
+	quota := newNamespaceQuota(100, 0, time.Minute)
+	quota.windowStart = now
+
+	quota.add("shop", 150, 2, now)
+	quota.add("billing", 10, 1, now)
+	quota.admit("shop", now)
+	quota.admit("shop", now)
+
+	usages := quota.getUsages()
+	if len(usages) != 2 || usages[0].Namespace != "billing" || usages[1].Namespace != "shop" {
+		t.Fatalf("got %+v", usages)
+	}
+
+	if shop := usages[1]; shop.Bytes != 150 || shop.Transactions != 2 || shop.RejectedStreams != 2 || !shop.WindowStart.Equal(now) {
+		t.Fatalf("got %+v", shop)
+	}
+}
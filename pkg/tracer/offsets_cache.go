@@ -0,0 +1,197 @@
+package tracer
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Build ID note sections, the Go one is preferred, the GNU one exists for external linking
+var buildIdSections = []string{".note.go.buildid", ".note.gnu.build-id"}
+
+// offsetsCacheVersion is bumped when cachedGoOffsets or the analysis of the binaries changes, the
+// files of the other versions are ignored
+const offsetsCacheVersion = 1
+
+// offsetsCache keeps the analysis results of the Go binaries in a directory keyed by their
+// ELF build ID, so the containers restarted with the same image are attached instantly
+type offsetsCache struct {
+	dir string
+}
+
+// The JSON form of goOffsets
+type cachedGoOffsets struct {
+	Version       int      `json:"version"`
+	WriteEnter    uint64   `json:"writeEnter"`
+	WriteExits    []uint64 `json:"writeExits"`
+	ReadEnter     uint64   `json:"readEnter"`
+	ReadExits     []uint64 `json:"readExits"`
+	GoVersion     string   `json:"goVersion"`
+	Abi           goAbi    `json:"abi"`
+	GoidOffset    uint64   `json:"goidOffset"`
+	GStructOffset uint64   `json:"gStructOffset"`
+}
+
+func newOffsetsCache(dir string) *offsetsCache {
+	return &offsetsCache{dir: dir}
+}
+
+// findGoOffsets analyzes the binary on a cache miss, the binaries without a build ID are
+// analyzed each time
+func (c *offsetsCache) findGoOffsets(fpath string) (goOffsets, error) {
+	if c.dir == "" {
+		return findGoOffsets(fpath)
+	}
+
+	buildId, err := readBuildId(fpath)
+	if err != nil {
+		log.Debug().Err(err).Str("path", fpath).Msg("Couldn't read the build ID, not caching:")
+		return findGoOffsets(fpath)
+	}
+
+	cachePath := filepath.Join(c.dir, fmt.Sprintf("v%d-%s.json", offsetsCacheVersion, buildId))
+	if offsets, err := c.load(cachePath); err == nil {
+		log.Debug().Str("path", fpath).Str("build-id", buildId).Msg("Found the Go offsets in cache:")
+		return offsets, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		log.Warn().Err(err).Str("path", cachePath).Msg("Couldn't read the cached Go offsets:")
+	}
+
+	offsets, err := findGoOffsets(fpath)
+	if err != nil {
+		return offsets, err
+	}
+
+	if err := c.store(cachePath, offsets); err != nil {
+		log.Warn().Err(err).Str("path", cachePath).Msg("Couldn't cache the Go offsets:")
+	}
+
+	return offsets, nil
+}
+
+func (c *offsetsCache) load(cachePath string) (goOffsets, error) {
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		return goOffsets{}, errors.Wrap(err, 0)
+	}
+
+	var cached cachedGoOffsets
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return goOffsets{}, errors.Wrap(err, 0)
+	}
+
+	if cached.Version != offsetsCacheVersion {
+		return goOffsets{}, errors.Errorf("Cache version %d, expected %d", cached.Version, offsetsCacheVersion)
+	}
+
+	return goOffsets{
+		GoWriteOffset: &goExtendedOffset{enter: cached.WriteEnter, exits: cached.WriteExits},
+		GoReadOffset:  &goExtendedOffset{enter: cached.ReadEnter, exits: cached.ReadExits},
+		GoVersion:     cached.GoVersion,
+		Abi:           cached.Abi,
+		GoidOffset:    cached.GoidOffset,
+		GStructOffset: cached.GStructOffset,
+	}, nil
+}
+
+func (c *offsetsCache) store(cachePath string, offsets goOffsets) error {
+	data, err := json.Marshal(cachedGoOffsets{
+		Version:       offsetsCacheVersion,
+		WriteEnter:    offsets.GoWriteOffset.enter,
+		WriteExits:    offsets.GoWriteOffset.exits,
+		ReadEnter:     offsets.GoReadOffset.enter,
+		ReadExits:     offsets.GoReadOffset.exits,
+		GoVersion:     offsets.GoVersion,
+		Abi:           offsets.Abi,
+		GoidOffset:    offsets.GoidOffset,
+		GStructOffset: offsets.GStructOffset,
+	})
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	if err := os.MkdirAll(c.dir, os.ModePerm); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	// Written to a file of its own and renamed into place, so a concurrent attach never reads a
+	// partial file, and the workers that analyze the same binary don't write the same file
+	tmp, err := os.CreateTemp(c.dir, filepath.Base(cachePath)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), cachePath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, 0)
+	}
+
+	return nil
+}
+
+// readBuildId returns the hex encoded build ID of an ELF file
+func readBuildId(fpath string) (string, error) {
+	file, err := elf.Open(fpath)
+	if err != nil {
+		return "", errors.Wrap(err, 0)
+	}
+	defer file.Close()
+
+	for _, name := range buildIdSections {
+		section := file.Section(name)
+		if section == nil {
+			continue
+		}
+
+		data, err := section.Data()
+		if err != nil {
+			return "", errors.Wrap(err, 0)
+		}
+
+		if desc := parseElfNote(data, file.ByteOrder); len(desc) > 0 {
+			return hex.EncodeToString(desc), nil
+		}
+	}
+
+	return "", errors.Errorf("No build ID found in %s", fpath)
+}
+
+// parseElfNote returns the descriptor of the first note, the name and the descriptor are
+// 4 bytes aligned
+func parseElfNote(data []byte, order binary.ByteOrder) []byte {
+	var header struct {
+		NameSize uint32
+		DescSize uint32
+		Type     uint32
+	}
+
+	if err := binary.Read(bytes.NewReader(data), order, &header); err != nil {
+		return nil
+	}
+
+	start := 12 + (uint64(header.NameSize)+3)&^3
+	end := start + uint64(header.DescSize)
+	if end > uint64(len(data)) {
+		return nil
+	}
+
+	return data[start:end]
+}
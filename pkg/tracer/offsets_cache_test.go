@@ -0,0 +1,132 @@
+package tracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newElfNote(order binary.ByteOrder, name string, desc []byte) []byte {
+	var note bytes.Buffer
+	_ = binary.Write(&note, order, []uint32{uint32(len(name)), uint32(len(desc)), 3})
+	note.WriteString(name)
+	for note.Len()%4 != 0 {
+		note.WriteByte(0)
+	}
+	note.Write(desc)
+	return note.Bytes()
+}
+
+func TestParseElfNote(t *testing.T) {
+	desc := []byte{0xde, 0xad, 0xbe, 0xef, 0x01}
+
+	le, be := binary.LittleEndian, binary.BigEndian
+
+	tests := []struct {
+		name  string
+		order binary.ByteOrder
+		data  []byte
+		want  []byte
+	}{
+		{"gnu", le, newElfNote(le, "GNU\x00", desc), desc},
+		{"big endian", be, newElfNote(be, "GNU\x00", desc), desc},
+		{"padded name", le, newElfNote(le, "Go\x00", desc), desc},
+		{"empty descriptor", le, newElfNote(le, "GNU\x00", nil), []byte{}},
+		{"truncated descriptor", le, newElfNote(le, "GNU\x00", desc)[:18], nil},
+		{"truncated header", le, []byte{4, 0, 0, 0}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parseElfNote(test.data, test.order); !bytes.Equal(got, test.want) || (got == nil) != (test.want == nil) {
+				t.Fatalf("got %x, want %x", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadBuildId(t *testing.T) {
+	// Go links the test binary with a .note.go.buildid
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notElf := filepath.Join(t.TempDir(), "not-elf")
+	if err := os.WriteFile(notElf, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		fpath string
+		err   bool
+	}{
+		{"test binary", executable, false},
+		{"not elf", notElf, true},
+		{"missing", notElf + ".missing", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			id, err := readBuildId(test.fpath)
+			if (err != nil) != test.err {
+				t.Fatalf("got %v, want an error %v", err, test.err)
+			}
+
+			if !test.err && id == "" {
+				t.Fatal("got an empty build ID")
+			}
+		})
+	}
+}
+
+func TestOffsetsCacheStoreLoad(t *testing.T) {
+	dir := t.TempDir()
+	cache := newOffsetsCache(dir)
+	offsets := goOffsets{
+		GoWriteOffset: &goExtendedOffset{enter: 0x10, exits: []uint64{0x20, 0x30}},
+		GoReadOffset:  &goExtendedOffset{enter: 0x40, exits: []uint64{0x50}},
+		GoVersion:     "1.21.0",
+		GoidOffset:    152,
+		GStructOffset: 0x30,
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "stored"},
+		{name: "other version", content: `{"version":0,"writeEnter":16}`, wantErr: true},
+		{name: "corrupt", content: `{"version":`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cachePath := filepath.Join(dir, "build.json")
+			if tt.content == "" {
+				if err := cache.store(cachePath, offsets); err != nil {
+					t.Fatal(err)
+				}
+			} else if err := os.WriteFile(cachePath, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := cache.load(cachePath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, offsets) {
+				t.Errorf("load() = %+v, want %+v", got, offsets)
+			}
+		})
+	}
+
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(matches) != 0 {
+		t.Errorf("temporary files left: %v", matches)
+	}
+}
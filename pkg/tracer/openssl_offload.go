@@ -0,0 +1,229 @@
+package tracer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// The engines of OpenSSL 1.1 and 3.x, e.g. the QAT engine, and the providers of 3.x that
+// offload the crypto. In their async mode the plaintext may bypass SSL_write and SSL_read,
+// so BIO_write and BIO_read are hooked as well. The default providers aren't offloads.
+var opensslOffloadRegex = regexp.MustCompile(`(/engines-[0-9.]+/[^/]+\.so|/ossl-modules/[^/]+\.so|/(qatengine|qatprovider)\.so[^/]*)$`)
+
+var opensslDefaultProviders = []string{"legacy.so", "fips.so"}
+
+var libcryptoRegex = regexp.MustCompile(`/libcrypto\.so[^/]*$`)
+
+var bioSymbols = []string{"BIO_write", "BIO_read"}
+
+// Logs the secrets of SSLKEYLOGFILE, it's internal to libssl and found in the symbol table
+// only if the library isn't stripped
+const logSecretSymbol = "ssl_log_secret"
+
+// The offset of the method in struct bio_st, OpenSSL 3.x added the library context before it
+const (
+	bioMethodOffset    = 0
+	bioMethodOffsetV30 = 8
+)
+
+// findOpensslOffloads returns the mapped engines and providers of the process, an engine
+// that is loaded after the process is targeted isn't found
+func findOpensslOffloads(procfs string, pid uint32) ([]string, error) {
+	paths, err := getMappedPaths(procfs, pid)
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	offloads := make([]string, 0)
+	for _, path := range paths {
+		if opensslOffloadRegex.MatchString(path) && !containsString(opensslDefaultProviders, filepath.Base(path)) {
+			offloads = append(offloads, path)
+		}
+	}
+
+	return offloads, nil
+}
+
+// findBioLibrary returns the libcrypto.so next to sslLibrary, or sslLibrary itself if OpenSSL
+// is linked statically. The mapped libcrypto.so files are preferred.
+func findBioLibrary(procfs string, pid uint32, sslLibrary string) (string, error) {
+	if definesSymbol(sslLibrary, "BIO_write") {
+		return sslLibrary, nil
+	}
+
+	paths, err := getMappedPaths(procfs, pid)
+	if err != nil {
+		return "", errors.Wrap(err, 0)
+	}
+
+	found := ""
+	for _, path := range paths {
+		if !libcryptoRegex.MatchString(path) {
+			continue
+		}
+
+		fullpath := fmt.Sprintf("%v/%v/root%v", procfs, pid, path)
+		if filepath.Dir(fullpath) == filepath.Dir(sslLibrary) {
+			return fullpath, nil
+		}
+
+		if found == "" {
+			found = fullpath
+		}
+	}
+
+	// A libssl that isn't loaded yet has its libcrypto next to it with the same version
+	if found == "" {
+		sibling := filepath.Join(filepath.Dir(sslLibrary), strings.Replace(filepath.Base(sslLibrary), "libssl", "libcrypto", 1))
+		if _, err := os.Stat(sibling); err == nil {
+			return sibling, nil
+		}
+
+		return "", errors.Errorf("libcrypto.so not found for PID %d", pid)
+	}
+
+	return found, nil
+}
+
+type bioHooks struct {
+	probes []link.Link
+}
+
+func (s *bioHooks) installUprobes(bpfObjects *tracerObjects, cryptoLibraryPath string, overrides []SymbolOffset) error {
+	executable, err := link.OpenExecutable(cryptoLibraryPath)
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	attaches := []struct {
+		symbol  string
+		program *ebpf.Program
+		ret     bool
+	}{
+		{"BIO_write", bpfObjects.OpensslBioWrite, false},
+		{"BIO_write", bpfObjects.OpensslBioRetWrite, true},
+		{"BIO_read", bpfObjects.OpensslBioRead, false},
+		{"BIO_read", bpfObjects.OpensslBioRetRead, true},
+	}
+
+	for _, attach := range attaches {
+		options, err := getUprobeOptions(overrides, cryptoLibraryPath, attach.symbol)
+		if err != nil {
+			s.close()
+			return err
+		}
+
+		var probe link.Link
+		if attach.ret {
+			probe, err = executable.Uretprobe(attach.symbol, attach.program, options)
+		} else {
+			probe, err = executable.Uprobe(attach.symbol, attach.program, options)
+		}
+
+		if err != nil {
+			s.close()
+			return errors.Wrap(err, 0)
+		}
+
+		s.probes = append(s.probes, probe)
+	}
+
+	return nil
+}
+
+// installKeylogUprobe hooks ssl_log_secret of sslLibraryPath for the processes captured at
+// BIO level, it's detached with the BIO hooks
+func (s *bioHooks) installKeylogUprobe(bpfObjects *tracerObjects, sslLibraryPath string, overrides []SymbolOffset) error {
+	executable, err := link.OpenExecutable(sslLibraryPath)
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	options, err := getUprobeOptions(overrides, sslLibraryPath, logSecretSymbol)
+	if err != nil {
+		return err
+	}
+
+	probe, err := executable.Uprobe(logSecretSymbol, bpfObjects.OpensslLogSecret, options)
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	s.probes = append(s.probes, probe)
+
+	return nil
+}
+
+func (s *bioHooks) close() []error {
+	returnValue := make([]error, 0)
+
+	for _, probe := range s.probes {
+		if err := probe.Close(); err != nil {
+			returnValue = append(returnValue, err)
+		}
+	}
+
+	s.probes = nil
+
+	return returnValue
+}
+
+// targetOpensslBio hooks BIO_write and BIO_read of the process if it offloads the crypto of
+// OpenSSL or if it's captured at BIO level, they are detached with the SSL hooks of sslLibrary
+func (t *Tracer) targetOpensslBio(pid uint32, sslLibrary string, hooks *sslHooks) error {
+	capture := containsPid(t.config.BioCapturePids, pid)
+
+	var offloads []string
+	if !capture {
+		var err error
+		offloads, err = findOpensslOffloads(t.procfs, pid)
+		if err != nil || len(offloads) == 0 {
+			return err
+		}
+	}
+
+	cryptoLibrary, err := findBioLibrary(t.procfs, pid, sslLibrary)
+	if err != nil {
+		return err
+	}
+
+	offset := uint32(bioMethodOffset)
+	if definesSymbol(cryptoLibrary, "OSSL_LIB_CTX_new") {
+		offset = bioMethodOffsetV30
+	}
+
+	if err := hooks.bio.installUprobes(&t.bpfObjects, cryptoLibrary, t.config.SymbolOffsets); err != nil {
+		return err
+	}
+
+	if err := t.bpfObjects.tracerMaps.OpensslBioMethodOffsets.Put(pid, offset); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	if !capture {
+		log.Info().Int("pid", int(pid)).Strs("offloads", offloads).Str("path", cryptoLibrary).Msg("OpenSSL offloads the crypto, hooking BIO_write and BIO_read:")
+		return nil
+	}
+
+	// The records are captured without the secrets if ssl_log_secret can't be hooked
+	if err := hooks.bio.installKeylogUprobe(&t.bpfObjects, sslLibrary, t.config.SymbolOffsets); err != nil {
+		log.Warn().Err(err).Int("pid", int(pid)).Str("path", sslLibrary).
+			Msg("Couldn't hook ssl_log_secret, set its offset with -symbol-offsets to decrypt the records:")
+	}
+
+	if err := t.bpfObjects.tracerMaps.BioCapturePids.Put(pid, uint32(1)); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	log.Info().Int("pid", int(pid)).Str("path", cryptoLibrary).Msg("Capturing the records of OpenSSL at BIO level:")
+
+	return nil
+}
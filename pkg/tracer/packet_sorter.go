@@ -1,16 +1,17 @@
-package main
+package tracer
 
 import (
 	"errors"
 	"os"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/kubeshark/gopacket"
 	"github.com/kubeshark/gopacket/layers"
 	"github.com/kubeshark/gopacket/pcapgo"
 	"github.com/kubeshark/tracer/misc"
-	"github.com/rs/zerolog/log"
 )
 
 type SortedPacket struct {
@@ -21,12 +22,16 @@ type SortedPacket struct {
 type MasterPcap struct {
 	file   *os.File
 	writer *pcapgo.Writer
+	// Unix nanoseconds of the start of the write in progress, 0 if none
+	writingSince atomic.Int64
 	sync.Mutex
 }
 
 func (m *MasterPcap) WritePacket(ci gopacket.CaptureInfo, data []byte) (err error) {
 	m.Lock()
+	m.writingSince.Store(time.Now().UnixNano())
 	err = m.writer.WritePacket(ci, data)
+	m.writingSince.Store(0)
 	m.Unlock()
 	return
 }
@@ -55,11 +60,11 @@ func (s *PacketSorter) initMasterPcap() {
 	if _, err = os.Stat(misc.GetMasterPcapPath()); errors.Is(err, os.ErrNotExist) {
 		err = syscall.Mkfifo(misc.GetMasterPcapPath(), 0666)
 		if err != nil {
-			log.Error().Err(err).Msg("Couldn't create the named pipe:")
+			sorterLog.get().Error().Err(err).Msg("Couldn't create the named pipe:")
 		}
 		file, err = os.OpenFile(misc.GetMasterPcapPath(), os.O_APPEND|os.O_WRONLY, os.ModeNamedPipe)
 		if err != nil {
-			log.Error().Err(err).Msg("Couldn't create master PCAP:")
+			sorterLog.get().Error().Err(err).Msg("Couldn't create master PCAP:")
 		} else {
 			writer = pcapgo.NewWriter(file)
 			s.masterPcap = &MasterPcap{
@@ -68,13 +73,13 @@ func (s *PacketSorter) initMasterPcap() {
 			}
 			err = writer.WriteFileHeader(uint32(misc.Snaplen), layers.LinkTypeEthernet)
 			if err != nil {
-				log.Error().Err(err).Msg("While writing the PCAP header:")
+				sorterLog.get().Error().Err(err).Msg("While writing the PCAP header:")
 			}
 		}
 	} else {
 		file, err = os.OpenFile(misc.GetMasterPcapPath(), os.O_APPEND|os.O_WRONLY, os.ModeNamedPipe)
 		if err != nil {
-			log.Error().Err(err).Msg("Couldn't open master PCAP:")
+			sorterLog.get().Error().Err(err).Msg("Couldn't open master PCAP:")
 		} else {
 			writer = pcapgo.NewWriter(file)
 			s.masterPcap = &MasterPcap{
@@ -93,4 +98,12 @@ func (s *PacketSorter) Close() {
 	if s.sortedPackets != nil {
 		close(s.sortedPackets)
 	}
+
+	if s.masterPcap != nil {
+		s.masterPcap.Lock()
+		if err := s.masterPcap.file.Close(); err != nil {
+			sorterLog.get().Error().Err(err).Msg("Couldn't close master PCAP:")
+		}
+		s.masterPcap.Unlock()
+	}
 }
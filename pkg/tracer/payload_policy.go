@@ -0,0 +1,98 @@
+package tracer
+
+import (
+	"net"
+	"sync"
+
+	"github.com/go-errors/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+// payloadPolicy permits capturing the payloads of the connections whose peer is in one of
+// the CIDRs or is a targeted pod in one of the namespaces. The chunks of the other
+// connections are metadata only, their payload is neither written nor emitted.
+type payloadPolicy struct {
+	cidrs      []*net.IPNet
+	namespaces map[string]bool
+	// The namespaces of the targeted pods by their IP
+	podNamespaces map[string]string
+	sync.RWMutex
+}
+
+func parseCidrs(values []string) ([]*net.IPNet, error) {
+	cidrs := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		_, cidr, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, errors.Errorf("Invalid CIDR %q", value)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+
+	return cidrs, nil
+}
+
+func newPayloadPolicy(config *Config) (*payloadPolicy, error) {
+	p := &payloadPolicy{podNamespaces: make(map[string]string)}
+	if err := p.set(config); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// set replaces the CIDRs and the namespaces, on reload
+func (p *payloadPolicy) set(config *Config) error {
+	cidrs, err := parseCidrs(config.PayloadCidrs)
+	if err != nil {
+		return err
+	}
+
+	namespaces := make(map[string]bool)
+	for _, namespace := range config.PayloadNamespaces {
+		namespaces[namespace] = true
+	}
+
+	p.Lock()
+	p.cidrs = cidrs
+	p.namespaces = namespaces
+	p.Unlock()
+
+	return nil
+}
+
+func (p *payloadPolicy) setPods(pods []v1.Pod) {
+	podNamespaces := make(map[string]string)
+	for _, pod := range pods {
+		for _, ip := range pod.Status.PodIPs {
+			podNamespaces[ip.IP] = pod.Namespace
+		}
+	}
+
+	p.Lock()
+	p.podNamespaces = podNamespaces
+	p.Unlock()
+}
+
+func (p *payloadPolicy) allows(peer net.IP) bool {
+	p.RLock()
+	defer p.RUnlock()
+
+	if len(p.cidrs) == 0 && len(p.namespaces) == 0 {
+		return true
+	}
+
+	for _, cidr := range p.cidrs {
+		if cidr.Contains(peer) {
+			return true
+		}
+	}
+
+	if len(p.namespaces) == 0 {
+		return false
+	}
+
+	namespace, ok := p.podNamespaces[peer.String()]
+
+	return ok && p.namespaces[namespace]
+}
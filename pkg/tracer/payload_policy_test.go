@@ -0,0 +1,56 @@
+package tracer
+
+import (
+	"net"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestPayloadPolicy(t *testing.T) {
+	pod := v1.Pod{}
+	pod.Namespace = "payments"
+	pod.Status.PodIPs = []v1.PodIP{{IP: "10.1.0.5"}}
+
+	tests := []struct {
+		name   string
+		config Config
+		peer   string
+		allows bool
+	}{
+		{"no policy", Config{}, "8.8.8.8", true},
+		{"in cidr", Config{PayloadCidrs: []string{"10.0.0.0/8"}}, "10.2.0.1", true},
+		{"outside cidr", Config{PayloadCidrs: []string{"10.0.0.0/8"}}, "8.8.8.8", false},
+		{"pod in namespace", Config{PayloadNamespaces: []string{"payments"}}, "10.1.0.5", true},
+		{"pod in other namespace", Config{PayloadNamespaces: []string{"orders"}}, "10.1.0.5", false},
+		{"not a pod", Config{PayloadNamespaces: []string{"payments"}}, "10.1.0.6", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := newPayloadPolicy(&test.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			policy.setPods([]v1.Pod{pod})
+
+			if got := policy.allows(net.ParseIP(test.peer)); got != test.allows {
+				t.Errorf("got %v, want %v", got, test.allows)
+			}
+
+			// Reloaded with another policy, the pods are kept
+			if err := policy.set(&Config{PayloadCidrs: []string{test.peer + "/32"}}); err != nil {
+				t.Fatal(err)
+			}
+
+			if !policy.allows(net.ParseIP(test.peer)) {
+				t.Errorf("the reloaded policy doesn't allow %v", test.peer)
+			}
+		})
+	}
+
+	policy, _ := newPayloadPolicy(&Config{})
+	if err := policy.set(&Config{PayloadCidrs: []string{"10.0.0.0"}}); err == nil {
+		t.Error("got no error for an invalid CIDR")
+	}
+}
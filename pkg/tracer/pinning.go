@@ -0,0 +1,138 @@
+package tracer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// pinMaps makes the maps of spec pinned by name under path, a restarted tracer loads the
+// same maps with their connections and targets. The ring buffer keeps the chunks that are
+// produced meanwhile, the perf buffer loses them with the readers of the previous tracer.
+func pinMaps(spec *ebpf.CollectionSpec, path string) error {
+	if err := makePinDirs(path); err != nil {
+		return err
+	}
+
+	for name, mapSpec := range spec.Maps {
+		// .rodata and the other sections of the global variables
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		mapSpec.Pinning = ebpf.PinByName
+	}
+
+	return nil
+}
+
+func getPinnedMapsPath(path string) string {
+	return filepath.Join(path, "maps")
+}
+
+// resetPins removes the maps and the links pinned under path, e.g. when the layout of the
+// maps changed, the links are detached once the previous tracer closes them
+func resetPins(path string) error {
+	if err := os.RemoveAll(path); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	return makePinDirs(path)
+}
+
+func makePinDirs(path string) error {
+	for _, dir := range []string{"maps", "links"} {
+		if err := os.MkdirAll(filepath.Join(path, dir), 0700); err != nil {
+			return errors.Wrap(err, 0)
+		}
+	}
+
+	return nil
+}
+
+// attachPinned returns the link pinned as name under pinPath if it runs the same program,
+// so the hook stays attached while the tracer restarts. Otherwise the link is attached with
+// attach and pinned in place of the previous one. Nothing is pinned if pinPath is empty.
+func attachPinned(pinPath string, name string, program *ebpf.Program, attach func() (link.Link, error)) (link.Link, error) {
+	if pinPath == "" {
+		return attach()
+	}
+
+	path := filepath.Join(pinPath, "links", name)
+
+	previous, err := link.LoadPinnedLink(path, nil)
+	if err == nil && runsProgram(previous, program) {
+		log.Debug().Str("link", name).Msg("Reusing the pinned link:")
+		return previous, nil
+	}
+
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warn().Err(err).Str("link", name).Msg("Couldn't load the pinned link:")
+	}
+
+	current, err := attach()
+	if err != nil {
+		if previous != nil {
+			previous.Close()
+		}
+		return nil, err
+	}
+
+	// The new link is attached before the previous one is detached, nothing is missed
+	if previous != nil {
+		if err := previous.Unpin(); err != nil {
+			LogError(errors.Wrap(err, 0))
+		}
+		previous.Close()
+	}
+
+	if err := current.Pin(path); err != nil {
+		log.Warn().Err(err).Str("link", name).Msg("Couldn't pin the link, it's detached on restart:")
+	}
+
+	return current, nil
+}
+
+// runsProgram compares the tags of the programs, which don't depend on the maps
+func runsProgram(l link.Link, program *ebpf.Program) bool {
+	linkInfo, err := l.Info()
+	if err != nil {
+		return false
+	}
+
+	pinned, err := ebpf.NewProgramFromID(linkInfo.Program)
+	if err != nil {
+		return false
+	}
+	defer pinned.Close()
+
+	pinnedInfo, err := pinned.Info()
+	if err != nil {
+		return false
+	}
+
+	currentInfo, err := program.Info()
+	if err != nil {
+		return false
+	}
+
+	return pinnedInfo.Tag == currentInfo.Tag
+}
+
+// unpinLinks detaches the pinned links on close, e.g. when their probe group is disabled
+func unpinLinks(links ...link.Link) {
+	for _, l := range links {
+		if l == nil {
+			continue
+		}
+
+		if err := l.Unpin(); err != nil {
+			LogError(errors.Wrap(err, 0))
+		}
+	}
+}
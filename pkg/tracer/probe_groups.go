@@ -0,0 +1,271 @@
+package tracer
+
+import (
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// The probes are attached and detached in groups, so the sources that aren't needed don't
+// add their overhead
+const (
+	ProbeGroupOpenssl    = "openssl"
+	ProbeGroupGo         = "go"
+	ProbeGroupMbedtls    = "mbedtls"
+	ProbeGroupWolfssl    = "wolfssl"
+	ProbeGroupS2n        = "s2n"
+	ProbeGroupSyscalls   = "syscalls"
+	ProbeGroupTcpKprobes = "tcp-kprobes"
+)
+
+var ProbeGroups = []string{ProbeGroupGo, ProbeGroupMbedtls, ProbeGroupOpenssl, ProbeGroupS2n, ProbeGroupSyscalls, ProbeGroupTcpKprobes, ProbeGroupWolfssl}
+
+// isUprobeGroup is true for the groups whose uprobes are attached to the targeted processes
+func isUprobeGroup(group string) bool {
+	return group == ProbeGroupOpenssl || group == ProbeGroupGo || getTlsLibrary(group) != nil
+}
+
+// ProbeGroupStatus is the state of a probe group, Attached is the number of the libraries
+// or binaries that the uprobes of the group are attached to
+type ProbeGroupStatus struct {
+	Name     string `json:"name"`
+	Enabled  bool   `json:"enabled"`
+	Attached int    `json:"attached,omitempty"`
+}
+
+func validateProbeGroups(groups []string) error {
+	for _, group := range groups {
+		if !containsString(ProbeGroups, group) {
+			return errors.Errorf("Unknown probe group %q, expected one of %v", group, ProbeGroups)
+		}
+	}
+
+	return nil
+}
+
+// initProbeGroups enables the groups of the config, all of them if none are given. Only the
+// syscalls are enabled in the syscalls-only mode.
+func (t *Tracer) initProbeGroups() {
+	for _, group := range ProbeGroups {
+		enabled := len(t.config.ProbeGroups) == 0 || containsString(t.config.ProbeGroups, group)
+		if t.config.SyscallsOnly {
+			enabled = group == ProbeGroupSyscalls
+		}
+		t.disabledGroups.Store(group, !enabled)
+	}
+
+	t.warnProbeGroupDependencies()
+}
+
+func (t *Tracer) isProbeGroupEnabled(group string) bool {
+	disabled, ok := t.disabledGroups.Load(group)
+	return !ok || !disabled.(bool)
+}
+
+func (t *Tracer) checkProbeGroup(group string) error {
+	if !t.isProbeGroupEnabled(group) {
+		return errors.Errorf("Probe group %s is disabled", group)
+	}
+
+	return nil
+}
+
+// The uprobes only know the fd, the syscall tracepoints and the tcp kprobes find its connection
+func (t *Tracer) warnProbeGroupDependencies() {
+	uprobes := false
+	for _, group := range ProbeGroups {
+		uprobes = uprobes || (isUprobeGroup(group) && t.isProbeGroupEnabled(group))
+	}
+
+	if !uprobes {
+		return
+	}
+
+	for _, group := range []string{ProbeGroupSyscalls, ProbeGroupTcpKprobes} {
+		if !t.isProbeGroupEnabled(group) {
+			log.Warn().Str("group", group).Msg("The chunks of the uprobes can't be matched to their connections without the probe group:")
+		}
+	}
+}
+
+// SetProbeGroup attaches or detaches the probes of a group at runtime. The uprobes of an
+// enabled group are attached to the processes that are already targeted.
+func (t *Tracer) SetProbeGroup(group string, enabled bool) error {
+	if err := validateProbeGroups([]string{group}); err != nil {
+		return err
+	}
+
+	if enabled && t.config.SyscallsOnly && group != ProbeGroupSyscalls {
+		return errors.Errorf("Probe group %s can't be attached in the syscalls-only mode", group)
+	}
+
+	t.targetsLock.Lock()
+	defer t.targetsLock.Unlock()
+
+	if t.detached.Load() {
+		return errors.New("Probes are detached")
+	}
+
+	if t.isProbeGroupEnabled(group) == enabled {
+		return nil
+	}
+
+	if !enabled {
+		errs := t.detachProbeGroup(group)
+		t.disabledGroups.Store(group, true)
+		if len(errs) > 0 {
+			return joinErrors(errs)
+		}
+	} else if err := t.attachProbeGroup(group); err != nil {
+		return err
+	}
+
+	log.Info().Str("group", group).Bool("enabled", enabled).Msg("Probe group changed:")
+	t.warnProbeGroupDependencies()
+
+	return nil
+}
+
+func (t *Tracer) attachProbeGroup(group string) error {
+	switch group {
+	case ProbeGroupSyscalls:
+		hooks := syscallHooks{}
+		if err := hooks.installSyscallHooks(&t.bpfObjects, t.config.PinPath); err != nil {
+			hooks.close()
+			return err
+		}
+		t.syscallHooks = hooks
+	case ProbeGroupTcpKprobes:
+		hooks := tcpKprobeHooks{}
+		if err := hooks.installTcpKprobeHooks(&t.bpfObjects, t.bpfFeatures.fentry, t.config.PinPath); err != nil {
+			hooks.close()
+			return err
+		}
+		t.tcpKprobeHooks = hooks
+	}
+
+	t.disabledGroups.Store(group, false)
+
+	if isUprobeGroup(group) {
+		t.attachGroupTargets(group)
+	}
+
+	return nil
+}
+
+func (t *Tracer) detachProbeGroup(group string) []error {
+	errs := make([]error, 0)
+
+	switch group {
+	case ProbeGroupSyscalls:
+		unpinLinks(t.syscallHooks.links()...)
+		errs = t.syscallHooks.close()
+		t.syscallHooks = syscallHooks{}
+	case ProbeGroupTcpKprobes:
+		unpinLinks(t.tcpKprobeHooks.links()...)
+		errs = t.tcpKprobeHooks.close()
+		t.tcpKprobeHooks = tcpKprobeHooks{}
+	case ProbeGroupOpenssl:
+		for _, hooks := range t.sslHooksStructs {
+			errs = append(errs, hooks.close()...)
+		}
+		t.sslHooksStructs = make([]sslHooks, 0)
+	case ProbeGroupGo:
+		for _, hooks := range t.goHooksStructs {
+			errs = append(errs, hooks.close()...)
+		}
+		t.goHooksStructs = make([]goHooks, 0)
+	default:
+		kept := make([]tlsLibraryHooks, 0, len(t.tlsLibraryHooksStructs))
+		for _, hooks := range t.tlsLibraryHooksStructs {
+			if hooks.group == group {
+				errs = append(errs, hooks.close()...)
+			} else {
+				kept = append(kept, hooks)
+			}
+		}
+		t.tlsLibraryHooksStructs = kept
+	}
+
+	return errs
+}
+
+// attachGroupTargets attaches the uprobes of the group to the processes of the pods and the
+// registered PIDs, the processes that don't use the library are skipped
+func (t *Tracer) attachGroupTargets(group string) {
+	pids := make(map[uint32]bool)
+	t.pidTargets.Range(func(key, v interface{}) bool {
+		pids[key.(uint32)] = true
+		return true
+	})
+	t.registeredPids.Range(func(key, v interface{}) bool {
+		pids[key.(uint32)] = true
+		return true
+	})
+	delete(pids, GlobalWorkerPid)
+
+	for pid := range pids {
+		switch group {
+		case ProbeGroupOpenssl:
+			sslLibraries, _ := findSsllibs(t.procfs, pid)
+			for _, sslLibrary := range sslLibraries {
+				if err := t.targetSSLLibPid(pid, sslLibrary); err != nil {
+					LogError(err)
+				}
+			}
+		case ProbeGroupGo:
+			if err := t.targetGoPid(t.procfs, pid); err != nil {
+				LogError(err)
+			}
+		default:
+			library := getTlsLibrary(group)
+			files, _ := findTlsLibraryFiles(t.procfs, pid, library)
+			for _, file := range files {
+				if err := t.targetTlsLibraryPid(pid, library, file); err != nil {
+					LogError(err)
+				}
+			}
+		}
+	}
+}
+
+// ProbeGroupStatuses returns the groups in the order of ProbeGroups
+func (t *Tracer) ProbeGroupStatuses() []ProbeGroupStatus {
+	t.targetsLock.Lock()
+	defer t.targetsLock.Unlock()
+
+	statuses := make([]ProbeGroupStatus, 0, len(ProbeGroups))
+	for _, group := range ProbeGroups {
+		status := ProbeGroupStatus{Name: group, Enabled: t.isProbeGroupEnabled(group)}
+		switch group {
+		case ProbeGroupOpenssl:
+			status.Attached = len(t.sslHooksStructs)
+		case ProbeGroupGo:
+			status.Attached = len(t.goHooksStructs)
+		default:
+			for _, hooks := range t.tlsLibraryHooksStructs {
+				if hooks.group == group {
+					status.Attached++
+				}
+			}
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// SetProbeGroups enables the given groups and disables the others, all of them if none are
+// given. The groups are changed in order until one fails.
+func (t *Tracer) SetProbeGroups(groups []string) error {
+	if err := validateProbeGroups(groups); err != nil {
+		return err
+	}
+
+	for _, group := range ProbeGroups {
+		if err := t.SetProbeGroup(group, len(groups) == 0 || containsString(groups, group)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
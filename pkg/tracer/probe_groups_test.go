@@ -0,0 +1,36 @@
+package tracer
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSetProbeGroupDetached(t *testing.T) {
+	tracer := &Tracer{}
+	tracer.initProbeGroups()
+
+	// The groups are changed while the probes are detached, e.g. over the API on shutdown
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_ = tracer.SetProbeGroup(ProbeGroupGo, false)
+		}
+	}()
+
+	if errs := tracer.detach(); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	wg.Wait()
+
+	for _, group := range []string{ProbeGroupGo, ProbeGroupOpenssl} {
+		if err := tracer.SetProbeGroup(group, false); err == nil {
+			t.Fatalf("the group %s is changed after the probes are detached", group)
+		}
+	}
+
+	if err := tracer.SetProbeGroup("unknown", true); err == nil {
+		t.Fatal("got no error for an unknown group")
+	}
+}
@@ -0,0 +1,82 @@
+package tracer
+
+import "fmt"
+
+// ProbeOrigin is the eBPF probe that produced a chunk, the same values can be found in maps.h
+type ProbeOrigin uint32
+
+const (
+	ProbeOriginUnknown ProbeOrigin = iota
+	ProbeOriginSslWrite
+	ProbeOriginSslRead
+	ProbeOriginSslWriteEx
+	ProbeOriginSslReadEx
+	ProbeOriginGoAbi0Write
+	ProbeOriginGoAbi0Read
+	ProbeOriginGoAbiInternalWrite
+	ProbeOriginGoAbiInternalRead
+	ProbeOriginMbedtlsWrite
+	ProbeOriginMbedtlsRead
+	ProbeOriginWolfsslWrite
+	ProbeOriginWolfsslRead
+	ProbeOriginS2nSend
+	ProbeOriginS2nRecv
+	ProbeOriginOpensslBioWrite
+	ProbeOriginOpensslBioRead
+	ProbeOriginOpensslLogSecret
+	ProbeOriginSyscallWrite
+	ProbeOriginSyscallRead
+)
+
+var probeOriginNames = map[ProbeOrigin]string{
+	ProbeOriginSslWrite:           "uretprobe/ssl_write",
+	ProbeOriginSslRead:            "uretprobe/ssl_read",
+	ProbeOriginSslWriteEx:         "uretprobe/ssl_write_ex",
+	ProbeOriginSslReadEx:          "uretprobe/ssl_read_ex",
+	ProbeOriginGoAbi0Write:        "uprobe/go_crypto_tls_abi0_write_ex",
+	ProbeOriginGoAbi0Read:         "uprobe/go_crypto_tls_abi0_read_ex",
+	ProbeOriginGoAbiInternalWrite: "uprobe/go_crypto_tls_abi_internal_write_ex",
+	ProbeOriginGoAbiInternalRead:  "uprobe/go_crypto_tls_abi_internal_read_ex",
+	ProbeOriginMbedtlsWrite:       "uretprobe/mbedtls_write",
+	ProbeOriginMbedtlsRead:        "uretprobe/mbedtls_read",
+	ProbeOriginWolfsslWrite:       "uretprobe/wolfssl_write",
+	ProbeOriginWolfsslRead:        "uretprobe/wolfssl_read",
+	ProbeOriginS2nSend:            "uretprobe/s2n_send",
+	ProbeOriginS2nRecv:            "uretprobe/s2n_recv",
+	ProbeOriginOpensslBioWrite:    "uretprobe/openssl_bio_write",
+	ProbeOriginOpensslBioRead:     "uretprobe/openssl_bio_read",
+	ProbeOriginOpensslLogSecret:   "uprobe/openssl_log_secret",
+	ProbeOriginSyscallWrite:       "tracepoint/sys_exit_write",
+	ProbeOriginSyscallRead:        "tracepoint/sys_exit_read",
+}
+
+func (o ProbeOrigin) String() string {
+	if name, ok := probeOriginNames[o]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("unknown(%d)", uint32(o))
+}
+
+// AddressSource is the instrumentation that resolved the file descriptor and the
+// address of a chunk. The address is always read by the tcp_sendmsg/tcp_recvmsg
+// kprobes, or udp_sendmsg/udp_recvmsg for DTLS, the file descriptor of libssl by the
+// read/write syscall tracepoints, or recvfrom/sendto, and the one of Go by the same
+// tracepoints through the goroutine context. In the syscalls-only mode both are read by the
+// read/write syscall tracepoints.
+func (o ProbeOrigin) AddressSource() string {
+	switch o {
+	case ProbeOriginSslWrite, ProbeOriginSslRead, ProbeOriginSslWriteEx, ProbeOriginSslReadEx, ProbeOriginOpensslBioWrite, ProbeOriginOpensslBioRead:
+		return "fd: syscall tracepoint, address: kprobe"
+	case ProbeOriginGoAbi0Write, ProbeOriginGoAbi0Read, ProbeOriginGoAbiInternalWrite, ProbeOriginGoAbiInternalRead:
+		return "fd: syscall tracepoint (goroutine), address: kprobe"
+	case ProbeOriginMbedtlsWrite, ProbeOriginMbedtlsRead:
+		return "fd: p_bio or syscall tracepoint, address: kprobe"
+	case ProbeOriginWolfsslWrite, ProbeOriginWolfsslRead, ProbeOriginS2nSend, ProbeOriginS2nRecv:
+		return "fd: syscall tracepoint, address: kprobe"
+	case ProbeOriginSyscallWrite, ProbeOriginSyscallRead:
+		return "fd: syscall tracepoint, address: syscall tracepoint"
+	default:
+		return "unknown"
+	}
+}
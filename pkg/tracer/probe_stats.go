@@ -0,0 +1,166 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/unix"
+)
+
+type ProbeOverhead struct {
+	Program      string        `json:"program"`
+	RunsPerSec   float64       `json:"runsPerSec"`
+	AvgRunTime   time.Duration `json:"avgRunTime"`
+	CpuPercent   float64       `json:"cpuPercent"`
+	TotalRuns    uint64        `json:"totalRuns"`
+	TotalRunTime time.Duration `json:"totalRunTime"`
+}
+
+type probeCounters struct {
+	runs    uint64
+	runTime time.Duration
+}
+
+// probeStats estimates the CPU cost of each eBPF program from the run time statistics
+// of the kernel (BPF_ENABLE_STATS), which are only collected while they are enabled.
+type probeStats struct {
+	programs map[string]*ebpf.Program
+	stats    io.Closer
+	last     map[string]probeCounters
+	lastTime time.Time
+	report   []ProbeOverhead
+	sync.Mutex
+}
+
+func newProbeStats(bpfObjects *tracerObjects) *probeStats {
+	programs := make(map[string]*ebpf.Program)
+
+	value := reflect.ValueOf(bpfObjects.tracerPrograms)
+	for i := 0; i < value.NumField(); i++ {
+		program, ok := value.Field(i).Interface().(*ebpf.Program)
+		if !ok || program == nil {
+			continue
+		}
+
+		programs[value.Type().Field(i).Tag.Get("ebpf")] = program
+	}
+
+	return &probeStats{
+		programs: programs,
+		last:     make(map[string]probeCounters),
+	}
+}
+
+func (s *probeStats) init() error {
+	var err error
+
+	s.stats, err = ebpf.EnableStats(uint32(unix.BPF_STATS_RUN_TIME))
+
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	s.lastTime = time.Now()
+
+	return nil
+}
+
+func (s *probeStats) close() error {
+	if s.stats == nil {
+		return nil
+	}
+
+	return s.stats.Close()
+}
+
+func (s *probeStats) poll(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report := s.collect()
+
+		for _, overhead := range report {
+			if overhead.RunsPerSec == 0 {
+				continue
+			}
+
+			log.Info().
+				Str("program", overhead.Program).
+				Str("cpu", fmt.Sprintf("%.3f%%", overhead.CpuPercent)).
+				Str("runs-per-sec", fmt.Sprintf("%.1f", overhead.RunsPerSec)).
+				Dur("avg-run-time", overhead.AvgRunTime).
+				Msg("Probe overhead:")
+		}
+	}
+}
+
+func (s *probeStats) collect() []ProbeOverhead {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(s.lastTime)
+	s.lastTime = now
+
+	report := make([]ProbeOverhead, 0, len(s.programs))
+
+	for name, program := range s.programs {
+		stats, err := program.Stats()
+		if err != nil {
+			log.Debug().Err(err).Str("program", name).Msg("Couldn't get program stats:")
+			continue
+		}
+
+		runs, runTime := stats.RunCount, stats.Runtime
+
+		last := s.last[name]
+		s.last[name] = probeCounters{runs: runs, runTime: runTime}
+
+		overhead := ProbeOverhead{
+			Program:      name,
+			TotalRuns:    runs,
+			TotalRunTime: runTime,
+		}
+
+		if runs > last.runs && elapsed > 0 {
+			deltaRuns := runs - last.runs
+			deltaRunTime := runTime - last.runTime
+
+			overhead.RunsPerSec = float64(deltaRuns) / elapsed.Seconds()
+			overhead.AvgRunTime = deltaRunTime / time.Duration(deltaRuns)
+			overhead.CpuPercent = float64(deltaRunTime) / float64(elapsed) * 100
+		}
+
+		report = append(report, overhead)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].CpuPercent > report[j].CpuPercent
+	})
+
+	s.report = report
+
+	return report
+}
+
+func (s *probeStats) getReport() []ProbeOverhead {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.report
+}
@@ -0,0 +1,86 @@
+package tracer
+
+import (
+	"github.com/cilium/ebpf"
+	"github.com/go-errors/errors"
+)
+
+// handleProcessExit closes the streams that only the exited process had chunks of and
+// removes its state, the state in kernel is removed in the background
+func (p *tlsPoller) handleProcessExit(pid uint32, streamsMap *TcpStreamMap) {
+	closed := 0
+	for key, stream := range p.streams {
+		if !stream.pids[pid] {
+			continue
+		}
+
+		delete(stream.pids, pid)
+		if len(stream.pids) > 0 {
+			continue
+		}
+
+		stream.doTcpTeardown()
+		p.tls.closeFanout(stream)
+		p.untrackStream(key)
+		streamsMap.Delete(stream.getId())
+		closed++
+	}
+
+	pollerLog.get().Debug().Int("pid", int(pid)).Int("closed-streams", closed).Msg("Process exited:")
+
+	go p.tls.removeProcessContexts(pid)
+}
+
+// removeProcessContexts deletes the entries of the process from the maps that are keyed by
+// the PID and a file descriptor or a goroutine, then untargets the PID so a new process
+// that reuses it isn't captured. The per thread entries are deleted by the eBPF program.
+func (t *Tracer) removeProcessContexts(pid uint32) {
+	maps := []*ebpf.Map{
+		t.bpfObjects.tracerMaps.ConnectionContext,
+		t.bpfObjects.tracerMaps.GoWriteContext,
+		t.bpfObjects.tracerMaps.GoReadContext,
+		t.bpfObjects.tracerMaps.GoUserKernelWriteContext,
+		t.bpfObjects.tracerMaps.GoUserKernelReadContext,
+	}
+
+	for _, m := range maps {
+		if err := deleteProcessEntries(m, pid); err != nil {
+			LogError(err)
+		}
+	}
+
+	t.pidTargets.Delete(pid)
+
+	if _, ok := t.registeredPids.Load(pid); ok && pid != GlobalWorkerPid {
+		if err := t.RemovePid(pid); err != nil {
+			LogError(err)
+		}
+	}
+}
+
+// deleteProcessEntries deletes the entries whose key is <pid> << 32 | <fd or goroutine>
+func deleteProcessEntries(m *ebpf.Map, pid uint32) error {
+	var key uint64
+	var value []byte
+	keys := make([]uint64, 0)
+
+	entries := m.Iterate()
+	for entries.Next(&key, &value) {
+		if uint32(key>>32) == pid {
+			keys = append(keys, key)
+		}
+	}
+
+	if err := entries.Err(); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	// Deleted after the iteration, deleting the current key restarts it
+	for _, key := range keys {
+		if err := m.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return errors.Wrap(err, 0)
+		}
+	}
+
+	return nil
+}
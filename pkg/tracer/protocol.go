@@ -0,0 +1,59 @@
+package tracer
+
+import (
+	"bytes"
+)
+
+// The application protocols of the streams, detected by the first chunk with a payload, or
+// the names of the dissector plugins
+const (
+	ProtocolHttp1   = "http/1"
+	ProtocolHttp2   = "http/2"
+	ProtocolTls     = "tls"
+	ProtocolUnknown = "unknown"
+)
+
+var protocols = []string{ProtocolHttp1, ProtocolHttp2, ProtocolTls, ProtocolUnknown}
+
+var http2Preface = []byte("PRI * HTTP/2.0\r\n")
+
+var http1Methods = [][]byte{
+	[]byte("GET "), []byte("POST "), []byte("PUT "), []byte("DELETE "), []byte("HEAD "),
+	[]byte("OPTIONS "), []byte("PATCH "), []byte("CONNECT "), []byte("TRACE "),
+}
+
+func detectProtocol(data []byte) string {
+	if bytes.HasPrefix(data, http2Preface) {
+		return ProtocolHttp2
+	}
+
+	if bytes.HasPrefix(data, []byte("HTTP/1.")) {
+		return ProtocolHttp1
+	}
+
+	for _, method := range http1Methods {
+		if bytes.HasPrefix(data, method) {
+			return ProtocolHttp1
+		}
+	}
+
+	if isNestedTls(data) {
+		return ProtocolTls
+	}
+
+	// An HTTP/2 server doesn't send the preface, its first frame is SETTINGS
+	if len(data) >= 9 && data[3] == 0x4 && data[4]&0xfe == 0 && bytes.Equal(data[5:9], []byte{0, 0, 0, 0}) {
+		return ProtocolHttp2
+	}
+
+	return ProtocolUnknown
+}
+
+func isBuiltinProtocol(protocol string) bool {
+	return containsString(protocols, protocol)
+}
+
+// IsKnownProtocol checks a built-in protocol or the name of a loaded dissector plugin
+func (t *Tracer) IsKnownProtocol(protocol string) bool {
+	return isBuiltinProtocol(protocol) || t.poller.plugins.isLoaded(protocol)
+}
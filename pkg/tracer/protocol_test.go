@@ -0,0 +1,29 @@
+package tracer
+
+import "testing"
+
+func TestDetectProtocol(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"http/2 preface", []byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"), ProtocolHttp2},
+		{"http/2 settings", []byte{0x00, 0x00, 0x12, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00}, ProtocolHttp2},
+		{"http/2 settings ack", []byte{0x00, 0x00, 0x00, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00}, ProtocolHttp2},
+		{"http/1 request", []byte("GET / HTTP/1.1\r\n"), ProtocolHttp1},
+		{"http/1 connect", []byte("CONNECT example.com:443 HTTP/1.1\r\n"), ProtocolHttp1},
+		{"http/1 response", []byte("HTTP/1.1 200 OK\r\n"), ProtocolHttp1},
+		{"tls", []byte{0x16, 0x03, 0x01, 0x00, 0xc8, 0x01, 0x00}, ProtocolTls},
+		{"unknown", []byte("*1\r\n$4\r\nPING\r\n"), ProtocolUnknown},
+		{"empty", nil, ProtocolUnknown},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := detectProtocol(test.data); got != test.want {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package tracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	proxyV1MaxLength    = 107
+	proxyV2HeaderLength = 16
+	proxyV2Tcp4         = 0x11
+	proxyV2Tcp4Length   = 12
+	proxyV2CommandProxy = 0x21
+)
+
+var (
+	proxyV1Signature = []byte("PROXY ")
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// The original client address conveyed by a HAProxy PROXY protocol header
+type proxyHeader struct {
+	srcIP   string
+	srcPort string
+}
+
+// The results of parsing the start of a stream for a PROXY protocol header
+const (
+	proxyNoHeader = iota
+	// The data is the start of a header, it's decided with the next chunks
+	proxyHeaderIncomplete
+	proxyHeaderFound
+)
+
+// parseProxyHeader parses a PROXY protocol v1 or v2 header at the start of the stream
+// and returns it with its length. Only TCP over IPv4 is supported, since the synthetic
+// packets of the master PCAP are IPv4, the other headers are found without an address.
+// The header is only seen by the tracer when it is carried inside the TLS session, the
+// ones that precede the handshake aren't decrypted.
+func parseProxyHeader(data []byte) (*proxyHeader, int, int) {
+	if isPrefixOf(data, proxyV1Signature) || isPrefixOf(data, proxyV2Signature) {
+		return nil, 0, proxyHeaderIncomplete
+	}
+
+	if bytes.HasPrefix(data, proxyV1Signature) {
+		return parseProxyV1Header(data)
+	}
+
+	if bytes.HasPrefix(data, proxyV2Signature) {
+		return parseProxyV2Header(data)
+	}
+
+	return nil, 0, proxyNoHeader
+}
+
+// isPrefixOf reports whether data is shorter than the signature and starts it
+func isPrefixOf(data []byte, signature []byte) bool {
+	return len(data) < len(signature) && bytes.HasPrefix(signature, data)
+}
+
+// PROXY TCP4 <src-ip> <dst-ip> <src-port> <dst-port>\r\n
+// PROXY UNKNOWN\r\n
+func parseProxyV1Header(data []byte) (*proxyHeader, int, int) {
+	end := bytes.Index(data, []byte("\r\n"))
+	if end < 0 && len(data) < proxyV1MaxLength {
+		return nil, 0, proxyHeaderIncomplete
+	}
+
+	if end < 0 || end+2 > proxyV1MaxLength {
+		return nil, 0, proxyNoHeader
+	}
+
+	fields := strings.Fields(string(data[:end]))
+
+	// Sent by the proxies for their own connections, e.g. the health checks, whatever
+	// follows UNKNOWN is ignored
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, end + 2, proxyHeaderFound
+	}
+
+	if len(fields) != 6 || fields[1] != "TCP4" && fields[1] != "TCP6" {
+		return nil, 0, proxyNoHeader
+	}
+
+	if _, err := strconv.ParseUint(fields[4], 10, 16); err != nil {
+		return nil, 0, proxyNoHeader
+	}
+
+	ip := net.ParseIP(fields[2])
+	if ip == nil {
+		return nil, 0, proxyNoHeader
+	}
+
+	if fields[1] == "TCP6" {
+		return nil, end + 2, proxyHeaderFound
+	}
+
+	if ip.To4() == nil {
+		return nil, 0, proxyNoHeader
+	}
+
+	return &proxyHeader{
+		srcIP:   fields[2],
+		srcPort: fields[4],
+	}, end + 2, proxyHeaderFound
+}
+
+func parseProxyV2Header(data []byte) (*proxyHeader, int, int) {
+	if len(data) < proxyV2HeaderLength {
+		return nil, 0, proxyHeaderIncomplete
+	}
+
+	length := proxyV2HeaderLength + int(binary.BigEndian.Uint16(data[14:16]))
+	if len(data) < length {
+		return nil, 0, proxyHeaderIncomplete
+	}
+
+	// LOCAL command or a family other than TCP over IPv4, strip the header and keep the address
+	if data[12] != proxyV2CommandProxy || data[13] != proxyV2Tcp4 || length-proxyV2HeaderLength < proxyV2Tcp4Length {
+		return nil, length, proxyHeaderFound
+	}
+
+	addresses := data[proxyV2HeaderLength:]
+
+	return &proxyHeader{
+		srcIP:   net.IP(addresses[0:4]).String(),
+		srcPort: strconv.FormatUint(uint64(binary.BigEndian.Uint16(addresses[8:10])), 10),
+	}, length, proxyHeaderFound
+}
@@ -0,0 +1,64 @@
+package tracer
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func newProxyV2Header(command byte, family byte, addresses []byte) []byte {
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, command, family, 0, 0)
+	binary.BigEndian.PutUint16(header[14:16], uint16(len(addresses)))
+	return append(header, addresses...)
+}
+
+func TestParseProxyHeader(t *testing.T) {
+	tcp4 := newProxyV2Header(proxyV2CommandProxy, proxyV2Tcp4, []byte{10, 0, 0, 1, 10, 0, 0, 2, 0x1f, 0x90, 0x01, 0xbb})
+	local := newProxyV2Header(0x20, 0, nil)
+
+	tests := []struct {
+		name    string
+		data    string
+		result  int
+		length  int
+		srcIP   string
+		srcPort string
+	}{
+		{"v1 tcp4", "PROXY TCP4 10.0.0.1 10.0.0.2 8080 443\r\nGET", proxyHeaderFound, 39, "10.0.0.1", "8080"},
+		{"v1 tcp6", "PROXY TCP6 ::1 ::2 8080 443\r\nGET", proxyHeaderFound, 29, "", ""},
+		{"v1 unknown", "PROXY UNKNOWN\r\nGET", proxyHeaderFound, 15, "", ""},
+		{"v1 unknown with addresses", "PROXY UNKNOWN ::1 ::2 1 2\r\n", proxyHeaderFound, 27, "", ""},
+		{"v1 split", "PROXY TCP4 10.0.0.1 ", proxyHeaderIncomplete, 0, "", ""},
+		{"v1 signature split", "PRO", proxyHeaderIncomplete, 0, "", ""},
+		{"v1 tcp4 with ipv6", "PROXY TCP4 ::1 ::2 8080 443\r\n", proxyNoHeader, 0, "", ""},
+		{"v1 bad port", "PROXY TCP4 10.0.0.1 10.0.0.2 80800 443\r\n", proxyNoHeader, 0, "", ""},
+		{"v1 bad family", "PROXY UDP4 10.0.0.1 10.0.0.2 8080 443\r\n", proxyNoHeader, 0, "", ""},
+		{"v1 too long", "PROXY " + string(make([]byte, proxyV1MaxLength)), proxyNoHeader, 0, "", ""},
+		{"v2 tcp4", string(tcp4) + "GET", proxyHeaderFound, len(tcp4), "10.0.0.1", "8080"},
+		{"v2 local", string(local), proxyHeaderFound, len(local), "", ""},
+		{"v2 split header", string(tcp4[:14]), proxyHeaderIncomplete, 0, "", ""},
+		{"v2 split addresses", string(tcp4[:20]), proxyHeaderIncomplete, 0, "", ""},
+		{"http", "GET / HTTP/1.1\r\n", proxyNoHeader, 0, "", ""},
+		{"post", "POST / HTTP/1.1\r\n", proxyNoHeader, 0, "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header, length, result := parseProxyHeader([]byte(test.data))
+			if result != test.result || length != test.length {
+				t.Fatalf("got result %d length %d, want %d %d", result, length, test.result, test.length)
+			}
+
+			if test.srcIP == "" {
+				if header != nil {
+					t.Fatalf("got header %+v, want none", header)
+				}
+				return
+			}
+
+			if header == nil || header.srcIP != test.srcIP || header.srcPort != test.srcPort {
+				t.Fatalf("got header %+v, want %s:%s", header, test.srcIP, test.srcPort)
+			}
+		})
+	}
+}
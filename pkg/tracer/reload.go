@@ -0,0 +1,70 @@
+package tracer
+
+import (
+	"reflect"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Reload applies the buffer sizes, the probe groups, the static targets, the analysis
+// settings, the payload policy and the label rules of config without reloading the eBPF
+// programs, the streams in flight are kept.
+// The other settings require a restart, a warning is logged when they change.
+func (t *Tracer) Reload(config Config) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	if !t.poller.ringbuf && config.ChunksBufferSize != t.poller.getChunksBufferSize() {
+		if err := t.SetChunksBufferSize(config.ChunksBufferSize); err != nil {
+			return err
+		}
+	}
+
+	if err := t.SetProbeGroups(config.ProbeGroups); err != nil {
+		return err
+	}
+
+	if err := t.poller.payload.set(&config); err != nil {
+		return err
+	}
+
+	if err := t.poller.labels.setRules(config.LabelRules); err != nil {
+		return err
+	}
+
+	t.targetsLock.Lock()
+	previous := t.config
+	targetsChanged := !reflect.DeepEqual(config.Pids, previous.Pids) || !reflect.DeepEqual(config.Cgroups, previous.Cgroups)
+	t.config.ChunksBufferSize = config.ChunksBufferSize
+	t.config.Pids = config.Pids
+	t.config.Cgroups = config.Cgroups
+	t.config.ProbeGroups = config.ProbeGroups
+	t.config.AnalysisWorkers = config.AnalysisWorkers
+	t.config.AnalysisTimeout = config.AnalysisTimeout
+	t.config.PayloadCidrs = config.PayloadCidrs
+	t.config.PayloadNamespaces = config.PayloadNamespaces
+	t.config.LabelRules = config.LabelRules
+	pods := t.pods
+	t.targetsLock.Unlock()
+
+	previous.ChunksBufferSize = config.ChunksBufferSize
+	previous.Pids = config.Pids
+	previous.Cgroups = config.Cgroups
+	previous.ProbeGroups = config.ProbeGroups
+	previous.AnalysisWorkers = config.AnalysisWorkers
+	previous.AnalysisTimeout = config.AnalysisTimeout
+	previous.PayloadCidrs = config.PayloadCidrs
+	previous.PayloadNamespaces = config.PayloadNamespaces
+	previous.LabelRules = config.LabelRules
+	if !reflect.DeepEqual(previous, config) {
+		log.Warn().Msg("Some of the changed settings are applied on restart only")
+	}
+
+	if targetsChanged {
+		log.Info().Msg("Reloading the targets...")
+		return t.UpdateTargets(pods)
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package tracer
+
+import "regexp"
+
+// s2n-tls of AWS is linked statically into the AWS Common Runtime, e.g. the awscrt module of
+// Python and the JNI library of the Java SDK, which is extracted with a prefix to a temporary
+// directory, or into the executables of the C++ SDK
+var s2nLibrary = &tlsLibrary{
+	group:          ProbeGroupS2n,
+	fileRegex:      regexp.MustCompile(`/libs2n\.so[^/]*$`),
+	embeddingRegex: regexp.MustCompile(`(/_awscrt[^/]*\.so|libaws-crt-jni\.so|/libaws-c-io\.so[^/]*)$`),
+	writeSymbol:    "s2n_send",
+	readSymbol:     "s2n_recv",
+	programs: func(bpfObjects *tracerObjects) tlsLibraryPrograms {
+		return tlsLibraryPrograms{
+			write:    bpfObjects.S2nSend,
+			writeRet: bpfObjects.S2nRetSend,
+			read:     bpfObjects.S2nRecv,
+			readRet:  bpfObjects.S2nRetRecv,
+		}
+	},
+}
@@ -0,0 +1,175 @@
+package tracer
+
+import (
+	"bufio"
+	"debug/elf"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// The ssl module of CPython, e.g. _ssl.cpython-311-x86_64-linux-gnu.so, has OpenSSL linked
+// statically in some builds
+var pythonSslModuleRegex = regexp.MustCompile(`/_ssl(\.cpython-[^/]*|\.[^/]*)?\.so$`)
+
+// The binaries that embed an interpreter with OpenSSL linked statically in some builds,
+// gunicorn runs in the python binary
+var sslEmbeddingBinaryRegex = regexp.MustCompile(`/(python[0-9.]*|uwsgi|php[0-9.]*|php-fpm[0-9.]*)$`)
+
+// findSsllibs returns the files whose SSL_* functions are probed. All the mapped libssl.so
+// files are returned, since the calls go to whichever one the symbols are interposed from,
+// e.g. when uwsgi and the ssl module of its Python are linked against different versions.
+// A libcurl that is linked against OpenSSL statically is returned as well, the ones that use
+// another TLS backend are only reported in the error if nothing else is found. The libssl
+// files of the root of an interpreter are returned if it hasn't loaded one yet.
+func findSsllibs(procfs string, pid uint32) ([]string, error) {
+	binary, err := os.Readlink(fmt.Sprintf("%s/%d/exe", procfs, pid))
+
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	log.Debug().Int("pid", int(pid)).Str("binary", binary).Msg("Binary that uses libssl:")
+
+	if strings.HasSuffix(binary, "/node") {
+		library, err := findLibraryByPid(procfs, pid, binary)
+		if err != nil {
+			return nil, err
+		}
+		return []string{library}, nil
+	}
+
+	paths, err := getMappedPaths(procfs, pid)
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	libraries := make([]string, 0)
+	unsupported := make([]string, 0)
+	for _, path := range paths {
+		fullpath := fmt.Sprintf("%v/%v/root%v", procfs, pid, path)
+
+		switch {
+		case libsslRegex.MatchString(path):
+			if _, err := os.Stat(fullpath); os.IsNotExist(err) {
+				continue
+			}
+		case curlLibraryRegex.MatchString(path):
+			backends, static := findCurlTlsBackends(fullpath)
+			log.Debug().Int("pid", int(pid)).Str("path", path).Strs("backends", backends).Bool("static", static).Msg("TLS backends of libcurl:")
+			for _, backend := range backends {
+				// The backends with a probe group of their own are targeted by their group
+				if backend != tlsBackendOpenssl && getTlsLibrary(backend) == nil {
+					unsupported = append(unsupported, fmt.Sprintf("%s (%s)", path, backend))
+				}
+			}
+			// The libssl.so of a dynamically linked OpenSSL is in the memory map on its own
+			if !static {
+				continue
+			}
+		case pythonSslModuleRegex.MatchString(path), path == binary && sslEmbeddingBinaryRegex.MatchString(path):
+			if !hasSslSymbols(fullpath) {
+				continue
+			}
+		default:
+			continue
+		}
+
+		libraries = append(libraries, fullpath)
+	}
+
+	// An interpreter loads libssl with its ssl module, which may be imported later
+	if len(libraries) == 0 && len(unsupported) == 0 && sslEmbeddingBinaryRegex.MatchString(binary) {
+		libraries = findRootSsllibs(procfs, pid)
+	}
+
+	if len(libraries) == 0 && len(unsupported) > 0 {
+		return nil, errors.Errorf("libssl.so not found for PID %d, the TLS backends of libcurl aren't supported: %s", pid, strings.Join(unsupported, ", "))
+	}
+
+	if len(libraries) == 0 {
+		return nil, errors.Errorf("libssl.so not found for PID %d", pid)
+	}
+
+	return libraries, nil
+}
+
+// getMappedPaths returns the distinct files in the memory map of the process, in order
+func getMappedPaths(procfs string, pid uint32) ([]string, error) {
+	file, err := os.Open(fmt.Sprintf("%v/%v/maps", procfs, pid))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+
+	seen := make(map[string]bool)
+	paths := make([]string, 0)
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+
+		if len(parts) <= 5 || !strings.HasPrefix(parts[5], "/") || seen[parts[5]] {
+			continue
+		}
+
+		seen[parts[5]] = true
+		paths = append(paths, parts[5])
+	}
+
+	return paths, scanner.Err()
+}
+
+// hasSslSymbols is true if OpenSSL is linked statically into the file, a file that is linked
+// against libssl.so has SSL_write undefined
+func hasSslSymbols(path string) bool {
+	file, err := elf.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	symbol, err := getSymbol(file, "SSL_write")
+	return err == nil && symbol.Section != elf.SHN_UNDEF
+}
+
+func findLibraryByPid(procfs string, pid uint32, libraryName string) (string, error) {
+	file, err := os.Open(fmt.Sprintf("%v/%v/maps", procfs, pid))
+
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bufio.ScanLines)
+
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+
+		if len(parts) <= 5 {
+			continue
+		}
+
+		fpath := parts[5]
+
+		if libraryName != "" && !strings.Contains(fpath, libraryName) {
+			continue
+		}
+
+		fullpath := fmt.Sprintf("%v/%v/root%v", procfs, pid, fpath)
+
+		if _, err := os.Stat(fullpath); os.IsNotExist(err) {
+			continue
+		}
+
+		return fullpath, nil
+	}
+
+	return "", errors.Errorf("%s not found for PID %d", libraryName, pid)
+}
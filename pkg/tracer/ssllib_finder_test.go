@@ -0,0 +1,268 @@
+package tracer
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeElf writes an ELF file whose symbol table has SSL_write, defined in .text or
+// undefined as in a file that is linked against libssl.so
+func writeElf(t *testing.T, fpath string, defined bool) {
+	strtab := []byte("\x00SSL_write\x00")
+	shstrtab := []byte("\x00.text\x00.strtab\x00.symtab\x00.shstrtab\x00")
+	text := make([]byte, 16)
+
+	sym := elf.Sym64{Name: 1, Info: elf.ST_INFO(elf.STB_GLOBAL, elf.STT_FUNC), Size: 16}
+	if defined {
+		sym.Shndx = 1
+	}
+
+	var symtab bytes.Buffer
+	_ = binary.Write(&symtab, binary.LittleEndian, []elf.Sym64{{}, sym})
+
+	headerSize := uint64(binary.Size(elf.Header64{}))
+	contents := [][]byte{text, strtab, symtab.Bytes(), shstrtab}
+	offsets := make([]uint64, len(contents))
+	offset := headerSize
+	for i, content := range contents {
+		offsets[i] = offset
+		offset += uint64(len(content))
+	}
+
+	sections := []elf.Section64{
+		{},
+		{Name: 1, Type: uint32(elf.SHT_PROGBITS), Flags: uint64(elf.SHF_ALLOC | elf.SHF_EXECINSTR), Off: offsets[0], Size: uint64(len(text)), Addralign: 16},
+		{Name: 7, Type: uint32(elf.SHT_STRTAB), Off: offsets[1], Size: uint64(len(strtab)), Addralign: 1},
+		{Name: 15, Type: uint32(elf.SHT_SYMTAB), Off: offsets[2], Size: uint64(symtab.Len()), Link: 2, Info: 1, Addralign: 8, Entsize: 24},
+		{Name: 23, Type: uint32(elf.SHT_STRTAB), Off: offsets[3], Size: uint64(len(shstrtab)), Addralign: 1},
+	}
+
+	header := elf.Header64{
+		Type:      uint16(elf.ET_DYN),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     offset,
+		Ehsize:    uint16(headerSize),
+		Phentsize: uint16(binary.Size(elf.Prog64{})),
+		Shentsize: uint16(binary.Size(elf.Section64{})),
+		Shnum:     uint16(len(sections)),
+		Shstrndx:  4,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	var file bytes.Buffer
+	_ = binary.Write(&file, binary.LittleEndian, header)
+	for _, content := range contents {
+		file.Write(content)
+	}
+	_ = binary.Write(&file, binary.LittleEndian, sections)
+
+	writeFile(t, fpath, file.Bytes())
+}
+
+func writeFile(t *testing.T, fpath string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(fpath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The files of the root of the process, the ones with SSL_write are ELF files
+type rootFile struct {
+	path string
+	// Mapped by the process
+	mapped bool
+	// SSL_write is defined, OpenSSL is linked statically
+	static bool
+	// Only in the root, not a library
+	plain bool
+}
+
+// The layouts of the official python images of Debian and Alpine, and of the binaries that
+// embed Python
+func TestFindSsllibsPython(t *testing.T) {
+	tests := []struct {
+		name   string
+		binary string
+		files  []rootFile
+		want   []string
+		err    bool
+	}{
+		{
+			"python 3.8 bullseye",
+			"/usr/local/bin/python3.8",
+			[]rootFile{
+				{path: "/usr/local/bin/python3.8", mapped: true},
+				{path: "/usr/local/lib/python3.8/lib-dynload/_ssl.cpython-38-x86_64-linux-gnu.so", mapped: true},
+				{path: "/usr/lib/x86_64-linux-gnu/libssl.so.1.1", mapped: true},
+				{path: "/usr/lib/x86_64-linux-gnu/libcrypto.so.1.1", mapped: true},
+			},
+			[]string{"/usr/lib/x86_64-linux-gnu/libssl.so.1.1"},
+			false,
+		},
+		{
+			"python 3.9 with a static ssl module",
+			"/usr/local/bin/python3.9",
+			[]rootFile{
+				{path: "/usr/local/bin/python3.9", mapped: true},
+				{path: "/usr/local/lib/python3.9/lib-dynload/_ssl.cpython-39-x86_64-linux-gnu.so", mapped: true, static: true},
+			},
+			[]string{"/usr/local/lib/python3.9/lib-dynload/_ssl.cpython-39-x86_64-linux-gnu.so"},
+			false,
+		},
+		{
+			"python 3.10 bookworm",
+			"/usr/local/bin/python3.10",
+			[]rootFile{
+				{path: "/usr/local/bin/python3.10", mapped: true},
+				{path: "/usr/local/lib/python3.10/lib-dynload/_ssl.cpython-310-x86_64-linux-gnu.so", mapped: true},
+				{path: "/usr/lib/x86_64-linux-gnu/libssl.so.3", mapped: true},
+			},
+			[]string{"/usr/lib/x86_64-linux-gnu/libssl.so.3"},
+			false,
+		},
+		{
+			"python 3.11 in uwsgi with two libssl",
+			"/usr/local/bin/uwsgi",
+			[]rootFile{
+				{path: "/usr/local/bin/uwsgi", mapped: true},
+				{path: "/usr/lib/x86_64-linux-gnu/libssl.so.1.1", mapped: true},
+				{path: "/usr/local/lib/python3.11/lib-dynload/_ssl.cpython-311-x86_64-linux-gnu.so", mapped: true},
+				{path: "/usr/lib/x86_64-linux-gnu/libssl.so.3", mapped: true},
+			},
+			[]string{"/usr/lib/x86_64-linux-gnu/libssl.so.1.1", "/usr/lib/x86_64-linux-gnu/libssl.so.3"},
+			false,
+		},
+		{
+			"gunicorn of a static python 3.11",
+			"/opt/python/bin/python3.11",
+			[]rootFile{
+				{path: "/opt/python/bin/python3.11", mapped: true, static: true},
+			},
+			[]string{"/opt/python/bin/python3.11"},
+			false,
+		},
+		{
+			"python 3.12 alpine",
+			"/usr/local/bin/python3.12",
+			[]rootFile{
+				{path: "/usr/local/bin/python3.12", mapped: true},
+				{path: "/usr/local/lib/python3.12/lib-dynload/_ssl.cpython-312-x86_64-linux-musl.so", mapped: true},
+				{path: "/usr/lib/libssl.so.3", mapped: true},
+			},
+			[]string{"/usr/lib/libssl.so.3"},
+			false,
+		},
+		{
+			"python 3.12 before importing ssl",
+			"/usr/local/bin/python3.12",
+			[]rootFile{
+				{path: "/usr/local/bin/python3.12", mapped: true},
+				{path: "/usr/lib/libssl.so.3"},
+				{path: "/usr/lib/libcrypto.so.3"},
+			},
+			[]string{"/usr/lib/libssl.so.3"},
+			false,
+		},
+		{
+			"unmapped libssl of another binary",
+			"/usr/local/bin/server",
+			[]rootFile{
+				{path: "/usr/local/bin/server", mapped: true},
+				{path: "/usr/lib/libssl.so.3"},
+			},
+			nil,
+			true,
+		},
+		{
+			"mapped libssl missing in the root",
+			"/usr/local/bin/python3.8",
+			[]rootFile{
+				{path: "/usr/local/bin/python3.8", mapped: true},
+				{path: "/usr/lib/x86_64-linux-gnu/libssl.so.1.1", mapped: true, plain: true},
+			},
+			nil,
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			procfs := t.TempDir()
+			const pid = 1234
+			root := fmt.Sprintf("%s/%d/root", procfs, pid)
+
+			if err := os.MkdirAll(root, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink(test.binary, fmt.Sprintf("%s/%d/exe", procfs, pid)); err != nil {
+				t.Fatal(err)
+			}
+
+			var maps strings.Builder
+			for i, file := range test.files {
+				if file.mapped {
+					fmt.Fprintf(&maps, "7f%010x-7f%010x r-xp 00000000 08:01 %d %s\n", i<<12, (i+1)<<12, 100+i, file.path)
+				}
+
+				switch {
+				case file.plain:
+					// Mapped but deleted from the root, e.g. after an upgrade
+				case strings.Contains(file.path, "libssl"), strings.Contains(file.path, "libcrypto"):
+					writeFile(t, root+file.path, []byte("library"))
+				default:
+					writeElf(t, root+file.path, file.static)
+				}
+			}
+			writeFile(t, fmt.Sprintf("%s/%d/maps", procfs, pid), []byte(maps.String()))
+
+			libraries, err := findSsllibs(procfs, pid)
+			if (err != nil) != test.err {
+				t.Fatalf("got %v, want an error %v", err, test.err)
+			}
+
+			var got []string
+			for _, library := range libraries {
+				got = append(got, strings.TrimPrefix(library, root))
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPythonSslModuleRegex(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/usr/local/lib/python3.8/lib-dynload/_ssl.cpython-38-x86_64-linux-gnu.so", true},
+		{"/usr/local/lib/python3.12/lib-dynload/_ssl.cpython-312-aarch64-linux-musl.so", true},
+		{"/usr/lib64/python3.9/lib-dynload/_ssl.cpython-39-x86_64-linux-gnu.so", true},
+		{"/usr/lib/python2.7/lib-dynload/_ssl.x86_64-linux-gnu.so", true},
+		{"/usr/lib/python3/dist-packages/_ssl.so", true},
+		{"/usr/local/lib/python3.8/lib-dynload/_hashlib.cpython-38-x86_64-linux-gnu.so", false},
+		{"/usr/local/lib/python3.8/site-packages/_ssl_helper.py", false},
+	}
+
+	for _, test := range tests {
+		if got := pythonSslModuleRegex.MatchString(test.path); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.path, got, test.want)
+		}
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package tracer
 
 import (
 	"github.com/cilium/ebpf/link"
@@ -14,68 +14,84 @@ type sslHooks struct {
 	sslWriteExRetProbe link.Link
 	sslReadExProbe     link.Link
 	sslReadExRetProbe  link.Link
+	// Attached if the process offloads the crypto
+	bio bioHooks
 }
 
-func (s *sslHooks) installUprobes(bpfObjects *tracerObjects, sslLibraryPath string) error {
-	sslLibrary, err := link.OpenExecutable(sslLibraryPath)
+func (s *sslHooks) installUprobes(bpfObjects *tracerObjects, library *sslLibrary, overrides []SymbolOffset) error {
+	sslLibrary, err := link.OpenExecutable(library.path)
 
 	if err != nil {
 		return errors.Wrap(err, 0)
 	}
 
-	if err != nil {
-		return errors.Wrap(err, 0)
+	options := make(map[string]*link.UprobeOptions)
+	for _, symbol := range sslSymbols {
+		if options[symbol], err = library.getUprobeOptions(overrides, symbol); err != nil {
+			return err
+		}
 	}
 
-	return s.installSslHooks(bpfObjects, sslLibrary)
+	for _, symbol := range []string{"SSL_write", "SSL_read"} {
+		if options[symbol] == nil {
+			return errors.Errorf("%s isn't found in %s, set its offset with -symbol-offsets", symbol, library.path)
+		}
+	}
+
+	return s.installSslHooks(bpfObjects, sslLibrary, options)
 }
 
-func (s *sslHooks) installSslHooks(bpfObjects *tracerObjects, sslLibrary *link.Executable) error {
+func (s *sslHooks) installSslHooks(bpfObjects *tracerObjects, sslLibrary *link.Executable, options map[string]*link.UprobeOptions) error {
 	var err error
 
-	s.sslWriteProbe, err = sslLibrary.Uprobe("SSL_write", bpfObjects.SslWrite, nil)
+	s.sslWriteProbe, err = sslLibrary.Uprobe("SSL_write", bpfObjects.SslWrite, options["SSL_write"])
 
 	if err != nil {
 		return errors.Wrap(err, 0)
 	}
 
-	s.sslWriteRetProbe, err = sslLibrary.Uretprobe("SSL_write", bpfObjects.SslRetWrite, nil)
+	s.sslWriteRetProbe, err = sslLibrary.Uretprobe("SSL_write", bpfObjects.SslRetWrite, options["SSL_write"])
 
 	if err != nil {
 		return errors.Wrap(err, 0)
 	}
 
-	s.sslReadProbe, err = sslLibrary.Uprobe("SSL_read", bpfObjects.SslRead, nil)
+	s.sslReadProbe, err = sslLibrary.Uprobe("SSL_read", bpfObjects.SslRead, options["SSL_read"])
 
 	if err != nil {
 		return errors.Wrap(err, 0)
 	}
 
-	s.sslReadRetProbe, err = sslLibrary.Uretprobe("SSL_read", bpfObjects.SslRetRead, nil)
+	s.sslReadRetProbe, err = sslLibrary.Uretprobe("SSL_read", bpfObjects.SslRetRead, options["SSL_read"])
 
 	if err != nil {
 		return errors.Wrap(err, 0)
 	}
 
-	s.sslWriteExProbe, err = sslLibrary.Uprobe("SSL_write_ex", bpfObjects.SslWriteEx, nil)
+	// OpenSSL before 1.1.1 has no SSL_write_ex and SSL_read_ex
+	if options["SSL_write_ex"] == nil || options["SSL_read_ex"] == nil {
+		return nil
+	}
+
+	s.sslWriteExProbe, err = sslLibrary.Uprobe("SSL_write_ex", bpfObjects.SslWriteEx, options["SSL_write_ex"])
 
 	if err != nil {
 		return errors.Wrap(err, 0)
 	}
 
-	s.sslWriteExRetProbe, err = sslLibrary.Uretprobe("SSL_write_ex", bpfObjects.SslRetWriteEx, nil)
+	s.sslWriteExRetProbe, err = sslLibrary.Uretprobe("SSL_write_ex", bpfObjects.SslRetWriteEx, options["SSL_write_ex"])
 
 	if err != nil {
 		return errors.Wrap(err, 0)
 	}
 
-	s.sslReadExProbe, err = sslLibrary.Uprobe("SSL_read_ex", bpfObjects.SslReadEx, nil)
+	s.sslReadExProbe, err = sslLibrary.Uprobe("SSL_read_ex", bpfObjects.SslReadEx, options["SSL_read_ex"])
 
 	if err != nil {
 		return errors.Wrap(err, 0)
 	}
 
-	s.sslReadExRetProbe, err = sslLibrary.Uretprobe("SSL_read_ex", bpfObjects.SslRetReadEx, nil)
+	s.sslReadExRetProbe, err = sslLibrary.Uretprobe("SSL_read_ex", bpfObjects.SslRetReadEx, options["SSL_read_ex"])
 
 	if err != nil {
 		return errors.Wrap(err, 0)
@@ -127,5 +143,7 @@ func (s *sslHooks) close() []error {
 		}
 	}
 
+	returnValue = append(returnValue, s.bio.close()...)
+
 	return returnValue
 }
@@ -0,0 +1,172 @@
+package tracer
+
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+
+	"github.com/cilium/ebpf/link"
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// The names of libssl of the distros, e.g. libssl.so.1.0.0 of Debian, libssl.so.1.0.2k of
+// RHEL 7 linked as libssl.so.10, libssl.so.1.1 and libssl.so.3. libssl3.so is the one of NSS.
+var libsslRegex = regexp.MustCompile(`/libssl\.so(\.[0-9]+[a-z]*)*$`)
+
+// The directories of libssl in the root of a process, Alpine has it in /lib and /usr/lib,
+// Debian in the multiarch directories and RHEL in /usr/lib64
+var sslLibraryDirs = []string{"/lib", "/lib64", "/usr/lib", "/usr/lib64", "/usr/local/lib", "/usr/local/lib64", "/usr/local/ssl/lib"}
+
+var multiarchTriplets = map[string]string{
+	"amd64":   "x86_64-linux-gnu",
+	"arm64":   "aarch64-linux-gnu",
+	"s390x":   "s390x-linux-gnu",
+	"ppc64le": "powerpc64le-linux-gnu",
+}
+
+// The symbols that are only defined by an OpenSSL version and the later ones, SSL_library_init
+// became a macro in 1.1
+var opensslVersionSymbols = []struct {
+	symbol  string
+	version string
+}{
+	{"SSL_CTX_new_ex", "3"},
+	{"OPENSSL_init_ssl", "1.1"},
+	{"SSL_library_init", "1.0"},
+}
+
+// sslLibrary is a file with OpenSSL, and the file offsets of the SSL_* functions it defines.
+// SSL_write_ex and SSL_read_ex are only defined since 1.1.1.
+type sslLibrary struct {
+	path    string
+	version string
+	offsets map[string]uint64
+}
+
+// resolveSslLibrary reads the version of OpenSSL and the offsets of the SSL_* functions from
+// the ELF symbols of path, a stripped library is resolved by its dynamic symbols. The offsets
+// of the symbols that aren't found are given with -symbol-offsets.
+func resolveSslLibrary(path string) (*sslLibrary, error) {
+	file, err := elf.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+	defer file.Close()
+
+	symbols, _ := file.Symbols()
+	dynamicSymbols, _ := file.DynamicSymbols()
+
+	defined := make(map[string]elf.Symbol)
+	for _, symbol := range append(symbols, dynamicSymbols...) {
+		if symbol.Section != elf.SHN_UNDEF && elf.ST_TYPE(symbol.Info) == elf.STT_FUNC {
+			defined[symbol.Name] = symbol
+		}
+	}
+
+	library := &sslLibrary{path: path, version: "unknown", offsets: make(map[string]uint64)}
+
+	for _, versionSymbol := range opensslVersionSymbols {
+		if _, ok := defined[versionSymbol.symbol]; ok {
+			library.version = versionSymbol.version
+			break
+		}
+	}
+
+	for _, name := range sslSymbols {
+		symbol, ok := defined[name]
+		if !ok {
+			continue
+		}
+
+		offset, err := getFileOffset(file, symbol.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		library.offsets[name] = offset
+	}
+
+	log.Debug().Str("path", path).Str("version", library.version).Interface("offsets", library.offsets).Msg("Resolved libssl:")
+
+	return library, nil
+}
+
+// getFileOffset converts the virtual address of a symbol to its offset in the file
+func getFileOffset(file *elf.File, address uint64) (uint64, error) {
+	for _, prog := range file.Progs {
+		if prog.Type == elf.PT_LOAD && prog.Flags&elf.PF_X != 0 && prog.Vaddr <= address && address < prog.Vaddr+prog.Memsz {
+			return address - prog.Vaddr + prog.Off, nil
+		}
+	}
+
+	return 0, errors.Errorf("Address 0x%x is not in an executable segment", address)
+}
+
+// getUprobeOptions returns the options of the uprobes of symbol, at the overridden offset if
+// there is one, nil if the library doesn't define the symbol
+func (l *sslLibrary) getUprobeOptions(overrides []SymbolOffset, symbol string) (*link.UprobeOptions, error) {
+	options, err := getUprobeOptions(overrides, l.path, symbol)
+	if err != nil || options != nil {
+		return options, err
+	}
+
+	offset, ok := l.offsets[symbol]
+	if !ok {
+		return nil, nil
+	}
+
+	return &link.UprobeOptions{Address: offset}, nil
+}
+
+// findRootSsllibs returns the libssl files in the library directories of the root of the
+// process, which it hasn't loaded yet, e.g. an interpreter before its ssl module is imported.
+// The uprobes are attached to the files, so the library is hooked once it's loaded.
+func findRootSsllibs(procfs string, pid uint32) []string {
+	dirs := sslLibraryDirs
+	if triplet, ok := multiarchTriplets[runtime.GOARCH]; ok {
+		dirs = append(dirs, "/lib/"+triplet, "/usr/lib/"+triplet)
+	}
+
+	root := fmt.Sprintf("%v/%v/root", procfs, pid)
+
+	// The versioned files, libssl.so and the other symbolic links point to them. The same
+	// file is found twice if a directory links to another one, e.g. /lib64 to /usr/lib64.
+	found := make([]os.FileInfo, 0)
+	libraries := make([]string, 0)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(root + dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			path := root + dir + "/" + entry.Name()
+			if !entry.Type().IsRegular() || !libsslRegex.MatchString(path) {
+				continue
+			}
+
+			info, err := entry.Info()
+			if err != nil || containsFile(found, info) {
+				continue
+			}
+
+			found = append(found, info)
+			libraries = append(libraries, path)
+		}
+	}
+
+	return libraries
+}
+
+func containsFile(files []os.FileInfo, file os.FileInfo) bool {
+	for _, f := range files {
+		if os.SameFile(f, file) {
+			return true
+		}
+	}
+
+	return false
+}
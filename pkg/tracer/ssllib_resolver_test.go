@@ -0,0 +1,212 @@
+package tracer
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The segment of the text of the test libraries, loaded at a different address than its
+// offset in the file as in the libraries that are linked with -z separate-code
+const (
+	testTextOffset  = 128
+	testTextAddress = 0x200000 + testTextOffset
+)
+
+// writeSslElf writes an ELF library whose symbol table defines the functions in its text, 16
+// bytes each in order
+func writeSslElf(t *testing.T, fpath string, functions []string) {
+	strtab := []byte{0}
+	symbols := []elf.Sym64{{}}
+	for i, function := range functions {
+		symbols = append(symbols, elf.Sym64{
+			Name:  uint32(len(strtab)),
+			Info:  elf.ST_INFO(elf.STB_GLOBAL, elf.STT_FUNC),
+			Shndx: 1,
+			Value: testTextAddress + uint64(i)*16,
+			Size:  16,
+		})
+		strtab = append(strtab, function+"\x00"...)
+	}
+
+	var symtab bytes.Buffer
+	_ = binary.Write(&symtab, binary.LittleEndian, symbols)
+
+	shstrtab := []byte("\x00.text\x00.strtab\x00.symtab\x00.shstrtab\x00")
+	text := make([]byte, 16*max(len(functions), 1))
+
+	headerSize := uint64(binary.Size(elf.Header64{}))
+	contents := [][]byte{text, strtab, symtab.Bytes(), shstrtab}
+	offsets := make([]uint64, len(contents))
+	offset := uint64(testTextOffset)
+	for i, content := range contents {
+		offsets[i] = offset
+		offset += uint64(len(content))
+	}
+
+	prog := elf.Prog64{
+		Type:   uint32(elf.PT_LOAD),
+		Flags:  uint32(elf.PF_R | elf.PF_X),
+		Off:    testTextOffset,
+		Vaddr:  testTextAddress,
+		Paddr:  testTextAddress,
+		Filesz: uint64(len(text)),
+		Memsz:  uint64(len(text)),
+		Align:  0x1000,
+	}
+
+	sections := []elf.Section64{
+		{},
+		{Name: 1, Type: uint32(elf.SHT_PROGBITS), Flags: uint64(elf.SHF_ALLOC | elf.SHF_EXECINSTR), Addr: testTextAddress, Off: offsets[0], Size: uint64(len(text)), Addralign: 16},
+		{Name: 7, Type: uint32(elf.SHT_STRTAB), Off: offsets[1], Size: uint64(len(strtab)), Addralign: 1},
+		{Name: 15, Type: uint32(elf.SHT_SYMTAB), Off: offsets[2], Size: uint64(symtab.Len()), Link: 2, Info: 1, Addralign: 8, Entsize: 24},
+		{Name: 23, Type: uint32(elf.SHT_STRTAB), Off: offsets[3], Size: uint64(len(shstrtab)), Addralign: 1},
+	}
+
+	header := elf.Header64{
+		Type:      uint16(elf.ET_DYN),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Phoff:     headerSize,
+		Shoff:     offset,
+		Ehsize:    uint16(headerSize),
+		Phentsize: uint16(binary.Size(elf.Prog64{})),
+		Phnum:     1,
+		Shentsize: uint16(binary.Size(elf.Section64{})),
+		Shnum:     uint16(len(sections)),
+		Shstrndx:  4,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	var file bytes.Buffer
+	_ = binary.Write(&file, binary.LittleEndian, header)
+	_ = binary.Write(&file, binary.LittleEndian, prog)
+	file.Write(make([]byte, testTextOffset-file.Len()))
+	for _, content := range contents {
+		file.Write(content)
+	}
+	_ = binary.Write(&file, binary.LittleEndian, sections)
+
+	writeFile(t, fpath, file.Bytes())
+}
+
+func TestResolveSslLibrary(t *testing.T) {
+	tests := []struct {
+		name      string
+		functions []string
+		version   string
+		offsets   map[string]uint64
+	}{
+		{
+			"openssl 3",
+			[]string{"SSL_CTX_new_ex", "OPENSSL_init_ssl", "SSL_write", "SSL_read", "SSL_write_ex", "SSL_read_ex"},
+			"3",
+			map[string]uint64{"SSL_write": testTextOffset + 32, "SSL_read": testTextOffset + 48, "SSL_write_ex": testTextOffset + 64, "SSL_read_ex": testTextOffset + 80},
+		},
+		{
+			"openssl 1.1.1",
+			[]string{"OPENSSL_init_ssl", "SSL_write", "SSL_read", "SSL_write_ex", "SSL_read_ex"},
+			"1.1",
+			map[string]uint64{"SSL_write": testTextOffset + 16, "SSL_read": testTextOffset + 32, "SSL_write_ex": testTextOffset + 48, "SSL_read_ex": testTextOffset + 64},
+		},
+		{
+			// RHEL 7, without the _ex functions
+			"openssl 1.0.2",
+			[]string{"SSL_library_init", "SSL_read", "SSL_write"},
+			"1.0",
+			map[string]uint64{"SSL_read": testTextOffset + 16, "SSL_write": testTextOffset + 32},
+		},
+		{
+			"unknown",
+			[]string{"SSL_write"},
+			"unknown",
+			map[string]uint64{"SSL_write": testTextOffset},
+		},
+		{
+			"no ssl",
+			[]string{"inflate"},
+			"unknown",
+			map[string]uint64{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "libssl.so")
+			writeSslElf(t, path, test.functions)
+
+			library, err := resolveSslLibrary(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if library.version != test.version {
+				t.Errorf("got the version %q, want %q", library.version, test.version)
+			}
+
+			if !reflect.DeepEqual(library.offsets, test.offsets) {
+				t.Errorf("got the offsets %v, want %v", library.offsets, test.offsets)
+			}
+		})
+	}
+}
+
+func TestResolveSslLibraryNotElf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "libssl.so")
+	writeFile(t, path, []byte("not an ELF file"))
+
+	if _, err := resolveSslLibrary(path); err == nil {
+		t.Fatal("resolved a file that isn't ELF")
+	}
+}
+
+func TestGetFileOffset(t *testing.T) {
+	file := &elf.File{
+		Progs: []*elf.Prog{
+			{ProgHeader: elf.ProgHeader{Type: elf.PT_LOAD, Flags: elf.PF_R, Off: 0, Vaddr: 0, Memsz: 0x1000}},
+			{ProgHeader: elf.ProgHeader{Type: elf.PT_LOAD, Flags: elf.PF_R | elf.PF_X, Off: 0x1000, Vaddr: 0x201000, Memsz: 0x2000}},
+			{ProgHeader: elf.ProgHeader{Type: elf.PT_NOTE, Flags: elf.PF_R | elf.PF_X, Off: 0x5000, Vaddr: 0x5000, Memsz: 0x100}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		address uint64
+		offset  uint64
+		err     string
+	}{
+		{"start", 0x201000, 0x1000, ""},
+		{"inside", 0x201a30, 0x1a30, ""},
+		{"last", 0x202fff, 0x2fff, ""},
+		{"end", 0x203000, 0, "not in an executable segment"},
+		{"not executable", 0x500, 0, "not in an executable segment"},
+		{"not loaded", 0x5010, 0, "not in an executable segment"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offset, err := getFileOffset(file, test.address)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got %v, want an error %q", err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if offset != test.offset {
+				t.Fatalf("got 0x%x, want 0x%x", offset, test.offset)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package tracer
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// streamShedding caps the number of the tracked streams. Beyond MaxStreams the least recently
+// active stream is closed for the new one, e.g. during a load test or a SYN flood, instead of
+// keeping the streams that are never closed while their processes run.
+type streamShedding struct {
+	// The keys of the streams of tlsPoller.streams, the most recently active first
+	active *simplelru.LRU
+	shed   uint64
+}
+
+func newStreamShedding(maxStreams int) (*streamShedding, error) {
+	if maxStreams <= 0 {
+		return nil, nil
+	}
+
+	active, err := simplelru.NewLRU(maxStreams, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	return &streamShedding{active: active}, nil
+}
+
+// trackStream adds a new stream, the least recently active one is shed if the cap is reached
+func (p *tlsPoller) trackStream(key string, stream *tlsStream, streamsMap *TcpStreamMap) {
+	p.streams[key] = stream
+
+	if p.shedding == nil {
+		return
+	}
+
+	if p.shedding.active.Len() >= p.tls.config.MaxStreams {
+		if oldest, _, ok := p.shedding.active.RemoveOldest(); ok {
+			p.shedStream(oldest.(string), streamsMap)
+		}
+	}
+
+	p.shedding.active.Add(key, nil)
+}
+
+// touchStream marks the stream as the most recently active
+func (p *tlsPoller) touchStream(key string) {
+	if p.shedding != nil {
+		p.shedding.active.Get(key)
+	}
+}
+
+// untrackStream removes a stream that is closed otherwise
+func (p *tlsPoller) untrackStream(key string) {
+	delete(p.streams, key)
+
+	if p.shedding != nil {
+		p.shedding.active.Remove(key)
+	}
+}
+
+// shedStream closes the stream the same way as the streams of an exited process, its
+// subscriptions get a shed event. A later chunk of the connection starts a new stream.
+func (p *tlsPoller) shedStream(key string, streamsMap *TcpStreamMap) {
+	stream, ok := p.streams[key]
+	if !ok {
+		return
+	}
+
+	p.shedding.shed++
+	if p.shedding.shed%10000 == 1 {
+		pollerLog.get().Warn().Int("max", p.tls.config.MaxStreams).Uint64("shed", p.shedding.shed).
+			Msg("Too many streams, shedding the least recently active ones:")
+	}
+	pollerLog.get().Debug().Int64("stream", stream.getId()).Str("key", key).Msg("Shed stream:")
+
+	stream.doTcpTeardown()
+	p.tls.emit(stream, newShedEvent(stream))
+	p.tls.closeFanout(stream)
+	delete(p.streams, key)
+	streamsMap.Delete(stream.getId())
+}
+
+// newShedEvent has no payload, the client is the source and the process is one of the
+// processes of the stream
+func newShedEvent(stream *tlsStream) Event {
+	id := stream.client.tcpID
+	srcPort, _ := strconv.ParseUint(id.SrcPort, 10, 16)
+	dstPort, _ := strconv.ParseUint(id.DstPort, 10, 16)
+
+	event := Event{
+		StreamId:  stream.getId(),
+		SrcIP:     net.ParseIP(id.SrcIP),
+		SrcPort:   uint16(srcPort),
+		DstIP:     net.ParseIP(id.DstIP),
+		DstPort:   uint16(dstPort),
+		IsClient:  true,
+		IsUdp:     stream.udp,
+		Protocol:  stream.protocol,
+		Namespace: stream.namespace,
+		Workload:  stream.workload,
+		MeshLeg:   stream.meshLeg,
+		Timestamp: time.Now().UTC(),
+		Shed:      true,
+	}
+
+	for pid := range stream.pids {
+		event.Pid = pid
+		break
+	}
+
+	return event
+}
@@ -0,0 +1,80 @@
+package tracer
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestStreamShedding(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxStreams int
+		// The streams are tracked by their key, "+key" touches a tracked stream and "-key"
+		// untracks it
+		ops     []string
+		tracked []string
+		shed    []string
+	}{
+		{"disabled", 0, []string{"a", "b", "c"}, []string{"a", "b", "c"}, nil},
+		{"below", 3, []string{"a", "b", "c"}, []string{"a", "b", "c"}, nil},
+		{"oldest", 2, []string{"a", "b", "c"}, []string{"b", "c"}, []string{"a"}},
+		{"touched", 2, []string{"a", "b", "+a", "c"}, []string{"a", "c"}, []string{"b"}},
+		{"untracked", 2, []string{"a", "b", "-a", "c"}, []string{"b", "c"}, nil},
+		{"several", 1, []string{"a", "b", "c"}, []string{"c"}, []string{"a", "b"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracer := &Tracer{config: Config{MaxStreams: test.maxStreams}}
+			events, err := tracer.Subscribe(EventFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			shedding, err := newStreamShedding(test.maxStreams)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &tlsPoller{tls: tracer, streams: make(map[string]*tlsStream), shedding: shedding}
+
+			streamsMap := NewTcpStreamMap()
+			for i, op := range test.ops {
+				switch op[0] {
+				case '+':
+					p.touchStream(op[1:])
+				case '-':
+					p.untrackStream(op[1:])
+				default:
+					stream := NewTlsStream(p, op)
+					stream.id = int64(i)
+					stream.namespace = "shop"
+					stream.client = NewTlsReader(&TcpID{SrcIP: "10.0.0.1", DstIP: "10.0.0.2", SrcPort: "40000", DstPort: "443"}, stream, true)
+					streamsMap.Store(stream.id, stream)
+					p.trackStream(op, stream, streamsMap)
+				}
+			}
+
+			tracked := make([]string, 0, len(p.streams))
+			for key := range p.streams {
+				tracked = append(tracked, key)
+			}
+			sort.Strings(tracked)
+			if !reflect.DeepEqual(tracked, test.tracked) {
+				t.Errorf("got the streams %v, want %v", tracked, test.tracked)
+			}
+
+			var shed []string
+			for len(events) > 0 {
+				event := <-events
+				if !event.Shed || event.Namespace != "shop" || event.DstPort != 443 {
+					t.Errorf("got the event %+v", event)
+				}
+				shed = append(shed, test.ops[event.StreamId])
+			}
+			if !reflect.DeepEqual(shed, test.shed) {
+				t.Errorf("got the shed streams %v, want %v", shed, test.shed)
+			}
+		})
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package tracer
 
 import (
 	"sync"
@@ -31,6 +31,14 @@ func (streamMap *TcpStreamMap) Store(key, value interface{}) {
 	}
 }
 
+func (streamMap *TcpStreamMap) Load(key interface{}) (interface{}, bool) {
+	if streamMap.streams == nil {
+		return nil, false
+	}
+
+	return streamMap.streams.Load(key)
+}
+
 func (streamMap *TcpStreamMap) Delete(key interface{}) {
 	if streamMap.streams != nil {
 		streamMap.streams.Delete(key)
@@ -42,6 +50,13 @@ func (streamMap *TcpStreamMap) NextId() int64 {
 	return streamMap.streamId
 }
 
+// SkipId makes sure that NextId never returns an id that is already in use
+func (streamMap *TcpStreamMap) SkipId(id int64) {
+	if id > streamMap.streamId {
+		streamMap.streamId = id
+	}
+}
+
 func (streamMap *TcpStreamMap) Close() {
 	streamMap.done <- true
 	streamMap.streams = nil
@@ -0,0 +1,174 @@
+package tracer
+
+import (
+	"debug/elf"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf/link"
+	"github.com/go-errors/errors"
+	"github.com/kubeshark/tracer/misc"
+	"github.com/rs/zerolog/log"
+)
+
+// SymbolOffset overrides the file offset of a hooked symbol of a binary, for the libraries
+// and the executables whose symbols can't be discovered, e.g. stripped, packed or obfuscated
+type SymbolOffset struct {
+	// As seen by the process, matched under <procfs>/<pid>/root as well
+	Path   string
+	Symbol string
+	Offset uint64
+	// The offsets of the return instructions, required for the Go crypto/tls functions
+	Returns []uint64
+}
+
+var sslSymbols = []string{"SSL_write", "SSL_read", "SSL_write_ex", "SSL_read_ex"}
+
+// ParseSymbolOffset parses <path>:<symbol>=<offset>[:<return offset>...], the offsets are
+// decimal or 0x prefixed hexadecimal, e.g. /usr/lib/libssl.so.3:SSL_write=0x3a2b0
+func ParseSymbolOffset(value string) (SymbolOffset, error) {
+	var result SymbolOffset
+
+	equal := strings.LastIndex(value, "=")
+	colon := -1
+	if equal >= 0 {
+		colon = strings.LastIndex(value[:equal], ":")
+	}
+	if colon < 0 {
+		return result, errors.Errorf("Invalid symbol offset %q, expected <path>:<symbol>=<offset>", value)
+	}
+
+	result.Path = value[:colon]
+	result.Symbol = value[colon+1 : equal]
+
+	for i, item := range strings.Split(value[equal+1:], ":") {
+		offset, err := strconv.ParseUint(item, 0, 64)
+		if err != nil {
+			return result, errors.Errorf("Invalid offset %q of symbol %s", item, result.Symbol)
+		}
+
+		if i == 0 {
+			result.Offset = offset
+		} else {
+			result.Returns = append(result.Returns, offset)
+		}
+	}
+
+	return result, result.validate()
+}
+
+func (o *SymbolOffset) validate() error {
+	if o.Path == "" {
+		return errors.Errorf("Missing the path of symbol %s", o.Symbol)
+	}
+
+	switch {
+	case o.Symbol == goWriteSymbol || o.Symbol == goReadSymbol:
+		if len(o.Returns) == 0 {
+			return errors.Errorf("Missing the return offsets of symbol %s", o.Symbol)
+		}
+	case misc.Contains(sslSymbols, o.Symbol), misc.Contains(bioSymbols, o.Symbol), o.Symbol == logSecretSymbol, isTlsLibrarySymbol(o.Symbol):
+		if len(o.Returns) > 0 {
+			return errors.Errorf("Unexpected return offsets of symbol %s, uretprobes are used", o.Symbol)
+		}
+	default:
+		return errors.Errorf("Unsupported symbol %q, expected one of %v, %v, %s, the read and write functions of %s or %s, %s", o.Symbol, sslSymbols, bioSymbols, logSecretSymbol, tlsLibraryGroups(), goWriteSymbol, goReadSymbol)
+	}
+
+	return nil
+}
+
+func (o *SymbolOffset) matchesPath(fpath string) bool {
+	return fpath == o.Path || strings.HasSuffix(fpath, "/root"+o.Path)
+}
+
+func findSymbolOffset(overrides []SymbolOffset, fpath string, symbol string) *SymbolOffset {
+	for i := range overrides {
+		if overrides[i].Symbol == symbol && overrides[i].matchesPath(fpath) {
+			return &overrides[i]
+		}
+	}
+
+	return nil
+}
+
+// getUprobeOptions returns the options that attach a uprobe at the overridden offset of
+// the symbol, nil to look up the symbol in the binary
+func getUprobeOptions(overrides []SymbolOffset, fpath string, symbol string) (*link.UprobeOptions, error) {
+	override := findSymbolOffset(overrides, fpath, symbol)
+	if override == nil {
+		return nil, nil
+	}
+
+	if err := checkExecutableOffsets(fpath, override.Offset); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("path", fpath).Str("symbol", symbol).Uint64("offset", override.Offset).Msg("Overriding symbol offset:")
+
+	return &link.UprobeOptions{Address: override.Offset}, nil
+}
+
+// overrideGoOffsets replaces the discovered offsets of the Go crypto/tls functions, the
+// binary is assumed to use ABIInternal if nothing was discovered
+func overrideGoOffsets(overrides []SymbolOffset, fpath string, offsets *goOffsets) (bool, error) {
+	write := findSymbolOffset(overrides, fpath, goWriteSymbol)
+	read := findSymbolOffset(overrides, fpath, goReadSymbol)
+	if write == nil && read == nil {
+		return false, nil
+	}
+
+	if offsets.GoWriteOffset == nil && write == nil || offsets.GoReadOffset == nil && read == nil {
+		return false, errors.Errorf("Both %s and %s must be overridden for %s", goWriteSymbol, goReadSymbol, fpath)
+	}
+
+	if offsets.GoWriteOffset == nil && offsets.GoReadOffset == nil {
+		offsets.Abi = ABIInternal
+	}
+
+	for _, override := range []*SymbolOffset{write, read} {
+		if override == nil {
+			continue
+		}
+
+		if err := checkExecutableOffsets(fpath, append([]uint64{override.Offset}, override.Returns...)...); err != nil {
+			return false, err
+		}
+
+		offset := &goExtendedOffset{enter: override.Offset, exits: override.Returns}
+		if override == write {
+			offsets.GoWriteOffset = offset
+		} else {
+			offsets.GoReadOffset = offset
+		}
+
+		log.Info().Str("path", fpath).Str("symbol", override.Symbol).Uint64("offset", override.Offset).Msg("Overriding symbol offset:")
+	}
+
+	return true, nil
+}
+
+// checkExecutableOffsets validates that the file offsets are within an executable segment
+func checkExecutableOffsets(fpath string, offsets ...uint64) error {
+	file, err := elf.Open(fpath)
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+	defer file.Close()
+
+	for _, offset := range offsets {
+		valid := false
+		for _, prog := range file.Progs {
+			if prog.Type == elf.PT_LOAD && prog.Flags&elf.PF_X != 0 && prog.Off <= offset && offset < prog.Off+prog.Filesz {
+				valid = true
+				break
+			}
+		}
+
+		if !valid {
+			return errors.Errorf("Offset 0x%x is not in an executable segment of %s", offset, fpath)
+		}
+	}
+
+	return nil
+}
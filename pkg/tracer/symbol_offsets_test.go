@@ -0,0 +1,106 @@
+package tracer
+
+import (
+	"debug/elf"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseSymbolOffset(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  SymbolOffset
+		err   bool
+	}{
+		{"hex", "/usr/lib/libssl.so.3:SSL_write=0x3a2b0", SymbolOffset{Path: "/usr/lib/libssl.so.3", Symbol: "SSL_write", Offset: 0x3a2b0}, false},
+		{"decimal", "/usr/lib/libssl.so.3:SSL_read_ex=1024", SymbolOffset{Path: "/usr/lib/libssl.so.3", Symbol: "SSL_read_ex", Offset: 1024}, false},
+		{"go with returns", "/app/server:crypto/tls.(*Conn).Write=0x100:0x180:0x1a0", SymbolOffset{Path: "/app/server", Symbol: goWriteSymbol, Offset: 0x100, Returns: []uint64{0x180, 0x1a0}}, false},
+		{"colon in path", "/opt/a:b/libssl.so:SSL_write=0x10", SymbolOffset{Path: "/opt/a:b/libssl.so", Symbol: "SSL_write", Offset: 0x10}, false},
+		{"bio", "/usr/lib/libcrypto.so.3:BIO_write=0x10", SymbolOffset{Path: "/usr/lib/libcrypto.so.3", Symbol: "BIO_write", Offset: 0x10}, false},
+		{"log secret", "/usr/lib/libssl.so.3:ssl_log_secret=0x10", SymbolOffset{Path: "/usr/lib/libssl.so.3", Symbol: logSecretSymbol, Offset: 0x10}, false},
+		{"tls library", "/usr/lib/libmbedtls.so:mbedtls_ssl_write=0x10", SymbolOffset{Path: "/usr/lib/libmbedtls.so", Symbol: "mbedtls_ssl_write", Offset: 0x10}, false},
+		{"go without returns", "/app/server:crypto/tls.(*Conn).Read=0x100", SymbolOffset{}, true},
+		{"returns of a uretprobe", "/usr/lib/libssl.so.3:SSL_write=0x10:0x20", SymbolOffset{}, true},
+		{"unsupported symbol", "/usr/lib/libssl.so.3:SSL_new=0x10", SymbolOffset{}, true},
+		{"missing path", ":SSL_write=0x10", SymbolOffset{}, true},
+		{"missing offset", "/usr/lib/libssl.so.3:SSL_write", SymbolOffset{}, true},
+		{"invalid offset", "/usr/lib/libssl.so.3:SSL_write=0xzz", SymbolOffset{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseSymbolOffset(test.value)
+			if test.err {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckExecutableOffsets(t *testing.T) {
+	// The test binary has an executable segment and a data one
+	fpath, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := elf.Open(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var text, data *elf.Prog
+	for _, prog := range file.Progs {
+		if prog.Type != elf.PT_LOAD {
+			continue
+		}
+		if prog.Flags&elf.PF_X != 0 && text == nil {
+			text = prog
+		} else if prog.Flags&elf.PF_X == 0 && prog.Off > 0 && data == nil {
+			data = prog
+		}
+	}
+	if text == nil || data == nil {
+		t.Skip("the test binary has no executable and data segments")
+	}
+
+	tests := []struct {
+		name    string
+		offsets []uint64
+		err     bool
+	}{
+		{"start of text", []uint64{text.Off}, false},
+		{"end of text", []uint64{text.Off + text.Filesz - 1}, false},
+		{"several in text", []uint64{text.Off, text.Off + 1}, false},
+		{"past the file", []uint64{^uint64(0)}, true},
+		{"in data", []uint64{data.Off}, true},
+		{"one invalid", []uint64{text.Off, ^uint64(0)}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkExecutableOffsets(fpath, test.offsets...)
+			if (err != nil) != test.err {
+				t.Fatalf("got %v, want an error %v", err, test.err)
+			}
+		})
+	}
+
+	if err := checkExecutableOffsets(fpath + ".missing"); err == nil {
+		t.Fatal("got no error for a missing file")
+	}
+}
@@ -0,0 +1,136 @@
+package tracer
+
+import (
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/go-errors/errors"
+)
+
+type syscallHooks struct {
+	sysEnterRead  link.Link
+	sysEnterWrite link.Link
+	sysExitRead   link.Link
+	sysExitWrite  link.Link
+	// The reads and the unconnected writes of the UDP sockets of DTLS
+	sysEnterRecvfrom link.Link
+	sysEnterSendto   link.Link
+	sysEnterAccept4  link.Link
+	sysExitAccept4   link.Link
+	sysEnterConnect  link.Link
+	sysExitConnect   link.Link
+	// Not a syscall, cleans up the contexts of the exited processes
+	schedProcessExit link.Link
+}
+
+// installSyscallHooks pins the links under pinPath if it isn't empty, see attachPinned
+func (s *syscallHooks) installSyscallHooks(bpfObjects *tracerObjects, pinPath string) error {
+	tracepoints := []struct {
+		link    *link.Link
+		group   string
+		name    string
+		program *ebpf.Program
+	}{
+		{&s.sysEnterRead, "syscalls", "sys_enter_read", bpfObjects.SysEnterRead},
+		{&s.sysEnterWrite, "syscalls", "sys_enter_write", bpfObjects.SysEnterWrite},
+		{&s.sysExitRead, "syscalls", "sys_exit_read", bpfObjects.SysExitRead},
+		{&s.sysExitWrite, "syscalls", "sys_exit_write", bpfObjects.SysExitWrite},
+		{&s.sysEnterRecvfrom, "syscalls", "sys_enter_recvfrom", bpfObjects.SysEnterRecvfrom},
+		{&s.sysEnterSendto, "syscalls", "sys_enter_sendto", bpfObjects.SysEnterSendto},
+		{&s.sysEnterAccept4, "syscalls", "sys_enter_accept4", bpfObjects.SysEnterAccept4},
+		{&s.sysExitAccept4, "syscalls", "sys_exit_accept4", bpfObjects.SysExitAccept4},
+		{&s.sysEnterConnect, "syscalls", "sys_enter_connect", bpfObjects.SysEnterConnect},
+		{&s.sysExitConnect, "syscalls", "sys_exit_connect", bpfObjects.SysExitConnect},
+		{&s.schedProcessExit, "sched", "sched_process_exit", bpfObjects.SchedProcessExit},
+	}
+
+	for _, tracepoint := range tracepoints {
+		l, err := attachPinned(pinPath, tracepoint.name, tracepoint.program, func() (link.Link, error) {
+			return link.Tracepoint(tracepoint.group, tracepoint.name, tracepoint.program, nil)
+		})
+
+		if err != nil {
+			return errors.Wrap(err, 0)
+		}
+
+		*tracepoint.link = l
+	}
+
+	return nil
+}
+
+func (s *syscallHooks) links() []link.Link {
+	return []link.Link{s.sysEnterRead, s.sysEnterWrite, s.sysExitRead, s.sysExitWrite, s.sysEnterRecvfrom,
+		s.sysEnterSendto, s.sysEnterAccept4, s.sysExitAccept4, s.sysEnterConnect, s.sysExitConnect, s.schedProcessExit}
+}
+
+func (s *syscallHooks) close() []error {
+	returnValue := make([]error, 0)
+
+	if s.sysEnterRead != nil {
+		if err := s.sysEnterRead.Close(); err != nil {
+			returnValue = append(returnValue, err)
+		}
+	}
+
+	if s.sysEnterWrite != nil {
+		if err := s.sysEnterWrite.Close(); err != nil {
+			returnValue = append(returnValue, err)
+		}
+	}
+
+	if s.sysExitRead != nil {
+		if err := s.sysExitRead.Close(); err != nil {
+			returnValue = append(returnValue, err)
+		}
+	}
+
+	if s.sysExitWrite != nil {
+		if err := s.sysExitWrite.Close(); err != nil {
+			returnValue = append(returnValue, err)
+		}
+	}
+
+	if s.sysEnterRecvfrom != nil {
+		if err := s.sysEnterRecvfrom.Close(); err != nil {
+			returnValue = append(returnValue, err)
+		}
+	}
+
+	if s.sysEnterSendto != nil {
+		if err := s.sysEnterSendto.Close(); err != nil {
+			returnValue = append(returnValue, err)
+		}
+	}
+
+	if s.sysEnterAccept4 != nil {
+		if err := s.sysEnterAccept4.Close(); err != nil {
+			returnValue = append(returnValue, err)
+		}
+	}
+
+	if s.sysExitAccept4 != nil {
+		if err := s.sysExitAccept4.Close(); err != nil {
+			returnValue = append(returnValue, err)
+		}
+	}
+
+	if s.sysEnterConnect != nil {
+		if err := s.sysEnterConnect.Close(); err != nil {
+			returnValue = append(returnValue, err)
+		}
+	}
+
+	if s.sysExitConnect != nil {
+		if err := s.sysExitConnect.Close(); err != nil {
+			returnValue = append(returnValue, err)
+		}
+	}
+
+	if s.schedProcessExit != nil {
+		if err := s.schedProcessExit.Close(); err != nil {
+			returnValue = append(returnValue, err)
+		}
+	}
+
+	return returnValue
+}
@@ -0,0 +1,156 @@
+package tracer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// targetAnalysis is the result of finding the libssl.so and the Go offsets of a process,
+// the probes are attached from the results one by one afterwards
+type targetAnalysis struct {
+	pid          uint32
+	sslLibraries []string
+	sslErr       error
+	// The files of the other TLS libraries by their probe group
+	tlsLibraryFiles map[string][]string
+	exePath         string
+	goOffsets       goOffsets
+	goErr           error
+	timedOut        bool
+}
+
+// analyzeTargets analyzes the processes in at most workers goroutines. An analysis that
+// takes longer than timeout is reported as timed out and its worker is freed, the abandoned
+// analysis still runs in the background until the binary is parsed, its result is dropped.
+func (t *Tracer) analyzeTargets(pids []uint32, workers int, timeout time.Duration) []targetAnalysis {
+	results := make([]targetAnalysis, len(pids))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(pids); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				results[index] = t.analyzeTargetWithTimeout(pids[index], timeout)
+			}
+		}()
+	}
+
+	for index := range pids {
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+func (t *Tracer) analyzeTargetWithTimeout(pid uint32, timeout time.Duration) targetAnalysis {
+	if timeout <= 0 {
+		return t.analyzeTarget(pid)
+	}
+
+	done := make(chan targetAnalysis, 1)
+	go func() {
+		done <- t.analyzeTarget(pid)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case result := <-done:
+		return result
+	case <-timer.C:
+		return targetAnalysis{pid: pid, timedOut: true}
+	}
+}
+
+func (t *Tracer) analyzeTarget(pid uint32) targetAnalysis {
+	start := time.Now()
+	result := targetAnalysis{pid: pid}
+
+	if t.isProbeGroupEnabled(ProbeGroupOpenssl) {
+		result.sslLibraries, result.sslErr = findSsllibs(t.procfs, pid)
+	}
+
+	for _, library := range tlsLibraries {
+		if !t.isProbeGroupEnabled(library.group) {
+			continue
+		}
+
+		files, err := findTlsLibraryFiles(t.procfs, pid, library)
+		if err == nil && len(files) > 0 {
+			if result.tlsLibraryFiles == nil {
+				result.tlsLibraryFiles = make(map[string][]string)
+			}
+			result.tlsLibraryFiles[library.group] = files
+		}
+	}
+
+	if t.isProbeGroupEnabled(ProbeGroupGo) {
+		result.exePath, result.goErr = findLibraryByPid(t.procfs, pid, "")
+		if result.goErr == nil {
+			result.goOffsets, result.goErr = t.offsetsCache.findGoOffsets(result.exePath)
+		}
+	}
+
+	log.Debug().Int("pid", int(pid)).Dur("took", time.Since(start)).Msg("Analyzed target:")
+
+	return result
+}
+
+// attachTarget attaches the probes of an analyzed process, the processes that use none of
+// libssl.so, the other TLS libraries and Go crypto/tls are skipped silently
+func (t *Tracer) attachTarget(result targetAnalysis) (bool, error) {
+	if result.timedOut {
+		return false, errors.Errorf("Analysis of pid %d timed out", result.pid)
+	}
+
+	if t.config.SyscallsOnly {
+		return true, t.targetSyscallsPid(result.pid)
+	}
+
+	attached := false
+
+	if result.sslLibraries == nil && result.sslErr == nil {
+		// The openssl probe group is disabled
+	} else if result.sslErr != nil {
+		log.Warn().Err(result.sslErr).Int("pid", int(result.pid)).Msg("PID skipped no libssl.so found:")
+	} else {
+		for _, sslLibrary := range result.sslLibraries {
+			log.Info().Str("path", sslLibrary).Int("pid", int(result.pid)).Msg("Found libssl.so:")
+			if err := t.targetSSLLibPid(result.pid, sslLibrary); err != nil {
+				LogError(err)
+			} else {
+				attached = true
+			}
+		}
+	}
+
+	for _, library := range tlsLibraries {
+		for _, file := range result.tlsLibraryFiles[library.group] {
+			if err := t.targetTlsLibraryPid(result.pid, library, file); err != nil {
+				LogError(err)
+			} else {
+				attached = true
+			}
+		}
+	}
+
+	if result.exePath != "" {
+		ok, err := t.attachGoPid(result.pid, result.exePath, result.goOffsets, result.goErr)
+		if err != nil {
+			return attached, err
+		}
+		attached = attached || ok
+	} else if result.goErr != nil {
+		return attached, result.goErr
+	}
+
+	return attached, nil
+}
@@ -0,0 +1,147 @@
+package tracer
+
+import (
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/link"
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// The fentry programs of the tcp hooks, replaced by empty programs if the kernel can't load them
+var tcpFentryPrograms = []string{"tcp_sendmsg_fentry", "tcp_recvmsg_fentry"}
+
+type tcpKprobeHooks struct {
+	tcpSendmsg link.Link
+	tcpRecvmsg link.Link
+	// The UDP sockets of DTLS, always kprobes
+	udpSendmsg link.Link
+	udpRecvmsg link.Link
+}
+
+// installTcpKprobeHooks pins the links under pinPath if it isn't empty, see attachPinned
+func (s *tcpKprobeHooks) installTcpKprobeHooks(bpfObjects *tracerObjects, fentry bool, pinPath string) error {
+	hooks := []struct {
+		link   *link.Link
+		symbol string
+		kprobe *ebpf.Program
+		fentry *ebpf.Program
+	}{
+		{&s.tcpSendmsg, "tcp_sendmsg", bpfObjects.TcpSendmsg, bpfObjects.TcpSendmsgFentry},
+		{&s.tcpRecvmsg, "tcp_recvmsg", bpfObjects.TcpRecvmsg, bpfObjects.TcpRecvmsgFentry},
+		{&s.udpSendmsg, "udp_sendmsg", bpfObjects.UdpSendmsg, nil},
+		{&s.udpRecvmsg, "udp_recvmsg", bpfObjects.UdpRecvmsg, nil},
+	}
+
+	for _, hook := range hooks {
+		useFentry := fentry && hook.fentry != nil
+
+		program := hook.kprobe
+		if useFentry {
+			program = hook.fentry
+		}
+
+		l, err := attachPinned(pinPath, hook.symbol, program, func() (link.Link, error) {
+			if useFentry {
+				return link.AttachTracing(link.TracingOptions{Program: program})
+			}
+
+			return link.Kprobe(hook.symbol, program, nil)
+		})
+
+		if err != nil {
+			return errors.Wrap(err, 0)
+		}
+
+		*hook.link = l
+	}
+
+	return nil
+}
+
+func (s *tcpKprobeHooks) links() []link.Link {
+	return []link.Link{s.tcpSendmsg, s.tcpRecvmsg, s.udpSendmsg, s.udpRecvmsg}
+}
+
+func (s *tcpKprobeHooks) close() []error {
+	returnValue := make([]error, 0)
+
+	if s.tcpSendmsg != nil {
+		if err := s.tcpSendmsg.Close(); err != nil {
+			returnValue = append(returnValue, err)
+		}
+	}
+
+	if s.tcpRecvmsg != nil {
+		if err := s.tcpRecvmsg.Close(); err != nil {
+			returnValue = append(returnValue, err)
+		}
+	}
+
+	if s.udpSendmsg != nil {
+		if err := s.udpSendmsg.Close(); err != nil {
+			returnValue = append(returnValue, err)
+		}
+	}
+
+	if s.udpRecvmsg != nil {
+		if err := s.udpRecvmsg.Close(); err != nil {
+			returnValue = append(returnValue, err)
+		}
+	}
+
+	return returnValue
+}
+
+// prepareTcpFentry returns whether the tcp hooks can be fentry programs, which run through
+// the BPF trampolines at a lower cost than the kprobes. Otherwise the fentry programs are
+// replaced by empty programs, so the objects load on the kernels without the trampolines.
+func prepareTcpFentry(spec *ebpf.CollectionSpec, kernelTypes *btf.Spec) bool {
+	err := probeFentry(kernelTypes)
+	if err == nil {
+		log.Info().Msg("Using fentry for the tcp hooks")
+		return true
+	}
+
+	log.Info().Err(err).Msg("fentry isn't supported, using kprobes for the tcp hooks:")
+
+	for _, name := range tcpFentryPrograms {
+		spec.Programs[name] = &ebpf.ProgramSpec{
+			Name:         spec.Programs[name].Name,
+			Type:         ebpf.SocketFilter,
+			Instructions: asm.Instructions{asm.Mov.Imm(asm.R0, 0), asm.Return()},
+			License:      "GPL",
+		}
+	}
+
+	return false
+}
+
+// probeFentry loads and attaches an empty fentry program to tcp_sendmsg. Attaching is needed
+// as well, the architectures without the trampolines, e.g. arm64 before 6.0, load it.
+func probeFentry(kernelTypes *btf.Spec) error {
+	program, err := ebpf.NewProgramWithOptions(&ebpf.ProgramSpec{
+		Name:         "probe_fentry",
+		Type:         ebpf.Tracing,
+		AttachType:   ebpf.AttachTraceFEntry,
+		AttachTo:     "tcp_sendmsg",
+		Instructions: asm.Instructions{asm.Mov.Imm(asm.R0, 0), asm.Return()},
+		License:      "GPL",
+	}, ebpf.ProgramOptions{KernelTypes: kernelTypes})
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+	defer program.Close()
+
+	probe, err := link.AttachTracing(link.TracingOptions{Program: program})
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	if err := probe.Close(); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	return nil
+}
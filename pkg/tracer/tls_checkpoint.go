@@ -0,0 +1,112 @@
+package tracer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/go-errors/errors"
+	"github.com/kubeshark/tracer/misc"
+	"github.com/rs/zerolog/log"
+)
+
+const checkpointMaxStreams = 10000
+
+// The state that is needed to continue a stream in the master PCAP after a restart.
+// There are no partial buffers to keep, chunks are written to the PCAP as they arrive.
+type tlsStreamCheckpoint struct {
+	Key    string              `json:"key"`
+	Id     int64               `json:"id"`
+	Client tlsReaderCheckpoint `json:"client"`
+	Server tlsReaderCheckpoint `json:"server"`
+}
+
+type tlsReaderCheckpoint struct {
+	TcpID      TcpID      `json:"tcpId"`
+	SeqNumbers seqNumbers `json:"seqNumbers"`
+}
+
+func (p *tlsPoller) saveCheckpoint() error {
+	checkpoints := make([]tlsStreamCheckpoint, 0, len(p.streams))
+
+	for key, stream := range p.streams {
+		if len(checkpoints) >= checkpointMaxStreams {
+			log.Warn().Int("max", checkpointMaxStreams).Msg("Too many streams, the rest are not checkpointed:")
+			break
+		}
+
+		// The handshake is not written yet, nothing to resume
+		if stream.layers == nil {
+			continue
+		}
+
+		checkpoints = append(checkpoints, tlsStreamCheckpoint{
+			Key: key,
+			Id:  stream.getId(),
+			Client: tlsReaderCheckpoint{
+				TcpID:      *stream.client.tcpID,
+				SeqNumbers: *stream.client.seqNumbers,
+			},
+			Server: tlsReaderCheckpoint{
+				TcpID:      *stream.server.tcpID,
+				SeqNumbers: *stream.server.seqNumbers,
+			},
+		})
+	}
+
+	data, err := json.Marshal(checkpoints)
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	if err := os.WriteFile(misc.GetCheckpointPath(), data, 0644); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	log.Info().Msg(fmt.Sprintf("Checkpointed %d streams", len(checkpoints)))
+
+	return nil
+}
+
+func (p *tlsPoller) restoreCheckpoint(streamsMap *TcpStreamMap) error {
+	data, err := os.ReadFile(misc.GetCheckpointPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	// A checkpoint is only valid for the first start after the shutdown that wrote it
+	if err := os.Remove(misc.GetCheckpointPath()); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	var checkpoints []tlsStreamCheckpoint
+	if err := json.Unmarshal(data, &checkpoints); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	for _, checkpoint := range checkpoints {
+		stream := NewTlsStream(p, checkpoint.Key)
+		stream.setId(checkpoint.Id)
+		stream.isResumed = true
+		streamsMap.Store(stream.getId(), stream)
+		streamsMap.SkipId(stream.getId())
+
+		stream.client = checkpoint.Client.newTlsReader(stream, true)
+		stream.server = checkpoint.Server.newTlsReader(stream, false)
+		p.trackStream(checkpoint.Key, stream, streamsMap)
+	}
+
+	log.Info().Msg(fmt.Sprintf("Restored %d streams from checkpoint", len(checkpoints)))
+
+	return nil
+}
+
+func (c *tlsReaderCheckpoint) newTlsReader(parent *tlsStream, isClient bool) *tlsReader {
+	tcpID := c.TcpID
+	reader := NewTlsReader(&tcpID, parent, isClient)
+	*reader.seqNumbers = c.SeqNumbers
+	return reader
+}
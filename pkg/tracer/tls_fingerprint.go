@@ -0,0 +1,376 @@
+package tracer
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-errors/errors"
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// The fingerprints of the ClientHellos that have no stream yet, the first chunk of a stream
+// follows its handshake closely
+const tlsFingerprintsSize = 4096
+
+const (
+	tlsExtensionServerName          = 0x0000
+	tlsExtensionSupportedGroups     = 0x000a
+	tlsExtensionPointFormats        = 0x000b
+	tlsExtensionSignatureAlgorithms = 0x000d
+	tlsExtensionAlpn                = 0x0010
+	tlsExtensionSupportedVersions   = 0x002b
+)
+
+// TlsFingerprint is the JA3 and the JA4 of the ClientHello of a connection
+type TlsFingerprint struct {
+	// The MD5 of the JA3 string
+	Ja3 string
+	Ja4 string
+}
+
+// clientHello has the fields of a ClientHello that the fingerprints consist of, in the order
+// of the message
+type clientHello struct {
+	version             uint16
+	ciphers             []uint16
+	extensions          []uint16
+	groups              []uint16
+	pointFormats        []uint8
+	signatureAlgorithms []uint16
+	supportedVersions   []uint16
+	alpn                []string
+	hasServerName       bool
+}
+
+// tlsFingerprints are the fingerprints of the recent ClientHellos by the addresses of their
+// connection, the streams take them on their chunks
+type tlsFingerprints struct {
+	recent *simplelru.LRU
+	sync.Mutex
+}
+
+func newTlsFingerprints() (*tlsFingerprints, error) {
+	recent, err := simplelru.NewLRU(tlsFingerprintsSize, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	return &tlsFingerprints{recent: recent}, nil
+}
+
+func getFingerprintKey(clientIp net.IP, clientPort uint16, serverIp net.IP, serverPort uint16) string {
+	return fmt.Sprintf("%s:%d-%s:%d", clientIp, clientPort, serverIp, serverPort)
+}
+
+func (f *tlsFingerprints) add(key string, fingerprint *TlsFingerprint) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.recent.Add(key, fingerprint)
+}
+
+// get returns the fingerprint of the connection of a chunk, nil if its ClientHello wasn't seen
+func (f *tlsFingerprints) get(chunk *tracerTlsChunk) *TlsFingerprint {
+	srcIp, srcPort := chunk.getSrcAddress()
+	dstIp, dstPort := chunk.getDstAddress()
+
+	// The source is the address of the process
+	key := getFingerprintKey(srcIp, srcPort, dstIp, dstPort)
+	if !chunk.isClient() {
+		key = getFingerprintKey(dstIp, dstPort, srcIp, srcPort)
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	if fingerprint, ok := f.recent.Get(key); ok {
+		return fingerprint.(*TlsFingerprint)
+	}
+
+	return nil
+}
+
+// getTlsFingerprint computes the fingerprints of the TLS payload of a packet, false if it
+// isn't a whole ClientHello, e.g. one that continues in the next packet
+func getTlsFingerprint(payload []byte) (*TlsFingerprint, bool) {
+	hello, ok := parseClientHello(payload)
+	if !ok {
+		return nil, false
+	}
+
+	return &TlsFingerprint{Ja3: hello.ja3(), Ja4: hello.ja4()}, true
+}
+
+// parseClientHello reads the ClientHello of the first record of payload
+func parseClientHello(payload []byte) (*clientHello, bool) {
+	if len(payload) < tlsRecordHeaderLength+4 || payload[0] != tlsContentHandshake || payload[1] != tlsMajorVersion {
+		return nil, false
+	}
+
+	handshake := payload[tlsRecordHeaderLength:]
+	if handshake[0] != tlsHandshakeClientHelo {
+		return nil, false
+	}
+
+	length := int(handshake[1])<<16 | int(handshake[2])<<8 | int(handshake[3])
+	if len(handshake) < 4+length {
+		return nil, false
+	}
+
+	r := handshakeReader{data: handshake[4 : 4+length]}
+	hello := &clientHello{}
+
+	hello.version = r.uint16()
+	r.skip(32)
+	r.skip(int(r.uint8()))
+
+	ciphers := r.vector16()
+	for len(ciphers.data) >= 2 {
+		hello.ciphers = append(hello.ciphers, ciphers.uint16())
+	}
+
+	r.skip(int(r.uint8()))
+
+	// A ClientHello without extensions is valid
+	if len(r.data) == 0 {
+		return hello, !r.failed
+	}
+
+	extensions := r.vector16()
+	for len(extensions.data) > 0 && !extensions.failed {
+		extensionType := extensions.uint16()
+		data := extensions.vector16()
+		hello.extensions = append(hello.extensions, extensionType)
+
+		switch extensionType {
+		case tlsExtensionServerName:
+			hello.hasServerName = true
+		case tlsExtensionSupportedGroups:
+			hello.groups = data.vector16().uint16s()
+		case tlsExtensionPointFormats:
+			points := data.vector8()
+			hello.pointFormats = append(hello.pointFormats, points.data...)
+		case tlsExtensionSignatureAlgorithms:
+			hello.signatureAlgorithms = data.vector16().uint16s()
+		case tlsExtensionSupportedVersions:
+			hello.supportedVersions = data.vector8().uint16s()
+		case tlsExtensionAlpn:
+			protocols := data.vector16()
+			for len(protocols.data) > 0 && !protocols.failed {
+				hello.alpn = append(hello.alpn, string(protocols.vector8().data))
+			}
+		}
+	}
+
+	return hello, !r.failed && !extensions.failed
+}
+
+// isGrease checks the reserved values of RFC 8701, 0x0a0a to 0xfafa
+func isGrease(value uint16) bool {
+	return value&0x0f0f == 0x0a0a && value>>8 == value&0xff
+}
+
+func withoutGrease(values []uint16) []uint16 {
+	result := make([]uint16, 0, len(values))
+	for _, value := range values {
+		if !isGrease(value) {
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
+
+func joinDecimal(values []uint16) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = strconv.Itoa(int(value))
+	}
+
+	return strings.Join(parts, "-")
+}
+
+func joinHex(values []uint16) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = fmt.Sprintf("%04x", value)
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// ja3String is SSLVersion,Ciphers,Extensions,EllipticCurves,EllipticCurvePointFormats
+// without the GREASE values
+func (h *clientHello) ja3String() string {
+	points := make([]uint16, len(h.pointFormats))
+	for i, point := range h.pointFormats {
+		points[i] = uint16(point)
+	}
+
+	return fmt.Sprintf("%d,%s,%s,%s,%s", h.version,
+		joinDecimal(withoutGrease(h.ciphers)),
+		joinDecimal(withoutGrease(h.extensions)),
+		joinDecimal(withoutGrease(h.groups)),
+		joinDecimal(points))
+}
+
+func (h *clientHello) ja3() string {
+	hash := md5.Sum([]byte(h.ja3String()))
+	return hex.EncodeToString(hash[:])
+}
+
+// ja4 is the JA4 of a TCP connection, see https://github.com/FoxIO-LLC/ja4
+func (h *clientHello) ja4() string {
+	version := h.version
+	for _, supported := range withoutGrease(h.supportedVersions) {
+		if supported > version {
+			version = supported
+		}
+	}
+
+	serverName := "i"
+	if h.hasServerName {
+		serverName = "d"
+	}
+
+	ciphers := withoutGrease(h.ciphers)
+	extensions := withoutGrease(h.extensions)
+
+	prefix := fmt.Sprintf("t%s%s%02d%02d%s", getJa4Version(version), serverName,
+		min(len(ciphers), 99), min(len(extensions), 99), getJa4Alpn(h.alpn))
+
+	sortedCiphers := append([]uint16{}, ciphers...)
+	sort.Slice(sortedCiphers, func(i, j int) bool { return sortedCiphers[i] < sortedCiphers[j] })
+
+	// The server name and the ALPN are in the prefix already
+	sortedExtensions := make([]uint16, 0, len(extensions))
+	for _, extension := range extensions {
+		if extension != tlsExtensionServerName && extension != tlsExtensionAlpn {
+			sortedExtensions = append(sortedExtensions, extension)
+		}
+	}
+	sort.Slice(sortedExtensions, func(i, j int) bool { return sortedExtensions[i] < sortedExtensions[j] })
+
+	extensionsPart := joinHex(sortedExtensions)
+	if len(h.signatureAlgorithms) > 0 {
+		extensionsPart += "_" + joinHex(withoutGrease(h.signatureAlgorithms))
+	}
+
+	cipherHash := getJa4Hash(joinHex(sortedCiphers))
+	extensionHash := getJa4Hash(extensionsPart)
+
+	return prefix + "_" + cipherHash + "_" + extensionHash
+}
+
+func getJa4Version(version uint16) string {
+	switch version {
+	case 0x0304:
+		return "13"
+	case 0x0303:
+		return "12"
+	case 0x0302:
+		return "11"
+	case 0x0301:
+		return "10"
+	case 0x0300:
+		return "s3"
+	default:
+		return "00"
+	}
+}
+
+// getJa4Alpn is the first and the last character of the first protocol, or their hex
+// digits if they aren't alphanumeric
+func getJa4Alpn(alpn []string) string {
+	if len(alpn) == 0 || alpn[0] == "" {
+		return "00"
+	}
+
+	first, last := alpn[0][0], alpn[0][len(alpn[0])-1]
+	if !isAlphanumeric(first) || !isAlphanumeric(last) {
+		return fmt.Sprintf("%x", first>>4) + fmt.Sprintf("%x", last&0x0f)
+	}
+
+	return string([]byte{first, last})
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// getJa4Hash is the start of the SHA-256 of a list, zeros if the list is empty
+func getJa4Hash(list string) string {
+	if list == "" {
+		return "000000000000"
+	}
+
+	hash := sha256.Sum256([]byte(list))
+	return hex.EncodeToString(hash[:])[:12]
+}
+
+// handshakeReader reads the fields of a handshake message, a truncated field fails the reader and
+// everything after it is zero
+type handshakeReader struct {
+	data   []byte
+	failed bool
+}
+
+func (r *handshakeReader) next(n int) []byte {
+	if r.failed || n > len(r.data) {
+		r.failed = true
+		r.data = nil
+		return nil
+	}
+
+	value := r.data[:n]
+	r.data = r.data[n:]
+
+	return value
+}
+
+func (r *handshakeReader) skip(n int) {
+	r.next(n)
+}
+
+func (r *handshakeReader) uint8() uint8 {
+	if value := r.next(1); value != nil {
+		return value[0]
+	}
+
+	return 0
+}
+
+func (r *handshakeReader) uint16() uint16 {
+	if value := r.next(2); value != nil {
+		return binary.BigEndian.Uint16(value)
+	}
+
+	return 0
+}
+
+func (r *handshakeReader) vector8() *handshakeReader {
+	length := int(r.uint8())
+	return &handshakeReader{data: r.next(length), failed: r.failed}
+}
+
+func (r *handshakeReader) vector16() *handshakeReader {
+	length := int(r.uint16())
+	return &handshakeReader{data: r.next(length), failed: r.failed}
+}
+
+func (r *handshakeReader) uint16s() []uint16 {
+	values := make([]uint16, 0, len(r.data)/2)
+	for len(r.data) >= 2 {
+		values = append(values, r.uint16())
+	}
+
+	return values
+}
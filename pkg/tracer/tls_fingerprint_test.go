@@ -0,0 +1,132 @@
+package tracer
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+type testExtension struct {
+	extensionType uint16
+	data          []byte
+}
+
+func appendUint16s(buffer []byte, values ...uint16) []byte {
+	for _, value := range values {
+		buffer = binary.BigEndian.AppendUint16(buffer, value)
+	}
+
+	return buffer
+}
+
+// vector16 prefixes data with its 16 bit length
+func vector16(data []byte) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(data))), data...)
+}
+
+func newClientHello(ciphers []uint16, extensions []testExtension) []byte {
+	body := appendUint16s(nil, 0x0303)
+	body = append(body, make([]byte, 32)...)
+	body = append(body, 0)
+	body = append(body, vector16(appendUint16s(nil, ciphers...))...)
+	body = append(body, 1, 0)
+
+	if extensions != nil {
+		var data []byte
+		for _, extension := range extensions {
+			data = appendUint16s(data, extension.extensionType)
+			data = append(data, vector16(extension.data)...)
+		}
+		body = append(body, vector16(data)...)
+	}
+
+	handshake := append([]byte{tlsHandshakeClientHelo, 0, byte(len(body) >> 8), byte(len(body))}, body...)
+	return append([]byte{tlsContentHandshake, 0x03, 0x01, byte(len(handshake) >> 8), byte(len(handshake))}, handshake...)
+}
+
+// The ClientHello of Chrome of the JA4 documentation, with GREASE values
+func newChromeClientHello() []byte {
+	ciphers := []uint16{0x2a2a, 0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f, 0xc02c, 0xc030, 0xcca9, 0xcca8, 0xc013, 0xc014, 0x009c, 0x009d, 0x002f, 0x0035}
+
+	alpn := []byte{2, 'h', '2', 8}
+	alpn = append(alpn, "http/1.1"...)
+
+	extensions := []testExtension{
+		{0x3a3a, nil},
+		{0x0000, vector16([]byte{0, 0, 1, 'a'})},
+		{0x0017, nil},
+		{0xff01, []byte{0}},
+		{0x000a, vector16(appendUint16s(nil, 0x4a4a, 0x001d, 0x0017, 0x0018))},
+		{0x000b, []byte{1, 0}},
+		{0x0023, nil},
+		{0x0010, vector16(alpn)},
+		{0x0005, []byte{1, 0, 0, 0, 0}},
+		{0x000d, vector16(appendUint16s(nil, 0x0403, 0x0804, 0x0401, 0x0503, 0x0805, 0x0501, 0x0806, 0x0601))},
+		{0x0012, nil},
+		{0x0033, vector16(nil)},
+		{0x002d, []byte{1, 1}},
+		{0x002b, append([]byte{6}, appendUint16s(nil, 0x5a5a, 0x0304, 0x0303)...)},
+		{0x001b, []byte{2, 0, 2}},
+		{0x4469, nil},
+		{0x0015, nil},
+		{0x1a1a, []byte{0}},
+	}
+
+	return newClientHello(ciphers, extensions)
+}
+
+func TestTlsFingerprint(t *testing.T) {
+	noExtensions := newClientHello([]uint16{0x002f}, nil)
+	numericAlpn := newClientHello([]uint16{0x002f}, []testExtension{{0x0010, vector16([]byte{1, 0xab})}})
+
+	tests := []struct {
+		name    string
+		payload []byte
+		ja3     string
+		ja4     string
+	}{
+		{
+			"chrome",
+			newChromeClientHello(),
+			"771,4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-49172-156-157-47-53,0-23-65281-10-11-35-16-5-13-18-51-45-43-27-17513-21,29-23-24,0",
+			"t13d1516h2_8daaf6152771_e5627efa2ab1",
+		},
+		{"no extensions", noExtensions, "771,47,,,", "t12i010000_ba72b8082249_000000000000"},
+		{"hex alpn", numericAlpn, "771,47,16,,", "t12i0101ab_ba72b8082249_000000000000"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hello, ok := parseClientHello(test.payload)
+			if !ok {
+				t.Fatal("the ClientHello isn't parsed")
+			}
+
+			if got := hello.ja3String(); got != test.ja3 {
+				t.Errorf("got JA3 %q, want %q", got, test.ja3)
+			}
+
+			if got := hello.ja4(); got != test.ja4 {
+				t.Errorf("got JA4 %q, want %q", got, test.ja4)
+			}
+		})
+	}
+}
+
+func TestTlsFingerprintInvalid(t *testing.T) {
+	hello := newChromeClientHello()
+	serverHello := append([]byte{}, hello...)
+	serverHello[tlsRecordHeaderLength] = tlsHandshakeServerHelo
+
+	tests := map[string][]byte{
+		"truncated":    hello[:len(hello)-10],
+		"server hello": serverHello,
+		"not tls":      []byte("GET / HTTP/1.1\r\n\r\n"),
+		"empty":        nil,
+	}
+
+	for name, payload := range tests {
+		if _, ok := getTlsFingerprint(payload); ok {
+			t.Errorf("got a fingerprint of the %s payload", name)
+		}
+	}
+}
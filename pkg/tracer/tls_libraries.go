@@ -0,0 +1,219 @@
+package tracer
+
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/go-errors/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// tlsLibrary is a TLS library besides OpenSSL whose write and read functions take the context,
+// the buffer and its length, and return the number of bytes, so they are probed the way
+// SSL_write and SSL_read are. Each library is a probe group of its own.
+type tlsLibrary struct {
+	group string
+	// The shared library in the memory map of a process
+	fileRegex *regexp.Regexp
+	// The mapped shared objects that may link the library statically, they are used if they
+	// define writeSymbol. nil if there are none.
+	embeddingRegex *regexp.Regexp
+	writeSymbol    string
+	readSymbol     string
+	programs       func(bpfObjects *tracerObjects) tlsLibraryPrograms
+	// Called after the uprobes are attached to a file of a process, nil if nothing is needed
+	prepare func(t *Tracer, pid uint32, path string) error
+}
+
+type tlsLibraryPrograms struct {
+	write    *ebpf.Program
+	writeRet *ebpf.Program
+	read     *ebpf.Program
+	readRet  *ebpf.Program
+}
+
+var tlsLibraries = []*tlsLibrary{mbedtlsLibrary, wolfsslLibrary, s2nLibrary}
+
+func getTlsLibrary(group string) *tlsLibrary {
+	for _, library := range tlsLibraries {
+		if library.group == group {
+			return library
+		}
+	}
+
+	return nil
+}
+
+func tlsLibraryGroups() []string {
+	groups := make([]string, 0, len(tlsLibraries))
+	for _, library := range tlsLibraries {
+		groups = append(groups, library.group)
+	}
+
+	return groups
+}
+
+func isTlsLibrarySymbol(symbol string) bool {
+	for _, library := range tlsLibraries {
+		if symbol == library.writeSymbol || symbol == library.readSymbol {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findTlsLibraryFiles returns the mapped shared libraries of the TLS library and the shared
+// objects that link it statically, or the executable if the library is linked into it
+// statically, e.g. in the embedded services
+func findTlsLibraryFiles(procfs string, pid uint32, library *tlsLibrary) ([]string, error) {
+	paths, err := getMappedPaths(procfs, pid)
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	files := make([]string, 0)
+	for _, path := range paths {
+		fullpath := fmt.Sprintf("%v/%v/root%v", procfs, pid, path)
+
+		switch {
+		case library.fileRegex.MatchString(path):
+			if _, err := os.Stat(fullpath); os.IsNotExist(err) {
+				continue
+			}
+		case library.embeddingRegex != nil && library.embeddingRegex.MatchString(path):
+			if !definesSymbol(fullpath, library.writeSymbol) {
+				continue
+			}
+		default:
+			continue
+		}
+
+		files = append(files, fullpath)
+	}
+
+	if len(files) > 0 {
+		return files, nil
+	}
+
+	binary, err := os.Readlink(fmt.Sprintf("%v/%v/exe", procfs, pid))
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	fullpath := fmt.Sprintf("%v/%v/root%v", procfs, pid, binary)
+	if definesSymbol(fullpath, library.writeSymbol) {
+		files = append(files, fullpath)
+	}
+
+	return files, nil
+}
+
+func definesSymbol(path string, name string) bool {
+	file, err := elf.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	symbol, err := getSymbol(file, name)
+	return err == nil && symbol.Section != elf.SHN_UNDEF
+}
+
+type tlsLibraryHooks struct {
+	group  string
+	probes []link.Link
+}
+
+func (s *tlsLibraryHooks) installUprobes(bpfObjects *tracerObjects, library *tlsLibrary, path string, overrides []SymbolOffset) error {
+	executable, err := link.OpenExecutable(path)
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	s.group = library.group
+	programs := library.programs(bpfObjects)
+
+	attaches := []struct {
+		symbol  string
+		program *ebpf.Program
+		ret     bool
+	}{
+		{library.writeSymbol, programs.write, false},
+		{library.writeSymbol, programs.writeRet, true},
+		{library.readSymbol, programs.read, false},
+		{library.readSymbol, programs.readRet, true},
+	}
+
+	for _, attach := range attaches {
+		options, err := getUprobeOptions(overrides, path, attach.symbol)
+		if err != nil {
+			s.close()
+			return err
+		}
+
+		var probe link.Link
+		if attach.ret {
+			probe, err = executable.Uretprobe(attach.symbol, attach.program, options)
+		} else {
+			probe, err = executable.Uprobe(attach.symbol, attach.program, options)
+		}
+
+		if err != nil {
+			s.close()
+			return errors.Wrap(err, 0)
+		}
+
+		s.probes = append(s.probes, probe)
+	}
+
+	return nil
+}
+
+func (s *tlsLibraryHooks) close() []error {
+	returnValue := make([]error, 0)
+
+	for _, probe := range s.probes {
+		if err := probe.Close(); err != nil {
+			returnValue = append(returnValue, err)
+		}
+	}
+
+	s.probes = nil
+
+	return returnValue
+}
+
+func (t *Tracer) targetTlsLibraryPid(pid uint32, library *tlsLibrary, path string) error {
+	if err := t.checkProbeGroup(library.group); err != nil {
+		return err
+	}
+
+	hooks := tlsLibraryHooks{}
+
+	if err := hooks.installUprobes(&t.bpfObjects, library, path, t.config.SymbolOffsets); err != nil {
+		return err
+	}
+
+	t.tlsLibraryHooksStructs = append(t.tlsLibraryHooksStructs, hooks)
+
+	if library.prepare != nil {
+		if err := library.prepare(t, pid, path); err != nil {
+			return err
+		}
+	}
+
+	log.Info().Int("pid", int(pid)).Str("library", library.group).Str("path", path).Msg("Targeting TLS:")
+
+	if err := t.bpfObjects.tracerMaps.PidsMap.Put(pid, uint32(1)); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	t.registeredPids.Store(pid, true)
+
+	return nil
+}
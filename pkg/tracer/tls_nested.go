@@ -0,0 +1,59 @@
+package tracer
+
+import "net/http"
+
+const (
+	tlsRecordHeaderLength  = 5
+	tlsContentHandshake    = 0x16
+	tlsMajorVersion        = 0x03
+	tlsMaxMinorVersion     = 0x04
+	tlsHandshakeClientHelo = 0x01
+	tlsHandshakeServerHelo = 0x02
+)
+
+// isNestedTls reports whether the decrypted data starts with a TLS handshake record,
+// which means the plaintext of the outer TLS connection is itself TLS (stunnel, HTTPS
+// proxying, etc.). If the inner TLS library is probed as well, its plaintext is captured
+// on its own stream, otherwise the payload is unreadable.
+func isNestedTls(data []byte) bool {
+	if len(data) < tlsRecordHeaderLength+1 {
+		return false
+	}
+
+	if data[0] != tlsContentHandshake || data[1] != tlsMajorVersion || data[2] > tlsMaxMinorVersion {
+		return false
+	}
+
+	handshakeType := data[tlsRecordHeaderLength]
+
+	return handshakeType == tlsHandshakeClientHelo || handshakeType == tlsHandshakeServerHelo
+}
+
+// The states of an HTTP CONNECT exchange of a stream, a proxy tunnels another connection,
+// usually TLS, once it answers with 2xx
+const (
+	connectNone = iota
+	connectRequested
+	connectEstablished
+)
+
+// updateConnectState follows the CONNECT exchange at the start of a stream, it returns true
+// for the first chunk that the client sends into the tunnel
+func updateConnectState(state *int, data []byte, isClient bool, firstChunk bool) bool {
+	switch {
+	case *state == connectNone && isClient && firstChunk:
+		if message, _ := parseHttpMessage(data); message != nil && message.Method == http.MethodConnect {
+			*state = connectRequested
+		}
+	case *state == connectRequested && !isClient:
+		*state = connectNone
+		if message, _ := parseHttpMessage(data); message != nil && message.Status/100 == 2 {
+			*state = connectEstablished
+		}
+	case *state == connectEstablished && isClient:
+		*state = connectNone
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,79 @@
+package tracer
+
+import "testing"
+
+func TestIsNestedTls(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"client hello", []byte{0x16, 0x03, 0x01, 0x00, 0xc8, 0x01, 0x00}, true},
+		{"server hello", []byte{0x16, 0x03, 0x03, 0x00, 0x5a, 0x02, 0x00}, true},
+		{"finished", []byte{0x16, 0x03, 0x03, 0x00, 0x5a, 0x14, 0x00}, false},
+		{"application data", []byte{0x17, 0x03, 0x03, 0x00, 0x20, 0x01}, false},
+		{"bad version", []byte{0x16, 0x03, 0x05, 0x00, 0x20, 0x01}, false},
+		{"short", []byte{0x16, 0x03, 0x01, 0x00, 0xc8}, false},
+		{"http", []byte("GET / HTTP/1.1\r\n"), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isNestedTls(test.data); got != test.want {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUpdateConnectState(t *testing.T) {
+	type chunk struct {
+		data     string
+		isClient bool
+	}
+
+	tests := []struct {
+		name   string
+		chunks []chunk
+		// The index of the chunk that starts the tunnel, -1 if none
+		start int
+	}{
+		{"established", []chunk{
+			{"CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\n\r\n", true},
+			{"HTTP/1.1 200 Connection established\r\n\r\n", false},
+			{"\x16\x03\x01", true},
+		}, 2},
+		{"refused", []chunk{
+			{"CONNECT example.com:443 HTTP/1.1\r\n\r\n", true},
+			{"HTTP/1.1 407 Proxy Authentication Required\r\n\r\n", false},
+			{"CONNECT example.com:443 HTTP/1.1\r\n\r\n", true},
+		}, -1},
+		{"not connect", []chunk{
+			{"GET / HTTP/1.1\r\n\r\n", true},
+			{"HTTP/1.1 200 OK\r\n\r\n", false},
+			{"GET / HTTP/1.1\r\n\r\n", true},
+		}, -1},
+		{"connect later", []chunk{
+			{"GET / HTTP/1.1\r\n\r\n", true},
+			{"CONNECT example.com:443 HTTP/1.1\r\n\r\n", true},
+			{"HTTP/1.1 200 OK\r\n\r\n", false},
+			{"\x16\x03\x01", true},
+		}, -1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state := connectNone
+			clientChunks := 0
+			for i, c := range test.chunks {
+				if c.isClient {
+					clientChunks++
+				}
+
+				if got := updateConnectState(&state, []byte(c.data), c.isClient, c.isClient && clientChunks == 1); got != (i == test.start) {
+					t.Fatalf("chunk %d: got %v, want %v", i, got, i == test.start)
+				}
+			}
+		})
+	}
+}
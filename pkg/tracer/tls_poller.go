@@ -0,0 +1,494 @@
+package tracer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cilium/ebpf/perf"
+	"github.com/go-errors/errors"
+	"github.com/hashicorp/golang-lru/simplelru"
+	"github.com/kubeshark/tracer/misc"
+)
+
+const (
+	fdCachedItemAvgSize = 40
+	defaultFdCacheSize  = 500000 / fdCachedItemAvgSize
+	// The chunks reader wakes up at least this often to notice the shutdown
+	chunksPollTimeout = 100 * time.Millisecond
+)
+
+type tlsPoller struct {
+	tls            *Tracer
+	streams        map[string]*tlsStream
+	closeStreams   chan string
+	chunksReader   chunksReader
+	readerLock     sync.Mutex
+	bufferSize     int
+	ringbuf        bool
+	procfs         string
+	fdCache        *simplelru.LRU // Actual type is map[string]addressPair
+	evictedCounter int
+	sorter         *PacketSorter
+	throttle       *cpuThrottle
+	quota          *namespaceQuota
+	chaos          *chaos
+	payload        *payloadPolicy
+	labels         *labelExtractor
+	plugins        *dissectorPlugins
+	hold           *enrichmentHold
+	skippedStreams *simplelru.LRU
+	shedding       *streamShedding
+	keylog         *keylogWriter
+	// Nil unless the handshakes are captured
+	fingerprints *tlsFingerprints
+	// For the health checks, lastPoll is in Unix nanoseconds
+	polling  atomic.Bool
+	lastPoll atomic.Int64
+}
+
+func newTlsPoller(
+	tls *Tracer,
+	procfs string,
+) (*tlsPoller, error) {
+	sortedPackets := make(chan *SortedPacket, misc.PacketChannelBufferSize)
+	poller := &tlsPoller{
+		tls:          tls,
+		streams:      make(map[string]*tlsStream),
+		closeStreams: make(chan string, misc.TlsCloseChannelBufferSize),
+		chunksReader: nil,
+		procfs:       procfs,
+		sorter:       NewPacketSorter(sortedPackets),
+		throttle:     newCpuThrottle(tls.config.MaxCpu),
+		quota:        newNamespaceQuota(tls.config.NamespaceQuotaBytes, tls.config.NamespaceQuotaTransactions, tls.config.NamespaceQuotaWindow),
+		chaos:        newChaos(&tls.config),
+		hold:         newEnrichmentHold(tls.config.EnrichmentHoldTime, tls.config.EnrichmentHoldChunks),
+	}
+
+	fdCache, err := simplelru.NewLRU(tls.config.FdCacheSize, poller.fdCacheEvictCallback)
+
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	poller.fdCache = fdCache
+
+	skippedStreams, err := simplelru.NewLRU(tls.config.FdCacheSize, nil)
+
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	poller.skippedStreams = skippedStreams
+
+	poller.shedding, err = newStreamShedding(tls.config.MaxStreams)
+
+	if err != nil {
+		return nil, err
+	}
+
+	poller.payload, err = newPayloadPolicy(&tls.config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	poller.labels, err = newLabelExtractor(tls.config.LabelRules)
+
+	if err != nil {
+		return nil, err
+	}
+
+	poller.plugins, err = loadDissectorPlugins(tls.config.DissectorPlugins)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing is decrypted with the secrets if only the metadata is captured
+	if len(tls.config.BioCapturePids) > 0 && !tls.config.MetadataOnly {
+		poller.keylog, err = newKeylogWriter()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return poller, nil
+}
+
+func (p *tlsPoller) init(bpfObjects *tracerObjects, bufferSize int, ringbuf bool) error {
+	p.bufferSize = bufferSize
+	p.ringbuf = ringbuf
+
+	if ringbuf {
+		reader, err := newRingbufChunksReader(bpfObjects)
+		if err != nil {
+			return err
+		}
+
+		p.chunksReader = reader
+		return nil
+	}
+
+	reader, err := perf.NewReader(bpfObjects.ChunksBuffer, bufferSize)
+
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	p.chunksReader = perfChunksReader{reader}
+
+	return nil
+}
+
+func (p *tlsPoller) close() error {
+	if p.keylog != nil {
+		if err := p.keylog.close(); err != nil {
+			LogError(errors.Wrap(err, 0))
+		}
+	}
+
+	if err := p.plugins.close(); err != nil {
+		LogError(errors.Wrap(err, 0))
+	}
+
+	return p.getChunksReader().Close()
+}
+
+// poll handles the chunks until ctx is done and the chunks that are already in the perf
+// buffer are drained, then flushes the streams. ctx is expected to be done after the
+// probes are detached, otherwise the chunks produced meanwhile are lost.
+func (p *tlsPoller) poll(ctx context.Context, streamsMap *TcpStreamMap) {
+	// tracerTlsChunk is generated by bpf2go.
+	chunks := make(chan *tracerTlsChunk)
+
+	if p.tls.config.Checkpoint {
+		if err := p.restoreCheckpoint(streamsMap); err != nil {
+			LogError(err)
+		}
+	}
+
+	if p.throttle.isEnabled() {
+		go p.throttle.watch(ctx)
+	}
+
+	if p.chaos.isEnabled() {
+		pollerLog.get().Warn().Msg("Chaos mode is enabled, chunks are degraded on purpose")
+	}
+
+	go p.pollChunksPerfBuffer(ctx, chunks)
+
+	var ready <-chan struct{}
+	var holdTimeout <-chan time.Time
+	if p.hold.start() {
+		ready = p.hold.ready
+		timer := time.NewTimer(p.hold.holdTime)
+		defer timer.Stop()
+		holdTimeout = timer.C
+	}
+
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				if p.hold.isHolding() {
+					p.handleChunks(p.hold.release("stopped"), streamsMap)
+				}
+				p.flush(streamsMap)
+				return
+			}
+
+			if p.hold.isHolding() {
+				if chunk = p.hold.add(chunk); chunk == nil {
+					continue
+				}
+			}
+
+			p.handleChunk(chunk, streamsMap)
+		case <-ready:
+			ready, holdTimeout = nil, nil
+			p.handleChunks(p.hold.release("ready"), streamsMap)
+		case <-holdTimeout:
+			ready, holdTimeout = nil, nil
+			p.handleChunks(p.hold.release("timeout"), streamsMap)
+		case key := <-p.closeStreams:
+			p.untrackStream(key)
+		}
+	}
+}
+
+func (p *tlsPoller) handleChunks(chunks []*tracerTlsChunk, streamsMap *TcpStreamMap) {
+	for _, chunk := range chunks {
+		p.handleChunk(chunk, streamsMap)
+	}
+}
+
+func (p *tlsPoller) handleChunk(chunk *tracerTlsChunk, streamsMap *TcpStreamMap) {
+	if chunk.isExit() {
+		p.handleProcessExit(chunk.Pid, streamsMap)
+		return
+	}
+
+	if chunk.isKeylog() {
+		if p.keylog != nil {
+			p.keylog.handleSecret(chunk)
+		}
+		return
+	}
+
+	// The secrets of a peer whose payload isn't captured aren't written either
+	if chunk.isCiphertext() && p.keylog != nil {
+		peer, _ := chunk.getDstAddress()
+		p.keylog.handleRecords(chunk, p.payload.allows(peer))
+	}
+
+	if !p.chaos.isEnabled() {
+		if err := p.handleTlsChunk(chunk, streamsMap); err != nil {
+			LogError(err)
+		}
+		return
+	}
+
+	for _, chunk := range p.chaos.apply(chunk) {
+		if err := p.handleTlsChunk(chunk, streamsMap); err != nil {
+			LogError(err)
+		}
+	}
+}
+
+func (p *tlsPoller) flush(streamsMap *TcpStreamMap) {
+	for _, chunk := range p.chaos.flush() {
+		if err := p.handleTlsChunk(chunk, streamsMap); err != nil {
+			LogError(err)
+		}
+	}
+
+	if p.tls.config.Checkpoint {
+		if err := p.saveCheckpoint(); err != nil {
+			LogError(err)
+		}
+	}
+
+	p.sorter.Close()
+}
+
+func (p *tlsPoller) pollChunksPerfBuffer(ctx context.Context, chunks chan<- *tracerTlsChunk) {
+	pollerLog.get().Info().Msg("Start polling for tls events")
+
+	p.polling.Store(true)
+	defer p.polling.Store(false)
+
+	for {
+		p.lastPoll.Store(time.Now().UnixNano())
+
+		// The pinned hooks stay attached, the chunks in the buffer are left to the next tracer
+		if p.tls.config.PinPath != "" && ctx.Err() != nil {
+			pollerLog.get().Info().Msg("Left the tls chunks buffer to the next tracer")
+			close(chunks)
+			return
+		}
+
+		reader := p.getChunksReader()
+		reader.SetDeadline(time.Now().Add(chunksPollTimeout))
+		sample, lost, err := reader.read()
+
+		if err != nil {
+			// The buffer is drained once it's idle after the shutdown
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				if ctx.Err() == nil {
+					continue
+				}
+
+				pollerLog.get().Info().Msg("Drained the tls chunks buffer")
+				close(chunks)
+				return
+			}
+
+			// The reader was replaced by SetChunksBufferSize
+			if errors.Is(err, perf.ErrClosed) && reader != p.getChunksReader() {
+				continue
+			}
+
+			close(chunks)
+
+			if errors.Is(err, perf.ErrClosed) {
+				return
+			}
+
+			LogError(errors.Errorf("Error reading chunks from tls buffer, aborting TLS! %v", err))
+			return
+		}
+
+		if lost != 0 {
+			pollerLog.get().Info().Msg(fmt.Sprintf("Buffer is full, dropped %d chunks", lost))
+			continue
+		}
+
+		buffer := bytes.NewReader(sample)
+
+		var chunk tracerTlsChunk
+
+		if err := binary.Read(buffer, hostByteOrder, &chunk); err != nil {
+			LogError(errors.Errorf("Error parsing chunk %v", err))
+			continue
+		}
+
+		chunks <- &chunk
+	}
+}
+
+func (p *tlsPoller) handleTlsChunk(chunk *tracerTlsChunk, streamsMap *TcpStreamMap) error {
+	if p.tls.isPaused() {
+		return nil
+	}
+
+	target := p.tls.getPidTarget(chunk.Pid)
+
+	address := chunk.getAddressPair()
+
+	// Creates one *tlsStream per TCP stream, or per UDP peers
+	key := buildTlsKey(address, chunk.isRequest())
+	if chunk.isUdp() {
+		key = "udp:" + key
+	}
+	stream, streamExists := p.streams[key]
+	if !streamExists {
+		if p.isSkippedStream(key, time.Now()) {
+			return nil
+		}
+
+		// Skipped like the throttled streams, the quota is checked again after a while
+		if admitted, exceeded := p.quota.admit(target.namespace, time.Now()); !admitted {
+			p.skippedStreams.Add(key, time.Now())
+			if exceeded != nil {
+				p.tls.Publish(newQuotaExceededEvent(exceeded))
+			}
+			return nil
+		}
+
+		if !p.throttle.admit() {
+			p.skippedStreams.Add(key, time.Now())
+			return nil
+		}
+
+		stream = NewTlsStream(p, key)
+		stream.setId(streamsMap.NextId())
+		stream.namespace = target.namespace
+		stream.workload = target.workload
+		stream.meshLeg = target.meshLeg
+		stream.udp = chunk.isUdp()
+		if len(p.tls.coexisting) > 0 {
+			pollerLog.get().Info().Int64("stream", stream.getId()).Str("dedup-key", buildDedupKey(chunk.Pid, chunk.Fd, key)).Msg("New stream:")
+		}
+		pollerLog.get().Debug().Int64("stream", stream.getId()).Str("key", key).Stringer("origin", chunk.getOrigin()).
+			Str("source", chunk.getOrigin().AddressSource()).Msg("New stream from probe:")
+		if stream.meshLeg != "" {
+			pollerLog.get().Debug().Int64("stream", stream.getId()).Str("key", key).Str("leg", stream.meshLeg).Msg("New stream of meshed pod:")
+		}
+		stream.client = NewTlsReader(p.buildTcpId(address, true), stream, true)
+		stream.server = NewTlsReader(p.buildTcpId(address, false), stream, false)
+		// Stored once complete, Annotate reads it from the other goroutines
+		streamsMap.Store(stream.getId(), stream)
+		p.trackStream(key, stream, streamsMap)
+	} else {
+		p.touchStream(key)
+	}
+
+	stream.pids[chunk.Pid] = true
+
+	detected := false
+	if stream.protocol == "" && chunk.Recorded > 0 {
+		stream.protocol = detectProtocol(chunk.getRecordedData())
+		stream.fanout.generation = 0
+		detected = true
+	}
+
+	// The peer is the destination of the socket of the process
+	peer, _ := chunk.getDstAddress()
+	allowed := p.payload.allows(peer)
+
+	// The plugins only see the payloads that the policy allows
+	if detected && allowed && stream.protocol == ProtocolUnknown {
+		if protocol := p.plugins.detectProtocol(chunk.getRecordedData()); protocol != "" {
+			stream.protocol = protocol
+		}
+	}
+	if detected {
+		dissectorsLog.get().Debug().Int64("stream", stream.getId()).Str("protocol", stream.protocol).Msg("Detected protocol:")
+	}
+
+	if stream.fingerprint == nil && p.fingerprints != nil && !stream.udp {
+		stream.fingerprint = p.fingerprints.get(chunk)
+	}
+
+	event := newEvent(chunk, stream, target)
+
+	if !allowed {
+		event.Data = nil
+		p.tls.emit(stream, event)
+		return nil
+	}
+
+	reader := chunk.getReader(stream)
+	reader.newChunk(chunk)
+
+	switch stream.protocol {
+	case ProtocolHttp1:
+		event.Http, _ = parseHttpMessage(event.Data)
+	case ProtocolHttp2, ProtocolTls, ProtocolUnknown:
+	default:
+		event.Fields = p.plugins.dissect(stream.protocol, event.Data)
+	}
+
+	// The streams of the other protocols count as a transaction each
+	transactions := uint64(0)
+	if event.Http != nil && event.Http.Method != "" || detected && stream.protocol != ProtocolHttp1 {
+		transactions = 1
+	}
+	p.quota.add(stream.namespace, uint64(chunk.Recorded), transactions, time.Now())
+	event.Labels = stream.addLabels(p.labels.extract(event.Data))
+	p.tls.emit(stream, event)
+
+	return nil
+}
+
+func buildTlsKey(address *addressPair, isRequest bool) string {
+	if isRequest {
+		return fmt.Sprintf("%s:%d>%s:%d", address.srcIp, address.srcPort, address.dstIp, address.dstPort)
+	} else {
+		return fmt.Sprintf("%s:%d>%s:%d", address.dstIp, address.dstPort, address.srcIp, address.srcPort)
+	}
+}
+
+func (p *tlsPoller) buildTcpId(address *addressPair, isRequest bool) *TcpID {
+	if isRequest {
+		return &TcpID{
+			SrcIP:   address.srcIp.String(),
+			DstIP:   address.dstIp.String(),
+			SrcPort: strconv.FormatUint(uint64(address.srcPort), 10),
+			DstPort: strconv.FormatUint(uint64(address.dstPort), 10),
+		}
+	} else {
+		return &TcpID{
+			SrcIP:   address.dstIp.String(),
+			DstIP:   address.srcIp.String(),
+			SrcPort: strconv.FormatUint(uint64(address.dstPort), 10),
+			DstPort: strconv.FormatUint(uint64(address.srcPort), 10),
+		}
+	}
+}
+
+func (p *tlsPoller) fdCacheEvictCallback(key interface{}, value interface{}) {
+	p.evictedCounter = p.evictedCounter + 1
+
+	if p.evictedCounter%1000000 == 0 {
+		pollerLog.get().Info().Msg(fmt.Sprintf("Tls fdCache evicted %d items", p.evictedCounter))
+	}
+}
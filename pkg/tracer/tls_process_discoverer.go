@@ -1,4 +1,4 @@
-package main
+package tracer
 
 import (
 	"fmt"
@@ -10,6 +10,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-errors/errors"
 	"github.com/rs/zerolog/log"
@@ -18,33 +19,94 @@ import (
 
 var numberRegex = regexp.MustCompile("[0-9]+")
 
-func UpdateTargets(pods []v1.Pod) error {
-	containerIds := buildContainerIdsMap(pods)
-	log.Debug().Interface("container-ids", containerIds).Send()
+// UpdateTargets replaces the targeted processes with the processes of the containers of the pods
+func (t *Tracer) UpdateTargets(pods []v1.Pod) error {
+	t.targetsLock.Lock()
+	defer t.targetsLock.Unlock()
+
+	t.pods = pods
+	t.poller.payload.setPods(pods)
 
-	containerPids, err := findContainerPids(tracer.procfs, containerIds)
+	containerPids, err := findTargetPids(t.procfs, pods, &t.config)
 	if err != nil {
 		return err
 	}
 
 	log.Info().Interface("pids", reflect.ValueOf(containerPids).MapKeys()).Send()
 
-	tracer.ClearPids()
+	t.ClearPids()
 
-	// TODO: CAUSES INITIAL MEMORY SPIKE
-	for pid := range containerPids {
-		if err := tracer.AddSSLLibPid(tracer.procfs, pid); err != nil {
-			LogError(err)
+	pids := make([]uint32, 0, len(containerPids))
+	for pid, pod := range containerPids {
+		// Only the containers of the targeted leg are left in a meshed pod
+		leg := ""
+		if isMeshedPod(&pod) {
+			leg = t.config.MeshLeg
 		}
 
-		if err := tracer.AddGoPid(tracer.procfs, pid); err != nil {
+		t.setPidTarget(pid, pod.Namespace, getWorkloadName(&pod), leg)
+		pids = append(pids, pid)
+	}
+
+	if t.handshakes != nil {
+		t.handshakes.setTargets(t.procfs, pids)
+	}
+
+	// TODO: CAUSES INITIAL MEMORY SPIKE
+	start := time.Now()
+	attached, skipped, failed, timedOut := 0, 0, 0, 0
+	for _, result := range t.analyzeTargets(pids, t.config.AnalysisWorkers, t.config.AnalysisTimeout) {
+		ok, err := t.attachTarget(result)
+		switch {
+		case result.timedOut:
+			timedOut++
+			log.Warn().Int("pid", int(result.pid)).Dur("timeout", t.config.AnalysisTimeout).Msg("Analysis of PID timed out, skipped:")
+		case err != nil:
+			failed++
 			LogError(err)
+		case ok:
+			attached++
+		default:
+			skipped++
 		}
 	}
 
+	log.Info().
+		Int("attached", attached).
+		Int("skipped", skipped).
+		Int("failed", failed).
+		Int("timed-out", timedOut).
+		Dur("took", time.Since(start)).
+		Msg("Targets updated:")
+
 	return nil
 }
 
+// findTargetPids returns the processes of the pods, of the configured cgroups and the
+// configured PIDs, the pod is empty for the latter two
+func findTargetPids(procfs string, pods []v1.Pod, config *Config) (map[uint32]v1.Pod, error) {
+	containerIds := buildContainerIdsMap(pods, config.MeshLeg)
+	for _, cgroup := range config.Cgroups {
+		if _, ok := containerIds[cgroup]; !ok {
+			containerIds[cgroup] = v1.Pod{}
+		}
+	}
+	log.Debug().Interface("container-ids", containerIds).Send()
+
+	containerPids, err := findContainerPids(procfs, containerIds)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pid := range config.Pids {
+		if _, ok := containerPids[pid]; !ok {
+			containerPids[pid] = v1.Pod{}
+		}
+	}
+
+	return containerPids, nil
+}
+
 func findContainerPids(procfs string, containerIds map[string]v1.Pod) (map[uint32]v1.Pod, error) {
 	result := make(map[uint32]v1.Pod)
 
@@ -88,11 +150,16 @@ func findContainerPids(procfs string, containerIds map[string]v1.Pod) (map[uint3
 	return result, nil
 }
 
-func buildContainerIdsMap(pods []v1.Pod) map[string]v1.Pod {
+func buildContainerIdsMap(pods []v1.Pod, meshLeg string) map[string]v1.Pod {
 	result := make(map[string]v1.Pod)
 
 	for _, pod := range pods {
 		for _, container := range pod.Status.ContainerStatuses {
+			if leg := getMeshLeg(&pod, container.Name); leg != "" && leg != meshLeg {
+				log.Debug().Str("pod", pod.Name).Str("container", container.Name).Str("leg", leg).Msg("Skipping the other leg of meshed pod:")
+				continue
+			}
+
 			parsedUrl, err := url.Parse(container.ContainerID)
 			if err != nil {
 				log.Warn().Msg(fmt.Sprintf("Expecting URL like container ID %v", container.ContainerID))
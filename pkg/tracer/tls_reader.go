@@ -0,0 +1,82 @@
+package tracer
+
+import (
+	"time"
+)
+
+type TcpID struct {
+	SrcIP   string
+	DstIP   string
+	SrcPort string
+	DstPort string
+}
+
+type seqNumbers struct {
+	Seq uint32
+	Ack uint32
+}
+
+type tlsReader struct {
+	seenChunks  int
+	tcpID       *TcpID
+	isClient    bool
+	captureTime time.Time
+	parent      *tlsStream
+	seqNumbers  *seqNumbers
+}
+
+func NewTlsReader(tcpID *TcpID, parent *tlsStream, isClient bool) *tlsReader {
+	return &tlsReader{
+		tcpID:       tcpID,
+		isClient:    isClient,
+		captureTime: time.Now(),
+		parent:      parent,
+		seqNumbers:  &seqNumbers{},
+	}
+}
+
+func (r *tlsReader) newChunk(chunk *tracerTlsChunk) {
+	r.captureTime = time.Now()
+	r.seenChunks = r.seenChunks + 1
+
+	data := chunk.getRecordedData()
+
+	if r.isClient && (r.seenChunks == 1 || r.parent.proxyPending != nil) {
+		data = r.parent.handleProxyHeader(data)
+		if len(data) == 0 {
+			return
+		}
+	}
+
+	// The first chunk of a CONNECT tunnel is checked like the first one of the stream
+	tunnelStart := updateConnectState(&r.parent.connect, data, r.isClient, r.seenChunks == 1)
+
+	// The records captured at BIO level are TLS, they aren't nested
+	if (r.seenChunks == 1 || tunnelStart) && !r.parent.isNested && !chunk.isCiphertext() && isNestedTls(data) {
+		r.parent.isNested = true
+		pollerLog.get().Warn().
+			Int64("stream", r.parent.getId()).
+			Str("key", r.parent.key).
+			Uint32("pid", chunk.Pid).
+			Bool("skipped", r.parent.poller.tls.config.SkipNestedTls).
+			Msg("Decrypted payload is TLS, the stream is tunneling another TLS connection:")
+	}
+
+	if r.parent.isNested && r.parent.poller.tls.config.SkipNestedTls {
+		return
+	}
+
+	r.parent.writeData(data, r)
+}
+
+func (r *tlsReader) GetIsClient() bool {
+	return r.isClient
+}
+
+func (r *tlsReader) GetCaptureTime() time.Time {
+	return r.captureTime
+}
+
+func (r *tlsReader) GetIsClosed() bool {
+	return false
+}
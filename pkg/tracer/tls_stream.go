@@ -1,16 +1,16 @@
-package main
+package tracer
 
 import (
 	"net"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kubeshark/gopacket"
 	"github.com/kubeshark/gopacket/layers"
 	"github.com/kubeshark/tracer/misc"
 	"github.com/kubeshark/tracer/misc/ethernet"
-	"github.com/rs/zerolog/log"
 )
 
 type tlsLayers struct {
@@ -34,6 +34,29 @@ type tlsStream struct {
 	client    *tlsReader
 	server    *tlsReader
 	layers    *tlsLayers
+	isResumed bool
+	// Of the pod of the process that started the stream
+	namespace string
+	workload  string
+	meshLeg   string
+	isNested  bool
+	// The start of the client's data that may be a PROXY protocol header
+	proxyPending []byte
+	// The state of a CONNECT exchange, its tunnel is checked for nested TLS
+	connect int
+	// Written as datagrams without the TCP handshake, layers stays nil
+	udp bool
+	// The processes that had chunks of the stream, it's closed when all of them exit
+	pids map[uint32]bool
+	// Detected by the first chunk with a payload
+	protocol string
+	// Found by the label rules in the chunks of both directions
+	labels map[string]string
+	// Of the captured ClientHello of the connection
+	fingerprint *TlsFingerprint
+	fanout      streamFanout
+	// Attached by the consumers with Tracer.Annotate, from their goroutines
+	annotations atomic.Pointer[[]string]
 	sync.Mutex
 }
 
@@ -41,6 +64,7 @@ func NewTlsStream(poller *tlsPoller, key string) *tlsStream {
 	return &tlsStream{
 		poller: poller,
 		key:    key,
+		pids:   make(map[uint32]bool),
 	}
 }
 
@@ -105,7 +129,42 @@ func (t *tlsStream) doTcpHandshake() {
 	t.server.seqNumbers.Ack = 1
 }
 
+// doTcpTeardown writes the FIN of both sides, the stream must not be written afterwards
+func (t *tlsStream) doTcpTeardown() {
+	t.isClosed = true
+
+	// Nothing was written yet
+	if t.layers == nil {
+		return
+	}
+
+	data := []byte{}
+	t.setLayers(data, t.client)
+
+	// FIN-ACK
+	t.layers.tcp.FIN = true
+	t.layers.tcp.ACK = true
+	t.loadSecNumbers(true)
+	t.writeLayers(data, true, 1)
+
+	// FIN-ACK
+	t.layers.swap()
+	t.loadSecNumbers(false)
+	t.writeLayers(data, false, 1)
+
+	// ACK
+	t.layers.swap()
+	t.layers.tcp.FIN = false
+	t.loadSecNumbers(true)
+	t.writeLayers(data, true, 0)
+}
+
 func (t *tlsStream) writeData(data []byte, reader *tlsReader) {
+	if t.udp {
+		t.writeDatagram(data, reader)
+		return
+	}
+
 	t.setLayers(data, reader)
 	t.layers.tcp.ACK = true
 	if reader.isClient {
@@ -122,6 +181,25 @@ func (t *tlsStream) writeData(data []byte, reader *tlsReader) {
 	t.writeLayers([]byte{}, !reader.isClient, 0)
 }
 
+// writeDatagram writes the data of a UDP stream, e.g. DTLS, as a datagram
+func (t *tlsStream) writeDatagram(data []byte, reader *tlsReader) {
+	ipv4 := t.newIPv4Layer(reader)
+	ipv4.Protocol = layers.IPProtocolUDP
+	udp := t.newUDPLayer(reader)
+	err := udp.SetNetworkLayerForChecksum(ipv4)
+	if err != nil {
+		sorterLog.get().Error().Err(err).Send()
+	}
+
+	t.writePacket(
+		layers.LayerTypeEthernet,
+		ethernet.NewEthernetLayer(layers.EthernetTypeIPv4),
+		ipv4,
+		udp,
+		gopacket.Payload(data),
+	)
+}
+
 func (t *tlsStream) writeLayers(data []byte, isClient bool, sentLen uint32) {
 	t.writePacket(
 		layers.LayerTypeEthernet,
@@ -141,7 +219,7 @@ func (t *tlsStream) writePacket(firstLayerType gopacket.LayerType, l ...gopacket
 	}
 	err := gopacket.SerializeLayers(buf, opts, l...)
 	if err != nil {
-		log.Error().Err(err).Msg("Error serializing packet:")
+		sorterLog.get().Error().Err(err).Msg("Error serializing packet:")
 		return
 	}
 
@@ -150,7 +228,7 @@ func (t *tlsStream) writePacket(firstLayerType gopacket.LayerType, l ...gopacket
 
 	err = t.poller.sorter.GetMasterPcap().WritePacket(info, data)
 	if err != nil {
-		log.Error().Err(err).Msg("Error writing PCAP:")
+		sorterLog.get().Error().Err(err).Msg("Error writing PCAP:")
 	}
 }
 
@@ -189,7 +267,7 @@ func (t *tlsStream) setLayers(data []byte, reader *tlsReader) {
 	tcp := t.newTCPLayer(reader)
 	err := tcp.SetNetworkLayerForChecksum(ipv4)
 	if err != nil {
-		log.Error().Err(err).Send()
+		sorterLog.get().Error().Err(err).Send()
 	}
 
 	if t.layers == nil {
@@ -198,7 +276,11 @@ func (t *tlsStream) setLayers(data []byte, reader *tlsReader) {
 			ipv4:     ipv4,
 			tcp:      tcp,
 		}
-		t.doTcpHandshake()
+
+		// A stream restored from a checkpoint already had its handshake written
+		if !t.isResumed {
+			t.doTcpHandshake()
+		}
 	} else {
 		t.layers.ipv4.SrcIP = ipv4.SrcIP
 		t.layers.ipv4.DstIP = ipv4.DstIP
@@ -208,6 +290,42 @@ func (t *tlsStream) setLayers(data []byte, reader *tlsReader) {
 	}
 }
 
+// Strips the PROXY protocol header and replaces the client address with the original one, a
+// header that is split across chunks is held until it's complete
+func (t *tlsStream) handleProxyHeader(data []byte) []byte {
+	if t.proxyPending != nil {
+		data = append(t.proxyPending, data...)
+		t.proxyPending = nil
+	}
+
+	// The addresses can only be changed before the first packet is written
+	if t.layers != nil {
+		return data
+	}
+
+	header, length, result := parseProxyHeader(data)
+	switch result {
+	case proxyNoHeader:
+		return data
+	case proxyHeaderIncomplete:
+		t.proxyPending = append([]byte(nil), data...)
+		return nil
+	}
+
+	if header != nil {
+		dissectorsLog.get().Debug().
+			Int64("stream", t.getId()).
+			Str("proxy", t.client.tcpID.SrcIP).
+			Str("client", header.srcIP).
+			Msg("PROXY protocol header, using the original client address:")
+
+		t.client.tcpID.SrcIP, t.client.tcpID.SrcPort = header.srcIP, header.srcPort
+		t.server.tcpID.DstIP, t.server.tcpID.DstPort = header.srcIP, header.srcPort
+	}
+
+	return data[length:]
+}
+
 func (t *tlsStream) newIPv4Layer(reader *tlsReader) *layers.IPv4 {
 	srcIP, _, err := net.ParseCIDR(reader.tcpID.SrcIP + "/24")
 	if err != nil {
@@ -247,3 +365,18 @@ func (t *tlsStream) newTCPLayer(reader *tlsReader) *layers.TCP {
 		Ack:     0,
 	}
 }
+
+func (t *tlsStream) newUDPLayer(reader *tlsReader) *layers.UDP {
+	srcPort, err := strconv.ParseUint(reader.tcpID.SrcPort, 10, 64)
+	if err != nil {
+		panic(err)
+	}
+	dstPort, err := strconv.ParseUint(reader.tcpID.DstPort, 10, 64)
+	if err != nil {
+		panic(err)
+	}
+	return &layers.UDP{
+		SrcPort: layers.UDPPort(srcPort),
+		DstPort: layers.UDPPort(dstPort),
+	}
+}
@@ -0,0 +1,619 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/rlimit"
+	"github.com/go-errors/errors"
+	"github.com/moby/moby/pkg/parsers/kernel"
+	"github.com/rs/zerolog/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+const GlobalWorkerPid = 0
+
+// The object is built once per architecture, its CO-RE relocations are resolved against the
+// BTF of the kernel on load
+
+//go:generate go run github.com/cilium/ebpf/cmd/bpf2go@v0.9.1 -target $BPF_TARGET -cflags $BPF_CFLAGS -type tls_chunk -type goid_offsets -type flow_key -type flow_stats -type settings tracer ../../bpf/tracer.c
+
+// Tracer captures the plaintext of the TLS traffic of the targeted processes with eBPF
+// and writes it to the master PCAP as synthetic TCP packets.
+type Tracer struct {
+	config                 Config
+	streamsMap             *TcpStreamMap
+	events                 <-chan Event
+	subscriptions          []*subscription
+	subsLock               sync.Mutex
+	subsGeneration         uint64
+	lastSubsId             uint64
+	isStopped              bool
+	cancel                 context.CancelFunc
+	done                   chan struct{}
+	stopErrs               []error
+	paused                 atomic.Bool
+	settingsLock           sync.Mutex
+	started                atomic.Bool
+	detached               atomic.Bool
+	bpfObjects             tracerObjects
+	bpfFeatures            bpfFeatures
+	syscallHooks           syscallHooks
+	tcpKprobeHooks         tcpKprobeHooks
+	sslHooksStructs        []sslHooks
+	goHooksStructs         []goHooks
+	tlsLibraryHooksStructs []tlsLibraryHooks
+	poller                 *tlsPoller
+	bpfLogger              *bpfLogger
+	probeStats             *probeStats
+	flowPoller             *flowPoller
+	coexisting             []string
+	handshakes             *handshakeCapture
+	pods                   []v1.Pod
+	targetsLock            sync.Mutex
+	registeredPids         sync.Map
+	disabledGroups         sync.Map
+	pidTargets             sync.Map
+	offsetsCache           *offsetsCache
+	procfs                 string
+}
+
+// New loads the eBPF objects and installs the syscall and kprobe hooks, the processes
+// are targeted with UpdateTargets. Nothing is polled until Start is called.
+func New(config Config) (*Tracer, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	t := &Tracer{
+		config:       config,
+		streamsMap:   NewTcpStreamMap(),
+		procfs:       config.Procfs,
+		offsetsCache: newOffsetsCache(config.OffsetsCachePath),
+	}
+
+	if config.EventBufferSize > 0 {
+		t.events, _ = t.Subscribe(EventFilter{})
+	}
+
+	t.initProbeGroups()
+
+	if err := t.init(config.ChunksBufferSize, config.LogBufferSize, config.Procfs); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (t *Tracer) init(
+	chunksBufferSize int,
+	logBufferSize int,
+	procfs string,
+) error {
+	log.Info().Msg(fmt.Sprintf("Initializing tracer (chunksSize: %d) (logSize: %d)", chunksBufferSize, logBufferSize))
+
+	logBpfToken(procfs)
+
+	var err error
+	t.bpfObjects = tracerObjects{}
+	if t.bpfFeatures, err = loadBpfObjects(&t.bpfObjects, t.config.BtfPath, t.config.RingBufferSize, t.config.PinPath); err != nil {
+		return err
+	}
+
+	if t.config.MigrationPath != "" {
+		if err = migrateMaps(&t.bpfObjects, t.config.MigrationPath); err != nil {
+			return err
+		}
+
+		if err = t.registerMigratedPids(); err != nil {
+			return err
+		}
+	}
+
+	if err = writeSettings(&t.bpfObjects, &t.config, false, t.bpfFeatures.ringbuf); err != nil {
+		return err
+	}
+
+	t.coexisting, err = detectCoexistingAgents()
+	if err != nil {
+		log.Warn().Err(err).Msg("Couldn't detect the other eBPF agents:")
+	} else if len(t.coexisting) > 0 {
+		log.Warn().Strs("agents", t.coexisting).Msg("Other eBPF capture agents are running, streams are logged with dedup keys:")
+	}
+
+	t.syscallHooks = syscallHooks{}
+	if t.isProbeGroupEnabled(ProbeGroupSyscalls) {
+		if err := t.syscallHooks.installSyscallHooks(&t.bpfObjects, t.config.PinPath); err != nil {
+			return err
+		}
+	}
+
+	t.tcpKprobeHooks = tcpKprobeHooks{}
+	if t.isProbeGroupEnabled(ProbeGroupTcpKprobes) {
+		if err := t.tcpKprobeHooks.installTcpKprobeHooks(&t.bpfObjects, t.bpfFeatures.fentry, t.config.PinPath); err != nil {
+			return err
+		}
+	}
+
+	t.sslHooksStructs = make([]sslHooks, 0)
+	t.tlsLibraryHooksStructs = make([]tlsLibraryHooks, 0)
+
+	t.bpfLogger = newBpfLogger()
+	if err := t.bpfLogger.init(&t.bpfObjects, logBufferSize); err != nil {
+		return err
+	}
+
+	t.probeStats = newProbeStats(&t.bpfObjects)
+	t.flowPoller = newFlowPoller(&t.bpfObjects)
+
+	t.poller, err = newTlsPoller(
+		t,
+		procfs,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if err = t.poller.init(&t.bpfObjects, chunksBufferSize, t.bpfFeatures.ringbuf); err != nil {
+		return err
+	}
+
+	// The handshake records are payloads, nothing is written if only the metadata is captured
+	if t.config.CaptureHandshakes && !t.config.MetadataOnly {
+		if t.poller.fingerprints, err = newTlsFingerprints(); err != nil {
+			return err
+		}
+
+		t.handshakes, err = newHandshakeCapture(&t.bpfObjects, t.poller.sorter, t.isPaused, t.poller.payload.allows, t.poller.fingerprints)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Start polls the perf buffers in the background and returns immediately, cancelling ctx
+// stops the tracer the same way as Stop
+func (t *Tracer) Start(ctx context.Context) {
+	ctx, t.cancel = context.WithCancel(ctx)
+	pollCtx, cancelPoll := context.WithCancel(context.Background())
+	t.done = make(chan struct{})
+
+	if t.handshakes != nil {
+		t.handshakes.start()
+	}
+
+	if t.config.MetadataOnly {
+		go t.flowPoller.poll(ctx, t.config.MetadataInterval)
+	}
+
+	if t.config.ProbeStatsInterval > 0 {
+		go t.pollForProbeStats(ctx, t.config.ProbeStatsInterval)
+	}
+
+	go t.bpfLogger.poll()
+
+	t.started.Store(true)
+
+	// The probes are detached before the poller is stopped, so nothing is produced
+	// while the chunks in flight are drained
+	var detachErrs []error
+	detached := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		detachErrs = t.detach()
+		close(detached)
+		cancelPoll()
+	}()
+
+	go func() {
+		t.poller.poll(pollCtx, t.streamsMap)
+		t.closeSubscriptions()
+
+		<-detached
+		t.stopErrs = append(detachErrs, t.release()...)
+		close(t.done)
+	}()
+}
+
+// Stop detaches the probes, handles the chunks that are already in the perf buffer, flushes
+// the streams and closes the tracer
+func (t *Tracer) Stop() []error {
+	if t.done == nil {
+		return t.Close()
+	}
+
+	t.cancel()
+	<-t.done
+
+	return t.stopErrs
+}
+
+// Events returns the decrypted chunks, nil if Config.EventBufferSize is 0. The chunks are
+// dropped while the channel is full. The channel is closed on Stop.
+func (t *Tracer) Events() <-chan Event {
+	return t.events
+}
+
+func (t *Tracer) pollForProbeStats(ctx context.Context, interval time.Duration) {
+	if err := t.probeStats.init(); err != nil {
+		LogError(err)
+		return
+	}
+
+	t.probeStats.poll(ctx, interval)
+}
+
+// ProbeOverheadReport returns the eBPF programs ranked by their estimated CPU usage
+func (t *Tracer) ProbeOverheadReport() []ProbeOverhead {
+	return t.probeStats.getReport()
+}
+
+func (t *Tracer) GlobalSSLLibTarget(procfs string, pid string) error {
+	_pid, err := strconv.Atoi(pid)
+	if err != nil {
+		return err
+	}
+
+	return t.AddSSLLibPid(procfs, uint32(_pid))
+}
+
+func (t *Tracer) GlobalGoTarget(procfs string, pid string) error {
+	_pid, err := strconv.Atoi(pid)
+	if err != nil {
+		return err
+	}
+
+	return t.AddGoPid(procfs, uint32(_pid))
+}
+
+func (t *Tracer) AddSSLLibPid(procfs string, pid uint32) error {
+	if t.config.SyscallsOnly {
+		return t.targetSyscallsPid(pid)
+	}
+
+	sslLibraries, err := findSsllibs(procfs, pid)
+
+	if err != nil {
+		log.Warn().Err(err).Int("pid", int(pid)).Msg("PID skipped no libssl.so found:")
+		return nil // hide the error on purpose, it's OK for a process to not use libssl.so
+	}
+
+	// The hooks are appended to the same slices as by UpdateTargets and SetProbeGroup
+	t.targetsLock.Lock()
+	defer t.targetsLock.Unlock()
+
+	for _, sslLibrary := range sslLibraries {
+		log.Info().Str("path", sslLibrary).Int("pid", int(pid)).Msg("Found libssl.so:")
+
+		if err := t.targetSSLLibPid(pid, sslLibrary); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *Tracer) AddGoPid(procfs string, pid uint32) error {
+	t.targetsLock.Lock()
+	defer t.targetsLock.Unlock()
+
+	return t.targetGoPid(procfs, pid)
+}
+
+func (t *Tracer) RemovePid(pid uint32) error {
+	log.Info().Msg(fmt.Sprintf("Removing PID (pid: %v)", pid))
+
+	pids := t.bpfObjects.tracerMaps.PidsMap
+
+	if err := pids.Delete(pid); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	// The exit of an untargeted process isn't seen in kernel, goid_offsets_map isn't an LRU
+	if err := t.bpfObjects.tracerMaps.GoidOffsetsMap.Delete(pid); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+		return errors.Wrap(err, 0)
+	}
+
+	t.registeredPids.Delete(pid)
+
+	return nil
+}
+
+type pidTarget struct {
+	namespace string
+	workload  string
+	meshLeg   string
+}
+
+func (t *Tracer) setPidTarget(pid uint32, namespace string, workload string, meshLeg string) {
+	t.pidTargets.Store(pid, pidTarget{namespace: namespace, workload: workload, meshLeg: meshLeg})
+}
+
+func (t *Tracer) getPidTarget(pid uint32) pidTarget {
+	if target, ok := t.pidTargets.Load(pid); ok {
+		return target.(pidTarget)
+	}
+
+	return pidTarget{}
+}
+
+func (t *Tracer) ClearPids() {
+	t.pidTargets.Range(func(key, v interface{}) bool {
+		t.pidTargets.Delete(key)
+		return true
+	})
+
+	t.registeredPids.Range(func(key, v interface{}) bool {
+		pid := key.(uint32)
+		if pid == GlobalWorkerPid {
+			return true
+		}
+
+		if err := t.RemovePid(pid); err != nil {
+			LogError(err)
+		}
+		t.registeredPids.Delete(key)
+		return true
+	})
+}
+
+func (t *Tracer) Close() []error {
+	return append(t.detach(), t.release()...)
+}
+
+// detach closes the probes and the sockets that produce the chunks, under targetsLock so a
+// probe group isn't attached meanwhile
+func (t *Tracer) detach() []error {
+	t.targetsLock.Lock()
+	defer t.targetsLock.Unlock()
+
+	t.detached.Store(true)
+
+	returnValue := make([]error, 0)
+
+	returnValue = append(returnValue, t.syscallHooks.close()...)
+
+	returnValue = append(returnValue, t.tcpKprobeHooks.close()...)
+
+	for _, sslHooks := range t.sslHooksStructs {
+		returnValue = append(returnValue, sslHooks.close()...)
+	}
+
+	for _, goHooks := range t.goHooksStructs {
+		returnValue = append(returnValue, goHooks.close()...)
+	}
+
+	for _, hooks := range t.tlsLibraryHooksStructs {
+		returnValue = append(returnValue, hooks.close()...)
+	}
+
+	if t.handshakes != nil {
+		if err := t.handshakes.close(); err != nil {
+			returnValue = append(returnValue, err)
+		}
+	}
+
+	return returnValue
+}
+
+// release closes the readers and the eBPF objects
+func (t *Tracer) release() []error {
+	returnValue := make([]error, 0)
+
+	if err := t.bpfObjects.Close(); err != nil {
+		returnValue = append(returnValue, err)
+	}
+
+	if err := t.bpfLogger.close(); err != nil {
+		returnValue = append(returnValue, err)
+	}
+
+	if err := t.poller.close(); err != nil {
+		returnValue = append(returnValue, err)
+	}
+
+	if err := t.probeStats.close(); err != nil {
+		returnValue = append(returnValue, err)
+	}
+
+	return returnValue
+}
+
+// bpfFeatures are the optional kernel features that the loaded objects use
+type bpfFeatures struct {
+	// The chunks are sent through the ring buffer instead of the perf buffer
+	ringbuf bool
+	// The tcp hooks are attached with fentry instead of kprobes
+	fentry bool
+}
+
+// loadBpfObjects loads the maps pinned under pinPath if it isn't empty, see pinMaps
+func loadBpfObjects(bpfObjects *tracerObjects, btfPath string, ringBufferSize int, pinPath string) (bpfFeatures, error) {
+	features := bpfFeatures{}
+
+	err := setupRLimit()
+	if err != nil {
+		return features, err
+	}
+
+	var kernelVersion *kernel.VersionInfo
+	kernelVersion, err = kernel.GetKernelVersion()
+	if err != nil {
+		return features, err
+	}
+
+	log.Info().Msg(fmt.Sprintf("Detected Linux kernel version: %s", kernelVersion))
+
+	kernelTypes, err := loadKernelBtf(btfPath)
+	if err != nil {
+		return features, err
+	}
+
+	// An empty placeholder is embedded if the objects of the architecture weren't built
+	if len(_TracerBytes) == 0 {
+		return features, errors.Errorf("The eBPF objects of %s are empty, build them with make bpf", runtime.GOARCH)
+	}
+
+	spec, err := loadTracer()
+	if err != nil {
+		return features, errors.Wrap(err, 0)
+	}
+
+	if features.ringbuf, err = prepareChunksRingbuf(spec, ringBufferSize); err != nil {
+		return features, err
+	}
+
+	features.fentry = prepareTcpFentry(spec, kernelTypes)
+
+	opts := &ebpf.CollectionOptions{
+		Programs: ebpf.ProgramOptions{
+			KernelTypes: kernelTypes,
+		},
+	}
+
+	if pinPath != "" {
+		if err := pinMaps(spec, pinPath); err != nil {
+			return features, err
+		}
+		opts.Maps.PinPath = getPinnedMapsPath(pinPath)
+	}
+
+	err = spec.LoadAndAssign(bpfObjects, opts)
+	if pinPath != "" && errors.Is(err, ebpf.ErrMapIncompatible) {
+		log.Warn().Err(err).Str("path", pinPath).Msg("The pinned maps are incompatible, replacing them:")
+
+		if err := resetPins(pinPath); err != nil {
+			return features, err
+		}
+
+		err = spec.LoadAndAssign(bpfObjects, opts)
+	}
+
+	if err != nil {
+		return features, errors.Wrap(err, 0)
+	}
+
+	return features, nil
+}
+
+func setupRLimit() error {
+	err := rlimit.RemoveMemlock()
+
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	return nil
+}
+
+func (t *Tracer) targetSSLLibPid(pid uint32, sslLibrary string) error {
+	if err := t.checkProbeGroup(ProbeGroupOpenssl); err != nil {
+		return err
+	}
+
+	library, err := resolveSslLibrary(sslLibrary)
+	if err != nil {
+		return err
+	}
+
+	newSsl := sslHooks{}
+
+	if err := newSsl.installUprobes(&t.bpfObjects, library, t.config.SymbolOffsets); err != nil {
+		return err
+	}
+
+	if err := t.targetOpensslBio(pid, sslLibrary, &newSsl); err != nil {
+		LogError(err)
+	}
+
+	log.Info().Msg(fmt.Sprintf("Targeting TLS (pid: %v) (libssl: %v) (OpenSSL: %v)", pid, sslLibrary, library.version))
+
+	t.sslHooksStructs = append(t.sslHooksStructs, newSsl)
+
+	pids := t.bpfObjects.tracerMaps.PidsMap
+
+	if err := pids.Put(pid, uint32(1)); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	t.registeredPids.Store(pid, true)
+
+	return nil
+}
+
+func (t *Tracer) targetGoPid(procfs string, pid uint32) error {
+	if t.config.SyscallsOnly {
+		return t.targetSyscallsPid(pid)
+	}
+
+	exePath, err := findLibraryByPid(procfs, pid, "")
+	if err != nil {
+		return err
+	}
+
+	offsets, findErr := t.offsetsCache.findGoOffsets(exePath)
+
+	_, err = t.attachGoPid(pid, exePath, offsets, findErr)
+	return err
+}
+
+// attachGoPid attaches the Go crypto/tls probes from the discovered offsets, or from the
+// overridden ones if the discovery failed
+func (t *Tracer) attachGoPid(pid uint32, exePath string, offsets goOffsets, findErr error) (bool, error) {
+	if err := t.checkProbeGroup(ProbeGroupGo); err != nil {
+		return false, err
+	}
+
+	hooks := goHooks{}
+
+	if err := hooks.installUprobes(&t.bpfObjects, exePath, offsets, findErr, t.config.SymbolOffsets); err != nil {
+		log.Info().Msg(fmt.Sprintf("PID skipped not a Go binary or symbol table is stripped (pid: %v) %v", pid, exePath))
+		return false, nil // hide the error on purpose, its OK for a process to be not a Go binary or stripped Go binary
+	}
+
+	log.Info().Msg(fmt.Sprintf("Targeting TLS (pid: %v) (Go: %v)", pid, exePath))
+
+	t.goHooksStructs = append(t.goHooksStructs, hooks)
+
+	pids := t.bpfObjects.tracerMaps.PidsMap
+
+	if err := pids.Put(pid, uint32(1)); err != nil {
+		return false, errors.Wrap(err, 0)
+	}
+
+	t.registeredPids.Store(pid, true)
+
+	return true, nil
+}
+
+// targetSyscallsPid captures the payloads of the reads and writes of the process in the
+// syscalls-only mode, nothing is hooked in the process
+func (t *Tracer) targetSyscallsPid(pid uint32) error {
+	if err := t.bpfObjects.tracerMaps.PidsMap.Put(pid, uint32(1)); err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	log.Info().Int("pid", int(pid)).Msg("Targeting the syscalls:")
+
+	t.registeredPids.Store(pid, true)
+
+	return nil
+}
+
+func LogError(err error) {
+	var e *errors.Error
+	if errors.As(err, &e) {
+		log.Error().Str("stack", e.ErrorStack()).Send()
+	} else {
+		log.Error().Err(err).Send()
+	}
+}
@@ -2,7 +2,7 @@
 //go:build 386 || amd64
 // +build 386 amd64
 
-package main
+package tracer
 
 import (
 	"bytes"
@@ -13,11 +13,34 @@ import (
 	"github.com/cilium/ebpf"
 )
 
+type tracerFlowKey struct {
+	Pid         uint32
+	Flags       uint32
+	AddressInfo struct {
+		Saddr uint32
+		Daddr uint32
+		Sport uint16
+		Dport uint16
+	}
+}
+
+type tracerFlowStats struct {
+	Bytes    uint64
+	Messages uint64
+}
+
 type tracerGoidOffsets struct {
 	G_addrOffset uint64
 	GoidOffset   uint64
 }
 
+type tracerSettings struct {
+	MetadataMode uint32
+	Paused       uint32
+	Ringbuf      uint32
+	SyscallsOnly uint32
+}
+
 type tracerTlsChunk struct {
 	Pid         uint32
 	Tgid        uint32
@@ -26,6 +49,7 @@ type tracerTlsChunk struct {
 	Recorded    uint32
 	Fd          uint32
 	Flags       uint32
+	Origin      uint32
 	AddressInfo struct {
 		Saddr uint32
 		Daddr uint32
@@ -84,6 +108,20 @@ type tracerProgramSpecs struct {
 	GoCryptoTlsAbiInternalReadEx  *ebpf.ProgramSpec `ebpf:"go_crypto_tls_abi_internal_read_ex"`
 	GoCryptoTlsAbiInternalWrite   *ebpf.ProgramSpec `ebpf:"go_crypto_tls_abi_internal_write"`
 	GoCryptoTlsAbiInternalWriteEx *ebpf.ProgramSpec `ebpf:"go_crypto_tls_abi_internal_write_ex"`
+	MbedtlsRead                   *ebpf.ProgramSpec `ebpf:"mbedtls_read"`
+	MbedtlsRetRead                *ebpf.ProgramSpec `ebpf:"mbedtls_ret_read"`
+	MbedtlsRetWrite               *ebpf.ProgramSpec `ebpf:"mbedtls_ret_write"`
+	MbedtlsWrite                  *ebpf.ProgramSpec `ebpf:"mbedtls_write"`
+	OpensslBioRead                *ebpf.ProgramSpec `ebpf:"openssl_bio_read"`
+	OpensslBioRetRead             *ebpf.ProgramSpec `ebpf:"openssl_bio_ret_read"`
+	OpensslBioRetWrite            *ebpf.ProgramSpec `ebpf:"openssl_bio_ret_write"`
+	OpensslBioWrite               *ebpf.ProgramSpec `ebpf:"openssl_bio_write"`
+	OpensslLogSecret              *ebpf.ProgramSpec `ebpf:"openssl_log_secret"`
+	S2nRecv                       *ebpf.ProgramSpec `ebpf:"s2n_recv"`
+	S2nRetRecv                    *ebpf.ProgramSpec `ebpf:"s2n_ret_recv"`
+	S2nRetSend                    *ebpf.ProgramSpec `ebpf:"s2n_ret_send"`
+	S2nSend                       *ebpf.ProgramSpec `ebpf:"s2n_send"`
+	SchedProcessExit              *ebpf.ProgramSpec `ebpf:"sched_process_exit"`
 	SslRead                       *ebpf.ProgramSpec `ebpf:"ssl_read"`
 	SslReadEx                     *ebpf.ProgramSpec `ebpf:"ssl_read_ex"`
 	SslRetRead                    *ebpf.ProgramSpec `ebpf:"ssl_ret_read"`
@@ -95,13 +133,24 @@ type tracerProgramSpecs struct {
 	SysEnterAccept4               *ebpf.ProgramSpec `ebpf:"sys_enter_accept4"`
 	SysEnterConnect               *ebpf.ProgramSpec `ebpf:"sys_enter_connect"`
 	SysEnterRead                  *ebpf.ProgramSpec `ebpf:"sys_enter_read"`
+	SysEnterRecvfrom              *ebpf.ProgramSpec `ebpf:"sys_enter_recvfrom"`
+	SysEnterSendto                *ebpf.ProgramSpec `ebpf:"sys_enter_sendto"`
 	SysEnterWrite                 *ebpf.ProgramSpec `ebpf:"sys_enter_write"`
 	SysExitAccept4                *ebpf.ProgramSpec `ebpf:"sys_exit_accept4"`
 	SysExitConnect                *ebpf.ProgramSpec `ebpf:"sys_exit_connect"`
 	SysExitRead                   *ebpf.ProgramSpec `ebpf:"sys_exit_read"`
 	SysExitWrite                  *ebpf.ProgramSpec `ebpf:"sys_exit_write"`
 	TcpRecvmsg                    *ebpf.ProgramSpec `ebpf:"tcp_recvmsg"`
+	TcpRecvmsgFentry              *ebpf.ProgramSpec `ebpf:"tcp_recvmsg_fentry"`
 	TcpSendmsg                    *ebpf.ProgramSpec `ebpf:"tcp_sendmsg"`
+	TcpSendmsgFentry              *ebpf.ProgramSpec `ebpf:"tcp_sendmsg_fentry"`
+	TlsHandshakeFilter            *ebpf.ProgramSpec `ebpf:"tls_handshake_filter"`
+	UdpRecvmsg                    *ebpf.ProgramSpec `ebpf:"udp_recvmsg"`
+	UdpSendmsg                    *ebpf.ProgramSpec `ebpf:"udp_sendmsg"`
+	WolfsslRead                   *ebpf.ProgramSpec `ebpf:"wolfssl_read"`
+	WolfsslRetRead                *ebpf.ProgramSpec `ebpf:"wolfssl_ret_read"`
+	WolfsslRetWrite               *ebpf.ProgramSpec `ebpf:"wolfssl_ret_write"`
+	WolfsslWrite                  *ebpf.ProgramSpec `ebpf:"wolfssl_write"`
 }
 
 // tracerMapSpecs contains maps before they are loaded into the kernel.
@@ -109,9 +158,13 @@ type tracerProgramSpecs struct {
 // It can be passed ebpf.CollectionSpec.Assign.
 type tracerMapSpecs struct {
 	AcceptSyscallContext     *ebpf.MapSpec `ebpf:"accept_syscall_context"`
+	BioCapturePids           *ebpf.MapSpec `ebpf:"bio_capture_pids"`
 	ChunksBuffer             *ebpf.MapSpec `ebpf:"chunks_buffer"`
+	ChunksRingbuf            *ebpf.MapSpec `ebpf:"chunks_ringbuf"`
+	ChunksRingbufDrops       *ebpf.MapSpec `ebpf:"chunks_ringbuf_drops"`
 	ConnectSyscallInfo       *ebpf.MapSpec `ebpf:"connect_syscall_info"`
 	ConnectionContext        *ebpf.MapSpec `ebpf:"connection_context"`
+	FlowStatsMap             *ebpf.MapSpec `ebpf:"flow_stats_map"`
 	GoKernelReadContext      *ebpf.MapSpec `ebpf:"go_kernel_read_context"`
 	GoKernelWriteContext     *ebpf.MapSpec `ebpf:"go_kernel_write_context"`
 	GoReadContext            *ebpf.MapSpec `ebpf:"go_read_context"`
@@ -121,9 +174,19 @@ type tracerMapSpecs struct {
 	GoidOffsetsMap           *ebpf.MapSpec `ebpf:"goid_offsets_map"`
 	Heap                     *ebpf.MapSpec `ebpf:"heap"`
 	LogBuffer                *ebpf.MapSpec `ebpf:"log_buffer"`
+	MbedtlsBioOffsets        *ebpf.MapSpec `ebpf:"mbedtls_bio_offsets"`
+	OpensslBioCalls          *ebpf.MapSpec `ebpf:"openssl_bio_calls"`
+	OpensslBioMethodOffsets  *ebpf.MapSpec `ebpf:"openssl_bio_method_offsets"`
+	OpensslCallStarted       *ebpf.MapSpec `ebpf:"openssl_call_started"`
+	OpensslPendingWrite      *ebpf.MapSpec `ebpf:"openssl_pending_write"`
 	OpensslReadContext       *ebpf.MapSpec `ebpf:"openssl_read_context"`
 	OpensslWriteContext      *ebpf.MapSpec `ebpf:"openssl_write_context"`
 	PidsMap                  *ebpf.MapSpec `ebpf:"pids_map"`
+	SettingsMap              *ebpf.MapSpec `ebpf:"settings_map"`
+	SyscallPayloadContext    *ebpf.MapSpec `ebpf:"syscall_payload_context"`
+	ThreadReadSocket         *ebpf.MapSpec `ebpf:"thread_read_socket"`
+	ThreadWriteSocket        *ebpf.MapSpec `ebpf:"thread_write_socket"`
+	UdpPeers                 *ebpf.MapSpec `ebpf:"udp_peers"`
 }
 
 // tracerObjects contains all objects after they have been loaded into the kernel.
@@ -146,9 +209,13 @@ func (o *tracerObjects) Close() error {
 // It can be passed to loadTracerObjects or ebpf.CollectionSpec.LoadAndAssign.
 type tracerMaps struct {
 	AcceptSyscallContext     *ebpf.Map `ebpf:"accept_syscall_context"`
+	BioCapturePids           *ebpf.Map `ebpf:"bio_capture_pids"`
 	ChunksBuffer             *ebpf.Map `ebpf:"chunks_buffer"`
+	ChunksRingbuf            *ebpf.Map `ebpf:"chunks_ringbuf"`
+	ChunksRingbufDrops       *ebpf.Map `ebpf:"chunks_ringbuf_drops"`
 	ConnectSyscallInfo       *ebpf.Map `ebpf:"connect_syscall_info"`
 	ConnectionContext        *ebpf.Map `ebpf:"connection_context"`
+	FlowStatsMap             *ebpf.Map `ebpf:"flow_stats_map"`
 	GoKernelReadContext      *ebpf.Map `ebpf:"go_kernel_read_context"`
 	GoKernelWriteContext     *ebpf.Map `ebpf:"go_kernel_write_context"`
 	GoReadContext            *ebpf.Map `ebpf:"go_read_context"`
@@ -158,17 +225,31 @@ type tracerMaps struct {
 	GoidOffsetsMap           *ebpf.Map `ebpf:"goid_offsets_map"`
 	Heap                     *ebpf.Map `ebpf:"heap"`
 	LogBuffer                *ebpf.Map `ebpf:"log_buffer"`
+	MbedtlsBioOffsets        *ebpf.Map `ebpf:"mbedtls_bio_offsets"`
+	OpensslBioCalls          *ebpf.Map `ebpf:"openssl_bio_calls"`
+	OpensslBioMethodOffsets  *ebpf.Map `ebpf:"openssl_bio_method_offsets"`
+	OpensslCallStarted       *ebpf.Map `ebpf:"openssl_call_started"`
+	OpensslPendingWrite      *ebpf.Map `ebpf:"openssl_pending_write"`
 	OpensslReadContext       *ebpf.Map `ebpf:"openssl_read_context"`
 	OpensslWriteContext      *ebpf.Map `ebpf:"openssl_write_context"`
 	PidsMap                  *ebpf.Map `ebpf:"pids_map"`
+	SettingsMap              *ebpf.Map `ebpf:"settings_map"`
+	SyscallPayloadContext    *ebpf.Map `ebpf:"syscall_payload_context"`
+	ThreadReadSocket         *ebpf.Map `ebpf:"thread_read_socket"`
+	ThreadWriteSocket        *ebpf.Map `ebpf:"thread_write_socket"`
+	UdpPeers                 *ebpf.Map `ebpf:"udp_peers"`
 }
 
 func (m *tracerMaps) Close() error {
 	return _TracerClose(
 		m.AcceptSyscallContext,
+		m.BioCapturePids,
 		m.ChunksBuffer,
+		m.ChunksRingbuf,
+		m.ChunksRingbufDrops,
 		m.ConnectSyscallInfo,
 		m.ConnectionContext,
+		m.FlowStatsMap,
 		m.GoKernelReadContext,
 		m.GoKernelWriteContext,
 		m.GoReadContext,
@@ -178,9 +259,19 @@ func (m *tracerMaps) Close() error {
 		m.GoidOffsetsMap,
 		m.Heap,
 		m.LogBuffer,
+		m.MbedtlsBioOffsets,
+		m.OpensslBioCalls,
+		m.OpensslBioMethodOffsets,
+		m.OpensslCallStarted,
+		m.OpensslPendingWrite,
 		m.OpensslReadContext,
 		m.OpensslWriteContext,
 		m.PidsMap,
+		m.SettingsMap,
+		m.SyscallPayloadContext,
+		m.ThreadReadSocket,
+		m.ThreadWriteSocket,
+		m.UdpPeers,
 	)
 }
 
@@ -196,6 +287,20 @@ type tracerPrograms struct {
 	GoCryptoTlsAbiInternalReadEx  *ebpf.Program `ebpf:"go_crypto_tls_abi_internal_read_ex"`
 	GoCryptoTlsAbiInternalWrite   *ebpf.Program `ebpf:"go_crypto_tls_abi_internal_write"`
 	GoCryptoTlsAbiInternalWriteEx *ebpf.Program `ebpf:"go_crypto_tls_abi_internal_write_ex"`
+	MbedtlsRead                   *ebpf.Program `ebpf:"mbedtls_read"`
+	MbedtlsRetRead                *ebpf.Program `ebpf:"mbedtls_ret_read"`
+	MbedtlsRetWrite               *ebpf.Program `ebpf:"mbedtls_ret_write"`
+	MbedtlsWrite                  *ebpf.Program `ebpf:"mbedtls_write"`
+	OpensslBioRead                *ebpf.Program `ebpf:"openssl_bio_read"`
+	OpensslBioRetRead             *ebpf.Program `ebpf:"openssl_bio_ret_read"`
+	OpensslBioRetWrite            *ebpf.Program `ebpf:"openssl_bio_ret_write"`
+	OpensslBioWrite               *ebpf.Program `ebpf:"openssl_bio_write"`
+	OpensslLogSecret              *ebpf.Program `ebpf:"openssl_log_secret"`
+	S2nRecv                       *ebpf.Program `ebpf:"s2n_recv"`
+	S2nRetRecv                    *ebpf.Program `ebpf:"s2n_ret_recv"`
+	S2nRetSend                    *ebpf.Program `ebpf:"s2n_ret_send"`
+	S2nSend                       *ebpf.Program `ebpf:"s2n_send"`
+	SchedProcessExit              *ebpf.Program `ebpf:"sched_process_exit"`
 	SslRead                       *ebpf.Program `ebpf:"ssl_read"`
 	SslReadEx                     *ebpf.Program `ebpf:"ssl_read_ex"`
 	SslRetRead                    *ebpf.Program `ebpf:"ssl_ret_read"`
@@ -207,13 +312,24 @@ type tracerPrograms struct {
 	SysEnterAccept4               *ebpf.Program `ebpf:"sys_enter_accept4"`
 	SysEnterConnect               *ebpf.Program `ebpf:"sys_enter_connect"`
 	SysEnterRead                  *ebpf.Program `ebpf:"sys_enter_read"`
+	SysEnterRecvfrom              *ebpf.Program `ebpf:"sys_enter_recvfrom"`
+	SysEnterSendto                *ebpf.Program `ebpf:"sys_enter_sendto"`
 	SysEnterWrite                 *ebpf.Program `ebpf:"sys_enter_write"`
 	SysExitAccept4                *ebpf.Program `ebpf:"sys_exit_accept4"`
 	SysExitConnect                *ebpf.Program `ebpf:"sys_exit_connect"`
 	SysExitRead                   *ebpf.Program `ebpf:"sys_exit_read"`
 	SysExitWrite                  *ebpf.Program `ebpf:"sys_exit_write"`
 	TcpRecvmsg                    *ebpf.Program `ebpf:"tcp_recvmsg"`
+	TcpRecvmsgFentry              *ebpf.Program `ebpf:"tcp_recvmsg_fentry"`
 	TcpSendmsg                    *ebpf.Program `ebpf:"tcp_sendmsg"`
+	TcpSendmsgFentry              *ebpf.Program `ebpf:"tcp_sendmsg_fentry"`
+	TlsHandshakeFilter            *ebpf.Program `ebpf:"tls_handshake_filter"`
+	UdpRecvmsg                    *ebpf.Program `ebpf:"udp_recvmsg"`
+	UdpSendmsg                    *ebpf.Program `ebpf:"udp_sendmsg"`
+	WolfsslRead                   *ebpf.Program `ebpf:"wolfssl_read"`
+	WolfsslRetRead                *ebpf.Program `ebpf:"wolfssl_ret_read"`
+	WolfsslRetWrite               *ebpf.Program `ebpf:"wolfssl_ret_write"`
+	WolfsslWrite                  *ebpf.Program `ebpf:"wolfssl_write"`
 }
 
 func (p *tracerPrograms) Close() error {
@@ -226,6 +342,20 @@ func (p *tracerPrograms) Close() error {
 		p.GoCryptoTlsAbiInternalReadEx,
 		p.GoCryptoTlsAbiInternalWrite,
 		p.GoCryptoTlsAbiInternalWriteEx,
+		p.MbedtlsRead,
+		p.MbedtlsRetRead,
+		p.MbedtlsRetWrite,
+		p.MbedtlsWrite,
+		p.OpensslBioRead,
+		p.OpensslBioRetRead,
+		p.OpensslBioRetWrite,
+		p.OpensslBioWrite,
+		p.OpensslLogSecret,
+		p.S2nRecv,
+		p.S2nRetRecv,
+		p.S2nRetSend,
+		p.S2nSend,
+		p.SchedProcessExit,
 		p.SslRead,
 		p.SslReadEx,
 		p.SslRetRead,
@@ -237,13 +367,24 @@ func (p *tracerPrograms) Close() error {
 		p.SysEnterAccept4,
 		p.SysEnterConnect,
 		p.SysEnterRead,
+		p.SysEnterRecvfrom,
+		p.SysEnterSendto,
 		p.SysEnterWrite,
 		p.SysExitAccept4,
 		p.SysExitConnect,
 		p.SysExitRead,
 		p.SysExitWrite,
 		p.TcpRecvmsg,
+		p.TcpRecvmsgFentry,
 		p.TcpSendmsg,
+		p.TcpSendmsgFentry,
+		p.TlsHandshakeFilter,
+		p.UdpRecvmsg,
+		p.UdpSendmsg,
+		p.WolfsslRead,
+		p.WolfsslRetRead,
+		p.WolfsslRetWrite,
+		p.WolfsslWrite,
 	)
 }
 
@@ -1,4 +1,4 @@
-package main
+package tracer
 
 import (
 	"debug/elf"
@@ -0,0 +1,20 @@
+package tracer
+
+import "regexp"
+
+// wolfSSL is found in the embedded gateways and in some proxies, the fd of WOLFSSL isn't read
+// as its layout depends on the build options, so nothing is prepared
+var wolfsslLibrary = &tlsLibrary{
+	group:       ProbeGroupWolfssl,
+	fileRegex:   regexp.MustCompile(`/libwolfssl\.so[^/]*$`),
+	writeSymbol: "wolfSSL_write",
+	readSymbol:  "wolfSSL_read",
+	programs: func(bpfObjects *tracerObjects) tlsLibraryPrograms {
+		return tlsLibraryPrograms{
+			write:    bpfObjects.WolfsslWrite,
+			writeRet: bpfObjects.WolfsslRetWrite,
+			read:     bpfObjects.WolfsslRead,
+			readRet:  bpfObjects.WolfsslRetRead,
+		}
+	},
+}
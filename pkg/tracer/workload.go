@@ -0,0 +1,30 @@
+package tracer
+
+import (
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// getWorkloadName returns the name of the controller of the pod, which is stable across the
+// restarts and the rollouts, e.g. the Deployment instead of the ReplicaSet or the pod
+func getWorkloadName(pod *v1.Pod) string {
+	if pod.Name == "" {
+		return ""
+	}
+
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller == nil || !*owner.Controller {
+			continue
+		}
+
+		// <deployment>-<pod-template-hash>
+		if hash := pod.Labels["pod-template-hash"]; owner.Kind == "ReplicaSet" && hash != "" {
+			return strings.TrimSuffix(owner.Name, "-"+hash)
+		}
+
+		return owner.Name
+	}
+
+	return pod.Name
+}
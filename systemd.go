@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-errors/errors"
+	"github.com/kubeshark/tracer/pkg/tracer"
+	"github.com/rs/zerolog/log"
+)
+
+// The watchdog is only pinged while this check of the tracer passes
+const watchdogCheck = "perf-reader"
+
+// sdNotify sends a state like READY=1 to systemd when the tracer is a Type=notify service,
+// it does nothing otherwise
+func sdNotify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+
+	// An abstract socket
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		tracer.LogError(errors.Wrap(err, 0))
+		return
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		tracer.LogError(errors.Wrap(err, 0))
+	}
+}
+
+// getWatchdogInterval returns the interval of WatchdogSec= of the service, 0 if the
+// watchdog isn't enabled for this process
+func getWatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	return time.Duration(usec) * time.Microsecond
+}
+
+// startWatchdog pings the systemd watchdog at half of its interval while the chunks perf
+// buffer is read, so systemd restarts the tracer if the reader is stuck
+func startWatchdog(ctx context.Context, t *tracer.Tracer) {
+	interval := getWatchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	log.Info().Dur("interval", interval).Msg("Starting systemd watchdog:")
+
+	go func() {
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := getFailedCheck(t.Live(), watchdogCheck); err != nil {
+				log.Warn().Err(err).Msg("Not pinging the systemd watchdog:")
+				continue
+			}
+
+			sdNotify("WATCHDOG=1")
+		}
+	}()
+}
+
+func getFailedCheck(checks []tracer.HealthCheck, name string) error {
+	for _, check := range checks {
+		if check.Name == name && !check.Ok {
+			return errors.New(check.Error)
+		}
+	}
+
+	return nil
+}